@@ -45,6 +45,8 @@ func main() {
 			hyaline.Extract(logLevel),
 			hyaline.Merge(logLevel),
 			hyaline.Check(logLevel),
+			hyaline.Apply(logLevel),
+			hyaline.Explain(logLevel),
 			hyaline.Audit(logLevel),
 			hyaline.Serve(logLevel, Version),
 			hyaline.Export(logLevel),
@@ -0,0 +1,61 @@
+package hyaline
+
+import (
+	"hyaline/internal/action"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+func Apply(logLevel *slog.LevelVar) *cli.Command {
+	return &cli.Command{
+		Name:  "apply",
+		Usage: "Apply changes to documentation",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "suggestions",
+				Usage: "Apply the suggested updates in a set of recommendations to the documentation on disk",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Required: true,
+						Usage:    "Path to the config file",
+					},
+					&cli.StringFlag{
+						Name:     "documentation",
+						Required: true,
+						Usage:    "Path to the documentation data set the recommendations were made against",
+					},
+					&cli.StringFlag{
+						Name:     "recommendations",
+						Required: true,
+						Usage:    "Path to the recommendations (json output of check diff, check pr, or check mr run with --suggest)",
+					},
+					&cli.StringFlag{
+						Name:     "path",
+						Required: false,
+						Usage:    "Path to the root of the repository containing the documentation",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					// Set log level
+					if cCtx.Bool("debug") {
+						logLevel.Set(slog.LevelDebug)
+					}
+
+					// Execute action
+					err := action.ApplySuggestions(&action.ApplySuggestionsArgs{
+						Config:          cCtx.String("config"),
+						Documentation:   cCtx.String("documentation"),
+						Recommendations: cCtx.String("recommendations"),
+						Path:            cCtx.String("path"),
+					})
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
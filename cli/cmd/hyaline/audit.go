@@ -34,7 +34,18 @@ func Audit(logLevel *slog.LevelVar) *cli.Command {
 					&cli.StringFlag{
 						Name:     "output",
 						Required: true,
-						Usage:    "Path to write the audit results JSON file",
+						Usage:    "Path to write the audit results to",
+					},
+					&cli.StringFlag{
+						Name:     "format",
+						Required: false,
+						Value:    "json",
+						Usage:    "Format to write the audit results in (one of json, sarif, junit)",
+					},
+					&cli.StringFlag{
+						Name:     "llm-cache",
+						Required: false,
+						Usage:    "Path to a directory to cache LLM responses in, so reruns of the same audit reuse previous responses (overrides llm.cache)",
 					},
 				},
 				Action: func(cCtx *cli.Context) error {
@@ -49,6 +60,8 @@ func Audit(logLevel *slog.LevelVar) *cli.Command {
 						Documentation: cCtx.String("documentation"),
 						Sources:       cCtx.StringSlice("source"),
 						Output:        cCtx.String("output"),
+						Format:        cCtx.String("format"),
+						LLMCache:      cCtx.String("llm-cache"),
 					})
 					if err != nil {
 						return cli.Exit(err.Error(), 1)
@@ -1,6 +1,7 @@
 package hyaline
 
 import (
+	"errors"
 	"hyaline/internal/action"
 	"log/slog"
 
@@ -51,6 +52,16 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 						Required: false,
 						Usage:    "Head reference (explicit commit hash or fully qualified reference). Either --head-ref or --head must be provided, but not both.",
 					},
+					&cli.BoolFlag{
+						Name:     "worktree",
+						Required: false,
+						Usage:    "Check uncommitted changes in the working tree (staged and unstaged) against HEAD instead of a base and head. Cannot be used with --staged, --base, --base-ref, --head, or --head-ref.",
+					},
+					&cli.BoolFlag{
+						Name:     "staged",
+						Required: false,
+						Usage:    "Check changes staged in the index against HEAD instead of a base and head. Cannot be used with --worktree, --base, --base-ref, --head, or --head-ref.",
+					},
 					&cli.StringFlag{
 						Name:     "pull-request",
 						Required: false,
@@ -63,8 +74,39 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 					},
 					&cli.StringFlag{
 						Name:     "output",
-						Required: true,
-						Usage:    "Path to write the results to",
+						Required: false,
+						Usage:    "Path to write the results to. Required unless --dry-run is set.",
+					},
+					&cli.StringFlag{
+						Name:     "format",
+						Required: false,
+						Value:    "json",
+						Usage:    "Format to write the results in (one of json, sarif, junit)",
+					},
+					&cli.StringFlag{
+						Name:     "llm-cache",
+						Required: false,
+						Usage:    "Path to a directory to cache LLM responses in, so reruns of the same check reuse previous responses (overrides llm.cache)",
+					},
+					&cli.BoolFlag{
+						Name:     "suggest",
+						Required: false,
+						Usage:    "Ask the LLM to suggest an update (as a unified diff) for each recommendation",
+					},
+					&cli.BoolFlag{
+						Name:     "exit-code",
+						Required: false,
+						Usage:    "Exit with a code of 2 if there are recommendations that have not been checked (such as in a pre-commit hook)",
+					},
+					&cli.IntFlag{
+						Name:     "commits",
+						Required: false,
+						Usage:    "Include the messages of up to this many commits between base and head (newest first) in the check. Cannot be used with --worktree or --staged.",
+					},
+					&cli.BoolFlag{
+						Name:     "dry-run",
+						Required: false,
+						Usage:    "Print the prompts that would be sent to the LLM (after redaction) to stdout without calling the LLM or writing any results",
 					},
 				},
 				Action: func(cCtx *cli.Context) error {
@@ -84,21 +126,43 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 					baseRef := cCtx.String("base-ref")
 					head := cCtx.String("head")
 					headRef := cCtx.String("head-ref")
+					worktree := cCtx.Bool("worktree")
+					staged := cCtx.Bool("staged")
 
-					// Validate base arguments
-					if base != "" && baseRef != "" {
-						return showHelpAndExit("--base and --base-ref are mutually exclusive")
+					if cCtx.Int("commits") < 0 {
+						return showHelpAndExit("--commits must not be negative")
 					}
-					if base == "" && baseRef == "" {
-						return showHelpAndExit("either --base or --base-ref is required")
+					if cCtx.String("output") == "" && !cCtx.Bool("dry-run") {
+						return showHelpAndExit("--output is required unless --dry-run is set")
 					}
 
-					// Validate head arguments
-					if head != "" && headRef != "" {
-						return showHelpAndExit("--head and --head-ref are mutually exclusive")
-					}
-					if head == "" && headRef == "" {
-						return showHelpAndExit("either --head or --head-ref is required")
+					if worktree || staged {
+						// Validate uncommitted change arguments
+						if worktree && staged {
+							return showHelpAndExit("--worktree and --staged are mutually exclusive")
+						}
+						if base != "" || baseRef != "" || head != "" || headRef != "" {
+							return showHelpAndExit("--worktree and --staged cannot be used with --base, --base-ref, --head, or --head-ref")
+						}
+						if cCtx.Int("commits") != 0 {
+							return showHelpAndExit("--commits cannot be used with --worktree or --staged")
+						}
+					} else {
+						// Validate base arguments
+						if base != "" && baseRef != "" {
+							return showHelpAndExit("--base and --base-ref are mutually exclusive")
+						}
+						if base == "" && baseRef == "" {
+							return showHelpAndExit("either --base or --base-ref is required")
+						}
+
+						// Validate head arguments
+						if head != "" && headRef != "" {
+							return showHelpAndExit("--head and --head-ref are mutually exclusive")
+						}
+						if head == "" && headRef == "" {
+							return showHelpAndExit("either --head or --head-ref is required")
+						}
 					}
 
 					// Execute action
@@ -113,7 +177,18 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 						PullRequest:   cCtx.String("pull-request"),
 						Issues:        cCtx.StringSlice("issue"),
 						Output:        cCtx.String("output"),
+						Format:        cCtx.String("format"),
+						LLMCache:      cCtx.String("llm-cache"),
+						Suggest:       cCtx.Bool("suggest"),
+						Worktree:      worktree,
+						Staged:        staged,
+						ExitCode:      cCtx.Bool("exit-code"),
+						Commits:       cCtx.Int("commits"),
+						DryRun:        cCtx.Bool("dry-run"),
 					})
+					if errors.Is(err, action.ErrRecommendationsFound) {
+						return cli.Exit(err.Error(), 2)
+					}
 					if err != nil {
 						return cli.Exit(err.Error(), 1)
 					}
@@ -158,6 +233,16 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 						Required: false,
 						Usage:    "Path to write the previous recommendations to (optional)",
 					},
+					&cli.StringFlag{
+						Name:     "llm-cache",
+						Required: false,
+						Usage:    "Path to a directory to cache LLM responses in, so reruns of the same check reuse previous responses (overrides llm.cache)",
+					},
+					&cli.BoolFlag{
+						Name:     "suggest",
+						Required: false,
+						Usage:    "Ask the LLM to suggest an update (as a unified diff) for each recommendation",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					// Set log level
@@ -174,6 +259,81 @@ func Check(logLevel *slog.LevelVar) *cli.Command {
 						Output:         cCtx.String("output"),
 						OutputCurrent:  cCtx.String("output-current"),
 						OutputPrevious: cCtx.String("output-previous"),
+						LLMCache:       cCtx.String("llm-cache"),
+						Suggest:        cCtx.Bool("suggest"),
+					})
+					if err != nil {
+						return cli.Exit(err.Error(), 1)
+					}
+					return nil
+				},
+			}, {
+				Name:  "mr",
+				Usage: "Check a GitLab merge request for issues and add recommendations as a note on the MR",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Required: true,
+						Usage:    "Path to the config file",
+					},
+					&cli.StringFlag{
+						Name:     "documentation",
+						Required: true,
+						Usage:    "Path to the current documentation data set",
+					},
+					&cli.StringFlag{
+						Name:     "merge-request",
+						Required: true,
+						Usage:    "GitLab Merge Request to check (GROUP/PROJECT/MR_IID)",
+					},
+					&cli.StringSliceFlag{
+						Name:     "issue",
+						Required: false,
+						Usage:    "GitLab Issue to include in the change (GROUP/PROJECT/ISSUE_IID). Accepts multiple issues by setting multiple times. Issues the MR closes are always included.",
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Required: false,
+						Usage:    "Path to write the combined (current and previous merged together) recommendations to (optional)",
+					},
+					&cli.StringFlag{
+						Name:     "output-current",
+						Required: false,
+						Usage:    "Path to write the current recommendations to (optional)",
+					},
+					&cli.StringFlag{
+						Name:     "output-previous",
+						Required: false,
+						Usage:    "Path to write the previous recommendations to (optional)",
+					},
+					&cli.StringFlag{
+						Name:     "llm-cache",
+						Required: false,
+						Usage:    "Path to a directory to cache LLM responses in, so reruns of the same check reuse previous responses (overrides llm.cache)",
+					},
+					&cli.BoolFlag{
+						Name:     "suggest",
+						Required: false,
+						Usage:    "Ask the LLM to suggest an update (as a unified diff) for each recommendation",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					// Set log level
+					if cCtx.Bool("debug") {
+						logLevel.Set(slog.LevelDebug)
+					}
+
+					// Execute action
+					err := action.CheckMR(&action.CheckMRArgs{
+						Config:         cCtx.String("config"),
+						Documentation:  cCtx.String("documentation"),
+						MergeRequest:   cCtx.String("merge-request"),
+						Issues:         cCtx.StringSlice("issue"),
+						Output:         cCtx.String("output"),
+						OutputCurrent:  cCtx.String("output-current"),
+						OutputPrevious: cCtx.String("output-previous"),
+						LLMCache:       cCtx.String("llm-cache"),
+						Suggest:        cCtx.Bool("suggest"),
 					})
 					if err != nil {
 						return cli.Exit(err.Error(), 1)
@@ -0,0 +1,74 @@
+package hyaline
+
+import (
+	"hyaline/internal/action"
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+func Explain(logLevel *slog.LevelVar) *cli.Command {
+	return &cli.Command{
+		Name:  "explain",
+		Usage: "Explain which documentation a check would recommend updating for changed files, without calling the LLM",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "config",
+				Required: true,
+				Usage:    "Path to the config file",
+			},
+			&cli.StringFlag{
+				Name:     "documentation",
+				Required: true,
+				Usage:    "Path to the current documentation data set",
+			},
+			&cli.StringSliceFlag{
+				Name:     "added",
+				Required: false,
+				Usage:    "Path of a file that was added. Accepts multiple files by setting multiple times.",
+			},
+			&cli.StringSliceFlag{
+				Name:     "modified",
+				Required: false,
+				Usage:    "Path of a file that was modified. Accepts multiple files by setting multiple times.",
+			},
+			&cli.StringSliceFlag{
+				Name:     "deleted",
+				Required: false,
+				Usage:    "Path of a file that was deleted. Accepts multiple files by setting multiple times.",
+			},
+			&cli.StringSliceFlag{
+				Name:     "renamed",
+				Required: false,
+				Usage:    "Original and new path of a file that was renamed (ORIGINAL:NEW). Accepts multiple files by setting multiple times.",
+			},
+		},
+		Action: func(cCtx *cli.Context) error {
+			// Set log level
+			if cCtx.Bool("debug") {
+				logLevel.Set(slog.LevelDebug)
+			}
+
+			// Ensure at least one file was passed in
+			if len(cCtx.StringSlice("added")) == 0 && len(cCtx.StringSlice("modified")) == 0 &&
+				len(cCtx.StringSlice("deleted")) == 0 && len(cCtx.StringSlice("renamed")) == 0 {
+				cli.ShowSubcommandHelp(cCtx)
+				return cli.Exit("\nError: at least one of --added, --modified, --deleted, or --renamed is required", 1)
+			}
+
+			// Execute action
+			err := action.Explain(&action.ExplainArgs{
+				Config:        cCtx.String("config"),
+				Documentation: cCtx.String("documentation"),
+				Added:         cCtx.StringSlice("added"),
+				Modified:      cCtx.StringSlice("modified"),
+				Deleted:       cCtx.StringSlice("deleted"),
+				Renamed:       cCtx.StringSlice("renamed"),
+			})
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			return nil
+		},
+	}
+}
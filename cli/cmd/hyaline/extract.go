@@ -26,6 +26,11 @@ func Extract(logLevel *slog.LevelVar) *cli.Command {
 						Required: true,
 						Usage:    "Path of the sqlite database to create",
 					},
+					&cli.StringFlag{
+						Name:     "previous",
+						Required: false,
+						Usage:    "Path of a previously extracted sqlite database. Documents that have not changed since then are copied instead of being extracted again",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					// Set log level
@@ -35,8 +40,9 @@ func Extract(logLevel *slog.LevelVar) *cli.Command {
 
 					// Execute action
 					err := action.ExtractDocumentation(&action.ExtractDocumentationArgs{
-						Config: cCtx.String("config"),
-						Output: cCtx.String("output"),
+						Config:   cCtx.String("config"),
+						Output:   cCtx.String("output"),
+						Previous: cCtx.String("previous"),
 					})
 					if err != nil {
 						return cli.Exit(err.Error(), 1)
@@ -14,7 +14,7 @@ func Serve(logLevel *slog.LevelVar, version string) *cli.Command {
 		Subcommands: []*cli.Command{
 			{
 				Name:  "mcp",
-				Usage: "Start MCP server using standard I/O transport",
+				Usage: "Start MCP server using standard I/O transport, or using streamable HTTP/SSE transport when `--listen` is set",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "documentation",
@@ -43,6 +43,16 @@ func Serve(logLevel *slog.LevelVar, version string) *cli.Command {
 						Required: false,
 						Usage:    "A GitHub Personal Access Token to read action artifacts from the hyaline-github-app-config repo. Required when using `--github-repo`. Consider setting this using an environment variable (e.g. `--github-token $HYALINE_SERVE_MCP_GITHUB_TOKEN`).",
 					},
+					&cli.StringFlag{
+						Name:     "listen",
+						Required: false,
+						Usage:    "The address to listen on for streamable HTTP (at `/mcp`) and SSE (at `/sse`) connections (e.g. `:8080`). When not set, the server uses standard I/O.",
+					},
+					&cli.StringFlag{
+						Name:     "auth-token",
+						Required: false,
+						Usage:    "A token that clients must send as a bearer token (`Authorization: Bearer <token>`). Only used with `--listen`. Consider setting this using an environment variable (e.g. `--auth-token $HYALINE_SERVE_MCP_AUTH_TOKEN`).",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					// Set log level
@@ -60,6 +70,9 @@ func Serve(logLevel *slog.LevelVar, version string) *cli.Command {
 					if cCtx.String("github-repo") != "" && cCtx.String("github-token") == "" {
 						return cli.Exit("--github-token is required when using --github-repo", 1)
 					}
+					if cCtx.String("auth-token") != "" && cCtx.String("listen") == "" {
+						return cli.Exit("--auth-token can only be used with --listen", 1)
+					}
 
 					// Execute action
 					err := action.ServeMCP(&action.ServeMCPArgs{
@@ -68,6 +81,8 @@ func Serve(logLevel *slog.LevelVar, version string) *cli.Command {
 						GitHubArtifact:     cCtx.String("github-artifact"),
 						GitHubArtifactPath: cCtx.String("github-artifact-path"),
 						GitHubToken:        cCtx.String("github-token"),
+						Listen:             cCtx.String("listen"),
+						AuthToken:          cCtx.String("auth-token"),
 					}, version)
 					if err != nil {
 						return cli.Exit(err.Error(), 1)
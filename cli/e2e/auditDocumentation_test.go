@@ -28,3 +28,74 @@ func TestAuditDocumentation(t *testing.T) {
 
 	compareFiles(goldenPath, outputPath, t)
 }
+
+func TestAuditDocumentationSarif(t *testing.T) {
+	goldenPath := "./_golden/audit-documentation-results.sarif"
+	outputPath := fmt.Sprintf("./_output/audit-documentation-%d.sarif", time.Now().UnixMilli())
+	args := []string{
+		"audit", "documentation",
+		"--config", "./_input/audit-documentation/hyaline.yml",
+		"--documentation", "./_input/audit-documentation/documentation.sqlite",
+		"--output", outputPath,
+		"--format", "sarif",
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log(string(stdOutStdErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestAuditDocumentationJUnit(t *testing.T) {
+	goldenPath := "./_golden/audit-documentation-results.xml"
+	outputPath := fmt.Sprintf("./_output/audit-documentation-%d.xml", time.Now().UnixMilli())
+	args := []string{
+		"audit", "documentation",
+		"--config", "./_input/audit-documentation/hyaline.yml",
+		"--documentation", "./_input/audit-documentation/documentation.sqlite",
+		"--output", outputPath,
+		"--format", "junit",
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log(string(stdOutStdErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestAuditDocumentationReplay(t *testing.T) {
+	goldenPath := "./_golden/audit-documentation-replay-results.json"
+	outputPath := fmt.Sprintf("./_output/audit-documentation-replay-%d.json", time.Now().UnixMilli())
+	args := []string{
+		"audit", "documentation",
+		"--config", "./_input/audit-documentation-replay/hyaline.yml",
+		"--documentation", "./_input/audit-documentation/documentation.sqlite",
+		"--output", outputPath,
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log(string(stdOutStdErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
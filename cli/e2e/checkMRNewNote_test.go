@@ -0,0 +1,58 @@
+package e2e
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckMRNewNote(t *testing.T) {
+	goldenPath := "./_golden/check-mr-new-note.json"
+	outputPath := fmt.Sprintf("./_output/check-mr-new-note-%d.json", time.Now().UnixMilli())
+	outputCurrentPath := fmt.Sprintf("./_output/check-mr-new-note-current-%d.json", time.Now().UnixMilli())
+	outputPreviousPath := fmt.Sprintf("./_output/check-mr-new-note-previous-%d.json", time.Now().UnixMilli())
+
+	gitlab, url := startFakeGitLab(t, []fakeGitLabNote{
+		{ID: 10, Body: "A system note", System: true},
+		{ID: 11, Body: "A comment from a reviewer"},
+	})
+	t.Setenv("HYALINE_E2E_GITLAB_URL", url)
+
+	args := []string{
+		"check", "mr",
+		"--config", "./_input/check-mr-new-note/hyaline.yml",
+		"--documentation", "./_input/check-mr-new-note/documentation.sqlite",
+		"--merge-request", fakeGitLabProject + "/1",
+		"--issue", fakeGitLabProject + "/3",
+		"--output", outputPath,
+		"--output-current", outputCurrentPath,
+		"--output-previous", outputPreviousPath,
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log("Check MR output:", string(stdOutStdErr))
+	if err != nil {
+		t.Fatal("Check MR failed:", err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+		updateGolden("./_golden/check-mr-new-note-current.json", outputCurrentPath, t)
+		updateGolden("./_golden/check-mr-new-note-previous.json", outputPreviousPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+	compareFiles("./_golden/check-mr-new-note-current.json", outputCurrentPath, t)
+	compareFiles("./_golden/check-mr-new-note-previous.json", outputPreviousPath, t)
+
+	if len(gitlab.added) != 1 || len(gitlab.updated) != 0 {
+		t.Fatalf("expected a single note to be added, got %d added and %d updated", len(gitlab.added), len(gitlab.updated))
+	}
+	if !strings.Contains(gitlab.added[0], " MR Check") {
+		t.Errorf("expected the added note to be an MR check, got: %s", gitlab.added[0])
+	}
+	if !strings.Contains(gitlab.added[0], "(updated in this MR)") {
+		t.Errorf("expected the added note to mark updated documentation, got: %s", gitlab.added[0])
+	}
+}
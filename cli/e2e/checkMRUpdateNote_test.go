@@ -0,0 +1,63 @@
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckMRUpdateNote(t *testing.T) {
+	goldenPath := "./_golden/check-mr-update-note.json"
+	outputPath := fmt.Sprintf("./_output/check-mr-update-note-%d.json", time.Now().UnixMilli())
+	outputCurrentPath := fmt.Sprintf("./_output/check-mr-update-note-current-%d.json", time.Now().UnixMilli())
+	outputPreviousPath := fmt.Sprintf("./_output/check-mr-update-note-previous-%d.json", time.Now().UnixMilli())
+
+	existingNote, err := os.ReadFile("./_input/check-mr-update-note/note.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitlab, url := startFakeGitLab(t, []fakeGitLabNote{
+		{ID: 10, Body: "A comment from a reviewer"},
+		{ID: 11, Body: string(existingNote)},
+	})
+	t.Setenv("HYALINE_E2E_GITLAB_URL", url)
+
+	args := []string{
+		"check", "mr",
+		"--config", "./_input/check-mr-update-note/hyaline.yml",
+		"--documentation", "./_input/check-mr-update-note/documentation.sqlite",
+		"--merge-request", fakeGitLabProject + "/1",
+		"--output", outputPath,
+		"--output-current", outputCurrentPath,
+		"--output-previous", outputPreviousPath,
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log("Check MR output:", string(stdOutStdErr))
+	if err != nil {
+		t.Fatal("Check MR failed:", err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+		updateGolden("./_golden/check-mr-update-note-current.json", outputCurrentPath, t)
+		updateGolden("./_golden/check-mr-update-note-previous.json", outputPreviousPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+	compareFiles("./_golden/check-mr-update-note-current.json", outputCurrentPath, t)
+	compareFiles("./_golden/check-mr-update-note-previous.json", outputPreviousPath, t)
+
+	if len(gitlab.added) != 0 || len(gitlab.updated) != 1 {
+		t.Fatalf("expected a single note to be updated, got %d added and %d updated", len(gitlab.added), len(gitlab.updated))
+	}
+	updatedNote, ok := gitlab.updated[11]
+	if !ok {
+		t.Fatal("expected the existing Hyaline note to be updated")
+	}
+	if !strings.Contains(updatedNote, "- [x] **docs/docsDoc.md** in `my-app`") {
+		t.Errorf("expected the manual check on docs/docsDoc.md to be preserved, got: %s", updatedNote)
+	}
+}
@@ -0,0 +1,27 @@
+package e2e
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExtractDocumentationFsPrevious(t *testing.T) {
+	goldenPath := "./_golden/extract-documentation-fs.sqlite"
+	outputPath := fmt.Sprintf("./_output/extract-documentation-fs-previous-%d.db", time.Now().UnixMilli())
+	args := []string{
+		"extract", "documentation",
+		"--config", "./_input/extract-documentation-fs/hyaline.yml",
+		"--previous", goldenPath,
+		"--output", outputPath,
+	}
+
+	stdOutStdErr, err := runBinary(args, t)
+	t.Log(string(stdOutStdErr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed since the golden extraction, so the output should be identical
+	compareDBs(goldenPath, outputPath, t)
+}
@@ -0,0 +1,148 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fakeGitLabProject = "hyaline/hyaline-example"
+const fakeGitLabToken = "test-token"
+
+type fakeGitLabNote struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+// fakeGitLab is a minimal in-memory GitLab API that serves a single merge request (IID 1)
+// along with its changed files, linked issues, and notes
+type fakeGitLab struct {
+	mu      sync.Mutex
+	notes   []fakeGitLabNote
+	added   []string
+	updated map[int64]string
+	files   map[string]map[string]string
+}
+
+// startFakeGitLab starts a fake GitLab server and returns the API base url to configure hyaline with
+func startFakeGitLab(t *testing.T, notes []fakeGitLabNote) (*fakeGitLab, string) {
+	gitlab := &fakeGitLab{
+		notes:   notes,
+		updated: make(map[int64]string),
+		files: map[string]map[string]string{
+			"base-sha": {
+				"README.md": "# Example\nThis is an example\n\n### Subsection 1\nSubsection 1 content\n\n### Subsection 2\nSubsection 2 content",
+			},
+			"head-sha": {
+				"README.md": "# Example\nThis is an example\n\n### Subsection 1\nSubsection 1 content has been updated\n\n### Subsection 2\nSubsection 2 content",
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gitlab.handle(t, w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return gitlab, server.URL + "/api/v4"
+}
+
+func (g *fakeGitLab) handle(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r.Header.Get("PRIVATE-TOKEN") != fakeGitLabToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	prefix := "/api/v4/projects/" + strings.ReplaceAll(fakeGitLabProject, "/", "%2F")
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, prefix) {
+		t.Logf("fake gitlab received request for unknown project: %s", path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	path = strings.TrimPrefix(path, prefix)
+
+	switch {
+	case r.Method == http.MethodGet && path == "/merge_requests/1":
+		writeFakeGitLabJSON(w, map[string]interface{}{
+			"iid":         1,
+			"title":       "Update subdir file",
+			"description": "This MR updates the subdir file. Closes #2",
+			"sha":         "head-sha",
+			"diff_refs": map[string]string{
+				"base_sha":  "base-sha",
+				"head_sha":  "head-sha",
+				"start_sha": "base-sha",
+			},
+		})
+	case r.Method == http.MethodGet && path == "/merge_requests/1/diffs":
+		writeFakeGitLabJSON(w, []map[string]interface{}{
+			{
+				"old_path": "subdir/subdirfile.js",
+				"new_path": "subdir/subdirfile.js",
+				"diff":     "@@ -1 +1 @@\n-console.log('subdir');\n+console.log('subdir updated');\n",
+			},
+			{
+				"old_path": "README.md",
+				"new_path": "README.md",
+				"diff":     "@@ -4,2 +4,2 @@\n ### Subsection 1\n-Subsection 1 content\n+Subsection 1 content has been updated\n",
+			},
+		})
+	case r.Method == http.MethodGet && path == "/merge_requests/1/closes_issues":
+		writeFakeGitLabJSON(w, []map[string]interface{}{
+			{"iid": 2, "title": "Subdir file is outdated", "description": "The subdir file needs to be updated"},
+		})
+	case r.Method == http.MethodGet && path == "/issues/3":
+		writeFakeGitLabJSON(w, map[string]interface{}{
+			"iid": 3, "title": "Related issue", "description": "An issue passed in explicitly",
+		})
+	case r.Method == http.MethodGet && path == "/merge_requests/1/notes":
+		writeFakeGitLabJSON(w, g.notes)
+	case r.Method == http.MethodPost && path == "/merge_requests/1/notes":
+		body := fakeGitLabNote{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		g.added = append(g.added, body.Body)
+		writeFakeGitLabJSON(w, body)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/merge_requests/1/notes/"):
+		var id int64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(path, "/merge_requests/1/notes/"), "%d", &id); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body := fakeGitLabNote{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		g.updated[id] = body.Body
+		writeFakeGitLabJSON(w, body)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/repository/files/") && strings.HasSuffix(path, "/raw"):
+		file := strings.TrimSuffix(strings.TrimPrefix(path, "/repository/files/"), "/raw")
+		file = strings.ReplaceAll(file, "%2F", "/")
+		contents, ok := g.files[r.URL.Query().Get("ref")][file]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(contents))
+	default:
+		t.Logf("fake gitlab received unexpected request: %s %s", r.Method, path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeFakeGitLabJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
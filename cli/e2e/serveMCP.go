@@ -2,7 +2,9 @@ package e2e
 
 import (
 	"context"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -79,3 +81,97 @@ func callServeMCPServer(t *testing.T, dbPath string, request mcp.CallToolRequest
 		t.Fatalf("expected to write output file: %v", err)
 	}
 }
+
+// startServeMCPHTTPServer starts the serve mcp command listening on a free local port and returns
+// the running command along with the base url of the server
+func startServeMCPHTTPServer(t *testing.T, dbPath string, authToken string) (*exec.Cmd, string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("expected to get current working directory: %v", err)
+	}
+	binaryPath := filepath.Join(dir, "../hyaline-e2e")
+
+	// Find a free port to listen on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected to find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	args := []string{"serve", "mcp", "--documentation", dbPath, "--listen", addr}
+	if authToken != "" {
+		args = append(args, "--auth-token", authToken)
+	}
+	cmd := exec.Command(binaryPath, args...)
+	cmd.Env = append(os.Environ(), "GOCOVERDIR=../.coverdata/e2e")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("expected to start MCP server: %v", err)
+	}
+	t.Cleanup(func() {
+		if cmd.ProcessState == nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+	})
+
+	// Wait for the server to start accepting connections
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected MCP server to start listening on %s: %v", addr, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return cmd, "http://" + addr
+}
+
+// initializeServeMCPClient starts and initializes an MCP client created for a remote transport
+func initializeServeMCPClient(t *testing.T, client *mcpClient.Client) error {
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	// Note: the SSE transport holds its stream open using the context it was started with
+	if err := client.Start(context.Background()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	request := mcp.InitializeRequest{}
+	request.Params.ProtocolVersion = "2024-11-05"
+	request.Params.ClientInfo = mcp.Implementation{
+		Name:    "hyaline-e2e-test-client",
+		Version: "0.0.1",
+	}
+
+	_, err := client.Initialize(ctx, request)
+	return err
+}
+
+// callServeMCPClient performs a request using an initialized client and writes output to the provided path
+func callServeMCPClient(t *testing.T, client *mcpClient.Client, request mcp.CallToolRequest, outputPath string) {
+	response, err := client.CallTool(context.Background(), request)
+	if err != nil {
+		t.Fatalf("expected to call '%s' tool successfully: %v", request.Params.Name, err)
+	}
+
+	textContent, ok := response.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatal("expected content to be of type TextContent")
+	}
+
+	err = os.WriteFile(outputPath, []byte(textContent.Text), 0644)
+	if err != nil {
+		t.Fatalf("expected to write output file: %v", err)
+	}
+}
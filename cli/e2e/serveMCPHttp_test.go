@@ -0,0 +1,106 @@
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	mcpClient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestServeMCPHttpStreamable(t *testing.T) {
+	_, baseURL := startServeMCPHTTPServer(t, "./_input/serve-mcp/documentation.sqlite", "test-token")
+
+	client, err := mcpClient.NewStreamableHttpClient(baseURL+"/mcp", transport.WithHTTPHeaders(map[string]string{
+		"Authorization": "Bearer test-token",
+	}))
+	if err != nil {
+		t.Fatalf("expected to create MCP client successfully: %v", err)
+	}
+	if err := initializeServeMCPClient(t, client); err != nil {
+		t.Fatalf("failed to initialize MCP client: %v", err)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "list_documents"
+
+	// Note: the output should match the output of the stdio transport
+	goldenPath := "./_golden/serve-mcp-list-documents-all.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-http-streamable-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPClient(t, client, request, outputPath)
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPHttpSSE(t *testing.T) {
+	_, baseURL := startServeMCPHTTPServer(t, "./_input/serve-mcp/documentation.sqlite", "test-token")
+
+	client, err := mcpClient.NewSSEMCPClient(baseURL+"/sse", mcpClient.WithHeaders(map[string]string{
+		"Authorization": "Bearer test-token",
+	}))
+	if err != nil {
+		t.Fatalf("expected to create MCP client successfully: %v", err)
+	}
+	if err := initializeServeMCPClient(t, client); err != nil {
+		t.Fatalf("failed to initialize MCP client: %v", err)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "list_documents"
+
+	// Note: the output should match the output of the stdio transport
+	goldenPath := "./_golden/serve-mcp-list-documents-all.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-http-sse-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPClient(t, client, request, outputPath)
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPHttpUnauthorized(t *testing.T) {
+	_, baseURL := startServeMCPHTTPServer(t, "./_input/serve-mcp/documentation.sqlite", "test-token")
+
+	client, err := mcpClient.NewStreamableHttpClient(baseURL+"/mcp", transport.WithHTTPHeaders(map[string]string{
+		"Authorization": "Bearer wrong-token",
+	}))
+	if err != nil {
+		t.Fatalf("expected to create MCP client successfully: %v", err)
+	}
+	if err := initializeServeMCPClient(t, client); err == nil {
+		t.Fatal("expected initializing the MCP client to fail with the wrong token")
+	}
+}
+
+func TestServeMCPHttpShutdown(t *testing.T) {
+	cmd, baseURL := startServeMCPHTTPServer(t, "./_input/serve-mcp/documentation.sqlite", "")
+
+	// Hold an SSE stream open to ensure shutdown does not wait on it
+	client, err := mcpClient.NewSSEMCPClient(baseURL + "/sse")
+	if err != nil {
+		t.Fatalf("expected to create MCP client successfully: %v", err)
+	}
+	if err := initializeServeMCPClient(t, client); err != nil {
+		t.Fatalf("failed to initialize MCP client: %v", err)
+	}
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatalf("expected to interrupt MCP server: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected MCP server to exit cleanly: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected MCP server to shut down within 5 seconds")
+	}
+}
@@ -0,0 +1,138 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	mcpClient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestServeMCPResourcesList(t *testing.T) {
+	client := setupServeMCPClient(t, "serve mcp --documentation ./_input/serve-mcp/documentation.sqlite")
+
+	result, err := client.ListResources(context.Background(), mcp.ListResourcesRequest{})
+	if err != nil {
+		t.Fatalf("expected to list resources successfully: %v", err)
+	}
+	templates, err := client.ListResourceTemplates(context.Background(), mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		t.Fatalf("expected to list resource templates successfully: %v", err)
+	}
+
+	// Resources are not returned in a deterministic order
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].URI < result.Resources[j].URI
+	})
+	output, err := json.MarshalIndent(map[string]any{
+		"resources":         result.Resources,
+		"resourceTemplates": templates.ResourceTemplates,
+	}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goldenPath := "./_golden/serve-mcp-resources-list.json"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-resources-list-%d.json", time.Now().UnixMilli())
+	err = os.WriteFile(outputPath, output, 0644)
+	if err != nil {
+		t.Fatalf("expected to write output file: %v", err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPResourcesReadDocument(t *testing.T) {
+	readServeMCPResource(t, "document://mcp-test/docs/doc.html", "serve-mcp-resources-read-document")
+}
+
+func TestServeMCPResourcesReadSection(t *testing.T) {
+	readServeMCPResource(t, "document://mcp-test/docs/doc.html#First%20Section/Sub%20Section%201", "serve-mcp-resources-read-section")
+}
+
+func TestServeMCPResourcesReadNotFound(t *testing.T) {
+	client := setupServeMCPClient(t, "serve mcp --documentation ./_input/serve-mcp/documentation.sqlite")
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = "document://mcp-test/docs/doc.html#Nonexistent"
+	_, err := client.ReadResource(context.Background(), request)
+	if err == nil {
+		t.Fatal("expected reading a nonexistent section to fail")
+	}
+}
+
+func TestServeMCPResourcesReloadNotification(t *testing.T) {
+	// Note: the stdio client does not dispatch notifications, so this uses the SSE transport
+	_, baseURL := startServeMCPHTTPServer(t, "./_input/serve-mcp/documentation.sqlite", "")
+	client, err := mcpClient.NewSSEMCPClient(baseURL + "/sse")
+	if err != nil {
+		t.Fatalf("expected to create MCP client successfully: %v", err)
+	}
+	if err := initializeServeMCPClient(t, client); err != nil {
+		t.Fatalf("failed to initialize MCP client: %v", err)
+	}
+
+	notified := make(chan struct{}, 1)
+	client.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == string(mcp.MethodNotificationResourcesListChanged) {
+			select {
+			case notified <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "reload_documentation"
+	_, err = client.CallTool(context.Background(), request)
+	if err != nil {
+		t.Fatalf("expected to call 'reload_documentation' tool successfully: %v", err)
+	}
+
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a resources list changed notification after reloading documentation")
+	}
+}
+
+// readServeMCPResource reads the resource at uri and compares its contents to the named golden file
+func readServeMCPResource(t *testing.T, uri string, name string) {
+	client := setupServeMCPClient(t, "serve mcp --documentation ./_input/serve-mcp/documentation.sqlite")
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	result, err := client.ReadResource(context.Background(), request)
+	if err != nil {
+		t.Fatalf("expected to read resource successfully: %v", err)
+	}
+	if len(result.Contents) != 1 {
+		t.Fatalf("expected 1 resource contents, got %d", len(result.Contents))
+	}
+	textContents, ok := result.Contents[0].(mcp.TextResourceContents)
+	if !ok {
+		t.Fatal("expected contents to be of type TextResourceContents")
+	}
+
+	goldenPath := fmt.Sprintf("./_golden/%s.txt", name)
+	outputPath := fmt.Sprintf("./_output/%s-%d.txt", name, time.Now().UnixMilli())
+	err = os.WriteFile(outputPath, []byte(textContents.Text), 0644)
+	if err != nil {
+		t.Fatalf("expected to write output file: %v", err)
+	}
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
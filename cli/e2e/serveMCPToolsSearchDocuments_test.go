@@ -0,0 +1,91 @@
+package e2e
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestServeMCPSearchDocumentsAll(t *testing.T) {
+	// Test searching all documents
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "search_documents"
+	request.Params.Arguments = map[string]any{
+		"query": "section two",
+	}
+
+	goldenPath := "./_golden/serve-mcp-search-documents-all.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-search-documents-all-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPServer(t, "./_input/serve-mcp/documentation.sqlite", request, outputPath)
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPSearchDocumentsLimit(t *testing.T) {
+	// Test limiting the number of search results
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "search_documents"
+	request.Params.Arguments = map[string]any{
+		"query": "content",
+		"limit": 2,
+	}
+
+	goldenPath := "./_golden/serve-mcp-search-documents-limit.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-search-documents-limit-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPServer(t, "./_input/serve-mcp/documentation.sqlite", request, outputPath)
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPSearchDocumentsTags(t *testing.T) {
+	// Test searching documents and sections filtered by tags
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "search_documents"
+	request.Params.Arguments = map[string]any{
+		"query":        "content",
+		"document_uri": "document://mcp-test?importance=high",
+	}
+
+	goldenPath := "./_golden/serve-mcp-search-documents-tags.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-search-documents-tags-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPServer(t, "./_input/serve-mcp/documentation.sqlite", request, outputPath)
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
+
+func TestServeMCPSearchDocumentsNotFound(t *testing.T) {
+	// Test searching for terms that do not appear in any document
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "search_documents"
+	request.Params.Arguments = map[string]any{
+		"query": "nonexistent",
+	}
+
+	goldenPath := "./_golden/serve-mcp-search-documents-notfound.txt"
+	outputPath := fmt.Sprintf("./_output/serve-mcp-search-documents-notfound-%d.txt", time.Now().UnixMilli())
+
+	callServeMCPServer(t, "./_input/serve-mcp/documentation.sqlite", request, outputPath)
+
+	if *update {
+		updateGolden(goldenPath, outputPath, t)
+	}
+
+	compareFiles(goldenPath, outputPath, t)
+}
@@ -0,0 +1,157 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"hyaline/internal/check"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type ApplySuggestionsArgs struct {
+	Config          string
+	Documentation   string
+	Recommendations string
+	Path            string
+}
+
+func ApplySuggestions(args *ApplySuggestionsArgs) error {
+	slog.Info("Applying suggestions",
+		"config", args.Config,
+		"documentation", args.Documentation,
+		"recommendations", args.Recommendations,
+		"path", args.Path)
+
+	// Load Config
+	cfg, err := config.Load(args.Config, true)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not load the config", "error", err)
+		return err
+	}
+
+	// Ensure check options are set as they are required for this action to run
+	if cfg.Check == nil {
+		slog.Debug("action.ApplySuggestions did not find check options")
+		err = errors.New("the apply suggestions command requires check options be set in the config")
+		return err
+	}
+
+	// Read recommendations
+	data, err := os.ReadFile(args.Recommendations)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not read recommendations", "recommendations", args.Recommendations, "error", err)
+		return err
+	}
+	var output CheckOutput
+	err = json.Unmarshal(data, &output)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not parse recommendations", "recommendations", args.Recommendations, "error", err)
+		return err
+	}
+
+	// Get Documents (the suggestions were made against their extracted contents)
+	docDB, close, err := sqlite.InitInput(args.Documentation)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not initialize documentation db", "documentation", args.Documentation, "error", err)
+		return err
+	}
+	defer close()
+	documents, err := docs.GetFilteredDocs(&cfg.Check.Documentation, docDB)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not get filtered documents", "error", err)
+		return err
+	}
+	documentMap := make(map[string]*docs.FilteredDoc)
+	for _, document := range documents {
+		documentMap[document.Document.SourceID+"/"+document.Document.ID] = document
+	}
+
+	absPath, err := filepath.Abs(args.Path)
+	if err != nil {
+		slog.Debug("action.ApplySuggestions could not determine absolute path", "error", err, "path", args.Path)
+		return err
+	}
+
+	// Apply each suggestion in turn, so that multiple suggestions for the same file build on one another
+	contents := make(map[string]string)
+	updatedFiles := []string{}
+	applied, skipped := 0, 0
+	for i := range output.Recommendations {
+		rec := &output.Recommendations[i]
+		if rec.Suggestion == nil || rec.Checked || rec.Outdated {
+			continue
+		}
+		uri := recommendationURI(rec)
+		// Suggestions kept from a previous comment or note only include their diff
+		if rec.Suggestion.Content == "" {
+			slog.Warn("Skipping suggestion, it does not include the suggested content", "document", uri)
+			skipped++
+			continue
+		}
+
+		document, ok := documentMap[rec.Source+"/"+rec.Document]
+		if !ok {
+			slog.Warn("Skipping suggestion, could not find document", "document", uri)
+			skipped++
+			continue
+		}
+		if document.Document.Type != config.DocTypeMarkdown.String() {
+			slog.Warn("Skipping suggestion, only suggestions for markdown documents can be applied", "document", uri, "type", document.Document.Type)
+			skipped++
+			continue
+		}
+
+		filename := filepath.Join(absPath, check.DocumentFilename(rec.Source, rec.Document, &cfg.Check.Options.DetectDocumentationUpdates))
+		markdown, ok := contents[filename]
+		if !ok {
+			var raw []byte
+			raw, err = os.ReadFile(filename)
+			if err != nil {
+				slog.Warn("Skipping suggestion, could not read document", "document", uri, "filename", filename, "error", err)
+				skipped++
+				continue
+			}
+			markdown = string(raw)
+			contents[filename] = markdown
+		}
+
+		updated, drifted, err := check.ApplySuggestion(markdown, document, strings.Join(rec.Section, "/"), rec.Suggestion)
+		if err != nil {
+			slog.Warn("Skipping suggestion, could not apply it", "document", uri, "filename", filename, "error", err)
+			skipped++
+			continue
+		}
+		if drifted {
+			slog.Warn("Document has changed since it was extracted, merged the suggestion with those changes", "document", uri, "filename", filename)
+		}
+		if !slices.Contains(updatedFiles, filename) {
+			updatedFiles = append(updatedFiles, filename)
+		}
+		contents[filename] = updated
+		applied++
+	}
+
+	// Write updated documents
+	for _, filename := range updatedFiles {
+		var info os.FileInfo
+		info, err = os.Stat(filename)
+		if err != nil {
+			slog.Debug("action.ApplySuggestions could not stat document", "filename", filename, "error", err)
+			return err
+		}
+		err = os.WriteFile(filename, []byte(contents[filename]), info.Mode().Perm())
+		if err != nil {
+			slog.Debug("action.ApplySuggestions could not write document", "filename", filename, "error", err)
+			return err
+		}
+	}
+	slog.Info("Applied suggestions", "applied", applied, "skipped", skipped, "files", len(updatedFiles))
+
+	return nil
+}
@@ -1,10 +1,11 @@
 package action
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"hyaline/internal/audit"
 	"hyaline/internal/config"
+	"hyaline/internal/llm"
 	"hyaline/internal/sqlite"
 	"log/slog"
 	"os"
@@ -15,6 +16,18 @@ import (
 // AuditOutput represents the top-level audit results
 type AuditOutput struct {
 	Results []audit.AuditRuleResult `json:"results"`
+	Usage   *AuditUsage             `json:"usage,omitempty"`
+}
+
+// AuditUsage is the LLM usage of an audit, in total and for each rule that used the LLM
+type AuditUsage struct {
+	Total llm.UsageSummary `json:"total"`
+	Rules []AuditRuleUsage `json:"rules"`
+}
+
+type AuditRuleUsage struct {
+	Rule string `json:"rule"`
+	llm.UsageSummary
 }
 
 type AuditDocumentationArgs struct {
@@ -22,6 +35,8 @@ type AuditDocumentationArgs struct {
 	Documentation string
 	Sources       []string
 	Output        string
+	Format        string
+	LLMCache      string
 }
 
 func AuditDocumentation(args *AuditDocumentationArgs) error {
@@ -29,7 +44,8 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 		"config", args.Config,
 		"documentation", args.Documentation,
 		"sources", args.Sources,
-		"output", args.Output)
+		"output", args.Output,
+		"format", args.Format)
 
 	// Load Config
 	cfg, err := config.Load(args.Config, true)
@@ -38,6 +54,15 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 		return err
 	}
 
+	// Override the LLM cache directory if one was passed in
+	if args.LLMCache != "" {
+		cfg.LLM.Cache = args.LLMCache
+	}
+
+	// LLM calls are cancelled once llm.timeout.total (if set) has elapsed
+	ctx, cancel := llm.WithTotalTimeout(context.Background(), &cfg.LLM)
+	defer cancel()
+
 	// Ensure audit configuration exists
 	if cfg.Audit == nil {
 		return fmt.Errorf("audit configuration not found in config file")
@@ -49,7 +74,14 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 		return nil
 	}
 
-	// Ensure output JSON file does not exist
+	// Validate format
+	format, err := getOutputFormat(args.Format)
+	if err != nil {
+		slog.Debug("action.AuditDocumentation received an invalid format", "format", args.Format)
+		return err
+	}
+
+	// Ensure output file does not exist
 	outputAbsPath, err := filepath.Abs(args.Output)
 	if err != nil {
 		slog.Debug("action.AuditDocumentation could not get an absolute path for output", "output", args.Output, "error", err)
@@ -71,7 +103,7 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 
 	slog.Debug("action.AuditDocumentation initialized documentation database", "documentation", args.Documentation)
 
-	auditRuleResults, err := audit.Documentation(cfg, db, args.Sources)
+	auditRuleResults, ruleUsage, err := audit.Documentation(cfg, db, args.Sources, llm.WithContext(ctx))
 	if err != nil {
 		slog.Debug("action.AuditDocumentation could not run audit", "error", err)
 		return err
@@ -100,15 +132,10 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 	// Create final output structure
 	auditResults := &AuditOutput{
 		Results: auditRuleResults,
+		Usage:   getAuditUsage(ruleUsage, &cfg.LLM),
 	}
 
-	// Write results to JSON file
-	jsonData, err := json.MarshalIndent(auditResults, "", "  ")
-	if err != nil {
-		slog.Debug("action.AuditDocumentation could not marshal JSON", "error", err)
-		return err
-	}
-
+	// Write results to output file
 	outputFile, err := os.Create(outputAbsPath)
 	if err != nil {
 		slog.Debug("action.AuditDocumentation could not create output file", "error", err)
@@ -116,7 +143,7 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 	}
 	defer outputFile.Close()
 
-	_, err = outputFile.Write(jsonData)
+	err = writeAuditOutput(outputFile, auditResults, format)
 	if err != nil {
 		slog.Debug("action.AuditDocumentation could not write output file", "error", err)
 		return err
@@ -125,3 +152,29 @@ func AuditDocumentation(args *AuditDocumentationArgs) error {
 	slog.Info("Audit documentation completed successfully")
 	return nil
 }
+
+// getAuditUsage summarizes the LLM usage of each rule, returning nil if no tokens were used (such as
+// when every LLM call was replayed or cached)
+func getAuditUsage(ruleUsage []audit.RuleUsage, llmConfig *config.LLM) *AuditUsage {
+	if len(ruleUsage) == 0 {
+		return nil
+	}
+
+	var total llm.Usage
+	rules := []AuditRuleUsage{}
+	for _, entry := range ruleUsage {
+		total.Add(entry.Usage)
+		rules = append(rules, AuditRuleUsage{
+			Rule:         entry.Rule,
+			UsageSummary: llm.Summarize(entry.Usage, llmConfig),
+		})
+	}
+
+	usage := &AuditUsage{
+		Total: llm.Summarize(total, llmConfig),
+		Rules: rules,
+	}
+	logUsage(usage.Total)
+
+	return usage
+}
@@ -1,8 +1,9 @@
 package action
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"hyaline/internal/check"
 	"hyaline/internal/code"
 	"hyaline/internal/config"
@@ -11,13 +12,16 @@ import (
 	"hyaline/internal/llm"
 	"hyaline/internal/repo"
 	"hyaline/internal/sqlite"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 type CheckDiffArgs struct {
@@ -31,23 +35,58 @@ type CheckDiffArgs struct {
 	PullRequest   string
 	Issues        []string
 	Output        string
+	Format        string
+	LLMCache      string
+	Suggest       bool
+	Worktree      bool
+	Staged        bool
+	ExitCode      bool
+	Commits       int
+	DryRun        bool
 }
 
+// The head of the output when checking uncommitted changes in the working tree or index
+const (
+	checkHeadWorktree = "worktree"
+	checkHeadStaged   = "staged"
+)
+
+// ErrRecommendationsFound is returned by CheckDiff when ExitCode is set and there are
+// recommendations that have not been checked
+var ErrRecommendationsFound = errors.New("found documentation that may need to be updated")
+
 type CheckOutput struct {
 	Recommendations []CheckRecommendation `json:"recommendations"`
 	Head            string                `json:"head"`
 	Base            string                `json:"base"`
+	Usage           *CheckUsage           `json:"usage,omitempty"`
+}
+
+// CheckUsage is the LLM usage of a check, in total, for each file that was checked, and for
+// suggestions (if any). The embeddings usage (if any) is reported separately without a cost, since
+// llm.pricing is the pricing of the LLM rather than of the embeddings model.
+type CheckUsage struct {
+	Total       llm.UsageSummary  `json:"total"`
+	Files       []CheckFileUsage  `json:"files"`
+	Suggestions *llm.UsageSummary `json:"suggestions,omitempty"`
+	Embeddings  *llm.UsageSummary `json:"embeddings,omitempty"`
+}
+
+type CheckFileUsage struct {
+	File string `json:"file"`
+	llm.UsageSummary
 }
 
 type CheckRecommendation struct {
-	Source         string         `json:"documentationSource"`
-	Document       string         `json:"document"`
-	Section        []string       `json:"section,omitempty"`
-	Recommendation string         `json:"recommendation"`
-	Reasons        []check.Reason `json:"reasons"`
-	Changed        bool           `json:"changed"`
-	Checked        bool           `json:"checked"`
-	Outdated       bool           `json:"outdated"`
+	Source         string            `json:"documentationSource"`
+	Document       string            `json:"document"`
+	Section        []string          `json:"section,omitempty"`
+	Recommendation string            `json:"recommendation"`
+	Reasons        []check.Reason    `json:"reasons"`
+	Changed        bool              `json:"changed"`
+	Checked        bool              `json:"checked"`
+	Outdated       bool              `json:"outdated"`
+	Suggestion     *check.Suggestion `json:"suggestion,omitempty"`
 }
 
 func sortCheckRecommendations(recommendations []CheckRecommendation) {
@@ -95,9 +134,13 @@ func CheckDiff(args *CheckDiffArgs) error {
 		"base-ref", args.BaseRef,
 		"head", args.Head,
 		"head-ref", args.HeadRef,
+		"worktree", args.Worktree,
+		"staged", args.Staged,
+		"commits", args.Commits,
 		"pull-request", args.PullRequest,
 		"issues", args.Issues,
-		"output", args.Output)
+		"output", args.Output,
+		"format", args.Format)
 
 	// Load Config
 	cfg, err := config.Load(args.Config, true)
@@ -106,6 +149,15 @@ func CheckDiff(args *CheckDiffArgs) error {
 		return err
 	}
 
+	// Override the LLM cache directory if one was passed in
+	if args.LLMCache != "" {
+		cfg.LLM.Cache = args.LLMCache
+	}
+
+	// LLM calls are cancelled once llm.timeout.total (if set) has elapsed
+	ctx, cancel := llm.WithTotalTimeout(context.Background(), &cfg.LLM)
+	defer cancel()
+
 	// Ensure check options are set as they are required for this action to run
 	if cfg.Check == nil {
 		slog.Debug("action.CheckDiff did not find check options")
@@ -119,16 +171,26 @@ func CheckDiff(args *CheckDiffArgs) error {
 		return nil
 	}
 
-	// Ensure output file does not exist
-	outputAbsPath, err := filepath.Abs(args.Output)
+	// Validate format
+	format, err := getOutputFormat(args.Format)
 	if err != nil {
-		slog.Debug("action.CheckDiff could not get an absolute path for output", "output", args.Output, "error", err)
+		slog.Debug("action.CheckDiff received an invalid format", "format", args.Format)
 		return err
 	}
-	_, err = os.Stat(outputAbsPath)
-	if err == nil {
-		slog.Debug("action.CheckDiff detected that output already exists", "absPath", outputAbsPath)
-		return errors.New("output file already exists")
+
+	// Ensure output file does not exist (no output is written for a dry run)
+	var outputAbsPath string
+	if !args.DryRun {
+		outputAbsPath, err = filepath.Abs(args.Output)
+		if err != nil {
+			slog.Debug("action.CheckDiff could not get an absolute path for output", "output", args.Output, "error", err)
+			return err
+		}
+		_, err = os.Stat(outputAbsPath)
+		if err == nil {
+			slog.Debug("action.CheckDiff detected that output already exists", "absPath", outputAbsPath)
+			return errors.New("output file already exists")
+		}
 	}
 
 	// Get Pull Request
@@ -176,7 +238,7 @@ func CheckDiff(args *CheckDiffArgs) error {
 	}
 	slog.Info("Retrieved filtered documents", "documents", len(documents))
 
-	// Open repo and resolve head and base references
+	// Open repo
 	var absPath string
 	absPath, err = filepath.Abs(args.Path)
 	if err != nil {
@@ -191,67 +253,156 @@ func CheckDiff(args *CheckDiffArgs) error {
 		return err
 	}
 
-	// Resolve head and base references
-	resolvedHead, err := repo.ResolveRef(r, args.Head, args.HeadRef)
-	if err != nil {
-		slog.Debug("action.CheckDiff could not resolve head reference", "error", err)
-		return err
-	}
-	resolvedBase, err := repo.ResolveRef(r, args.Base, args.BaseRef)
-	if err != nil {
-		slog.Debug("action.CheckDiff could not resolve base reference", "error", err)
-		return err
-	}
+	// Get Diff (of either uncommitted changes or between head and base)
+	var filteredFiles []code.FilteredFile
+	var changedFiles map[string]struct{}
+	var getFileContents check.GetFileContentsHandler
+	var commits []repo.Commit
+	var head, base string
+	if args.Worktree || args.Staged {
+		var changes []repo.WorktreeChange
+		var resolvedHead plumbing.Hash
+		changes, resolvedHead, err = repo.GetWorktreeChanges(r, args.Staged)
+		if err != nil {
+			slog.Debug("action.CheckDiff could not get uncommitted changes", "error", err)
+			return err
+		}
+		filteredFiles, changedFiles = code.GetFilteredWorktreeFiles(changes, &cfg.Check.Code)
+		slog.Info("Retrieved filtered files from uncommitted changes", "files", len(filteredFiles))
 
-	// Get Diff
-	filteredFiles, changedFiles, err := code.GetFilteredDiff(r, *resolvedHead, *resolvedBase, &cfg.Check.Code)
-	if err != nil {
-		slog.Debug("action.CheckDiff could not get filtered diff", "error", err)
-		return err
+		changeMap := make(map[string]repo.WorktreeChange)
+		for _, change := range changes {
+			changeMap[change.Path] = change
+		}
+		getFileContents = func(filename string) (original []byte, current []byte, err error) {
+			change := changeMap[filename]
+			return change.Original, change.Current, nil
+		}
+		head = checkHeadWorktree
+		if args.Staged {
+			head = checkHeadStaged
+		}
+		base = resolvedHead.String()
+	} else {
+		// Resolve head and base references
+		var resolvedHead, resolvedBase *plumbing.Hash
+		resolvedHead, err = repo.ResolveRef(r, args.Head, args.HeadRef)
+		if err != nil {
+			slog.Debug("action.CheckDiff could not resolve head reference", "error", err)
+			return err
+		}
+		resolvedBase, err = repo.ResolveRef(r, args.Base, args.BaseRef)
+		if err != nil {
+			slog.Debug("action.CheckDiff could not resolve base reference", "error", err)
+			return err
+		}
+
+		filteredFiles, changedFiles, err = code.GetFilteredDiff(r, *resolvedHead, *resolvedBase, &cfg.Check.Code)
+		if err != nil {
+			slog.Debug("action.CheckDiff could not get filtered diff", "error", err)
+			return err
+		}
+		slog.Info("Retrieved filtered files from diff", "files", len(filteredFiles))
+
+		getFileContents = func(filename string) (original []byte, current []byte, err error) {
+			original, err = repo.GetFileBytes(*resolvedBase, r, filename)
+			if err != nil {
+				return
+			}
+			current, err = repo.GetFileBytes(*resolvedHead, r, filename)
+			return
+		}
+		// Get the messages of the commits in the diff (if requested)
+		if args.Commits > 0 {
+			commits, err = repo.GetCommits(r, *resolvedHead, *resolvedBase, args.Commits)
+			if err != nil {
+				slog.Debug("action.CheckDiff could not get commits", "error", err)
+				return err
+			}
+			slog.Info("Retrieved commits", "commits", len(commits))
+		}
+
+		head = (*resolvedHead).String()
+		base = (*resolvedBase).String()
 	}
-	slog.Info("Retrieved filtered files from diff", "files", len(filteredFiles))
 
-	// Get recommendations
-	recommendations, _, err := getRecommendations(filteredFiles, documents, pr, issues, changedFiles, cfg.Check, &cfg.LLM)
+	// Detect documentation updated in the diff
+	documentationUpdates := check.DetectDocumentationUpdates(changedFiles, documents, &cfg.Check.Options.DetectDocumentationUpdates, getFileContents)
+
+	// Get recommendations (printing the prompts instead of calling the LLM for a dry run, which
+	// selects relevant documentation using BM25 only rather than calling the embeddings provider)
+	callLLM := llm.WithContext(ctx)
+	embed := llm.EmbedWithContext(ctx)
+	if args.DryRun {
+		callLLM = printPrompts(os.Stdout)
+		embed = nil
+	}
+	recommendations, _, usage, err := getRecommendations(filteredFiles, documents, pr, issues, commits, documentationUpdates, cfg.Check, &cfg.LLM, args.Suggest, callLLM, embed)
 	if err != nil {
 		slog.Debug("action.CheckDiff could not get recommendations", "error", err)
 		return err
 	}
+	if args.DryRun {
+		slog.Info("Dry run complete, the LLM was not called", "files", len(filteredFiles))
+		return nil
+	}
 
 	output := CheckOutput{
 		Recommendations: recommendations,
-		Head:            (*resolvedHead).String(),
-		Base:            (*resolvedBase).String(),
+		Head:            head,
+		Base:            base,
+		Usage:           usage,
 	}
 
 	// Output the results
-	jsonData, err := json.MarshalIndent(output, "", "  ")
-	if err != nil {
-		slog.Debug("action.CheckDiff could not marshal json", "error", err)
-		return err
-	}
 	outputFile, err := os.Create(outputAbsPath)
 	if err != nil {
 		slog.Debug("action.CheckDiff could not open output file", "error", err)
 		return err
 	}
 	defer outputFile.Close()
-	_, err = outputFile.Write(jsonData)
+	err = writeCheckOutput(outputFile, &output, format)
 	if err != nil {
 		slog.Debug("action.CheckDiff could not write output file", "error", err)
 		return err
 	}
-	slog.Info("Output recommendations", "recommendations", len(recommendations), "output", outputAbsPath)
+	slog.Info("Output recommendations", "recommendations", len(recommendations), "output", outputAbsPath, "format", format.String())
+
+	// Fail if there is documentation to review (if requested)
+	if args.ExitCode {
+		unchecked := 0
+		for i := range recommendations {
+			if recommendations[i].Checked || recommendations[i].Outdated {
+				continue
+			}
+			slog.Warn("Documentation may need to be updated", "document", recommendationURI(&recommendations[i]))
+			unchecked++
+		}
+		if unchecked > 0 {
+			return fmt.Errorf("%w (recommendations to review: %d)", ErrRecommendationsFound, unchecked)
+		}
+	}
 
 	return nil
 }
 
-func getRecommendations(filteredFiles []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue, changedFiles map[string]struct{}, checkConfig *config.Check, llmConfig *config.LLM) ([]CheckRecommendation, check.FileCheckContextHashes, error) {
+func getRecommendations(filteredFiles []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue, commits []repo.Commit, documentationUpdates check.DocumentationUpdates, checkConfig *config.Check, llmConfig *config.LLM, suggest bool, callLLM llm.CallLLMHandler, embed llm.EmbedHandler) ([]CheckRecommendation, check.FileCheckContextHashes, *CheckUsage, error) {
+	// Redact secrets before anything is sent to the LLM (if configured)
+	redactor, err := check.NewRedactor(&checkConfig.Options.Redaction)
+	if err != nil {
+		slog.Debug("getRecommendations could not create redactor", "error", err)
+		return nil, nil, nil, err
+	}
+	filteredFiles = redactor.RedactFiles(filteredFiles)
+	pr = redactor.RedactPullRequest(pr)
+	issues = redactor.RedactIssues(issues)
+	commits = redactor.RedactCommits(commits)
+
 	// Check Diff
-	results, fileCheckContextHashes, err := check.Diff(filteredFiles, documents, pr, issues, checkConfig, llmConfig, llm.CallLLM)
+	results, fileCheckContextHashes, diffUsage, err := check.Diff(filteredFiles, documents, pr, issues, commits, checkConfig, llmConfig, callLLM, embed)
 	if err != nil {
 		slog.Debug("getRecommendations could not check diff", "error", err)
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	slog.Info("Got results", "results", len(results))
 
@@ -261,7 +412,7 @@ func getRecommendations(filteredFiles []code.FilteredFile, documents []*docs.Fil
 	for _, result := range results {
 		changed := false
 		if updateSource == result.Source {
-			_, changed = changedFiles[result.Document]
+			changed = documentationUpdates.IsUpdated(result.Document, result.Section)
 		}
 		recommendations = append(recommendations, CheckRecommendation{
 			Source:         result.Source,
@@ -276,5 +427,153 @@ func getRecommendations(filteredFiles []code.FilteredFile, documents []*docs.Fil
 
 	sortCheckRecommendations(recommendations)
 
-	return recommendations, fileCheckContextHashes, nil
+	// Suggest updates (if requested)
+	var suggestionUsage llm.Usage
+	if suggest {
+		used := diffUsage.Embeddings
+		for _, entry := range diffUsage.Files {
+			used.Add(entry.Usage)
+		}
+		suggestionUsage, err = addSuggestions(recommendations, filteredFiles, documents, redactor, checkConfig, llmConfig, used, callLLM)
+		if err != nil {
+			slog.Debug("getRecommendations could not suggest updates", "error", err)
+			return nil, nil, nil, err
+		}
+	}
+
+	// Record the secrets that were redacted
+	if redactor != nil {
+		for _, redaction := range redactor.Redactions() {
+			slog.Warn("Redacted secrets before sending to the LLM", "source", redaction.Source, "name", redaction.Name, "detector", redaction.Detector, "count", redaction.Count)
+		}
+		if auditLog := checkConfig.Options.Redaction.AuditLog; auditLog != "" {
+			err = redactor.WriteAuditLog(auditLog)
+			if err != nil {
+				slog.Debug("getRecommendations could not write redaction audit log", "auditLog", auditLog, "error", err)
+				return nil, nil, nil, err
+			}
+			slog.Info("Output redaction audit log", "auditLog", auditLog)
+		}
+	}
+
+	return recommendations, fileCheckContextHashes, getCheckUsage(diffUsage, suggestionUsage, llmConfig), nil
+}
+
+// printPrompts returns an LLM handler that writes each prompt to w instead of calling the LLM (for
+// dry runs). No tools are called, so the LLM does not add any recommendations.
+func printPrompts(w io.Writer) llm.CallLLMHandler {
+	var mu sync.Mutex
+	return func(systemPrompt string, userPrompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		names := make([]string, 0, len(tools))
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := fmt.Fprintf(w, "=== System Prompt ===\n%s\n\n=== Prompt ===\n%s\n\n=== Tools ===\n%s\n\n", systemPrompt, userPrompt, strings.Join(names, ", "))
+		return "", llm.Usage{}, err
+	}
+}
+
+// addSuggestions asks the LLM to suggest an update for each recommendation (that was not already
+// changed), returning the usage of the suggestions. used is the usage of the check so far, which is
+// counted against the budget (if any).
+func addSuggestions(recommendations []CheckRecommendation, filteredFiles []code.FilteredFile, documents []*docs.FilteredDoc, redactor *check.Redactor, checkConfig *config.Check, llmConfig *config.LLM, used llm.Usage, callLLM llm.CallLLMHandler) (llm.Usage, error) {
+	documentMap := make(map[string]*docs.FilteredDoc)
+	for _, document := range documents {
+		documentMap[document.Document.SourceID+"/"+document.Document.ID] = document
+	}
+
+	budget := llm.NewBudget(llmConfig)
+	err := budget.Add(used)
+	if err != nil {
+		return llm.Usage{}, err
+	}
+
+	suggestionUsage := make([]llm.Usage, len(recommendations))
+	tasks := []func() error{}
+	for i := range recommendations {
+		rec := &recommendations[i]
+		if rec.Changed {
+			continue
+		}
+		document, ok := documentMap[rec.Source+"/"+rec.Document]
+		if !ok {
+			slog.Warn("Could not find document to suggest an update for", "source", rec.Source, "document", rec.Document)
+			continue
+		}
+		if document.Document.Type != config.DocTypeMarkdown.String() {
+			slog.Info("Skipping suggestion, only updates to markdown documents can be suggested", "document", recommendationURI(rec), "type", document.Document.Type)
+			continue
+		}
+		sectionID := strings.Join(rec.Section, "/")
+		filename := check.DocumentFilename(rec.Source, rec.Document, &checkConfig.Options.DetectDocumentationUpdates)
+
+		tasks = append(tasks, func() error {
+			suggestion, usage, err := check.Suggest(document, sectionID, rec.Reasons, filteredFiles, filename, redactor, llmConfig, callLLM)
+			suggestionUsage[i] = usage
+			if err != nil {
+				return fmt.Errorf("could not suggest an update for %s: %w", recommendationURI(rec), err)
+			}
+			rec.Suggestion = suggestion
+
+			return budget.Add(usage)
+		})
+	}
+	slog.Info("Suggesting updates", "recommendations", len(tasks))
+
+	err = llm.NewPool(llmConfig).Run(tasks)
+
+	var total llm.Usage
+	for _, usage := range suggestionUsage {
+		total.Add(usage)
+	}
+
+	return total, err
+}
+
+// getCheckUsage summarizes the LLM usage of each file and of suggestions, as well as the embeddings
+// usage, returning nil if no tokens were used (such as when every call was replayed or cached)
+func getCheckUsage(diffUsage check.DiffUsage, suggestionUsage llm.Usage, llmConfig *config.LLM) *CheckUsage {
+	if len(diffUsage.Files) == 0 && suggestionUsage.IsZero() && diffUsage.Embeddings.IsZero() {
+		return nil
+	}
+
+	var total llm.Usage
+	files := []CheckFileUsage{}
+	for _, entry := range diffUsage.Files {
+		total.Add(entry.Usage)
+		files = append(files, CheckFileUsage{
+			File:         entry.File,
+			UsageSummary: llm.Summarize(entry.Usage, llmConfig),
+		})
+	}
+
+	usage := &CheckUsage{
+		Files: files,
+	}
+	if !suggestionUsage.IsZero() {
+		total.Add(suggestionUsage)
+		suggestions := llm.Summarize(suggestionUsage, llmConfig)
+		usage.Suggestions = &suggestions
+	}
+	if !diffUsage.Embeddings.IsZero() {
+		embeddings := llm.Summarize(diffUsage.Embeddings, nil)
+		usage.Embeddings = &embeddings
+		slog.Info("Embeddings usage", "totalTokens", embeddings.TotalTokens)
+	}
+	usage.Total = llm.Summarize(total, llmConfig)
+	logUsage(usage.Total)
+
+	return usage
+}
+
+// logUsage logs the total LLM usage of a run, including the estimated cost (if any)
+func logUsage(total llm.UsageSummary) {
+	if total.EstimatedCost != nil {
+		slog.Info("LLM usage", "totalTokens", total.TotalTokens, "inputTokens", total.InputTokens, "outputTokens", total.OutputTokens, "estimatedCost", fmt.Sprintf("$%.4f", *total.EstimatedCost))
+		return
+	}
+	slog.Info("LLM usage", "totalTokens", total.TotalTokens, "inputTokens", total.InputTokens, "outputTokens", total.OutputTokens)
 }
@@ -0,0 +1,325 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"hyaline/internal/check"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/github"
+	"hyaline/internal/gitlab"
+	"hyaline/internal/io"
+	"hyaline/internal/llm"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// CHECK_MR_MAX_NOTE_LENGTH is the maximum length of a GitLab note
+const CHECK_MR_MAX_NOTE_LENGTH = 1000000
+
+type CheckMRArgs struct {
+	Config         string
+	Documentation  string
+	MergeRequest   string
+	Issues         []string
+	Output         string
+	OutputCurrent  string
+	OutputPrevious string
+	LLMCache       string
+	Suggest        bool
+}
+
+func CheckMR(args *CheckMRArgs) error {
+	slog.Info("Checking MR",
+		"config", args.Config,
+		"documentation", args.Documentation,
+		"merge-request", args.MergeRequest,
+		"issues", args.Issues,
+		"output", args.Output,
+		"output-current", args.OutputCurrent,
+		"output-previous", args.OutputPrevious,
+	)
+
+	// Load Config
+	cfg, err := config.Load(args.Config, true)
+	if err != nil {
+		slog.Debug("action.CheckMR could not load the config", "error", err)
+		return err
+	}
+
+	// Override the LLM cache directory if one was passed in
+	if args.LLMCache != "" {
+		cfg.LLM.Cache = args.LLMCache
+	}
+
+	// LLM calls are cancelled once llm.timeout.total (if set) has elapsed
+	ctx, cancel := llm.WithTotalTimeout(context.Background(), &cfg.LLM)
+	defer cancel()
+
+	// Ensure check options are set as they are required for this action to run
+	if cfg.Check == nil {
+		slog.Debug("action.CheckMR did not find check options")
+		err = errors.New("the check mr command requires check options be set in the config")
+		return err
+	}
+
+	// If check is disabled, skip
+	if cfg.Check.Disabled {
+		slog.Info("Check disabled. Skipping...")
+		return nil
+	}
+
+	// Ensure GitLab token is available
+	if cfg.GitLab.Token == "" {
+		return errors.New("gitlab token required to retrieve merge-request information")
+	}
+
+	// Initialize output files if provided
+	var outputFile, outputCurrentFile, outputPreviousFile *os.File
+
+	if args.Output != "" {
+		outputFile, err = io.InitOutput(args.Output)
+		if err != nil {
+			slog.Debug("action.CheckMR could not initialize output file", "error", err)
+			return err
+		}
+		defer outputFile.Close()
+	}
+
+	if args.OutputCurrent != "" {
+		outputCurrentFile, err = io.InitOutput(args.OutputCurrent)
+		if err != nil {
+			slog.Debug("action.CheckMR could not initialize output-current file", "error", err)
+			return err
+		}
+		defer outputCurrentFile.Close()
+	}
+
+	if args.OutputPrevious != "" {
+		outputPreviousFile, err = io.InitOutput(args.OutputPrevious)
+		if err != nil {
+			slog.Debug("action.CheckMR could not initialize output-previous file", "error", err)
+			return err
+		}
+		defer outputPreviousFile.Close()
+	}
+
+	// Get Merge Request
+	mr, err := gitlab.GetMergeRequest(args.MergeRequest, &cfg.GitLab)
+	if err != nil {
+		slog.Debug("action.CheckMR could not get merge request", "merge-request", args.MergeRequest, "error", err)
+		return err
+	}
+	slog.Info("Retrieved merge-request", "merge-request", args.MergeRequest)
+
+	// Get Issue(s), including any issues linked to the merge request
+	gitlabIssues, err := gitlab.ListMergeRequestIssues(args.MergeRequest, &cfg.GitLab)
+	if err != nil {
+		slog.Debug("action.CheckMR could not get linked issues", "merge-request", args.MergeRequest, "error", err)
+		return err
+	}
+	slog.Info("Retrieved linked issues", "issues", len(gitlabIssues))
+	if len(args.Issues) > 0 {
+		for _, ref := range args.Issues {
+			issue, err := gitlab.GetIssue(ref, &cfg.GitLab)
+			if err != nil {
+				slog.Debug("action.CheckMR could not get issue", "issue", ref, "error", err)
+				return err
+			}
+			gitlabIssues = append(gitlabIssues, issue)
+		}
+		slog.Info("Retrieved issues", "issues", strings.Join(args.Issues, ", "))
+	}
+
+	// Note: checking a diff only relies on the title and body of the change and its issues,
+	// so the merge request and issues are passed along in the same shape as their GitHub equivalents
+	pr := &github.PullRequest{
+		Title: mr.Title,
+		Body:  mr.Body,
+		Head:  mr.Head,
+		Base:  mr.Base,
+	}
+	issues := []*github.Issue{}
+	for _, issue := range gitlabIssues {
+		issues = append(issues, &github.Issue{
+			Title: issue.Title,
+			Body:  issue.Body,
+		})
+	}
+
+	// Get Documents
+	docDB, close, err := sqlite.InitInput(args.Documentation)
+	if err != nil {
+		slog.Debug("action.CheckMR could not initialize documentation db", "documentation", args.Documentation, "error", err)
+		return err
+	}
+	defer close()
+	documents, err := docs.GetFilteredDocs(&cfg.Check.Documentation, docDB)
+	if err != nil {
+		slog.Debug("action.CheckMR could not get filtered documents", "error", err)
+		return err
+	}
+	slog.Info("Retrieved filtered documents", "documents", len(documents))
+
+	// Get MR Files
+	filteredFiles, changedFiles, err := code.GetFilteredMRFiles(args.MergeRequest, &cfg.GitLab, &cfg.Check.Code)
+	if err != nil {
+		slog.Debug("action.CheckMR could not get filtered MR files", "error", err)
+		return err
+	}
+	slog.Info("Retrieved filtered files from MR", "files", len(filteredFiles))
+
+	// Detect documentation updated in the MR
+	getFileContents := func(filename string) (original []byte, current []byte, err error) {
+		original, err = gitlab.GetFileContents(args.MergeRequest, filename, mr.Base, &cfg.GitLab)
+		if err != nil {
+			return
+		}
+		current, err = gitlab.GetFileContents(args.MergeRequest, filename, mr.Head, &cfg.GitLab)
+		return
+	}
+	documentationUpdates := check.DetectDocumentationUpdates(changedFiles, documents, &cfg.Check.Options.DetectDocumentationUpdates, getFileContents)
+
+	// Get recommendations
+	recommendations, fileCheckContextHashes, usage, err := getRecommendations(filteredFiles, documents, pr, issues, nil, documentationUpdates, cfg.Check, &cfg.LLM, args.Suggest, llm.WithContext(ctx), llm.EmbedWithContext(ctx))
+	if err != nil {
+		slog.Debug("action.CheckMR could not get recommendations", "error", err)
+		return err
+	}
+	slog.Info("Retrieved recommendations", "recommendations", len(recommendations))
+
+	// Get existing Hyaline note
+	existingNote, err := findHyalineNote(args.MergeRequest, &cfg.GitLab)
+	if err != nil {
+		slog.Debug("action.CheckMR could not search for existing Hyaline note", "error", err)
+		return err
+	}
+
+	// Get previous recommendations from existing note if available
+	var previousOutput *CheckOutput
+	if existingNote != nil {
+		slog.Info("Retrieving previous recommendations from note", "noteID", existingNote.ID)
+		previousOutput, err = parseCheckPRComment(existingNote.Body)
+		if err != nil {
+			slog.Debug("action.CheckMR could not parse existing note", "error", err)
+			return err
+		}
+	}
+
+	previousRecommendations := []CheckRecommendation{}
+	if previousOutput != nil {
+		slog.Info("Found previous recommendations", "recommendations", len(previousOutput.Recommendations))
+		previousRecommendations = previousOutput.Recommendations
+	}
+
+	// Merge current and previous recommendations
+	mergedRecommendations := mergeCheckRecommendations(recommendations, previousRecommendations, fileCheckContextHashes)
+
+	mergedOutput := CheckOutput{
+		Recommendations: mergedRecommendations,
+		Head:            mr.Head,
+		Base:            mr.Base,
+	}
+
+	slog.Info("Merged recommendations", "mergedRecommendations", len(mergedRecommendations))
+
+	err = upsertMRNote(args.MergeRequest, existingNote, mergedOutput, &cfg.GitLab)
+	if err != nil {
+		slog.Debug("action.CheckMR could not upsert MR note", "error", err)
+		return err
+	}
+
+	// Write merged recommendations to output file if provided
+	if outputFile != nil {
+		output := mergedOutput
+		output.Usage = usage
+
+		err = io.WriteJSON(outputFile, output)
+		if err != nil {
+			slog.Debug("action.CheckMR could not write merged recommendations to output file", "error", err)
+			return err
+		}
+		slog.Info("Output merged recommendations", "recommendations", len(mergedRecommendations), "output", args.Output)
+	}
+
+	// Write current recommendations to output-current file if provided
+	if outputCurrentFile != nil {
+		output := CheckOutput{
+			Recommendations: recommendations,
+			Head:            mr.Head,
+			Base:            mr.Base,
+			Usage:           usage,
+		}
+
+		err = io.WriteJSON(outputCurrentFile, output)
+		if err != nil {
+			slog.Debug("action.CheckMR could not write current recommendations to output file", "error", err)
+			return err
+		}
+		slog.Info("Output current recommendations", "recommendations", len(recommendations), "output", args.OutputCurrent)
+	}
+
+	// Write previous recommendations to output-previous file if provided
+	if outputPreviousFile != nil {
+		if previousOutput == nil {
+			// Write empty JSON object when no previous output exists
+			_, err = outputPreviousFile.Write([]byte("{}"))
+			if err != nil {
+				slog.Debug("action.CheckMR could not write empty previous recommendations to output file", "error", err)
+				return err
+			}
+		} else {
+			err = io.WriteJSON(outputPreviousFile, previousOutput)
+			if err != nil {
+				slog.Debug("action.CheckMR could not write previous recommendations to output file", "error", err)
+				return err
+			}
+		}
+		slog.Info("Output previous recommendations", "recommendations", len(previousRecommendations), "output", args.OutputPrevious)
+	}
+
+	return nil
+}
+
+func upsertMRNote(mr string, existingNote *gitlab.Note, output CheckOutput, cfg *config.GitLab) error {
+	formattedNote := formatCheckComment(&output, "MR", CHECK_MR_MAX_NOTE_LENGTH)
+
+	if existingNote != nil {
+		slog.Info("Updating existing MR note", "noteID", existingNote.ID)
+		err := gitlab.UpdateNote(mr, existingNote.ID, formattedNote, cfg)
+		if err != nil {
+			slog.Debug("upsertMRNote could not update note", "mr", mr, "noteID", existingNote.ID, "error", err)
+			return err
+		}
+	} else {
+		slog.Info("Adding new MR note")
+		err := gitlab.AddNote(mr, formattedNote, cfg)
+		if err != nil {
+			slog.Debug("upsertMRNote could not add note", "mr", mr, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findHyalineNote(ref string, cfg *config.GitLab) (*gitlab.Note, error) {
+	// Get all notes for the MR
+	notes, err := gitlab.ListNotes(ref, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search for a (non-system) note that starts with the Hyaline header (with zero-width spaces)
+	for _, note := range notes {
+		if !note.System && strings.HasPrefix(note.Body, CHECK_PR_HYALINE_HEADER) {
+			return &note, nil
+		}
+	}
+
+	// No Hyaline note found
+	return nil, nil
+}
@@ -1,6 +1,7 @@
 package action
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"hyaline/internal/docs"
 	"hyaline/internal/github"
 	"hyaline/internal/io"
+	"hyaline/internal/llm"
 	"hyaline/internal/sqlite"
 	"log/slog"
 	"os"
@@ -22,6 +24,13 @@ const CHECK_PR_CDATA_START = "<![CDATA[ "
 const CHECK_PR_CDATA_END = " ]]>"
 const CHECK_PR_RECOMMENDATIONS_START = "### Recommendations"
 
+// CHECK_PR_MAX_COMMENT_LENGTH is the maximum length of a GitHub comment
+const CHECK_PR_MAX_COMMENT_LENGTH = 65536
+
+// CHECK_PR_MAX_SUGGESTION_LENGTH is the maximum length of the diff of each suggestion shown in a
+// comment, so that a single large suggestion does not take up the entire comment
+const CHECK_PR_MAX_SUGGESTION_LENGTH = 8192
+
 type CheckPRArgs struct {
 	Config         string
 	Documentation  string
@@ -30,6 +39,8 @@ type CheckPRArgs struct {
 	Output         string
 	OutputCurrent  string
 	OutputPrevious string
+	LLMCache       string
+	Suggest        bool
 }
 
 func CheckPR(args *CheckPRArgs) error {
@@ -50,6 +61,15 @@ func CheckPR(args *CheckPRArgs) error {
 		return err
 	}
 
+	// Override the LLM cache directory if one was passed in
+	if args.LLMCache != "" {
+		cfg.LLM.Cache = args.LLMCache
+	}
+
+	// LLM calls are cancelled once llm.timeout.total (if set) has elapsed
+	ctx, cancel := llm.WithTotalTimeout(context.Background(), &cfg.LLM)
+	defer cancel()
+
 	// Ensure check options are set as they are required for this action to run
 	if cfg.Check == nil {
 		slog.Debug("action.CheckPR did not find check options")
@@ -142,8 +162,19 @@ func CheckPR(args *CheckPRArgs) error {
 	}
 	slog.Info("Retrieved filtered files from PR", "files", len(filteredFiles))
 
+	// Detect documentation updated in the PR
+	getFileContents := func(filename string) (original []byte, current []byte, err error) {
+		original, err = github.GetFileContents(args.PullRequest, filename, pr.Base, cfg.GitHub.Token)
+		if err != nil {
+			return
+		}
+		current, err = github.GetFileContents(args.PullRequest, filename, pr.Head, cfg.GitHub.Token)
+		return
+	}
+	documentationUpdates := check.DetectDocumentationUpdates(changedFiles, documents, &cfg.Check.Options.DetectDocumentationUpdates, getFileContents)
+
 	// Get recommendations
-	recommendations, fileCheckContextHashes, err := getRecommendations(filteredFiles, documents, pr, issues, changedFiles, cfg.Check, &cfg.LLM)
+	recommendations, fileCheckContextHashes, usage, err := getRecommendations(filteredFiles, documents, pr, issues, nil, documentationUpdates, cfg.Check, &cfg.LLM, args.Suggest, llm.WithContext(ctx), llm.EmbedWithContext(ctx))
 	if err != nil {
 		slog.Debug("action.CheckPR could not get recommendations", "error", err)
 		return err
@@ -197,6 +228,7 @@ func CheckPR(args *CheckPRArgs) error {
 			Recommendations: mergedRecommendations,
 			Head:            pr.Head,
 			Base:            pr.Base,
+			Usage:           usage,
 		}
 
 		err = io.WriteJSON(outputFile, output)
@@ -213,6 +245,7 @@ func CheckPR(args *CheckPRArgs) error {
 			Recommendations: recommendations,
 			Head:            pr.Head,
 			Base:            pr.Base,
+			Usage:           usage,
 		}
 
 		err = io.WriteJSON(outputCurrentFile, output)
@@ -289,8 +322,13 @@ func mergeCheckRecommendations(newRecs []CheckRecommendation, existingRecs []Che
 				match = true
 				// Merge recommendations
 				updatedMergedRec := mergedRec
-				updatedMergedRec.Checked = existingRec.Checked
+				// Recommendations for documentation updated in this change stay checked
+				updatedMergedRec.Checked = existingRec.Checked || mergedRec.Changed
 				updatedMergedRec.Reasons = mergeCheckReasons(&mergedRec.Reasons, &existingRec.Reasons, fileCheckContextHashes)
+				// Keep a previous suggestion if one was not made this time
+				if updatedMergedRec.Suggestion == nil {
+					updatedMergedRec.Suggestion = existingRec.Suggestion
+				}
 				mergedRecs[index] = updatedMergedRec
 				break
 			}
@@ -454,10 +492,44 @@ func parseCheckPRComment(comment string) (output *CheckOutput, err error) {
 }
 
 func formatCheckPRComment(output *CheckOutput) string {
+	return formatCheckComment(output, "PR", CHECK_PR_MAX_COMMENT_LENGTH)
+}
+
+// formatCheckComment formats the recommendations as a comment for a change, where changeName is
+// the short name of the type of change the comment is being left on (e.g. PR or MR). Only the
+// (truncated) diff of each suggestion is included, as that is all that is shown in the comment
+// and kept by the next check. If the comment is longer than maxLength, suggestions are removed
+// starting with the last recommendation until it fits.
+func formatCheckComment(output *CheckOutput, changeName string, maxLength int) string {
+	commentOutput := *output
+	commentOutput.Recommendations = make([]CheckRecommendation, len(output.Recommendations))
+	for i, rec := range output.Recommendations {
+		if rec.Suggestion != nil {
+			rec.Suggestion = &check.Suggestion{Diff: truncateSuggestionDiff(rec.Suggestion.Diff)}
+		}
+		commentOutput.Recommendations[i] = rec
+	}
+
+	comment := formatCheckCommentRecommendations(&commentOutput, changeName)
+	for i := len(commentOutput.Recommendations) - 1; i >= 0 && len(comment) > maxLength; i-- {
+		if commentOutput.Recommendations[i].Suggestion == nil {
+			continue
+		}
+		commentOutput.Recommendations[i].Suggestion = nil
+		comment = formatCheckCommentRecommendations(&commentOutput, changeName)
+	}
+	if len(comment) > maxLength {
+		slog.Warn("Comment is longer than the maximum comment length", "length", len(comment), "maxLength", maxLength)
+	}
+
+	return comment
+}
+
+func formatCheckCommentRecommendations(output *CheckOutput, changeName string) string {
 	var md strings.Builder
 
 	// Note: The comment MUST start with the Hyaline header
-	md.WriteString(CHECK_PR_HYALINE_HEADER + " PR Check\n")
+	md.WriteString(fmt.Sprintf("%s %s Check\n", CHECK_PR_HYALINE_HEADER, changeName))
 	md.WriteString(fmt.Sprintf("**ref**: %s\n", html.EscapeString(output.Head)))
 	md.WriteString("\n")
 
@@ -487,12 +559,18 @@ func formatCheckPRComment(output *CheckOutput) string {
 			sections := formatSections(rec.Section)
 			reasons := formatReasons(rec.Reasons)
 			md.WriteString(fmt.Sprintf("- [%s] **%s**%s in `%s`", checked, html.EscapeString(rec.Document), html.EscapeString(sections), html.EscapeString(rec.Source)))
+			if rec.Changed {
+				md.WriteString(fmt.Sprintf(" (updated in this %s)", changeName))
+			}
 			md.WriteString(fmt.Sprintf("<details><summary>Reasons</summary><ul><li>%s</li></ul></details>", reasons))
+			if rec.Suggestion != nil && !rec.Checked {
+				md.WriteString(formatSuggestion(rec.Suggestion))
+			}
 			md.WriteString("\n")
 		}
-		md.WriteString("\nNote: Hyaline will automatically detect documentation updated in this PR and mark corresponding recommendations as reviewed.\n")
+		md.WriteString(fmt.Sprintf("\nNote: Hyaline will automatically detect documentation updated in this %s and mark corresponding recommendations as reviewed.\n", changeName))
 	} else if len(outdatedRecs) == 0 {
-		md.WriteString("Hyaline did not find any documentation related to the contents of this PR. If you are aware of documentation that should have been updated please update it and let your Hyaline administrator know about this message. Thanks!\n")
+		md.WriteString(fmt.Sprintf("Hyaline did not find any documentation related to the contents of this %s. If you are aware of documentation that should have been updated please update it and let your Hyaline administrator know about this message. Thanks!\n", changeName))
 	}
 
 	// Render outdated recommendations section if any exist
@@ -516,6 +594,43 @@ func formatCheckPRComment(output *CheckOutput) string {
 	return md.String()
 }
 
+// formatSuggestion formats a suggested update as a collapsible diff. Each line is indented so that
+// it stays within the recommendation's list item (and is not mistaken for a recommendation).
+func formatSuggestion(suggestion *check.Suggestion) string {
+	// Use a fence longer than any run of backticks in the diff
+	longestRun, run := 0, 0
+	for _, c := range suggestion.Diff {
+		if c == '`' {
+			run++
+			longestRun = max(longestRun, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longestRun+1))
+
+	var md strings.Builder
+	md.WriteString("<details><summary>Suggested update</summary>\n\n")
+	md.WriteString(fmt.Sprintf("  %sdiff\n", fence))
+	for _, line := range strings.Split(strings.TrimSuffix(suggestion.Diff, "\n"), "\n") {
+		md.WriteString(fmt.Sprintf("  %s\n", line))
+	}
+	md.WriteString(fmt.Sprintf("  %s\n\n  </details>", fence))
+
+	return md.String()
+}
+
+// truncateSuggestionDiff truncates diff to the last complete line within
+// CHECK_PR_MAX_SUGGESTION_LENGTH, noting that it was truncated
+func truncateSuggestionDiff(diff string) string {
+	if len(diff) <= CHECK_PR_MAX_SUGGESTION_LENGTH {
+		return diff
+	}
+	end := strings.LastIndex(diff[:CHECK_PR_MAX_SUGGESTION_LENGTH], "\n") + 1
+
+	return diff[:end] + "... (truncated, see the output of check with --suggest for the full suggestion)\n"
+}
+
 func formatSections(sections []string) string {
 	if len(sections) > 0 {
 		return fmt.Sprintf(" > %s", strings.Join(sections, " > "))
@@ -1,6 +1,7 @@
 package action
 
 import (
+	"fmt"
 	"hyaline/internal/check"
 	"strings"
 	"testing"
@@ -134,6 +135,36 @@ func TestMergeCheckRecommendations_MergeMatching(t *testing.T) {
 	}
 }
 
+func TestMergeCheckRecommendations_ChangedStaysChecked(t *testing.T) {
+	newRec := CheckRecommendation{
+		Source:   "docs",
+		Document: "README.md",
+		Reasons:  []check.Reason{createTestReason("New LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+		Changed:  true,
+		Checked:  true,
+	}
+
+	existingRec := CheckRecommendation{
+		Source:   "docs",
+		Document: "README.md",
+		Reasons:  []check.Reason{createTestReason("New LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+		Checked:  false,
+	}
+
+	contextHashes := check.FileCheckContextHashes{
+		"main.go": {check.DiffCheckTypeLLM: "hash1"},
+	}
+
+	result := mergeCheckRecommendations([]CheckRecommendation{newRec}, []CheckRecommendation{existingRec}, contextHashes)
+
+	if len(result) != 1 {
+		t.Errorf("Expected 1 recommendation, got %d", len(result))
+	}
+	if !result[0].Checked {
+		t.Errorf("Expected recommendation for changed documentation to be checked")
+	}
+}
+
 func TestMergeCheckRecommendations_DifferentDocuments(t *testing.T) {
 	newRec := CheckRecommendation{
 		Source:   "docs",
@@ -310,3 +341,127 @@ func TestCheckPRParseComment(t *testing.T) {
 		t.Errorf("Expected outdated rec to have 2 reasons, got %d", len(outdatedRec.Reasons))
 	}
 }
+
+func TestCheckPRFormatCommentChanged(t *testing.T) {
+	output := CheckOutput{
+		Recommendations: []CheckRecommendation{
+			{
+				Source:   "docs",
+				Document: "README.md",
+				Reasons:  []check.Reason{createTestReason("Reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+				Changed:  true,
+				Checked:  true,
+			},
+			{
+				Source:   "docs",
+				Document: "USAGE.md",
+				Reasons:  []check.Reason{createTestReason("Reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+			},
+		},
+		Head: "feat-1",
+		Base: "main",
+	}
+
+	formattedComment := formatCheckPRComment(&output)
+
+	if !strings.Contains(formattedComment, "- [x] **README.md** in `docs` (updated in this PR)") {
+		t.Errorf("Expected changed recommendation to be marked as updated in this PR")
+	}
+	if strings.Contains(formattedComment, "**USAGE.md** in `docs` (updated in this PR)") {
+		t.Errorf("Expected unchanged recommendation to not be marked as updated in this PR")
+	}
+
+	parsedOutput, err := parseCheckPRComment(formattedComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsedOutput.Recommendations[0].Checked || !parsedOutput.Recommendations[0].Changed {
+		t.Errorf("Expected changed recommendation to round trip as checked and changed")
+	}
+}
+
+func TestCheckPRFormatCommentSuggestion(t *testing.T) {
+	output := CheckOutput{
+		Recommendations: []CheckRecommendation{
+			{
+				Source:     "docs",
+				Document:   "README.md",
+				Reasons:    []check.Reason{createTestReason("Reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+				Suggestion: &check.Suggestion{Content: "Updated", Diff: "--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-- [link](old)\n+```go\n"},
+			},
+			{
+				Source:   "docs",
+				Document: "USAGE.md",
+				Reasons:  []check.Reason{createTestReason("Reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+			},
+		},
+		Head: "feat-1",
+		Base: "main",
+	}
+
+	formattedComment := formatCheckPRComment(&output)
+
+	if !strings.Contains(formattedComment, "<details><summary>Suggested update</summary>\n\n  ````diff\n  --- a/README.md\n") {
+		t.Errorf("Expected the suggestion to be rendered as an indented diff with a longer fence, got: %s", formattedComment)
+	}
+	if strings.Count(formattedComment, "Suggested update") != 1 {
+		t.Errorf("Expected only recommendations with a suggestion to include one")
+	}
+
+	// Lines of the diff must not be parsed as recommendations
+	formattedComment = strings.Replace(formattedComment, "- [ ] **USAGE.md**", "- [x] **USAGE.md**", 1)
+	parsedOutput, err := parseCheckPRComment(formattedComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedOutput.Recommendations[0].Checked || !parsedOutput.Recommendations[1].Checked {
+		t.Errorf("Expected only the second recommendation to be checked")
+	}
+	if parsedOutput.Recommendations[0].Suggestion == nil || parsedOutput.Recommendations[0].Suggestion.Diff != output.Recommendations[0].Suggestion.Diff {
+		t.Errorf("Expected the suggestion's diff to round trip")
+	}
+	// Only the diff is kept in the raw data
+	if parsedOutput.Recommendations[0].Suggestion != nil && parsedOutput.Recommendations[0].Suggestion.Content != "" {
+		t.Errorf("Expected the suggestion's content to not be included in the comment")
+	}
+	if output.Recommendations[0].Suggestion.Content != "Updated" {
+		t.Errorf("Expected formatting the comment to not modify the suggestion")
+	}
+}
+
+func TestCheckPRFormatCommentSuggestionLength(t *testing.T) {
+	largeDiff := "--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n" + strings.Repeat("+A line of the suggested update\n", 1000)
+	output := CheckOutput{Head: "feat-1", Base: "main"}
+	for i := range 10 {
+		output.Recommendations = append(output.Recommendations, CheckRecommendation{
+			Source:     "docs",
+			Document:   fmt.Sprintf("doc-%d.md", i),
+			Reasons:    []check.Reason{createTestReason("Reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+			Suggestion: &check.Suggestion{Content: "Updated", Diff: largeDiff},
+		})
+	}
+
+	formattedComment := formatCheckPRComment(&output)
+
+	if len(formattedComment) > CHECK_PR_MAX_COMMENT_LENGTH {
+		t.Errorf("Expected the comment to be at most %d characters, got %d", CHECK_PR_MAX_COMMENT_LENGTH, len(formattedComment))
+	}
+	if !strings.Contains(formattedComment, "... (truncated") {
+		t.Errorf("Expected long diffs to be truncated")
+	}
+
+	// Suggestions are removed starting with the last recommendation
+	parsedOutput, err := parseCheckPRComment(formattedComment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsedOutput.Recommendations) != 10 {
+		t.Fatalf("Expected all 10 recommendations to be kept, got %d", len(parsedOutput.Recommendations))
+	}
+	if parsedOutput.Recommendations[0].Suggestion == nil || len(parsedOutput.Recommendations[0].Suggestion.Diff) > CHECK_PR_MAX_SUGGESTION_LENGTH+100 {
+		t.Errorf("Expected the first recommendation to keep its truncated suggestion")
+	}
+	if parsedOutput.Recommendations[9].Suggestion != nil {
+		t.Errorf("Expected the last recommendation's suggestion to be removed")
+	}
+}
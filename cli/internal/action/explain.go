@@ -0,0 +1,156 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"hyaline/internal/check"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/sqlite"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type ExplainArgs struct {
+	Config        string
+	Documentation string
+	Added         []string
+	Modified      []string
+	Deleted       []string
+	Renamed       []string
+}
+
+func Explain(args *ExplainArgs) error {
+	slog.Info("Explaining check",
+		"config", args.Config,
+		"documentation", args.Documentation,
+		"added", args.Added,
+		"modified", args.Modified,
+		"deleted", args.Deleted,
+		"renamed", args.Renamed)
+
+	// Load Config
+	cfg, err := config.Load(args.Config, true)
+	if err != nil {
+		slog.Debug("action.Explain could not load the config", "error", err)
+		return err
+	}
+
+	// Ensure check options are set as they are required for this action to run
+	if cfg.Check == nil {
+		slog.Debug("action.Explain did not find check options")
+		return errors.New("the explain command requires check options be set in the config")
+	}
+	if cfg.Check.Disabled {
+		slog.Warn("Check is disabled, so no files would be checked")
+	}
+
+	// Build the changed files
+	files := []code.FilteredFile{}
+	for _, filename := range args.Added {
+		files = append(files, code.FilteredFile{Filename: filename, Action: code.ActionInsert})
+	}
+	for _, filename := range args.Modified {
+		files = append(files, code.FilteredFile{Filename: filename, OriginalFilename: filename, Action: code.ActionModify})
+	}
+	for _, filename := range args.Deleted {
+		files = append(files, code.FilteredFile{OriginalFilename: filename, Action: code.ActionDelete})
+	}
+	for _, renamed := range args.Renamed {
+		original, filename, found := strings.Cut(renamed, ":")
+		if !found || original == "" || filename == "" {
+			return fmt.Errorf("renamed files must be in the format ORIGINAL:NEW, found: %s", renamed)
+		}
+		files = append(files, code.FilteredFile{Filename: filename, OriginalFilename: original, Action: code.ActionRename})
+	}
+
+	// Get Documents
+	docDB, close, err := sqlite.InitInput(args.Documentation)
+	if err != nil {
+		slog.Debug("action.Explain could not initialize documentation db", "documentation", args.Documentation, "error", err)
+		return err
+	}
+	defer close()
+	documents, err := docs.GetFilteredDocs(&cfg.Check.Documentation, docDB)
+	if err != nil {
+		slog.Debug("action.Explain could not get filtered documents", "error", err)
+		return err
+	}
+	slog.Info("Retrieved filtered documents", "documents", len(documents))
+
+	// Explain each file
+	for _, file := range files {
+		explanation := check.Explain(file, documents, cfg.Check)
+		err = writeExplanation(os.Stdout, &explanation)
+		if err != nil {
+			slog.Debug("action.Explain could not write explanation", "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// explainActions are the names of each action as used in the config
+var explainActions = map[code.Action]string{
+	code.ActionInsert: "added",
+	code.ActionModify: "modified",
+	code.ActionDelete: "deleted",
+	code.ActionRename: "renamed",
+}
+
+// writeExplanation writes a human readable explanation of how a file would be checked to w
+func writeExplanation(w io.Writer, explanation *check.Explanation) error {
+	var str strings.Builder
+
+	file := explanation.File
+	switch file.Action {
+	case code.ActionDelete:
+		fmt.Fprintf(&str, "%s (%s)\n", file.OriginalFilename, explainActions[file.Action])
+	case code.ActionRename:
+		fmt.Fprintf(&str, "%s -> %s (%s)\n", file.OriginalFilename, file.Filename, explainActions[file.Action])
+	default:
+		fmt.Fprintf(&str, "%s (%s)\n", file.Filename, explainActions[file.Action])
+	}
+
+	// Whether the file is checked
+	if explanation.Included {
+		str.WriteString("  Checked: yes\n")
+	} else {
+		str.WriteString("  Checked: no\n")
+	}
+	if explanation.Include != "" {
+		fmt.Fprintf(&str, "    included by %s\n", explanation.Include)
+	} else {
+		str.WriteString("    not included by any check.code.include entry\n")
+	}
+	if explanation.Exclude != "" {
+		fmt.Fprintf(&str, "    excluded by %s\n", explanation.Exclude)
+	}
+
+	// The updateIf entries that match
+	if len(explanation.UpdateIfs) == 0 {
+		str.WriteString("  UpdateIf: no entries match\n")
+	} else {
+		str.WriteString("  UpdateIf:\n")
+		if !explanation.Included {
+			str.WriteString("    (these entries are not applied because the file is not checked)\n")
+		}
+		for _, updateIf := range explanation.UpdateIfs {
+			fmt.Fprintf(&str, "    %s: %s\n", updateIf.Location, updateIf.Path)
+			if len(updateIf.Documents) == 0 {
+				str.WriteString("      (no documents or sections in scope match this entry's documentation filter)\n")
+			}
+			for _, document := range updateIf.Documents {
+				fmt.Fprintf(&str, "      %s\n", document)
+			}
+		}
+	}
+	str.WriteString("\n")
+
+	_, err := io.WriteString(w, str.String())
+	return err
+}
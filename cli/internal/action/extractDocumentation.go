@@ -11,12 +11,13 @@ import (
 )
 
 type ExtractDocumentationArgs struct {
-	Config string
-	Output string
+	Config   string
+	Output   string
+	Previous string
 }
 
 func ExtractDocumentation(args *ExtractDocumentationArgs) error {
-	slog.Info("Extracting documentation", "config", args.Config, "output", args.Output)
+	slog.Info("Extracting documentation", "config", args.Config, "output", args.Output, "previous", args.Previous)
 
 	// Load Config
 	cfg, err := config.Load(args.Config, true)
@@ -46,8 +47,20 @@ func ExtractDocumentation(args *ExtractDocumentationArgs) error {
 	}
 	defer close()
 
+	// Open the previous database (if any) so unchanged documents can be reused
+	var previousDb *sqlite.Queries
+	if args.Previous != "" {
+		var closePrevious func() error
+		previousDb, closePrevious, err = sqlite.InitInput(args.Previous)
+		if err != nil {
+			slog.Debug("action.ExtractDocumentation could not initialize previous", "previous", args.Previous, "error", err)
+			return err
+		}
+		defer closePrevious()
+	}
+
 	// Extract documentation
-	err = extract.Documentation(cfg.Extract, docDb)
+	err = extract.Documentation(cfg.Extract, docDb, previousDb)
 	if err != nil {
 		slog.Debug("action.ExtractDocumentation could not extract documentation", "error", err)
 		return err
@@ -0,0 +1,253 @@
+package action
+
+import (
+	"fmt"
+	"hyaline/internal/check"
+	"hyaline/internal/docs"
+	"hyaline/internal/io"
+	"hyaline/internal/report"
+	"os"
+	"sort"
+	"strings"
+)
+
+type OutputFormatType string
+
+func (t OutputFormatType) String() string {
+	return string(t)
+}
+
+func (t OutputFormatType) IsValid() bool {
+	switch t {
+	case OutputFormatJson, OutputFormatSarif, OutputFormatJUnit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t OutputFormatType) PossibleValues() string {
+	return fmt.Sprintf("%s, %s, %s", OutputFormatJson, OutputFormatSarif, OutputFormatJUnit)
+}
+
+const (
+	OutputFormatJson  OutputFormatType = "json"
+	OutputFormatSarif OutputFormatType = "sarif"
+	OutputFormatJUnit OutputFormatType = "junit"
+)
+
+// getOutputFormat validates the requested format, defaulting to json if it is not set
+func getOutputFormat(format string) (OutputFormatType, error) {
+	if format == "" {
+		return OutputFormatJson, nil
+	}
+	outputFormat := OutputFormatType(format)
+	if !outputFormat.IsValid() {
+		return outputFormat, fmt.Errorf("invalid format, got: %s, wanted one of: %s", format, outputFormat.PossibleValues())
+	}
+
+	return outputFormat, nil
+}
+
+func writeCheckOutput(file *os.File, output *CheckOutput, format OutputFormatType) error {
+	switch format {
+	case OutputFormatSarif:
+		return report.WriteSARIF(file, formatCheckOutputSARIF(output))
+	case OutputFormatJUnit:
+		return report.WriteJUnit(file, formatCheckOutputJUnit(output))
+	default:
+		return io.WriteJSON(file, output)
+	}
+}
+
+func writeAuditOutput(file *os.File, output *AuditOutput, format OutputFormatType) error {
+	switch format {
+	case OutputFormatSarif:
+		return report.WriteSARIF(file, formatAuditOutputSARIF(output))
+	case OutputFormatJUnit:
+		return report.WriteJUnit(file, formatAuditOutputJUnit(output))
+	default:
+		return io.WriteJSON(file, output)
+	}
+}
+
+func recommendationURI(rec *CheckRecommendation) string {
+	uri := docs.DocumentURI{
+		SourceID:     rec.Source,
+		DocumentPath: rec.Document,
+		Section:      strings.Join(rec.Section, "/"),
+	}
+	return uri.String()
+}
+
+// formatCheckOutputSARIF maps each reason of each recommendation to a SARIF result. The result is
+// located at the code file that triggered the recommendation, and is related to the document
+// (or section) that should be reviewed via its document URI.
+func formatCheckOutputSARIF(output *CheckOutput) *report.SARIF {
+	rules := []report.SARIFRule{}
+	seenRules := make(map[string]struct{})
+	results := []report.SARIFResult{}
+
+	for _, rec := range output.Recommendations {
+		uri := recommendationURI(&rec)
+		for _, reason := range rec.Reasons {
+			ruleID := string(reason.Check.Type)
+			if _, ok := seenRules[ruleID]; !ok {
+				seenRules[ruleID] = struct{}{}
+				rules = append(rules, report.SARIFRule{
+					ID:               ruleID,
+					ShortDescription: report.SARIFMessage{Text: checkTypeDescription(reason.Check.Type)},
+				})
+			}
+
+			// Recommendations that have already been addressed are downgraded to notes
+			level := report.SARIFLevelWarning
+			if rec.Changed || rec.Outdated || reason.Outdated {
+				level = report.SARIFLevelNote
+			}
+
+			results = append(results, report.SARIFResult{
+				RuleID:  ruleID,
+				Level:   level,
+				Message: report.SARIFMessage{Text: fmt.Sprintf("%s: %s (%s)", rec.Recommendation, uri, reason.Reason)},
+				Locations: []report.SARIFLocation{
+					report.NewSARIFLocation(0, reason.Check.File, ""),
+				},
+				RelatedLocations: []report.SARIFLocation{
+					report.NewSARIFLocation(1, uri, "Documentation to review"),
+				},
+				Properties: map[string]interface{}{
+					"documentationSource": rec.Source,
+					"document":            rec.Document,
+					"section":             rec.Section,
+					"changed":             rec.Changed,
+					"checked":             rec.Checked,
+					"outdated":            rec.Outdated || reason.Outdated,
+				},
+			})
+		}
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return report.NewSARIF("Hyaline", rules, results)
+}
+
+func checkTypeDescription(checkType check.DiffCheckType) string {
+	switch checkType {
+	case check.DiffCheckTypeLLM:
+		return "Documentation may need to be updated based on the contents of the change"
+	case check.DiffCheckTypeUpdateIfTouched:
+		return "Documentation should be updated when matching files are touched"
+	case check.DiffCheckTypeUpdateIfAdded:
+		return "Documentation should be updated when matching files are added"
+	case check.DiffCheckTypeUpdateIfModified:
+		return "Documentation should be updated when matching files are modified"
+	case check.DiffCheckTypeUpdateIfDeleted:
+		return "Documentation should be updated when matching files are deleted"
+	case check.DiffCheckTypeUpdateIfRenamed:
+		return "Documentation should be updated when matching files are renamed"
+	default:
+		return string(checkType)
+	}
+}
+
+// formatCheckOutputJUnit maps each recommendation to a test case, which fails unless the
+// corresponding documentation was changed as a part of the diff
+func formatCheckOutputJUnit(output *CheckOutput) *report.JUnitTestSuites {
+	testCases := []report.JUnitTestCase{}
+	for _, rec := range output.Recommendations {
+		testCase := report.JUnitTestCase{
+			Name:      recommendationURI(&rec),
+			ClassName: rec.Source,
+		}
+		reasons := []string{}
+		for _, reason := range rec.Reasons {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", reason.Check.File, reason.Reason))
+		}
+		if rec.Changed {
+			testCase.SystemOut = strings.Join(reasons, "\n")
+		} else {
+			testCase.Failure = &report.JUnitFailure{
+				Message: rec.Recommendation,
+				Type:    "recommendation",
+				Text:    strings.Join(reasons, "\n"),
+			}
+		}
+		testCases = append(testCases, testCase)
+	}
+
+	suites := &report.JUnitTestSuites{Name: "hyaline check diff"}
+	suites.AddSuite("recommendations", testCases)
+
+	return suites
+}
+
+// formatAuditOutputSARIF maps each rule to a SARIF rule and each check to a result located at the
+// document URI that was checked
+func formatAuditOutputSARIF(output *AuditOutput) *report.SARIF {
+	rules := []report.SARIFRule{}
+	results := []report.SARIFResult{}
+
+	for _, ruleResult := range output.Results {
+		rules = append(rules, report.SARIFRule{
+			ID:               ruleResult.Rule,
+			ShortDescription: report.SARIFMessage{Text: ruleResult.Description},
+		})
+		for _, checkResult := range ruleResult.Checks {
+			kind := report.SARIFKindFail
+			level := report.SARIFLevelError
+			if checkResult.Pass {
+				kind = report.SARIFKindPass
+				level = report.SARIFLevelNone
+			}
+			result := report.SARIFResult{
+				RuleID:  ruleResult.Rule,
+				Kind:    kind,
+				Level:   level,
+				Message: report.SARIFMessage{Text: fmt.Sprintf("%s: %s", checkResult.Check, checkResult.Message)},
+				Properties: map[string]interface{}{
+					"check": checkResult.Check,
+				},
+			}
+			// Note: checks that did not match any documentation do not have a URI
+			if checkResult.URI != "" {
+				result.Locations = []report.SARIFLocation{
+					report.NewSARIFLocation(0, checkResult.URI, ""),
+				}
+			}
+			results = append(results, result)
+		}
+	}
+
+	return report.NewSARIF("Hyaline", rules, results)
+}
+
+// formatAuditOutputJUnit maps each rule to a test suite with one test case per check
+func formatAuditOutputJUnit(output *AuditOutput) *report.JUnitTestSuites {
+	suites := &report.JUnitTestSuites{Name: "hyaline audit documentation"}
+	for _, ruleResult := range output.Results {
+		testCases := []report.JUnitTestCase{}
+		for _, checkResult := range ruleResult.Checks {
+			testCase := report.JUnitTestCase{
+				Name:      strings.TrimSpace(fmt.Sprintf("%s %s", checkResult.Check, checkResult.URI)),
+				ClassName: ruleResult.Rule,
+			}
+			if checkResult.Pass {
+				testCase.SystemOut = checkResult.Message
+			} else {
+				testCase.Failure = &report.JUnitFailure{
+					Message: checkResult.Message,
+					Type:    checkResult.Check,
+					Text:    ruleResult.Description,
+				}
+			}
+			testCases = append(testCases, testCase)
+		}
+		suites.AddSuite(ruleResult.Rule, testCases)
+	}
+
+	return suites
+}
@@ -0,0 +1,117 @@
+package action
+
+import (
+	"hyaline/internal/check"
+	"testing"
+)
+
+func TestGetOutputFormat(t *testing.T) {
+	var tests = []struct {
+		format   string
+		expected OutputFormatType
+		err      bool
+	}{
+		{"", OutputFormatJson, false},
+		{"json", OutputFormatJson, false},
+		{"sarif", OutputFormatSarif, false},
+		{"junit", OutputFormatJUnit, false},
+		{"xml", "", true},
+	}
+
+	for i, test := range tests {
+		format, err := getOutputFormat(test.format)
+		if (err != nil) != test.err {
+			t.Errorf("(%d) expected error %t, got %v", i, test.err, err)
+			continue
+		}
+		if !test.err && format != test.expected {
+			t.Errorf("(%d) expected %s, got %s", i, test.expected, format)
+		}
+	}
+}
+
+func TestFormatCheckOutputSARIF(t *testing.T) {
+	output := &CheckOutput{
+		Recommendations: []CheckRecommendation{
+			{
+				Source:         "docs",
+				Document:       "README.md",
+				Section:        []string{"Usage", "Install"},
+				Recommendation: "Consider reviewing and updating this documentation",
+				Reasons: []check.Reason{
+					createTestReason("LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false),
+					createTestReason("Touched reason", check.DiffCheckTypeUpdateIfTouched, "cmd/app.go", "hash2", false),
+				},
+			},
+			{
+				Source:         "docs",
+				Document:       "CHANGELOG.md",
+				Recommendation: "Consider reviewing and updating this documentation",
+				Reasons: []check.Reason{
+					createTestReason("LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false),
+				},
+				Changed: true,
+			},
+		},
+	}
+
+	sarif := formatCheckOutputSARIF(output)
+
+	if len(sarif.Runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(sarif.Runs))
+	}
+	run := sarif.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("expected 2 rules, got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if first.RuleID != string(check.DiffCheckTypeLLM) || first.Level != "warning" {
+		t.Errorf("expected an LLM warning, got %s %s", first.RuleID, first.Level)
+	}
+	if first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "main.go" {
+		t.Errorf("expected result to be located at main.go, got %s", first.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	if first.RelatedLocations[0].PhysicalLocation.ArtifactLocation.URI != "document://docs/README.md#Usage/Install" {
+		t.Errorf("expected result to be related to the section uri, got %s", first.RelatedLocations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	if run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI != "cmd/app.go" {
+		t.Errorf("expected second result to be located at cmd/app.go, got %s", run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	if run.Results[2].Level != "note" {
+		t.Errorf("expected changed recommendation to be a note, got %s", run.Results[2].Level)
+	}
+}
+
+func TestFormatCheckOutputJUnit(t *testing.T) {
+	output := &CheckOutput{
+		Recommendations: []CheckRecommendation{
+			{
+				Source:   "docs",
+				Document: "README.md",
+				Reasons:  []check.Reason{createTestReason("LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+			},
+			{
+				Source:   "docs",
+				Document: "CHANGELOG.md",
+				Reasons:  []check.Reason{createTestReason("LLM reason", check.DiffCheckTypeLLM, "main.go", "hash1", false)},
+				Changed:  true,
+			},
+		},
+	}
+
+	junit := formatCheckOutputJUnit(output)
+
+	if junit.Tests != 2 || junit.Failures != 1 {
+		t.Errorf("expected 2 tests and 1 failure, got %d tests and %d failures", junit.Tests, junit.Failures)
+	}
+	if junit.Suites[0].TestCases[0].Name != "document://docs/README.md" || junit.Suites[0].TestCases[0].Failure == nil {
+		t.Errorf("expected README.md to fail")
+	}
+	if junit.Suites[0].TestCases[1].Failure != nil {
+		t.Errorf("expected changed CHANGELOG.md to pass")
+	}
+}
@@ -1,6 +1,7 @@
 package action
 
 import (
+	"context"
 	"fmt"
 	"hyaline/internal/github"
 	"hyaline/internal/io"
@@ -9,7 +10,9 @@ import (
 	"hyaline/internal/sqlite"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 )
 
 type ServeMCPArgs struct {
@@ -18,6 +21,8 @@ type ServeMCPArgs struct {
 	GitHubArtifact     string
 	GitHubArtifactPath string
 	GitHubToken        string
+	Listen             string
+	AuthToken          string
 }
 
 func ServeMCP(args *ServeMCPArgs, version string) error {
@@ -27,6 +32,8 @@ func ServeMCP(args *ServeMCPArgs, version string) error {
 		"githubRepo", args.GitHubRepo,
 		"githubArtifact", args.GitHubArtifact,
 		"githubArtifactPath", args.GitHubArtifactPath,
+		"listen", args.Listen,
+		"authToken", args.AuthToken != "",
 		"version", version,
 	))
 
@@ -84,9 +91,20 @@ func ServeMCP(args *ServeMCPArgs, version string) error {
 		slog.Debug("action.ServeMCP could not create MCP server", "error", err)
 		return err
 	}
+	defer server.Close()
 
-	// Start server using stdio transport
-	err = server.ServeStdio()
+	if args.Listen != "" {
+		// Start server using HTTP transport, shutting down gracefully when interrupted
+		if args.AuthToken == "" {
+			slog.Warn("MCP server is listening without authentication. Consider setting an auth token.")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = server.ServeHTTPTransport(ctx, args.Listen, args.AuthToken)
+	} else {
+		// Start server using stdio transport
+		err = server.ServeStdio()
+	}
 	if err != nil {
 		slog.Debug("action.ServeMCP server error", "error", err)
 		return err
@@ -24,7 +24,7 @@ type checkPromptMismatchSchema struct {
 }
 
 // ContentMatchesPrompt uses LLM to validate content against a custom prompt
-func ContentMatchesPrompt(sourceID, documentID string, sectionID string, prompt string, content string, cfg *config.LLM) (bool, string, error) {
+func ContentMatchesPrompt(sourceID, documentID string, sectionID string, prompt string, content string, cfg *config.LLM, callLLM llm.CallLLMHandler) (bool, string, llm.Usage, error) {
 	slog.Debug("audit.checks.ContentMatchesPrompt starting", "document", documentID, "section", sectionID)
 
 	var matches bool
@@ -115,11 +115,11 @@ func ContentMatchesPrompt(sourceID, documentID string, sectionID string, prompt
 	// Call LLM
 	userPrompt := userPromptBuilder.String()
 	slog.Debug("audit.checks.ContentMatchesPrompt calling the llm")
-	_, err := llm.CallLLM(systemPrompt, userPrompt, tools, cfg)
+	_, usage, err := callLLM(systemPrompt, userPrompt, tools, cfg)
 	if err != nil {
 		slog.Debug("audit.checks.ContentMatchesPrompt encountered an error when calling the llm", "error", err)
-		return false, "", err
+		return false, "", usage, err
 	}
 
-	return matches, reason, nil
+	return matches, reason, usage, nil
 }
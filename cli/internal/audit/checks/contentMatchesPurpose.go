@@ -24,7 +24,7 @@ type checkPurposeMismatchSchema struct {
 }
 
 // ContentMatchesPurpose validates content matches its stated purpose using LLM
-func ContentMatchesPurpose(sourceID, documentID string, sectionID string, purpose string, content string, cfg *config.LLM) (bool, string, error) {
+func ContentMatchesPurpose(sourceID, documentID string, sectionID string, purpose string, content string, cfg *config.LLM, callLLM llm.CallLLMHandler) (bool, string, llm.Usage, error) {
 	slog.Debug("audit.checks.ContentMatchesPurpose starting", "document", documentID, "section", sectionID)
 
 	var matches bool
@@ -115,11 +115,11 @@ func ContentMatchesPurpose(sourceID, documentID string, sectionID string, purpos
 	// Call LLM
 	userPrompt := prompt.String()
 	slog.Debug("audit.checks.ContentMatchesPurpose calling the llm")
-	_, err := llm.CallLLM(systemPrompt, userPrompt, tools, cfg)
+	_, usage, err := callLLM(systemPrompt, userPrompt, tools, cfg)
 	if err != nil {
 		slog.Debug("audit.checks.ContentMatchesPurpose encountered an error when calling the llm", "error", err)
-		return false, "", err
+		return false, "", usage, err
 	}
 
-	return matches, reason, nil
+	return matches, reason, usage, nil
 }
@@ -2,13 +2,16 @@ package audit
 
 import (
 	"context"
+	"fmt"
 	"hyaline/internal/audit/checks"
 	"hyaline/internal/config"
 	"hyaline/internal/docs"
+	"hyaline/internal/llm"
 	"hyaline/internal/sqlite"
 	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -41,33 +44,69 @@ type AuditCheckResult struct {
 	Message  string   `json:"message"`
 }
 
-// Documentation executes the audit process against the provided database
-func Documentation(cfg *config.Config, db *sqlite.Queries, sources []string) ([]AuditRuleResult, error) {
+// RuleUsage is the LLM usage when checking a rule
+type RuleUsage struct {
+	Rule  string
+	Usage llm.Usage
+}
+
+// llmChecks collects the LLM checks found while processing rules so that they can be run
+// (concurrently) once all rules have been processed, tracking the usage of each rule as they run
+type llmChecks struct {
+	tasks   []func() error
+	callLLM llm.CallLLMHandler
+	budget  *llm.Budget
+	mu      sync.Mutex
+	usage   map[string]llm.Usage
+}
+
+// add adds a check for rule, recording its usage and failing once the budget (if any) is exceeded
+func (c *llmChecks) add(rule string, check func() (llm.Usage, error)) {
+	c.tasks = append(c.tasks, func() error {
+		usage, err := check()
+
+		c.mu.Lock()
+		ruleUsage := c.usage[rule]
+		ruleUsage.Add(usage)
+		c.usage[rule] = ruleUsage
+		c.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		return c.budget.Add(usage)
+	})
+}
+
+// Documentation executes the audit process against the provided database (using callLLM for any
+// LLM checks), returning the results of each rule along with the LLM usage of each rule (if any)
+func Documentation(cfg *config.Config, db *sqlite.Queries, sources []string, callLLM llm.CallLLMHandler) ([]AuditRuleResult, []RuleUsage, error) {
 	slog.Debug("audit.Documentation starting")
 
 	// Load all data from database
 	documents, err := db.GetAllDocuments(context.Background())
 	if err != nil {
 		slog.Debug("audit.Documentation could not get all documents", "error", err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	documentTags, err := db.GetAllDocumentTags(context.Background())
 	if err != nil {
 		slog.Debug("audit.Documentation could not get all document tags", "error", err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	sections, err := db.GetAllSections(context.Background())
 	if err != nil {
 		slog.Debug("audit.Documentation could not get all sections", "error", err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	sectionTags, err := db.GetAllSectionTags(context.Background())
 	if err != nil {
 		slog.Debug("audit.Documentation could not get all section tags", "error", err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Filter data by sources if specified
@@ -82,7 +121,15 @@ func Documentation(cfg *config.Config, db *sqlite.Queries, sources []string) ([]
 	documentTagMap := docs.GetDocumentTagMap(documentTags)
 	sectionTagMap := docs.GetSectionTagMap(sectionTags)
 
-	results := []AuditRuleResult{}
+	ruleResults := []*AuditRuleResult{}
+
+	// LLM checks are collected while processing rules and run once all rules have been processed
+	// (so that they can run concurrently)
+	llmChecks := &llmChecks{
+		callLLM: callLLM,
+		budget:  llm.NewBudget(&cfg.LLM),
+		usage:   map[string]llm.Usage{},
+	}
 
 	// Process each rule
 	for i, configRule := range cfg.Audit.Rules {
@@ -96,17 +143,37 @@ func Documentation(cfg *config.Config, db *sqlite.Queries, sources []string) ([]
 
 		slog.Info("audit.Documentation processing rule", "ruleID", rule.ID)
 
-		ruleResult := AuditRuleResult{
+		ruleResult := &AuditRuleResult{
 			Rule:        rule.ID,
 			Description: rule.Description,
 			Checks:      []AuditCheckResult{},
 		}
 
 		// Process the rule
-		err := processRule(&rule, documents, documentTagMap, sections, sectionTagMap, &ruleResult, cfg)
+		err := processRule(&rule, documents, documentTagMap, sections, sectionTagMap, ruleResult, llmChecks, cfg)
 		if err != nil {
 			slog.Debug("audit.Documentation error processing rule", "ruleID", rule.ID, "error", err)
-			return nil, err
+			return nil, nil, err
+		}
+
+		ruleResults = append(ruleResults, ruleResult)
+	}
+
+	// Run the LLM checks
+	err = llm.NewPool(&cfg.LLM).Run(llmChecks.tasks)
+	if err != nil {
+		slog.Debug("audit.Documentation error running llm checks", "error", err)
+		return nil, nil, err
+	}
+
+	results := []AuditRuleResult{}
+	usage := []RuleUsage{}
+	for _, ruleResult := range ruleResults {
+		if ruleUsage := llmChecks.usage[ruleResult.Rule]; !ruleUsage.IsZero() {
+			usage = append(usage, RuleUsage{
+				Rule:  ruleResult.Rule,
+				Usage: ruleUsage,
+			})
 		}
 
 		// Calculate rule pass status based on checks
@@ -118,14 +185,14 @@ func Documentation(cfg *config.Config, db *sqlite.Queries, sources []string) ([]
 			}
 		}
 
-		results = append(results, ruleResult)
+		results = append(results, *ruleResult)
 	}
 
 	slog.Info("audit.Documentation completed")
-	return results, nil
+	return results, usage, nil
 }
 
-func processRule(rule *config.AuditRule, documents []sqlite.DOCUMENT, documentTagMap map[string][]docs.FilteredTag, sections []sqlite.SECTION, sectionTagMap map[string][]docs.FilteredTag, ruleResult *AuditRuleResult, cfg *config.Config) error {
+func processRule(rule *config.AuditRule, documents []sqlite.DOCUMENT, documentTagMap map[string][]docs.FilteredTag, sections []sqlite.SECTION, sectionTagMap map[string][]docs.FilteredTag, ruleResult *AuditRuleResult, llmChecks *llmChecks, cfg *config.Config) error {
 	// Track if we found any matches for CONTENT_EXISTS check
 	var firstMatchSource, firstMatchDocument string
 	var firstMatchSection []string
@@ -163,7 +230,7 @@ func processRule(rule *config.AuditRule, documents []sqlite.DOCUMENT, documentTa
 				}).String(),
 				Rule: rule.ID,
 			}
-			err := performContentChecks(rule, baseResult, document.SourceID, document.ID, "", document.ExtractedData, document.Purpose, ruleResult, cfg)
+			err := performContentChecks(rule, baseResult, document.SourceID, document.ID, "", document.ExtractedData, document.Purpose, ruleResult, llmChecks, cfg)
 			if err != nil {
 				return err
 			}
@@ -209,7 +276,7 @@ func processRule(rule *config.AuditRule, documents []sqlite.DOCUMENT, documentTa
 				}).String(),
 				Rule: rule.ID,
 			}
-			err := performContentChecks(rule, baseResult, section.SourceID, section.DocumentID, section.ID, section.ExtractedData, section.Purpose, ruleResult, cfg)
+			err := performContentChecks(rule, baseResult, section.SourceID, section.DocumentID, section.ID, section.ExtractedData, section.Purpose, ruleResult, llmChecks, cfg)
 			if err != nil {
 				return err
 			}
@@ -244,7 +311,9 @@ func processRule(rule *config.AuditRule, documents []sqlite.DOCUMENT, documentTa
 	return nil
 }
 
-func performContentChecks(rule *config.AuditRule, baseResult AuditCheckResult, sourceID, documentID, sectionID, content, purpose string, ruleResult *AuditRuleResult, cfg *config.Config) error {
+// performContentChecks performs the content checks for a document or section. LLM checks are
+// added to ruleResult immediately, but are only performed once their task in llmChecks is run.
+func performContentChecks(rule *config.AuditRule, baseResult AuditCheckResult, sourceID, documentID, sectionID, content, purpose string, ruleResult *AuditRuleResult, llmChecks *llmChecks, cfg *config.Config) error {
 	// CONTENT_MIN_LENGTH check
 	if rule.Checks.Content.MinLength > 0 {
 		pass, message := checks.ContentMinLength(content, rule.Checks.Content.MinLength)
@@ -275,35 +344,43 @@ func performContentChecks(rule *config.AuditRule, baseResult AuditCheckResult, s
 
 	// CONTENT_MATCHES_PROMPT check
 	if rule.Checks.Content.MatchesPrompt != "" {
-		pass, message, err := checks.ContentMatchesPrompt(sourceID, documentID, sectionID, rule.Checks.Content.MatchesPrompt, content, &cfg.LLM)
-		if err != nil {
-			slog.Debug("audit.performContentChecks error in CONTENT_MATCHES_PROMPT", "error", err)
-			return err
-		}
-
 		checkResult := baseResult
 		checkResult.Check = CheckContentMatchesPrompt
-		checkResult.Pass = pass
-		checkResult.Message = message
-
 		ruleResult.Checks = append(ruleResult.Checks, checkResult)
+		index := len(ruleResult.Checks) - 1
+
+		llmChecks.add(rule.ID, func() (llm.Usage, error) {
+			pass, message, usage, err := checks.ContentMatchesPrompt(sourceID, documentID, sectionID, rule.Checks.Content.MatchesPrompt, content, &cfg.LLM, llmChecks.callLLM)
+			if err != nil {
+				slog.Debug("audit.performContentChecks error in CONTENT_MATCHES_PROMPT", "rule", rule.ID, "uri", baseResult.URI, "error", err)
+				return usage, fmt.Errorf("could not check %s for rule %s against %s: %w", CheckContentMatchesPrompt, rule.ID, baseResult.URI, err)
+			}
+
+			ruleResult.Checks[index].Pass = pass
+			ruleResult.Checks[index].Message = message
+			return usage, nil
+		})
 	}
 
 	// CONTENT_MATCHES_PURPOSE check
 	if rule.Checks.Content.MatchesPurpose {
 		if purpose != "" {
-			pass, message, err := checks.ContentMatchesPurpose(sourceID, documentID, sectionID, purpose, content, &cfg.LLM)
-			if err != nil {
-				slog.Debug("audit.performContentChecks error in CONTENT_MATCHES_PURPOSE", "error", err)
-				return err
-			}
-
 			checkResult := baseResult
 			checkResult.Check = CheckContentMatchesPurpose
-			checkResult.Pass = pass
-			checkResult.Message = message
-
 			ruleResult.Checks = append(ruleResult.Checks, checkResult)
+			index := len(ruleResult.Checks) - 1
+
+			llmChecks.add(rule.ID, func() (llm.Usage, error) {
+				pass, message, usage, err := checks.ContentMatchesPurpose(sourceID, documentID, sectionID, purpose, content, &cfg.LLM, llmChecks.callLLM)
+				if err != nil {
+					slog.Debug("audit.performContentChecks error in CONTENT_MATCHES_PURPOSE", "rule", rule.ID, "uri", baseResult.URI, "error", err)
+					return usage, fmt.Errorf("could not check %s for rule %s against %s: %w", CheckContentMatchesPurpose, rule.ID, baseResult.URI, err)
+				}
+
+				ruleResult.Checks[index].Pass = pass
+				ruleResult.Checks[index].Message = message
+				return usage, nil
+			})
 		}
 	}
 
@@ -0,0 +1,140 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"hyaline/internal/diff"
+	"hyaline/internal/docs"
+	"hyaline/internal/extract"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// ErrSuggestionConflict is returned when a suggestion conflicts with changes made to the
+// documentation since it was extracted
+var ErrSuggestionConflict = errors.New("the suggestion conflicts with changes made to the documentation since it was extracted")
+
+// ApplySuggestion splices a suggestion into the markdown in place of the section with the given ID
+// (or in place of the entire document if sectionID is empty). The suggestion was made against the
+// extracted contents of document, so if the markdown has drifted since it was extracted the
+// suggestion is merged with those changes and drifted is true. If the changes conflict
+// ErrSuggestionConflict is returned.
+func ApplySuggestion(markdown string, document *docs.FilteredDoc, sectionID string, suggestion *Suggestion) (updated string, drifted bool, err error) {
+	// Line endings are normalized in the same way as when extracting markdown, and restored after
+	crlf := strings.Contains(markdown, "\r\n")
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	lines := strings.Split(markdown, "\n")
+
+	// Locate the section (and the section's original extracted contents)
+	original := document.Document.ExtractedData
+	start, end := 0, len(lines)
+	if sectionID != "" {
+		section := findSection(document.Sections, sectionID)
+		if section == nil {
+			err = fmt.Errorf("could not find section %s in document %s", sectionID, document.Document.ID)
+			slog.Debug("check.ApplySuggestion could not find extracted section", "error", err)
+			return
+		}
+		original = section.Section.ExtractedData
+
+		var ok bool
+		start, end, ok = extract.FindMarkdownSection(lines, sectionID)
+		if !ok {
+			err = fmt.Errorf("could not find section %s in the current contents of document %s", sectionID, document.Document.ID)
+			slog.Debug("check.ApplySuggestion could not find section", "error", err)
+			return
+		}
+		// The extracted contents of a section do not include its heading
+		start++
+	}
+
+	// Keep any whitespace surrounding the section (such as the blank lines before the next heading)
+	current := strings.Join(lines[start:end], "\n")
+	trimmed := strings.TrimSpace(current)
+	leading := current[:strings.Index(current, trimmed)]
+	trailing := current[len(leading)+len(trimmed):]
+
+	base := strings.TrimSpace(original)
+	content := strings.TrimSpace(suggestion.Content)
+	if trimmed != base {
+		drifted = true
+		content, err = mergeSuggestion(base, content, trimmed)
+		if err != nil {
+			slog.Debug("check.ApplySuggestion could not merge suggestion", "document", document.Document.ID, "section", sectionID, "error", err)
+			return
+		}
+	}
+
+	replacement := strings.Split(leading+content+trailing, "\n")
+	updated = strings.Join(slices.Concat(lines[:start], replacement, lines[end:]), "\n")
+	if crlf {
+		updated = strings.ReplaceAll(updated, "\n", "\r\n")
+	}
+
+	return
+}
+
+// mergeSuggestion performs a three way merge of the suggested and current contents, which were both
+// changed from base. Edits are merged line by line so that changes to the same line conflict.
+func mergeSuggestion(base string, suggested string, current string) (string, error) {
+	base += "\n"
+	suggestedEdits := lineEdits(base, diff.Strings(base, suggested+"\n"))
+	currentEdits := lineEdits(base, diff.Strings(base, current+"\n"))
+
+	merged, ok := diff.Merge(suggestedEdits, currentEdits)
+	if !ok {
+		return "", ErrSuggestionConflict
+	}
+	result, err := diff.Apply(base, merged)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(result, "\n"), nil
+}
+
+// lineEdits expands edits (which must be sorted and not overlap, as returned by diff.Strings) so
+// that each edit replaces one or more complete lines of src, combining edits to the same line
+func lineEdits(src string, edits []diff.Edit) []diff.Edit {
+	if len(edits) == 0 {
+		return edits
+	}
+
+	expanded := make([]diff.Edit, 0, len(edits))
+	prev := edits[0]
+	for _, edit := range edits[1:] {
+		between := src[prev.End:edit.Start]
+		if strings.Contains(between, "\n") {
+			expanded = append(expanded, expandLineEdit(src, prev))
+			prev = edit
+			continue
+		}
+		// The edits change the same line, so combine them
+		prev.New += between + edit.New
+		prev.End = edit.End
+	}
+
+	return append(expanded, expandLineEdit(src, prev))
+}
+
+// expandLineEdit returns edit expanded to the start of its first line and the end of its last line
+func expandLineEdit(src string, edit diff.Edit) diff.Edit {
+	start := edit.Start
+	if column := start - 1 - strings.LastIndex(src[:start], "\n"); column > 0 {
+		edit.Start -= column
+		edit.New = src[edit.Start:start] + edit.New
+	}
+
+	end := edit.End
+	if end > 0 && src[end-1] != '\n' || edit.New != "" && !strings.HasSuffix(edit.New, "\n") {
+		if newline := strings.IndexByte(src[end:], '\n'); newline == -1 {
+			edit.End = len(src)
+		} else {
+			edit.End = end + newline + 1
+		}
+	}
+	edit.New += src[end:edit.End]
+
+	return edit
+}
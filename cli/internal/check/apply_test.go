@@ -0,0 +1,107 @@
+package check
+
+import (
+	"errors"
+	"hyaline/internal/diff"
+	"reflect"
+	"testing"
+)
+
+func TestApplySuggestion(t *testing.T) {
+	document := suggestTestDocument()
+	suggestion := &Suggestion{Content: "Install using pnpm install"}
+
+	var tests = []struct {
+		markdown string
+		expected string
+		drifted  bool
+	}{
+		// Unchanged since extraction
+		{
+			"# App\n\n## Installation\n\nInstall using npm install\n\n## Usage\n\nRun app start\n",
+			"# App\n\n## Installation\n\nInstall using pnpm install\n\n## Usage\n\nRun app start\n",
+			false,
+		},
+		// Changed outside of the section
+		{
+			"# App\n\nAn app\n\n## Installation\n\nInstall using npm install\n\n## Usage\n\nRun app start --port 80\n",
+			"# App\n\nAn app\n\n## Installation\n\nInstall using pnpm install\n\n## Usage\n\nRun app start --port 80\n",
+			false,
+		},
+		// Changed within the section without conflicting
+		{
+			"# App\n\n## Installation\n\nRequires node.\n\nInstall using npm install\n\n## Usage\n\nRun app start\n",
+			"# App\n\n## Installation\n\nRequires node.\n\nInstall using pnpm install\n\n## Usage\n\nRun app start\n",
+			true,
+		},
+		// Line endings are kept
+		{
+			"# App\r\n\r\n## Installation\r\n\r\nInstall using npm install\r\n\r\n## Usage\r\n\r\nRun app start\r\n",
+			"# App\r\n\r\n## Installation\r\n\r\nInstall using pnpm install\r\n\r\n## Usage\r\n\r\nRun app start\r\n",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		updated, drifted, err := ApplySuggestion(test.markdown, document, "App/Installation", suggestion)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if updated != test.expected {
+			t.Errorf("test %d - expected %q, got %q", i, test.expected, updated)
+		}
+		if drifted != test.drifted {
+			t.Errorf("test %d - expected drifted to be %t, got %t", i, test.drifted, drifted)
+		}
+	}
+}
+
+func TestApplySuggestion_Document(t *testing.T) {
+	markdown := "# App\n\n## Installation\n\nInstall using npm install\n\n## Usage\n\nRun app start\n"
+	updated, drifted, err := ApplySuggestion(markdown, suggestTestDocument(), "", &Suggestion{Content: "# App\n\nSee the website"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if updated != "# App\n\nSee the website\n" || drifted {
+		t.Errorf("expected the document to be replaced, got %q (drifted %t)", updated, drifted)
+	}
+}
+
+func TestApplySuggestion_Conflict(t *testing.T) {
+	markdown := "# App\n\n## Installation\n\nInstall using yarn install\n\n## Usage\n\nRun app start\n"
+	_, _, err := ApplySuggestion(markdown, suggestTestDocument(), "App/Installation", &Suggestion{Content: "Install using pnpm install"})
+	if !errors.Is(err, ErrSuggestionConflict) {
+		t.Errorf("expected a conflict, got: %v", err)
+	}
+}
+
+func TestApplySuggestion_MissingSection(t *testing.T) {
+	markdown := "# App\n\n## Setup\n\nInstall using npm install\n"
+	_, _, err := ApplySuggestion(markdown, suggestTestDocument(), "App/Installation", &Suggestion{Content: "Install using pnpm install"})
+	if err == nil {
+		t.Errorf("expected an error when the section no longer exists")
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	src := "one\ntwo three\nfour\n"
+
+	var tests = []struct {
+		edits    []diff.Edit
+		expected []diff.Edit
+	}{
+		{[]diff.Edit{}, []diff.Edit{}},
+		{[]diff.Edit{{Start: 4, End: 8, New: ""}}, []diff.Edit{{Start: 4, End: 14, New: "three\n"}}},
+		{[]diff.Edit{{Start: 4, End: 7, New: "2"}, {Start: 8, End: 13, New: "3"}}, []diff.Edit{{Start: 4, End: 14, New: "2 3\n"}}},
+		{[]diff.Edit{{Start: 0, End: 3, New: "1"}, {Start: 14, End: 18, New: "4"}}, []diff.Edit{{Start: 0, End: 4, New: "1\n"}, {Start: 14, End: 19, New: "4\n"}}},
+		{[]diff.Edit{{Start: 19, End: 19, New: "five"}}, []diff.Edit{{Start: 19, End: 19, New: "five"}}},
+	}
+
+	for i, test := range tests {
+		result := lineEdits(src, test.edits)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("test %d - expected %v, got %v", i, test.expected, result)
+		}
+	}
+}
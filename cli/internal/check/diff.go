@@ -10,7 +10,9 @@ import (
 	"hyaline/internal/docs"
 	"hyaline/internal/github"
 	"hyaline/internal/llm"
+	"hyaline/internal/repo"
 	"log/slog"
+	"sort"
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -55,6 +57,19 @@ type Result struct {
 const checkNeedsUpdateName = "needs_update"
 const checkNoUpdateNeededName = "no_update_needed"
 
+// FileUsage is the LLM usage when checking a file
+type FileUsage struct {
+	File  string
+	Usage llm.Usage
+}
+
+// DiffUsage is the usage when checking a diff, which is made up of the LLM usage when checking
+// each file and the embeddings usage when selecting relevant documentation (if configured)
+type DiffUsage struct {
+	Files      []FileUsage
+	Embeddings llm.Usage
+}
+
 type checkNeedsUpdateSchema struct {
 	Entries []checkNeedsUpdateSchemaEntry `json:"entries" jsonschema:"title=The list of entries,description=The list of documents and/or sections that need to be updated along with the reason for each update"`
 }
@@ -69,7 +84,13 @@ type checkNoUpdateNeededSchema struct {
 
 type updateResultMapCallback func(id string, reason string, check DiffCheck)
 
-func Diff(files []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue, checkCfg *config.Check, llmCfg *config.LLM, callLLM llm.CallLLMHandler) (results []Result, fileCheckContextHashes FileCheckContextHashes, err error) {
+type pendingUpdate struct {
+	id     string
+	reason string
+	check  DiffCheck
+}
+
+func Diff(files []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue, commits []repo.Commit, checkCfg *config.Check, llmCfg *config.LLM, callLLM llm.CallLLMHandler, embed llm.EmbedHandler) (results []Result, fileCheckContextHashes FileCheckContextHashes, usage DiffUsage, err error) {
 	resultMap := make(map[string][]Reason)
 	fileCheckContextHashes = make(FileCheckContextHashes)
 	validIDs := buildValidIDMap(documents)
@@ -109,16 +130,64 @@ func Diff(files []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.P
 	// LLM system prompt and tools
 	systemPrompt := "You are a senior technical writer who writes clear and accurate documentation."
 
+	// Updates are buffered per file and applied in file order once all checks are complete, so
+	// that results are deterministic even though LLM checks run concurrently
+	fileUpdates := make([][]pendingUpdate, len(files))
+	tasks := make([]func() error, 0, len(files))
+
+	// Usage is tracked per file, and the run is aborted once the budget (if any) is exceeded
+	fileUsage := make([]llm.Usage, len(files))
+	budget := llm.NewBudget(llmCfg)
+
+	// Index the documentation so that only the most relevant documentation is included in each
+	// prompt (if configured). Documentation is only embedded when embed is not nil.
+	relevance, embedUsage, err := newRelevanceIndex(documents, &checkCfg.Options.Relevance, llmCfg, embed)
+	usage.Embeddings.Add(embedUsage)
+	if err != nil {
+		slog.Debug("check.Diff could not index documentation for relevance", "error", err)
+		return
+	}
+	err = budget.Add(embedUsage)
+	if err != nil {
+		slog.Debug("check.Diff exceeded the llm budget when embedding documentation", "error", err)
+		return
+	}
+
 	// Check each file in the diff
-	for _, file := range files {
+	for i, file := range files {
 		slog.Info("Checking file", "filename", file.Filename, "originalFilename", file.OriginalFilename)
+		bufferUpdate := func(id string, reason string, check DiffCheck) {
+			fileUpdates[i] = append(fileUpdates[i], pendingUpdate{
+				id:     id,
+				reason: reason,
+				check:  check,
+			})
+		}
 
 		// See if there are any updateIfs that apply
-		checkNewUpdateIfs(&file, documents, checkCfg, updateResultMap)
+		checkNewUpdateIfs(&file, documents, checkCfg, bufferUpdate)
+
+		// Select the documentation relevant to this file (if configured)
+		promptDocuments := documents
+		if relevance != nil {
+			var selected []string
+			promptDocuments, selected, embedUsage, err = relevance.selectRelevant(getRelevanceQuery(file, pr, issues, commits))
+			usage.Embeddings.Add(embedUsage)
+			if err != nil {
+				slog.Debug("check.Diff could not select relevant documentation", "file", file.Filename, "error", err)
+				return
+			}
+			err = budget.Add(embedUsage)
+			if err != nil {
+				slog.Debug("check.Diff exceeded the llm budget when embedding a change", "file", file.Filename, "error", err)
+				return
+			}
+			slog.Debug("check.Diff selected relevant documentation", "file", file.Filename, "selected", len(selected), "total", len(relevance.entries), "ids", selected)
+		}
 
 		// Ask LLM for documentation that should be updated for this diff
 		var prompt string
-		prompt, err = formatCheckPrompt(file, documents, pr, issues)
+		prompt, err = formatCheckPrompt(file, promptDocuments, pr, issues, commits)
 		if err != nil {
 			slog.Debug("check.Diff could not format prompt", "error", err)
 			return
@@ -134,17 +203,60 @@ func Diff(files []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.P
 		}
 		// Always track the context hash for LLM checks, since they are non-deterministic
 		updateFileCheckContextHashes(check)
-		tools := getCheckTools(updateResultMap, check)
-		slog.Debug("check.Diff calling llm", "file", file.Filename, "systemPrompt", systemPrompt, "prompt", prompt, "tools", len(tools))
-		_, err = callLLM(systemPrompt, prompt, tools, llmCfg)
-		if err != nil {
-			slog.Debug("check.Change encountered an error when calling the llm", "error", err)
-			return
+		tools := getCheckTools(bufferUpdate, check)
+		tasks = append(tasks, func() error {
+			slog.Debug("check.Diff calling llm", "file", file.Filename, "systemPrompt", systemPrompt, "prompt", prompt, "tools", len(tools))
+			_, callUsage, err := callLLM(systemPrompt, prompt, tools, llmCfg)
+			fileUsage[i] = callUsage
+			if err != nil {
+				slog.Debug("check.Change encountered an error when calling the llm", "file", filename, "error", err)
+				return fmt.Errorf("could not check file %s: %w", filename, err)
+			}
+			err = budget.Add(callUsage)
+			if err != nil {
+				slog.Debug("check.Diff exceeded the llm budget", "file", filename, "error", err)
+				return err
+			}
+			return nil
+		})
+	}
+
+	// Run the LLM checks
+	err = llm.NewPool(llmCfg).Run(tasks)
+	if err != nil {
+		return
+	}
+
+	// Collect usage for each file (in order)
+	for i, file := range files {
+		if fileUsage[i].IsZero() {
+			continue
+		}
+		filename := file.Filename
+		if filename == "" {
+			filename = file.OriginalFilename
+		}
+		usage.Files = append(usage.Files, FileUsage{
+			File:  filename,
+			Usage: fileUsage[i],
+		})
+	}
+
+	// Apply the updates for each file in order
+	for _, updates := range fileUpdates {
+		for _, update := range updates {
+			updateResultMap(update.id, update.reason, update.check)
 		}
 	}
 
-	// Process resultMap into results
-	for id, reasons := range resultMap {
+	// Process resultMap into results (in a stable order)
+	ids := make([]string, 0, len(resultMap))
+	for id := range resultMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		reasons := resultMap[id]
 		parsedURI, err := docs.NewDocumentURI(id)
 		if err != nil {
 			slog.Warn("check.Diff could not parse document URI from result map", "uri", id, "error", err)
@@ -165,21 +277,27 @@ func Diff(files []code.FilteredFile, documents []*docs.FilteredDoc, pr *github.P
 	return
 }
 
-func formatCheckPrompt(file code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue) (string, error) {
-	// Use the Diff property if available, otherwise calculate the diff
-	var textDiff string
-	var err error
-
+// getFileDiff returns the diff of the file, using the Diff property if available and otherwise
+// calculating the diff from the original and current contents
+func getFileDiff(file code.FilteredFile) (string, error) {
 	if file.Diff != "" {
-		textDiff = file.Diff
-	} else {
-		// Fallback to generating diff from Contents and OriginalContents
-		edits := diff.Strings(string(file.OriginalContents), string(file.Contents))
-		textDiff, err = diff.ToUnified("a/"+file.OriginalFilename, "b/"+file.Filename, string(file.OriginalContents), edits, 3)
-		if err != nil {
-			slog.Debug("check.Diff could not generate diff", "file", file.Filename, "error", err)
-			return "", err
-		}
+		return file.Diff, nil
+	}
+
+	edits := diff.Strings(string(file.OriginalContents), string(file.Contents))
+	textDiff, err := diff.ToUnified("a/"+file.OriginalFilename, "b/"+file.Filename, string(file.OriginalContents), edits, 3)
+	if err != nil {
+		slog.Debug("check.Diff could not generate diff", "file", file.Filename, "error", err)
+		return "", err
+	}
+
+	return textDiff, nil
+}
+
+func formatCheckPrompt(file code.FilteredFile, documents []*docs.FilteredDoc, pr *github.PullRequest, issues []*github.Issue, commits []repo.Commit) (string, error) {
+	textDiff, err := getFileDiff(file)
+	if err != nil {
+		return "", err
 	}
 
 	var prompt strings.Builder
@@ -205,6 +323,22 @@ func formatCheckPrompt(file code.FilteredFile, documents []*docs.FilteredDoc, pr
 		prompt.WriteString("\n\n")
 	}
 
+	// Add commit messages (if any)
+	if len(commits) > 0 {
+		prompt.WriteString("<commits>\n")
+		for _, commit := range commits {
+			prompt.WriteString("  <commit>\n")
+			prompt.WriteString(fmt.Sprintf("    <commit_hash>%s</commit_hash>\n", commit.Hash))
+			prompt.WriteString("    <commit_message>\n")
+			prompt.WriteString(commit.Message)
+			prompt.WriteString("\n")
+			prompt.WriteString("    </commit_message>\n")
+			prompt.WriteString("  </commit>\n")
+		}
+		prompt.WriteString("</commits>\n")
+		prompt.WriteString("\n\n")
+	}
+
 	// Add issue(s) (if any)
 	// Note: When we support more than just pull requests this will need to be updated
 	numIssues := len(issues)
@@ -302,6 +436,9 @@ func formatCheckPrompt(file code.FilteredFile, documents []*docs.FilteredDoc, pr
 	if pr != nil {
 		prompt.WriteString("and that the contents of related pull request(s) are in <pull_request>, ")
 	}
+	if len(commits) > 0 {
+		prompt.WriteString("and that the messages of the commits in this change are in <commits>, ")
+	}
 	if numIssues > 0 {
 		prompt.WriteString("and that the contents of related issue(s) are in <issue>, ")
 	}
@@ -442,46 +579,63 @@ func getCheckTools(cb updateResultMapCallback, check DiffCheck) (tools []*llm.To
 }
 
 func checkNewUpdateIfs(file *code.FilteredFile, documents []*docs.FilteredDoc, cfg *config.Check, cb updateResultMapCallback) {
+	for _, match := range matchUpdateIfs(file, cfg) {
+		checkNewUpdateIfDocuments(match.entry.Code.Path, documents, match.entry.Documentation, cb, match.action, file, match.checkType)
+	}
+}
+
+// updateIfMatch is an updateIf entry whose code path matches a file
+type updateIfMatch struct {
+	location  string
+	entry     config.CheckOptionsUpdateIfEntry
+	action    string
+	checkType DiffCheckType
+}
+
+// matchUpdateIfs returns the updateIf entries that apply to the file (touched entries first)
+func matchUpdateIfs(file *code.FilteredFile, cfg *config.Check) (matches []updateIfMatch) {
 	// Check touched
-	for _, entry := range cfg.Options.UpdateIf.Touched {
+	for i, entry := range cfg.Options.UpdateIf.Touched {
 		if (file.Filename != "" && doublestar.MatchUnvalidated(entry.Code.Path, file.Filename)) ||
 			(file.OriginalFilename != "" && doublestar.MatchUnvalidated(entry.Code.Path, file.OriginalFilename)) {
+			action := "touched"
 			if file.Action == code.ActionRename {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, fmt.Sprintf("touched (%s was renamed to %s)", file.OriginalFilename, file.Filename), file, DiffCheckTypeUpdateIfTouched)
-			} else {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, "touched", file, DiffCheckTypeUpdateIfTouched)
+				action = fmt.Sprintf("touched (%s was renamed to %s)", file.OriginalFilename, file.Filename)
 			}
+			matches = append(matches, updateIfMatch{fmt.Sprintf("check.options.updateIf.touched[%d]", i), entry, action, DiffCheckTypeUpdateIfTouched})
 		}
 	}
 
 	// Check other updateIfs based on the action
 	switch file.Action {
 	case code.ActionInsert:
-		for _, entry := range cfg.Options.UpdateIf.Added {
+		for i, entry := range cfg.Options.UpdateIf.Added {
 			if doublestar.MatchUnvalidated(entry.Code.Path, file.Filename) {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, "added", file, DiffCheckTypeUpdateIfAdded)
+				matches = append(matches, updateIfMatch{fmt.Sprintf("check.options.updateIf.added[%d]", i), entry, "added", DiffCheckTypeUpdateIfAdded})
 			}
 		}
 	case code.ActionModify:
-		for _, entry := range cfg.Options.UpdateIf.Modified {
+		for i, entry := range cfg.Options.UpdateIf.Modified {
 			if doublestar.MatchUnvalidated(entry.Code.Path, file.Filename) {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, "modified", file, DiffCheckTypeUpdateIfModified)
+				matches = append(matches, updateIfMatch{fmt.Sprintf("check.options.updateIf.modified[%d]", i), entry, "modified", DiffCheckTypeUpdateIfModified})
 			}
 		}
 	case code.ActionRename:
-		for _, entry := range cfg.Options.UpdateIf.Renamed {
+		for i, entry := range cfg.Options.UpdateIf.Renamed {
 			if doublestar.MatchUnvalidated(entry.Code.Path, file.Filename) ||
 				doublestar.MatchUnvalidated(entry.Code.Path, file.OriginalFilename) {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, "renamed", file, DiffCheckTypeUpdateIfRenamed)
+				matches = append(matches, updateIfMatch{fmt.Sprintf("check.options.updateIf.renamed[%d]", i), entry, "renamed", DiffCheckTypeUpdateIfRenamed})
 			}
 		}
 	case code.ActionDelete:
-		for _, entry := range cfg.Options.UpdateIf.Deleted {
+		for i, entry := range cfg.Options.UpdateIf.Deleted {
 			if doublestar.MatchUnvalidated(entry.Code.Path, file.OriginalFilename) {
-				checkNewUpdateIfDocuments(entry.Code.Path, documents, entry.Documentation, cb, "deleted", file, DiffCheckTypeUpdateIfDeleted)
+				matches = append(matches, updateIfMatch{fmt.Sprintf("check.options.updateIf.deleted[%d]", i), entry, "deleted", DiffCheckTypeUpdateIfDeleted})
 			}
 		}
 	}
+
+	return
 }
 
 func checkNewUpdateIfDocuments(glob string, documents []*docs.FilteredDoc, filter config.DocumentationFilter, cb updateResultMapCallback, action string, file *code.FilteredFile, checkType DiffCheckType) {
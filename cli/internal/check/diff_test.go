@@ -2,18 +2,24 @@ package check
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"hyaline/internal/code"
 	"hyaline/internal/config"
 	"hyaline/internal/docs"
 	"hyaline/internal/llm"
+	"hyaline/internal/repo"
 	"hyaline/internal/sqlite"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDiff_IgnoresInvalidIDsFromLLM(t *testing.T) {
 	// 1. Mock llm.CallLLM
 	var callLLM llm.CallLLMHandler
-	callLLM = func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, error) {
+	callLLM = func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
 		// Simulate LLM calling the 'needs_update' tool with valid and invalid IDs
 		for _, tool := range tools {
 			if tool.Name == checkNeedsUpdateName {
@@ -31,7 +37,7 @@ func TestDiff_IgnoresInvalidIDsFromLLM(t *testing.T) {
 				break
 			}
 		}
-		return "", nil
+		return "", llm.Usage{}, nil
 	}
 
 	// 2. Define valid documents and sections
@@ -59,7 +65,7 @@ func TestDiff_IgnoresInvalidIDsFromLLM(t *testing.T) {
 	llmCfg := &config.LLM{}
 
 	// 4. Call Diff
-	results, _, err := Diff(files, documents, nil, nil, checkCfg, llmCfg, callLLM)
+	results, _, _, err := Diff(files, documents, nil, nil, nil, checkCfg, llmCfg, callLLM, nil)
 	if err != nil {
 		t.Fatalf("Diff returned an error: %v", err)
 	}
@@ -95,3 +101,157 @@ func TestDiff_IgnoresInvalidIDsFromLLM(t *testing.T) {
 		t.Errorf("Did not find expected result for docs/valid.md#valid-section/nested-section")
 	}
 }
+
+func TestDiff_ConcurrentResultsAreDeterministic(t *testing.T) {
+	// Mock llm.CallLLM, delaying earlier files so that they complete last
+	var inFlight, maxInFlight atomic.Int32
+	callLLM := func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+
+		for i := 0; i < 4; i++ {
+			if strings.Contains(prompt, fmt.Sprintf("file%d.go", i)) {
+				time.Sleep(time.Duration(4-i) * 10 * time.Millisecond)
+				for _, tool := range tools {
+					if tool.Name == checkNeedsUpdateName {
+						inputBytes, _ := json.Marshal(checkNeedsUpdateSchema{
+							Entries: []checkNeedsUpdateSchemaEntry{
+								{ID: "document://docs/valid.md", Reason: fmt.Sprintf("file%d.go changed", i)},
+							},
+						})
+						tool.Callback(string(inputBytes))
+					}
+				}
+			}
+		}
+		return "", llm.Usage{}, nil
+	}
+
+	documents := []*docs.FilteredDoc{
+		{Document: &sqlite.DOCUMENT{ID: "valid.md", SourceID: "docs"}},
+	}
+	files := []code.FilteredFile{}
+	for i := 0; i < 4; i++ {
+		files = append(files, code.FilteredFile{Filename: fmt.Sprintf("file%d.go", i), Action: code.ActionModify, Contents: []byte("hello")})
+	}
+	checkCfg := &config.Check{Options: config.CheckOptions{UpdateIf: config.CheckOptionsUpdateIf{}}}
+	llmCfg := &config.LLM{Concurrency: 4}
+
+	results, fileCheckContextHashes, _, err := Diff(files, documents, nil, nil, nil, checkCfg, llmCfg, callLLM, nil)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+
+	if maxInFlight.Load() < 2 {
+		t.Errorf("Expected LLM calls to run concurrently, but at most %d ran at once", maxInFlight.Load())
+	}
+	if len(fileCheckContextHashes) != 4 {
+		t.Errorf("Expected context hashes for 4 files, but got %d", len(fileCheckContextHashes))
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, but got %d", len(results))
+	}
+	if len(results[0].Reasons) != 4 {
+		t.Fatalf("Expected 4 reasons, but got %d", len(results[0].Reasons))
+	}
+	for i, reason := range results[0].Reasons {
+		expected := fmt.Sprintf("file%d.go changed", i)
+		if reason.Reason != expected {
+			t.Errorf("Expected reason %d to be %q, but got %q", i, expected, reason.Reason)
+		}
+	}
+}
+
+func TestDiff_ErrorIncludesFile(t *testing.T) {
+	callLLM := func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		return "", llm.Usage{}, errors.New("llm unavailable")
+	}
+
+	files := []code.FilteredFile{
+		{Filename: "some/file.go", Action: code.ActionModify, Contents: []byte("hello")},
+	}
+	checkCfg := &config.Check{Options: config.CheckOptions{UpdateIf: config.CheckOptionsUpdateIf{}}}
+
+	_, _, _, err := Diff(files, []*docs.FilteredDoc{}, nil, nil, nil, checkCfg, &config.LLM{}, callLLM, nil)
+	if err == nil {
+		t.Fatalf("Expected Diff to return an error")
+	}
+	expected := "could not check file some/file.go: llm unavailable"
+	if err.Error() != expected {
+		t.Errorf("Expected error %q, but got %q", expected, err.Error())
+	}
+}
+
+func TestDiff_Usage(t *testing.T) {
+	callLLM := func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		return "", llm.Usage{InputTokens: 100, OutputTokens: 10}, nil
+	}
+
+	files := []code.FilteredFile{
+		{Filename: "file0.go", Action: code.ActionModify, Contents: []byte("hello")},
+		{OriginalFilename: "file1.go", Action: code.ActionDelete, OriginalContents: []byte("hello")},
+	}
+	checkCfg := &config.Check{Options: config.CheckOptions{UpdateIf: config.CheckOptionsUpdateIf{}}}
+
+	_, _, usage, err := Diff(files, []*docs.FilteredDoc{}, nil, nil, nil, checkCfg, &config.LLM{}, callLLM, nil)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+	if len(usage.Files) != 2 {
+		t.Fatalf("Expected usage for 2 files, but got %d", len(usage.Files))
+	}
+	for i, fileUsage := range usage.Files {
+		expected := fmt.Sprintf("file%d.go", i)
+		if fileUsage.File != expected {
+			t.Errorf("Expected usage %d to be for %s, but got %s", i, expected, fileUsage.File)
+		}
+		if fileUsage.Usage.TotalTokens() != 110 {
+			t.Errorf("Expected usage %d to be 110 tokens, but got %d", i, fileUsage.Usage.TotalTokens())
+		}
+	}
+
+	// Exceeding the budget aborts the run
+	_, _, _, err = Diff(files, []*docs.FilteredDoc{}, nil, nil, nil, checkCfg, &config.LLM{Budget: config.LLMBudget{MaxTokens: 150}}, callLLM, nil)
+	expected := "llm.budget.maxTokens of 150 exceeded, used 220 tokens"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, but got %v", expected, err)
+	}
+}
+
+func TestFormatCheckPrompt_Commits(t *testing.T) {
+	file := code.FilteredFile{Filename: "main.go", OriginalFilename: "main.go", Action: code.ActionModify, Diff: "+func main() {}\n"}
+	commits := []repo.Commit{
+		{Hash: "b2", Message: "Add the --verbose flag"},
+		{Hash: "a1", Message: "Rename the serve command\n\nThe serve command is now called start"},
+	}
+
+	prompt, err := formatCheckPrompt(file, []*docs.FilteredDoc{}, nil, nil, commits)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := "<commits>\n" +
+		"  <commit>\n    <commit_hash>b2</commit_hash>\n    <commit_message>\nAdd the --verbose flag\n    </commit_message>\n  </commit>\n" +
+		"  <commit>\n    <commit_hash>a1</commit_hash>\n    <commit_message>\nRename the serve command\n\nThe serve command is now called start\n    </commit_message>\n  </commit>\n" +
+		"</commits>\n"
+	if !strings.Contains(prompt, expected) {
+		t.Errorf("expected the prompt to contain the commits, got: %s", prompt)
+	}
+	if !strings.Contains(prompt, "and that the messages of the commits in this change are in <commits>, ") {
+		t.Errorf("expected the prompt to reference the commits, got: %s", prompt)
+	}
+
+	prompt, err = formatCheckPrompt(file, []*docs.FilteredDoc{}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Contains(prompt, "<commits>") {
+		t.Errorf("expected the prompt to not contain commits, got: %s", prompt)
+	}
+}
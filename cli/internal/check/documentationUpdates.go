@@ -0,0 +1,103 @@
+package check
+
+import (
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/extract"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// DocumentationUpdates maps the ID of each document that was updated to the set of section IDs
+// within that document that were updated. A nil set of sections means that the sections that
+// were updated could not be determined, so the entire document is treated as updated
+type DocumentationUpdates map[string]map[string]struct{}
+
+// GetFileContentsHandler returns the original and current contents of a file, returning nil
+// for either if the file does not exist on that side of the change
+type GetFileContentsHandler func(filename string) (original []byte, current []byte, err error)
+
+// IsUpdated returns true if the document (and section, if any) was updated
+func (updates DocumentationUpdates) IsUpdated(document string, section []string) bool {
+	sections, ok := updates[document]
+	if !ok {
+		return false
+	}
+	if sections == nil || len(section) == 0 {
+		return true
+	}
+	_, ok = sections[strings.Join(section, "/")]
+
+	return ok
+}
+
+// DetectDocumentationUpdates determines which documents (and sections) in the configured source
+// were updated by mapping the changed files to documents. The configured path is joined with
+// each document ID to get the path of that document in the repository. If the contents of a
+// document cannot be retrieved (e.g. it is too large or was deleted) the entire document is
+// treated as updated.
+func DetectDocumentationUpdates(changedFiles map[string]struct{}, documents []*docs.FilteredDoc, cfg *config.CheckOptionsDetectDocumentationUpdates, getFileContents GetFileContentsHandler) DocumentationUpdates {
+	updates := make(DocumentationUpdates)
+	if cfg.Source == "" {
+		return updates
+	}
+
+	for _, document := range documents {
+		if document.Document.SourceID != cfg.Source {
+			continue
+		}
+		filename := DocumentFilename(document.Document.SourceID, document.Document.ID, cfg)
+		if _, ok := changedFiles[filename]; !ok {
+			continue
+		}
+
+		// Only markdown documents can be broken down into updated sections
+		if document.Document.Type != config.DocTypeMarkdown.String() || getFileContents == nil {
+			updates[document.Document.ID] = nil
+			continue
+		}
+		original, current, err := getFileContents(filename)
+		if err != nil {
+			slog.Warn("Could not get file contents to detect updated sections, treating the entire document as updated", "filename", filename, "error", err)
+			updates[document.Document.ID] = nil
+			continue
+		}
+		updates[document.Document.ID] = getUpdatedMarkdownSections(string(original), string(current))
+	}
+	slog.Info("Detected documentation updates", "source", cfg.Source, "documents", len(updates))
+
+	return updates
+}
+
+// DocumentFilename returns the path of a document within the repository. Documents in the
+// configured source are relative to the configured path, while documents in other sources are
+// assumed to be relative to the root of the repository.
+func DocumentFilename(sourceID string, documentID string, cfg *config.CheckOptionsDetectDocumentationUpdates) string {
+	if cfg != nil && sourceID == cfg.Source {
+		return path.Join(cfg.Path, documentID)
+	}
+
+	return documentID
+}
+
+// getUpdatedMarkdownSections diffs the sections of the original and current markdown and returns
+// the IDs of the sections that were added, removed, or whose content changed
+func getUpdatedMarkdownSections(original string, current string) map[string]struct{} {
+	updated := make(map[string]struct{})
+	originalSections := extract.GetMarkdownSectionContents(original)
+	currentSections := extract.GetMarkdownSectionContents(current)
+
+	for id, content := range currentSections {
+		if originalContent, ok := originalSections[id]; !ok || originalContent != content {
+			updated[id] = struct{}{}
+		}
+	}
+	for id := range originalSections {
+		if _, ok := currentSections[id]; !ok {
+			updated[id] = struct{}{}
+		}
+	}
+
+	return updated
+}
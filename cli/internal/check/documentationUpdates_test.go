@@ -0,0 +1,101 @@
+package check
+
+import (
+	"errors"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/sqlite"
+	"testing"
+)
+
+func TestDetectDocumentationUpdates(t *testing.T) {
+	documents := []*docs.FilteredDoc{
+		{Document: &sqlite.DOCUMENT{ID: "guide.md", SourceID: "docs", Type: "md"}},
+		{Document: &sqlite.DOCUMENT{ID: "index.html", SourceID: "docs", Type: "html"}},
+		{Document: &sqlite.DOCUMENT{ID: "unchanged.md", SourceID: "docs", Type: "md"}},
+		{Document: &sqlite.DOCUMENT{ID: "guide.md", SourceID: "other", Type: "md"}},
+	}
+	changedFiles := map[string]struct{}{
+		"docs/guide.md":   {},
+		"docs/index.html": {},
+		"main.go":         {},
+	}
+	original := "# Guide\nIntro\n## Install\nRun it\n## Usage\nUse it\n## Removed\nGone"
+	current := "# Guide\nIntro\n## Install\nRun it differently\n## Usage\nUse it\n## Added\nNew"
+	getFileContents := func(filename string) ([]byte, []byte, error) {
+		if filename != "docs/guide.md" {
+			t.Errorf("unexpected file contents requested for %s", filename)
+		}
+		return []byte(original), []byte(current), nil
+	}
+	cfg := &config.CheckOptionsDetectDocumentationUpdates{Source: "docs", Path: "docs"}
+
+	updates := DetectDocumentationUpdates(changedFiles, documents, cfg, getFileContents)
+
+	if len(updates) != 2 {
+		t.Errorf("expected 2 updated documents, got %d", len(updates))
+	}
+	var tests = []struct {
+		document string
+		section  []string
+		updated  bool
+	}{
+		{"guide.md", []string{}, true},
+		{"guide.md", []string{"Guide"}, true},
+		{"guide.md", []string{"Guide", "Install"}, true},
+		{"guide.md", []string{"Guide", "Usage"}, false},
+		{"guide.md", []string{"Guide", "Removed"}, true},
+		{"guide.md", []string{"Guide", "Added"}, true},
+		{"index.html", []string{"Any"}, true},
+		{"unchanged.md", []string{}, false},
+	}
+	for i, test := range tests {
+		if updates.IsUpdated(test.document, test.section) != test.updated {
+			t.Errorf("(%d) expected %s %v updated to be %t", i, test.document, test.section, test.updated)
+		}
+	}
+}
+
+func TestDetectDocumentationUpdates_NoSource(t *testing.T) {
+	documents := []*docs.FilteredDoc{
+		{Document: &sqlite.DOCUMENT{ID: "guide.md", SourceID: "docs", Type: "md"}},
+	}
+	changedFiles := map[string]struct{}{"guide.md": {}}
+
+	updates := DetectDocumentationUpdates(changedFiles, documents, &config.CheckOptionsDetectDocumentationUpdates{}, nil)
+	if len(updates) != 0 {
+		t.Errorf("expected no updated documents, got %d", len(updates))
+	}
+}
+
+func TestDetectDocumentationUpdates_FileContentsError(t *testing.T) {
+	documents := []*docs.FilteredDoc{
+		{Document: &sqlite.DOCUMENT{ID: "large.md", SourceID: "docs", Type: "md"}},
+		{Document: &sqlite.DOCUMENT{ID: "guide.md", SourceID: "docs", Type: "md"}},
+	}
+	changedFiles := map[string]struct{}{
+		"large.md": {},
+		"guide.md": {},
+	}
+	getFileContents := func(filename string) ([]byte, []byte, error) {
+		if filename == "large.md" {
+			return nil, nil, errors.New("file is too large")
+		}
+		return []byte("# Guide\n## Install\nRun it\n## Usage\nUse it"), []byte("# Guide\n## Install\nRun it\n## Usage\nUse it differently"), nil
+	}
+	cfg := &config.CheckOptionsDetectDocumentationUpdates{Source: "docs"}
+
+	updates := DetectDocumentationUpdates(changedFiles, documents, cfg, getFileContents)
+
+	// The document whose contents could not be retrieved is treated as wholly updated, while other
+	// documents are still broken down into updated sections
+	if sections, ok := updates["large.md"]; !ok || sections != nil {
+		t.Errorf("expected large.md to be wholly updated, got %v (found: %t)", sections, ok)
+	}
+	if !updates.IsUpdated("large.md", []string{"Any"}) {
+		t.Errorf("expected any section of large.md to be updated")
+	}
+	if !updates.IsUpdated("guide.md", []string{"Guide", "Usage"}) || updates.IsUpdated("guide.md", []string{"Guide", "Install"}) {
+		t.Errorf("expected the Usage but not the Install section of guide.md to be updated, got %v", updates["guide.md"])
+	}
+}
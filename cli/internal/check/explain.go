@@ -0,0 +1,88 @@
+package check
+
+import (
+	"fmt"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"sort"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Explanation describes how a check would handle a changed file, without calling the LLM
+type Explanation struct {
+	File code.FilteredFile
+	// Included is true if the file is checked, based on check.code.include and check.code.exclude
+	Included bool
+	// Include is the location and pattern of the first check.code.include entry matching the file
+	// (if any), and Exclude is the location and pattern of the first check.code.exclude entry
+	// matching the file (if any)
+	Include string
+	Exclude string
+	// UpdateIfs are the updateIf entries that match the file
+	UpdateIfs []UpdateIfExplanation
+}
+
+// UpdateIfExplanation is an updateIf entry that matches a file, along with the URIs of the
+// documents and sections the entry recommends updating
+type UpdateIfExplanation struct {
+	Location  string
+	Path      string
+	Documents []string
+}
+
+// Explain returns an explanation of how a check would handle the file, including whether the file
+// is included in the check and which updateIf entries (and documentation) it would trigger.
+// documents should be the documentation in scope for the check (see docs.GetFilteredDocs).
+func Explain(file code.FilteredFile, documents []*docs.FilteredDoc, cfg *config.Check) Explanation {
+	explanation := Explanation{
+		File: file,
+	}
+
+	// Inserted, modified, and renamed files are filtered on their current name, and deleted files
+	// on their original name (see code.GetFilteredDiff)
+	path := file.Filename
+	if file.Action == code.ActionDelete {
+		path = file.OriginalFilename
+	}
+	explanation.Included = config.PathIsIncluded(path, cfg.Code.Include, cfg.Code.Exclude)
+
+	// Report the patterns responsible for including or excluding the file
+	for i, include := range cfg.Code.Include {
+		if doublestar.MatchUnvalidated(include, path) {
+			explanation.Include = fmt.Sprintf("check.code.include[%d]: %s", i, include)
+			break
+		}
+	}
+	if explanation.Include != "" {
+		for i, exclude := range cfg.Code.Exclude {
+			if doublestar.MatchUnvalidated(exclude, path) {
+				explanation.Exclude = fmt.Sprintf("check.code.exclude[%d]: %s", i, exclude)
+				break
+			}
+		}
+	}
+
+	// Resolve each matching updateIf entry to the documents and sections it applies to
+	for _, match := range matchUpdateIfs(&file, cfg) {
+		resolved := make(map[string]struct{})
+		collect := func(id string, reason string, check DiffCheck) {
+			resolved[id] = struct{}{}
+		}
+		checkNewUpdateIfDocuments(match.entry.Code.Path, documents, match.entry.Documentation, collect, match.action, &file, match.checkType)
+
+		ids := make([]string, 0, len(resolved))
+		for id := range resolved {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		explanation.UpdateIfs = append(explanation.UpdateIfs, UpdateIfExplanation{
+			Location:  match.location,
+			Path:      match.entry.Code.Path,
+			Documents: ids,
+		})
+	}
+
+	return explanation
+}
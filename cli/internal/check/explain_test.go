@@ -0,0 +1,87 @@
+package check
+
+import (
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	documents := []*docs.FilteredDoc{suggestTestDocument()}
+	cfg := &config.Check{
+		Code: config.CheckCode{
+			Include: []string{"src/**/*.go", "**/*.go"},
+			Exclude: []string{"**/*_test.go"},
+		},
+		Options: config.CheckOptions{
+			UpdateIf: config.CheckOptionsUpdateIf{
+				Touched: []config.CheckOptionsUpdateIfEntry{
+					{Code: config.CheckCodeFilter{Path: "src/**"}, Documentation: config.DocumentationFilter{Source: "docs", Document: "README.md"}},
+				},
+				Added: []config.CheckOptionsUpdateIfEntry{
+					{Code: config.CheckCodeFilter{Path: "src/cmd/*.go"}, Documentation: config.DocumentationFilter{Source: "docs", Document: "README.md", Section: "App/Usage"}},
+				},
+				Deleted: []config.CheckOptionsUpdateIfEntry{
+					{Code: config.CheckCodeFilter{Path: "**"}, Documentation: config.DocumentationFilter{Source: "other"}},
+				},
+			},
+		},
+	}
+
+	var tests = []struct {
+		file     code.FilteredFile
+		expected Explanation
+	}{
+		{
+			code.FilteredFile{Filename: "src/cmd/run.go", Action: code.ActionInsert},
+			Explanation{
+				Included: true,
+				Include:  "check.code.include[0]: src/**/*.go",
+				UpdateIfs: []UpdateIfExplanation{
+					{Location: "check.options.updateIf.touched[0]", Path: "src/**", Documents: []string{"document://docs/README.md"}},
+					{Location: "check.options.updateIf.added[0]", Path: "src/cmd/*.go", Documents: []string{"document://docs/README.md#App/Usage"}},
+				},
+			},
+		},
+		{
+			code.FilteredFile{Filename: "src/run_test.go", OriginalFilename: "src/run_test.go", Action: code.ActionModify},
+			Explanation{
+				Included: false,
+				Include:  "check.code.include[0]: src/**/*.go",
+				Exclude:  "check.code.exclude[0]: **/*_test.go",
+				UpdateIfs: []UpdateIfExplanation{
+					{Location: "check.options.updateIf.touched[0]", Path: "src/**", Documents: []string{"document://docs/README.md"}},
+				},
+			},
+		},
+		{
+			code.FilteredFile{OriginalFilename: "README.txt", Action: code.ActionDelete},
+			Explanation{
+				Included: false,
+				UpdateIfs: []UpdateIfExplanation{
+					{Location: "check.options.updateIf.deleted[0]", Path: "**", Documents: []string{}},
+				},
+			},
+		},
+		{
+			code.FilteredFile{Filename: "lib/run.go", OriginalFilename: "src/run.go", Action: code.ActionRename},
+			Explanation{
+				Included: true,
+				Include:  "check.code.include[1]: **/*.go",
+				UpdateIfs: []UpdateIfExplanation{
+					{Location: "check.options.updateIf.touched[0]", Path: "src/**", Documents: []string{"document://docs/README.md"}},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		explanation := Explain(test.file, documents, cfg)
+		test.expected.File = test.file
+		if !reflect.DeepEqual(explanation, test.expected) {
+			t.Errorf("test %d - expected %+v, got %+v", i, test.expected, explanation)
+		}
+	}
+}
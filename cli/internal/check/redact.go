@@ -0,0 +1,297 @@
+package check
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/github"
+	"hyaline/internal/repo"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The sources of text that can be redacted
+const (
+	RedactionSourceFile        = "file"
+	RedactionSourcePullRequest = "pullRequest"
+	RedactionSourceIssue       = "issue"
+	RedactionSourceCommit      = "commit"
+	RedactionSourceDocument    = "document"
+)
+
+// Redaction records the number of distinct secrets a detector redacted from a source (such as a
+// file)
+type Redaction struct {
+	Source   string `json:"source"`
+	Name     string `json:"name,omitempty"`
+	Detector string `json:"detector"`
+	Count    int    `json:"count"`
+}
+
+// RedactionLog is the audit log of the secrets redacted during a check. The secrets themselves are
+// never included in the log.
+type RedactionLog struct {
+	Redactions []Redaction `json:"redactions"`
+}
+
+type redactionDetector struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// builtInRedactionDetectors find common secrets. Detectors with a capture group only redact the
+// first group. Detectors are run in order, so more specific detectors come first.
+var builtInRedactionDetectors = []redactionDetector{
+	{"private-key", regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----|\z)`)},
+	{"aws-access-key-id", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{"gitlab-token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"anthropic-api-key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai-api-key", regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"stripe-api-key", regexp.MustCompile(`\b(?:sk|rk)_live_[0-9A-Za-z]{16,}\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"generic-secret", regexp.MustCompile(`(?i)[\w.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key)[\w.-]*["']?\s*(?::=|=>|[:=])\s*["']([^"'\s]{8,})["']`)},
+}
+
+// Redactor replaces secrets with placeholders before text is sent to the LLM. Each placeholder is
+// derived from the secret, so the same secret is always replaced with the same placeholder (and
+// prompts remain stable between runs). A nil Redactor does not redact anything.
+type Redactor struct {
+	detectors []redactionDetector
+
+	mu      sync.Mutex
+	secrets map[string]string
+	found   map[Redaction]map[string]struct{}
+}
+
+// NewRedactor returns a Redactor using the built-in detectors and the custom patterns in cfg, or
+// nil if redaction is not enabled
+func NewRedactor(cfg *config.CheckOptionsRedaction) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	detectors := append([]redactionDetector{}, builtInRedactionDetectors...)
+	for _, pattern := range cfg.Patterns {
+		regex, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			slog.Debug("check.NewRedactor could not compile pattern", "name", pattern.Name, "regex", pattern.Regex, "error", err)
+			return nil, err
+		}
+		detectors = append(detectors, redactionDetector{
+			name:  pattern.Name,
+			regex: regex,
+		})
+	}
+
+	return &Redactor{
+		detectors: detectors,
+		secrets:   make(map[string]string),
+		found:     make(map[Redaction]map[string]struct{}),
+	}, nil
+}
+
+// Redact returns text with any secrets replaced by placeholders, recording the redactions made
+// against the source and name (such as the filename) of the text
+func (r *Redactor) Redact(source string, name string, text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+
+	for _, detector := range r.detectors {
+		matches := detector.regex.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var redacted strings.Builder
+		last := 0
+		key := Redaction{Source: source, Name: name, Detector: detector.name}
+		for _, match := range matches {
+			// Redact the first capture group (if any), otherwise the entire match
+			start, end := match[0], match[1]
+			if detector.regex.NumSubexp() > 0 && match[2] != -1 {
+				start, end = match[2], match[3]
+			}
+			// Skip secrets that were already redacted by an earlier detector
+			if start == end || strings.Contains(text[start:end], "[REDACTED:") {
+				continue
+			}
+			placeholder := r.placeholder(key, text[start:end])
+			redacted.WriteString(text[last:start])
+			redacted.WriteString(placeholder)
+			last = end
+		}
+		redacted.WriteString(text[last:])
+		text = redacted.String()
+	}
+
+	return text
+}
+
+// placeholder returns the placeholder for a secret found by a detector in a source, remembering
+// the secret so that it can be restored later
+func (r *Redactor) placeholder(key Redaction, secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	placeholder := fmt.Sprintf("[REDACTED:%s:%s]", key.Detector, hex.EncodeToString(hash[:])[:8])
+
+	r.mu.Lock()
+	r.secrets[placeholder] = secret
+	if r.found[key] == nil {
+		r.found[key] = make(map[string]struct{})
+	}
+	r.found[key][placeholder] = struct{}{}
+	r.mu.Unlock()
+
+	return placeholder
+}
+
+// Restore returns text with any placeholders replaced by the secrets they redacted, so that
+// content written by the LLM (such as a suggestion) keeps the original values
+func (r *Redactor) Restore(text string) string {
+	if r == nil {
+		return text
+	}
+
+	r.mu.Lock()
+	replacements := make([]string, 0, len(r.secrets)*2)
+	for placeholder, secret := range r.secrets {
+		replacements = append(replacements, placeholder, secret)
+	}
+	r.mu.Unlock()
+	if len(replacements) == 0 {
+		return text
+	}
+
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// RedactFiles returns copies of files with secrets redacted from their contents and diffs
+func (r *Redactor) RedactFiles(files []code.FilteredFile) []code.FilteredFile {
+	if r == nil {
+		return files
+	}
+
+	redacted := make([]code.FilteredFile, 0, len(files))
+	for _, file := range files {
+		name := file.Filename
+		if name == "" {
+			name = file.OriginalFilename
+		}
+		file.Diff = r.Redact(RedactionSourceFile, name, file.Diff)
+		if file.Contents != nil {
+			file.Contents = []byte(r.Redact(RedactionSourceFile, name, string(file.Contents)))
+		}
+		if file.OriginalContents != nil {
+			file.OriginalContents = []byte(r.Redact(RedactionSourceFile, name, string(file.OriginalContents)))
+		}
+		redacted = append(redacted, file)
+	}
+
+	return redacted
+}
+
+// RedactPullRequest returns a copy of the pull request with secrets redacted from its title and body
+func (r *Redactor) RedactPullRequest(pr *github.PullRequest) *github.PullRequest {
+	if r == nil || pr == nil {
+		return pr
+	}
+
+	redacted := *pr
+	redacted.Title = r.Redact(RedactionSourcePullRequest, "", pr.Title)
+	redacted.Body = r.Redact(RedactionSourcePullRequest, "", pr.Body)
+
+	return &redacted
+}
+
+// RedactIssues returns copies of the issues with secrets redacted from their titles and bodies
+func (r *Redactor) RedactIssues(issues []*github.Issue) []*github.Issue {
+	if r == nil {
+		return issues
+	}
+
+	redacted := make([]*github.Issue, 0, len(issues))
+	for i, issue := range issues {
+		name := fmt.Sprintf("%d", i+1)
+		redacted = append(redacted, &github.Issue{
+			Title: r.Redact(RedactionSourceIssue, name, issue.Title),
+			Body:  r.Redact(RedactionSourceIssue, name, issue.Body),
+		})
+	}
+
+	return redacted
+}
+
+// RedactCommits returns copies of the commits with secrets redacted from their messages
+func (r *Redactor) RedactCommits(commits []repo.Commit) []repo.Commit {
+	if r == nil {
+		return commits
+	}
+
+	redacted := make([]repo.Commit, 0, len(commits))
+	for _, commit := range commits {
+		commit.Message = r.Redact(RedactionSourceCommit, commit.Hash, commit.Message)
+		redacted = append(redacted, commit)
+	}
+
+	return redacted
+}
+
+// Redactions returns the redactions made so far, sorted by source, name, and detector
+func (r *Redactor) Redactions() []Redaction {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	redactions := make([]Redaction, 0, len(r.found))
+	for redaction, placeholders := range r.found {
+		redaction.Count = len(placeholders)
+		redactions = append(redactions, redaction)
+	}
+	sort.Slice(redactions, func(i, j int) bool {
+		if redactions[i].Source != redactions[j].Source {
+			return redactions[i].Source < redactions[j].Source
+		}
+		if redactions[i].Name != redactions[j].Name {
+			return redactions[i].Name < redactions[j].Name
+		}
+		return redactions[i].Detector < redactions[j].Detector
+	})
+
+	return redactions
+}
+
+// WriteAuditLog writes the redactions made so far to path as JSON, replacing any existing log
+func (r *Redactor) WriteAuditLog(path string) error {
+	log := RedactionLog{
+		Redactions: r.Redactions(),
+	}
+	if log.Redactions == nil {
+		log.Redactions = []Redaction{}
+	}
+
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		slog.Debug("check.WriteAuditLog could not marshal redactions", "error", err)
+		return err
+	}
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		slog.Debug("check.WriteAuditLog could not write audit log", "path", path, "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,382 @@
+package check
+
+import (
+	"fmt"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/github"
+	"hyaline/internal/llm"
+	"hyaline/internal/repo"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters (see https://en.wikipedia.org/wiki/Okapi_BM25)
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// rrfK is the constant used when combining rankings using reciprocal rank fusion
+const rrfK = 60
+
+// maxEmbeddingTextLength is the maximum number of characters of a text to embed, so that texts fit
+// within the embedding model's input limit
+const maxEmbeddingTextLength = 8000
+
+// relevanceIndex ranks the documents and sections in a documentation set by their relevance to a
+// change, so that only the most relevant documentation is included in the prompt for each file
+type relevanceIndex struct {
+	documents  []*docs.FilteredDoc
+	entries    []relevanceEntry
+	idf        map[string]float64
+	avgLength  float64
+	embeddings [][]float64
+	cfg        *config.CheckOptionsRelevance
+	llmCfg     *config.LLM
+	embed      llm.EmbedHandler
+}
+
+// relevanceEntry is a single document or section in the index
+type relevanceEntry struct {
+	id       string
+	document int
+	// The index of each (nested) section within the document, which is empty for the document itself
+	path   []int
+	terms  map[string]int
+	length int
+}
+
+// newRelevanceIndex indexes documents for relevance ranking, returning the tokens used to embed
+// them. If relevance ranking is not configured, or the documentation already fits within
+// maxSections, nil is returned and the full set of documents should be used. Documents are only
+// embedded when embeddings are configured and embed is not nil (otherwise only BM25 is used).
+func newRelevanceIndex(documents []*docs.FilteredDoc, cfg *config.CheckOptionsRelevance, llmCfg *config.LLM, embed llm.EmbedHandler) (*relevanceIndex, llm.Usage, error) {
+	if cfg == nil || cfg.MaxSections == 0 {
+		return nil, llm.Usage{}, nil
+	}
+
+	index := &relevanceIndex{
+		documents: documents,
+		idf:       map[string]float64{},
+		cfg:       cfg,
+		llmCfg:    llmCfg,
+		embed:     embed,
+	}
+	texts := []string{}
+	addEntry := func(id string, document int, path []int, text string) {
+		terms := map[string]int{}
+		length := 0
+		for _, term := range tokenize(text) {
+			terms[term]++
+			length++
+		}
+		index.entries = append(index.entries, relevanceEntry{
+			id:       id,
+			document: document,
+			path:     path,
+			terms:    terms,
+			length:   length,
+		})
+		texts = append(texts, text)
+	}
+
+	var addSections func(document int, path []int, sections []docs.FilteredSection)
+	addSections = func(document int, path []int, sections []docs.FilteredSection) {
+		for i, section := range sections {
+			sectionPath := append(append([]int{}, path...), i)
+			uri := docs.DocumentURI{SourceID: section.Section.SourceID, DocumentPath: section.Section.DocumentID, Section: section.Section.ID}
+			addEntry(uri.String(), document, sectionPath, strings.Join([]string{section.Section.DocumentID, section.Section.Name, section.Section.Purpose, section.Section.ExtractedData}, "\n"))
+			addSections(document, sectionPath, section.Sections)
+		}
+	}
+	for i, document := range documents {
+		uri := docs.DocumentURI{SourceID: document.Document.SourceID, DocumentPath: document.Document.ID}
+		addEntry(uri.String(), i, nil, strings.Join([]string{document.Document.ID, document.Document.Purpose, document.Document.ExtractedData}, "\n"))
+		addSections(i, nil, document.Sections)
+	}
+
+	// Small documentation sets are included in full
+	if len(index.entries) <= cfg.MaxSections {
+		slog.Debug("check.newRelevanceIndex including all documentation", "entries", len(index.entries), "maxSections", cfg.MaxSections)
+		return nil, llm.Usage{}, nil
+	}
+
+	// Calculate the inverse document frequency of each term
+	documentFrequency := map[string]int{}
+	totalLength := 0
+	for _, entry := range index.entries {
+		for term := range entry.terms {
+			documentFrequency[term]++
+		}
+		totalLength += entry.length
+	}
+	n := float64(len(index.entries))
+	for term, frequency := range documentFrequency {
+		index.idf[term] = math.Log(1 + (n-float64(frequency)+0.5)/(float64(frequency)+0.5))
+	}
+	index.avgLength = float64(totalLength) / n
+
+	// Embed each entry (if configured)
+	var usage llm.Usage
+	if cfg.Embeddings != nil && embed != nil {
+		for i := range texts {
+			texts[i] = truncateEmbeddingText(texts[i])
+		}
+		var embeddings [][]float64
+		var err error
+		embeddings, usage, err = embed(texts, cfg.Embeddings, llmCfg)
+		if err != nil {
+			slog.Debug("check.newRelevanceIndex could not embed documentation", "error", err)
+			return nil, usage, err
+		}
+		index.embeddings = embeddings
+	}
+
+	return index, usage, nil
+}
+
+// selectRelevant returns the documents and sections most relevant to query (along with their
+// ancestors, so that the structure of the documentation is kept), as well as the IDs of the
+// documents and sections that were selected and the tokens used to embed the query
+func (r *relevanceIndex) selectRelevant(query string) ([]*docs.FilteredDoc, []string, llm.Usage, error) {
+	scores := r.bm25Scores(query)
+	ranking := rankByScore(scores)
+
+	var usage llm.Usage
+	if r.embeddings != nil {
+		queryEmbeddings, queryUsage, err := r.embed([]string{truncateEmbeddingText(query)}, r.cfg.Embeddings, r.llmCfg)
+		usage = queryUsage
+		if err != nil {
+			slog.Debug("check.selectRelevant could not embed query", "error", err)
+			return nil, nil, usage, err
+		}
+		similarities := make([]float64, len(r.embeddings))
+		for i, embedding := range r.embeddings {
+			similarities[i] = cosineSimilarity(queryEmbeddings[0], embedding)
+		}
+
+		// Combine the lexical and semantic rankings using reciprocal rank fusion (entries that share
+		// no terms with the query are only ranked semantically)
+		fused := make([]float64, len(r.entries))
+		for rank, entry := range ranking {
+			if scores[entry] > 0 {
+				fused[entry] += 1 / float64(rrfK+rank+1)
+			}
+		}
+		for rank, entry := range rankByScore(similarities) {
+			fused[entry] += 1 / float64(rrfK+rank+1)
+		}
+		ranking = rankByScore(fused)
+	} else {
+		// Without embeddings, entries that share no terms with the query are not relevant. If no
+		// entry shares any terms (e.g. the change is to a binary or generated file), the ranking is
+		// kept in the order the documentation was indexed so that the first maxSections documents and
+		// sections are used rather than no documentation at all.
+		relevant := []int{}
+		for _, entry := range ranking {
+			if scores[entry] > 0 {
+				relevant = append(relevant, entry)
+			}
+		}
+		if len(relevant) > 0 {
+			ranking = relevant
+		}
+	}
+
+	if len(ranking) > r.cfg.MaxSections {
+		ranking = ranking[:r.cfg.MaxSections]
+	}
+	selected := make(map[int]bool, len(ranking))
+	ids := []string{}
+	for _, entry := range ranking {
+		selected[entry] = true
+		ids = append(ids, r.entries[entry].id)
+	}
+
+	return r.buildDocuments(selected), ids, usage, nil
+}
+
+// bm25Scores returns the BM25 score of each entry for query
+func (r *relevanceIndex) bm25Scores(query string) []float64 {
+	// Sort the terms so that scores are summed in a stable order
+	queryTerms := tokenize(query)
+	sort.Strings(queryTerms)
+	queryTerms = slices.Compact(queryTerms)
+
+	scores := make([]float64, len(r.entries))
+	for i, entry := range r.entries {
+		for _, term := range queryTerms {
+			frequency := float64(entry.terms[term])
+			if frequency == 0 {
+				continue
+			}
+			norm := frequency + bm25K1*(1-bm25B+bm25B*float64(entry.length)/r.avgLength)
+			scores[i] += r.idf[term] * frequency * (bm25K1 + 1) / norm
+		}
+	}
+
+	return scores
+}
+
+// buildDocuments returns the documents containing a selected entry, keeping only the selected
+// sections and their ancestors
+func (r *relevanceIndex) buildDocuments(selected map[int]bool) []*docs.FilteredDoc {
+	// Mark each selected entry along with its ancestors
+	type key struct {
+		document int
+		path     string
+	}
+	keep := map[key]bool{}
+	for i, entry := range r.entries {
+		if !selected[i] {
+			continue
+		}
+		for depth := 0; depth <= len(entry.path); depth++ {
+			keep[key{entry.document, pathKey(entry.path[:depth])}] = true
+		}
+	}
+
+	var filterSections func(document int, path []int, sections []docs.FilteredSection) []docs.FilteredSection
+	filterSections = func(document int, path []int, sections []docs.FilteredSection) []docs.FilteredSection {
+		filtered := []docs.FilteredSection{}
+		for i, section := range sections {
+			sectionPath := append(append([]int{}, path...), i)
+			if !keep[key{document, pathKey(sectionPath)}] {
+				continue
+			}
+			section.Sections = filterSections(document, sectionPath, section.Sections)
+			filtered = append(filtered, section)
+		}
+		return filtered
+	}
+
+	documents := []*docs.FilteredDoc{}
+	for i, document := range r.documents {
+		if !keep[key{i, pathKey(nil)}] {
+			continue
+		}
+		filtered := *document
+		filtered.Sections = filterSections(i, nil, document.Sections)
+		documents = append(documents, &filtered)
+	}
+
+	return documents
+}
+
+// getRelevanceQuery returns the text used to find the documentation relevant to a changed file
+func getRelevanceQuery(file code.FilteredFile, pr *github.PullRequest, issues []*github.Issue, commits []repo.Commit) string {
+	parts := []string{file.Filename, file.OriginalFilename}
+	if file.Diff != "" {
+		parts = append(parts, file.Diff)
+	} else {
+		parts = append(parts, string(file.Contents), string(file.OriginalContents))
+	}
+	if pr != nil {
+		parts = append(parts, pr.Title, pr.Body)
+	}
+	for _, issue := range issues {
+		parts = append(parts, issue.Title, issue.Body)
+	}
+	for _, commit := range commits {
+		parts = append(parts, commit.Message)
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// tokenize splits text into lowercase terms. Identifiers are kept whole and are also split into
+// their parts (e.g. getUserName is indexed as getusername, get, user, and name), so that code
+// matches the prose used to document it.
+func tokenize(text string) []string {
+	terms := []string{}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			whole := strings.ToLower(strings.ReplaceAll(word, "_", ""))
+			if len(whole) > 1 {
+				terms = append(terms, whole)
+			}
+		}
+		for _, part := range parts {
+			if len(part) > 1 {
+				terms = append(terms, strings.ToLower(part))
+			}
+		}
+	}
+
+	return terms
+}
+
+// splitIdentifier splits a snake_case or camelCase identifier into its parts
+func splitIdentifier(word string) []string {
+	parts := []string{}
+	for _, segment := range strings.Split(word, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+			// Split acronyms from the following word (e.g. HTTPServer into HTTP and Server)
+			acronymEnd := i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+
+	return parts
+}
+
+// rankByScore returns the indexes of scores ordered from the highest to the lowest score. Ties
+// keep their original order.
+func rankByScore(scores []float64) []int {
+	ranking := make([]int, len(scores))
+	for i := range ranking {
+		ranking[i] = i
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return scores[ranking[i]] > scores[ranking[j]]
+	})
+
+	return ranking
+}
+
+func cosineSimilarity(a []float64, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func truncateEmbeddingText(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxEmbeddingTextLength {
+		return text
+	}
+
+	return string(runes[:maxEmbeddingTextLength])
+}
+
+// pathKey returns a key identifying a section by its path within a document
+func pathKey(path []int) string {
+	return fmt.Sprint(path)
+}
@@ -0,0 +1,291 @@
+package check
+
+import (
+	"encoding/json"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/docs"
+	"hyaline/internal/llm"
+	"hyaline/internal/sqlite"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func relevanceTestDocuments() []*docs.FilteredDoc {
+	section := func(documentID string, id string, name string, content string, sections ...docs.FilteredSection) docs.FilteredSection {
+		return docs.FilteredSection{
+			Section:  &sqlite.SECTION{ID: id, DocumentID: documentID, SourceID: "docs", Name: name, ExtractedData: content},
+			Sections: sections,
+		}
+	}
+
+	return []*docs.FilteredDoc{
+		{
+			Document: &sqlite.DOCUMENT{ID: "README.md", SourceID: "docs", ExtractedData: "An application for tracking orders"},
+			Sections: []docs.FilteredSection{
+				section("README.md", "Installation", "Installation", "Install dependencies using npm install"),
+				section("README.md", "Configuration", "Configuration", "The application is configured using environment variables",
+					section("README.md", "Configuration/Database", "Database", "Set DATABASE_URL to the connection string of the postgres database"),
+					section("README.md", "Configuration/Logging", "Logging", "Set LOG_LEVEL to debug to enable verbose logging"),
+				),
+			},
+		},
+		{
+			Document: &sqlite.DOCUMENT{ID: "orders.md", SourceID: "docs", ExtractedData: "Orders are created using the orders API"},
+			Sections: []docs.FilteredSection{
+				section("orders.md", "Creating Orders", "Creating Orders", "Call createOrder with the customer and the items being ordered"),
+				section("orders.md", "Cancelling Orders", "Cancelling Orders", "Call cancelOrder to cancel an order that has not shipped"),
+			},
+		},
+	}
+}
+
+// getSelectedIDs returns the IDs of each document and section in documents
+func getSelectedIDs(documents []*docs.FilteredDoc) []string {
+	ids := []string{}
+	for id := range buildValidIDMap(documents) {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{"Set the DATABASE_URL", []string{"set", "the", "databaseurl", "database", "url"}},
+		{"func getUserName() {", []string{"func", "getusername", "get", "user", "name"}},
+		{"HTTPServer a b2", []string{"httpserver", "http", "server", "b2"}},
+	}
+
+	for i, test := range tests {
+		terms := tokenize(test.text)
+		if !reflect.DeepEqual(terms, test.expected) {
+			t.Errorf("test %d - expected %v, got %v", i, test.expected, terms)
+		}
+	}
+}
+
+func TestNewRelevanceIndex_IncludesSmallDocumentation(t *testing.T) {
+	documents := relevanceTestDocuments()
+
+	var tests = []struct {
+		maxSections int
+		indexed     bool
+	}{
+		{0, false},
+		{9, false},
+		{20, false},
+		{3, true},
+	}
+
+	for i, test := range tests {
+		index, _, err := newRelevanceIndex(documents, &config.CheckOptionsRelevance{MaxSections: test.maxSections}, &config.LLM{}, nil)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if (index != nil) != test.indexed {
+			t.Errorf("test %d - expected indexed to be %t, got %t", i, test.indexed, index != nil)
+		}
+	}
+}
+
+func TestSelectRelevant(t *testing.T) {
+	index, _, err := newRelevanceIndex(relevanceTestDocuments(), &config.CheckOptionsRelevance{MaxSections: 2}, &config.LLM{}, nil)
+	if err != nil || index == nil {
+		t.Fatalf("expected an index, got error: %v", err)
+	}
+
+	documents, selected, _, err := index.selectRelevant("+ url := os.Getenv(\"DATABASE_URL\")\n+ db := connectPostgres(url)")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(selected) == 0 || selected[0] != "document://docs/README.md#Configuration/Database" {
+		t.Errorf("expected the database section to be the most relevant, got: %v", selected)
+	}
+	if len(selected) > 2 {
+		t.Errorf("expected at most 2 selections, got: %v", selected)
+	}
+
+	// The selected section is kept along with its parent section and document
+	ids := getSelectedIDs(documents)
+	for _, expected := range []string{"document://docs/README.md", "document://docs/README.md#Configuration", "document://docs/README.md#Configuration/Database"} {
+		if !strings.Contains(strings.Join(ids, "\n"), expected) {
+			t.Errorf("expected %s to be included, got: %v", expected, ids)
+		}
+	}
+	for _, id := range ids {
+		if strings.Contains(id, "Logging") || strings.Contains(id, "Installation") {
+			t.Errorf("expected %s to not be included", id)
+		}
+	}
+}
+
+func TestSelectRelevant_NoMatches(t *testing.T) {
+	index, _, err := newRelevanceIndex(relevanceTestDocuments(), &config.CheckOptionsRelevance{MaxSections: 2}, &config.LLM{}, nil)
+	if err != nil || index == nil {
+		t.Fatalf("expected an index, got error: %v", err)
+	}
+
+	documents, selected, _, err := index.selectRelevant("zzz")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// When nothing shares any terms with the query the first maxSections documents and sections are
+	// selected rather than no documentation at all
+	expected := []string{"document://docs/README.md", "document://docs/README.md#Installation"}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("expected %v to be selected, got: %v", expected, selected)
+	}
+	if len(documents) != 1 || documents[0].Document.ID != "README.md" || len(documents[0].Sections) != 1 {
+		t.Errorf("expected README.md with only its Installation section, got: %v", getSelectedIDs(documents))
+	}
+}
+
+func TestSelectRelevant_Embeddings(t *testing.T) {
+	// The fake embeddings provider embeds texts mentioning shipping close to one another, so that
+	// they match even though they do not share any terms
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		data := []map[string]interface{}{}
+		for i, input := range request.Input {
+			embedding := []float64{0, 1}
+			if strings.Contains(input, "shipped") || strings.Contains(input, "dispatch") {
+				embedding = []float64{1, 0}
+			}
+			data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": embedding})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"model":  "test-embed",
+			"data":   data,
+			"usage":  map[string]interface{}{"prompt_tokens": len(request.Input), "total_tokens": len(request.Input)},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.CheckOptionsRelevance{
+		MaxSections: 1,
+		Embeddings: &config.CheckOptionsRelevanceEmbeddings{
+			Provider: config.LLMProviderOpenAICompatible,
+			Model:    "test-embed",
+			Endpoint: server.URL,
+		},
+	}
+	index, usage, err := newRelevanceIndex(relevanceTestDocuments(), cfg, &config.LLM{}, llm.Embed)
+	if err != nil || index == nil {
+		t.Fatalf("expected an index, got error: %v", err)
+	}
+	if usage.InputTokens != int64(len(index.entries)) {
+		t.Errorf("expected %d input tokens to embed the documentation, got %d", len(index.entries), usage.InputTokens)
+	}
+
+	_, selected, usage, err := index.selectRelevant("dispatch")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := []string{"document://docs/orders.md#Cancelling Orders"}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("expected %v, got %v", expected, selected)
+	}
+	if usage.InputTokens != 1 {
+		t.Errorf("expected 1 input token to embed the query, got %d", usage.InputTokens)
+	}
+
+	// Without an embed handler (such as for a dry run) only BM25 is used
+	index, usage, err = newRelevanceIndex(relevanceTestDocuments(), cfg, &config.LLM{}, nil)
+	if err != nil || index == nil {
+		t.Fatalf("expected an index, got error: %v", err)
+	}
+	if index.embeddings != nil || !usage.IsZero() {
+		t.Errorf("expected no embeddings or usage, got %d embeddings and %+v", len(index.embeddings), usage)
+	}
+	_, selected, _, err = index.selectRelevant("dispatch")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected = []string{"document://docs/README.md"}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Errorf("expected the first document to be selected without embeddings, got %v", selected)
+	}
+}
+
+func TestDiff_RelevanceLimitsPromptDocumentation(t *testing.T) {
+	var prompts []string
+	callLLM := func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		prompts = append(prompts, prompt)
+		return "", llm.Usage{}, nil
+	}
+
+	files := []code.FilteredFile{
+		{Filename: "orders/cancel.go", Action: code.ActionModify, Diff: "+func cancelOrder(id string) error {\n"},
+	}
+	checkCfg := &config.Check{Options: config.CheckOptions{Relevance: config.CheckOptionsRelevance{MaxSections: 1}}}
+
+	_, _, _, err := Diff(files, relevanceTestDocuments(), nil, nil, nil, checkCfg, &config.LLM{}, callLLM, nil)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+	if len(prompts) != 1 {
+		t.Fatalf("Expected 1 prompt, but got %d", len(prompts))
+	}
+	if !strings.Contains(prompts[0], `<section id="document://docs/orders.md#Cancelling Orders">`) {
+		t.Errorf("Expected the prompt to include the relevant section, got: %s", prompts[0])
+	}
+	if strings.Contains(prompts[0], "README.md") {
+		t.Errorf("Expected the prompt to not include irrelevant documents, got: %s", prompts[0])
+	}
+}
+
+func TestDiff_EmbeddingsUsage(t *testing.T) {
+	callLLM := func(systemPrompt string, prompt string, tools []*llm.Tool, cfg *config.LLM) (string, llm.Usage, error) {
+		return "", llm.Usage{InputTokens: 100}, nil
+	}
+	embed := func(texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) ([][]float64, llm.Usage, error) {
+		embeddings := make([][]float64, len(texts))
+		for i := range texts {
+			embeddings[i] = []float64{1, 0}
+		}
+		return embeddings, llm.Usage{InputTokens: int64(len(texts))}, nil
+	}
+
+	files := []code.FilteredFile{
+		{Filename: "orders/cancel.go", Action: code.ActionModify, Diff: "+func cancelOrder(id string) error {\n"},
+	}
+	checkCfg := &config.Check{Options: config.CheckOptions{Relevance: config.CheckOptionsRelevance{
+		MaxSections: 1,
+		Embeddings:  &config.CheckOptionsRelevanceEmbeddings{Provider: config.LLMProviderOpenAICompatible, Model: "test-embed"},
+	}}}
+
+	// The documentation and the query for each file are embedded, and reported separately from the
+	// usage of each file
+	_, _, usage, err := Diff(files, relevanceTestDocuments(), nil, nil, nil, checkCfg, &config.LLM{}, callLLM, embed)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+	embeddingTokens := usage.Embeddings.TotalTokens()
+	if embeddingTokens < 2 {
+		t.Errorf("Expected the documentation and query to be embedded, but got %d tokens", embeddingTokens)
+	}
+	if len(usage.Files) != 1 || usage.Files[0].Usage.TotalTokens() != 100 {
+		t.Errorf("Expected 100 tokens to be used checking the file, but got %+v", usage.Files)
+	}
+
+	// Embeddings count against the budget
+	_, _, _, err = Diff(files, relevanceTestDocuments(), nil, nil, nil, checkCfg, &config.LLM{Budget: config.LLMBudget{MaxTokens: embeddingTokens - 1}}, callLLM, embed)
+	if err == nil || !strings.Contains(err.Error(), "llm.budget.maxTokens") {
+		t.Errorf("Expected the budget to be exceeded, but got %v", err)
+	}
+}
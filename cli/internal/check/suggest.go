@@ -0,0 +1,219 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"hyaline/internal/code"
+	"hyaline/internal/config"
+	"hyaline/internal/diff"
+	"hyaline/internal/docs"
+	"hyaline/internal/llm"
+	"hyaline/internal/tool"
+	"log/slog"
+	"strings"
+)
+
+// Suggestion is a proposed rewrite of a document or section, along with a unified diff of the
+// rewrite against the original document
+type Suggestion struct {
+	Content string `json:"content,omitempty"`
+	Diff    string `json:"diff"`
+}
+
+const suggestUpdateName = "suggest_update"
+const suggestNoUpdateName = "no_update_needed"
+
+type suggestUpdateSchema struct {
+	Content string `json:"content" jsonschema:"title=The updated content,description=The full updated content of the document or section in the same format as the original,example=Install the CLI using npm install -g my-app"`
+}
+
+type suggestNoUpdateSchema struct {
+}
+
+// Suggest asks the LLM to rewrite a markdown document or section (if sectionID is not empty) so
+// that it reflects the changes to files. A nil suggestion is returned if the LLM determines no
+// update is needed. filename is the path of the document used in the diff of the suggestion.
+// Secrets in the documentation are redacted from the prompt by redactor (if any) and restored in
+// the suggestion.
+func Suggest(document *docs.FilteredDoc, sectionID string, reasons []Reason, files []code.FilteredFile, filename string, redactor *Redactor, llmCfg *config.LLM, callLLM llm.CallLLMHandler) (suggestion *Suggestion, usage llm.Usage, err error) {
+	// Suggestions are diffed against (and applied to) the markdown on disk, so other types of
+	// documents are not supported
+	if document.Document.Type != config.DocTypeMarkdown.String() {
+		err = fmt.Errorf("could not suggest an update for document %s, only markdown documents are supported (got %s)", document.Document.ID, document.Document.Type)
+		slog.Debug("check.Suggest received an unsupported document type", "error", err)
+		return
+	}
+
+	original := document.Document.ExtractedData
+	if sectionID != "" {
+		section := findSection(document.Sections, sectionID)
+		if section == nil {
+			err = fmt.Errorf("could not find section %s in document %s", sectionID, document.Document.ID)
+			slog.Debug("check.Suggest could not find section", "error", err)
+			return
+		}
+		original = section.Section.ExtractedData
+	}
+
+	uri := docs.DocumentURI{SourceID: document.Document.SourceID, DocumentPath: document.Document.ID, Section: sectionID}
+	redactedOriginal := redactor.Redact(RedactionSourceDocument, uri.String(), original)
+	prompt, err := formatSuggestPrompt(document, sectionID, redactedOriginal, reasons, files)
+	if err != nil {
+		slog.Debug("check.Suggest could not format prompt", "error", err)
+		return
+	}
+
+	// Tools for the LLM response
+	var content string
+	tools := []*llm.Tool{
+		{
+			Name:        suggestUpdateName,
+			Description: "Record the updated content of the documentation",
+			Schema:      tool.Reflector.Reflect(&suggestUpdateSchema{}),
+			Callback: func(input string) (bool, string, error) {
+				var update suggestUpdateSchema
+				err := json.Unmarshal([]byte(input), &update)
+				if err != nil {
+					slog.Debug("check.Suggest - could not parse tool call input, invalid json", "tool", suggestUpdateName, "input", input, "error", err)
+					return true, "", err
+				}
+				content = update.Content
+
+				return true, "", nil
+			},
+		},
+		{
+			Name:        suggestNoUpdateName,
+			Description: "Record that the documentation does not need to be updated",
+			Schema:      tool.Reflector.Reflect(&suggestNoUpdateSchema{}),
+			Callback: func(input string) (bool, string, error) {
+				return true, "", nil
+			},
+		},
+	}
+
+	systemPrompt := "You are a senior technical writer who writes clear and accurate documentation."
+	slog.Debug("check.Suggest calling llm", "document", document.Document.ID, "section", sectionID)
+	_, usage, err = callLLM(systemPrompt, prompt, tools, llmCfg)
+	if err != nil {
+		slog.Debug("check.Suggest encountered an error when calling the llm", "error", err)
+		return
+	}
+
+	content = strings.TrimSpace(redactor.Restore(content))
+	if content == "" || content == strings.TrimSpace(original) {
+		slog.Debug("check.Suggest - no update suggested", "document", document.Document.ID, "section", sectionID)
+		return
+	}
+
+	unified, err := formatSuggestionDiff(filename, document.Document.RawData, original, content)
+	if err != nil {
+		slog.Debug("check.Suggest could not format diff", "error", err)
+		return
+	}
+	suggestion = &Suggestion{
+		Content: content,
+		Diff:    unified,
+	}
+
+	return
+}
+
+// formatSuggestionDiff returns a unified diff of the document as it was extracted (its raw
+// contents) with the original content replaced by the suggested content. If the original content
+// cannot be found in the raw contents, the diff is of the original content alone.
+func formatSuggestionDiff(filename string, rawData string, original string, content string) (string, error) {
+	before := original
+	after := content
+	// Line endings are normalized in the same way as when extracting markdown
+	rawData = strings.ReplaceAll(rawData, "\r\n", "\n")
+	if index := strings.Index(rawData, original); original != "" && index != -1 {
+		before = rawData
+		after = rawData[:index] + content + rawData[index+len(original):]
+	}
+
+	// Ensure each side ends with a newline so the diff does not need to report a missing newline
+	if !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	if !strings.HasSuffix(after, "\n") {
+		after += "\n"
+	}
+
+	edits := diff.Strings(before, after)
+	return diff.ToUnified("a/"+filename, "b/"+filename, before, edits, 3)
+}
+
+func formatSuggestPrompt(document *docs.FilteredDoc, sectionID string, original string, reasons []Reason, files []code.FilteredFile) (string, error) {
+	var prompt strings.Builder
+
+	tagName := "document"
+	if sectionID != "" {
+		tagName = "section"
+	}
+	uri := docs.DocumentURI{SourceID: document.Document.SourceID, DocumentPath: document.Document.ID, Section: sectionID}
+
+	fmt.Fprintf(&prompt, "The current contents of the %s are given in <%s>.\n\n", tagName, tagName)
+	fmt.Fprintf(&prompt, "<%s>\n", tagName)
+	fmt.Fprintf(&prompt, "  <%s_uri>%s</%s_uri>\n", tagName, uri.String(), tagName)
+	fmt.Fprintf(&prompt, "  <%s_content>\n", tagName)
+	prompt.WriteString(original)
+	prompt.WriteString("\n")
+	fmt.Fprintf(&prompt, "  </%s_content>\n", tagName)
+	fmt.Fprintf(&prompt, "</%s>\n\n", tagName)
+
+	// Add the reasons the documentation may need to be updated
+	prompt.WriteString("<reasons>\n")
+	for _, reason := range reasons {
+		fmt.Fprintf(&prompt, "  <reason>%s</reason>\n", reason.Reason)
+	}
+	prompt.WriteString("</reasons>\n\n")
+
+	// Add the changes to each file referenced by a reason
+	referenced := map[string]struct{}{}
+	for _, reason := range reasons {
+		referenced[reason.Check.File] = struct{}{}
+	}
+	for _, file := range files {
+		_, filenameReferenced := referenced[file.Filename]
+		_, originalFilenameReferenced := referenced[file.OriginalFilename]
+		if !filenameReferenced && !originalFilenameReferenced {
+			continue
+		}
+		textDiff, err := getFileDiff(file)
+		if err != nil {
+			return "", err
+		}
+		filename := file.Filename
+		if filename == "" {
+			filename = file.OriginalFilename
+		}
+		prompt.WriteString("<diff>\n")
+		fmt.Fprintf(&prompt, "  <file_name>%s</file_name>\n", filename)
+		prompt.WriteString(textDiff)
+		prompt.WriteString("</diff>\n\n")
+	}
+
+	// Add instructions
+	fmt.Fprintf(&prompt, "Given the code changes in <diff> and the reasons in <reasons> that the %s may need to be updated, rewrite the %s so that it accurately reflects the changes. ", tagName, tagName)
+	prompt.WriteString("Keep the existing structure, headings, formatting, and style, and only change what is needed. ")
+	fmt.Fprintf(&prompt, "Then, call the provided %s tool with the full updated contents of the %s. ", suggestUpdateName, tagName)
+	fmt.Fprintf(&prompt, "If the %s does not need to be updated call the %s tool instead.", tagName, suggestNoUpdateName)
+
+	return prompt.String(), nil
+}
+
+// findSection returns the section with the given ID (searching nested sections), or nil if it is
+// not found
+func findSection(sections []docs.FilteredSection, sectionID string) *docs.FilteredSection {
+	for i := range sections {
+		if sections[i].Section.ID == sectionID {
+			return &sections[i]
+		}
+		if section := findSection(sections[i].Sections, sectionID); section != nil {
+			return section
+		}
+	}
+
+	return nil
+}
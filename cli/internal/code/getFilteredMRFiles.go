@@ -0,0 +1,69 @@
+package code
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"hyaline/internal/gitlab"
+	"log/slog"
+)
+
+// GetFilteredMRFiles retrieves filtered files from a GitLab Merge Request
+func GetFilteredMRFiles(mergeRequest string, gitlabCfg *config.GitLab, cfg *config.CheckCode) (filteredFiles []FilteredFile, changedFiles map[string]struct{}, err error) {
+	changedFiles = make(map[string]struct{})
+
+	// Get MR files
+	files, err := gitlab.GetMergeRequestFiles(mergeRequest, gitlabCfg)
+	if err != nil {
+		slog.Debug("code.GetFilteredMRFiles could not get MR files", "error", err, "merge-request", mergeRequest)
+		return
+	}
+	slog.Info("Retrieved MR files", "files", len(files), "merge-request", mergeRequest)
+
+	// Examine each change in the MR
+	for _, file := range files {
+		slog.Debug("code.GetFilteredMRFiles processing file", "newPath", file.NewPath, "oldPath", file.OldPath)
+
+		switch {
+		case file.NewFile:
+			changedFiles[file.NewPath] = struct{}{}
+			if config.PathIsIncluded(file.NewPath, cfg.Include, cfg.Exclude) {
+				filteredFiles = append(filteredFiles, FilteredFile{
+					Filename: file.NewPath,
+					Action:   ActionInsert,
+					Diff:     fmt.Sprintf("--- /dev/null\n+++ b/%s\n%s", file.NewPath, file.Diff),
+				})
+			}
+		case file.DeletedFile:
+			changedFiles[file.OldPath] = struct{}{}
+			if config.PathIsIncluded(file.OldPath, cfg.Include, cfg.Exclude) {
+				filteredFiles = append(filteredFiles, FilteredFile{
+					OriginalFilename: file.OldPath,
+					Action:           ActionDelete,
+					Diff:             fmt.Sprintf("--- a/%s\n+++ /dev/null\n%s", file.OldPath, file.Diff),
+				})
+			}
+		case file.RenamedFile:
+			changedFiles[file.OldPath] = struct{}{}
+			changedFiles[file.NewPath] = struct{}{}
+			if config.PathIsIncluded(file.NewPath, cfg.Include, cfg.Exclude) {
+				filteredFiles = append(filteredFiles, FilteredFile{
+					Filename:         file.NewPath,
+					OriginalFilename: file.OldPath,
+					Action:           ActionRename,
+					Diff:             fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", file.OldPath, file.NewPath, file.Diff),
+				})
+			}
+		default:
+			changedFiles[file.NewPath] = struct{}{}
+			if config.PathIsIncluded(file.NewPath, cfg.Include, cfg.Exclude) {
+				filteredFiles = append(filteredFiles, FilteredFile{
+					Filename: file.NewPath,
+					Action:   ActionModify,
+					Diff:     fmt.Sprintf("--- a/%s\n+++ b/%s\n%s", file.NewPath, file.NewPath, file.Diff),
+				})
+			}
+		}
+	}
+
+	return
+}
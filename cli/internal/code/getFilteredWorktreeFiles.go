@@ -0,0 +1,48 @@
+package code
+
+import (
+	"hyaline/internal/config"
+	"hyaline/internal/repo"
+	"log/slog"
+)
+
+// GetFilteredWorktreeFiles filters uncommitted changes (see repo.GetWorktreeChanges), where each
+// change is an insert, modification, or deletion depending on which side of the change the file
+// exists on
+func GetFilteredWorktreeFiles(changes []repo.WorktreeChange, cfg *config.CheckCode) (filteredFiles []FilteredFile, changedFiles map[string]struct{}) {
+	changedFiles = make(map[string]struct{})
+
+	for _, change := range changes {
+		changedFiles[change.Path] = struct{}{}
+		if !config.PathIsIncluded(change.Path, cfg.Include, cfg.Exclude) {
+			continue
+		}
+
+		switch {
+		case change.Original == nil:
+			filteredFiles = append(filteredFiles, FilteredFile{
+				Filename: change.Path,
+				Action:   ActionInsert,
+				Contents: change.Current,
+			})
+		case change.Current == nil:
+			filteredFiles = append(filteredFiles, FilteredFile{
+				OriginalFilename: change.Path,
+				Action:           ActionDelete,
+				OriginalContents: change.Original,
+			})
+		default:
+			filteredFiles = append(filteredFiles, FilteredFile{
+				Filename:         change.Path,
+				OriginalFilename: change.Path,
+				Action:           ActionModify,
+				Contents:         change.Current,
+				OriginalContents: change.Original,
+			})
+		}
+	}
+
+	slog.Info("Filtered uncommitted files", "total", len(changes), "filtered", len(filteredFiles))
+
+	return
+}
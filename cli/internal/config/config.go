@@ -3,21 +3,56 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	LLM     LLM      `yaml:"llm,omitempty"`
 	GitHub  GitHub   `yaml:"github,omitempty"`
+	GitLab  GitLab   `yaml:"gitlab,omitempty"`
 	Extract *Extract `yaml:"extract,omitempty"`
 	Check   *Check   `yaml:"check,omitempty"`
 	Audit   *Audit   `yaml:"audit,omitempty"`
 }
 
 type LLM struct {
-	Provider LLMProvider `yaml:"provider,omitempty"`
-	Model    string      `yaml:"model,omitempty"`
-	Key      string      `yaml:"key,omitempty"`
-	Endpoint string      `yaml:"endpoint,omitempty"`
+	Provider    LLMProvider    `yaml:"provider,omitempty"`
+	Model       string         `yaml:"model,omitempty"`
+	Key         string         `yaml:"key,omitempty"`
+	Endpoint    string         `yaml:"endpoint,omitempty"`
+	ToolCalling LLMToolCalling `yaml:"toolCalling,omitempty"`
+	Record      string         `yaml:"record,omitempty"`
+	Replay      string         `yaml:"replay,omitempty"`
+	Cache       string         `yaml:"cache,omitempty"`
+	Concurrency int            `yaml:"concurrency,omitempty"`
+	RateLimit   LLMRateLimit   `yaml:"rateLimit,omitempty"`
+	MaxTokens   int            `yaml:"maxTokens,omitempty"`
+	Retries     *int           `yaml:"retries,omitempty"`
+	Timeout     LLMTimeout     `yaml:"timeout,omitempty"`
+	Pricing     *LLMPricing    `yaml:"pricing,omitempty"`
+	Budget      LLMBudget      `yaml:"budget,omitempty"`
+}
+
+type LLMTimeout struct {
+	Call  time.Duration `yaml:"call,omitempty"`
+	Total time.Duration `yaml:"total,omitempty"`
+}
+
+// LLMPricing is the price of each type of token, in USD per million tokens
+type LLMPricing struct {
+	Input      float64 `yaml:"input,omitempty"`
+	Output     float64 `yaml:"output,omitempty"`
+	CacheRead  float64 `yaml:"cacheRead,omitempty"`
+	CacheWrite float64 `yaml:"cacheWrite,omitempty"`
+}
+
+type LLMBudget struct {
+	MaxTokens int64 `yaml:"maxTokens,omitempty"`
+}
+
+type LLMRateLimit struct {
+	RequestsPerMinute int `yaml:"requestsPerMinute,omitempty"`
+	TokensPerMinute   int `yaml:"tokensPerMinute,omitempty"`
 }
 
 type LLMProvider string
@@ -28,7 +63,39 @@ func (p LLMProvider) String() string {
 
 func (p LLMProvider) IsValidLLMProvider() bool {
 	switch p {
-	case LLMProviderAnthropic, LLMProviderOpenAI, LLMProviderGitHubModels, LLMProviderTesting:
+	case LLMProviderAnthropic, LLMProviderOpenAI, LLMProviderGitHubModels, LLMProviderOllama, LLMProviderOpenAICompatible, LLMProviderTesting:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsOpenAICompatible returns true if the provider is a local or self-hosted server exposing an
+// OpenAI compatible API, which may not support all of the features of the OpenAI API
+func (p LLMProvider) IsOpenAICompatible() bool {
+	return p == LLMProviderOllama || p == LLMProviderOpenAICompatible
+}
+
+const (
+	LLMProviderAnthropic        LLMProvider = "anthropic"
+	LLMProviderOpenAI           LLMProvider = "openai"
+	LLMProviderGitHubModels     LLMProvider = "github-models"
+	LLMProviderOllama           LLMProvider = "ollama"
+	LLMProviderOpenAICompatible LLMProvider = "openai-compatible"
+	LLMProviderTesting          LLMProvider = "testing"
+)
+
+const DefaultOllamaEndpoint = "http://localhost:11434/v1"
+
+type LLMToolCalling string
+
+func (t LLMToolCalling) String() string {
+	return string(t)
+}
+
+func (t LLMToolCalling) IsValidLLMToolCalling() bool {
+	switch t {
+	case LLMToolCallingAuto, LLMToolCallingNative, LLMToolCallingJSON:
 		return true
 	default:
 		return false
@@ -36,16 +103,33 @@ func (p LLMProvider) IsValidLLMProvider() bool {
 }
 
 const (
-	LLMProviderAnthropic    LLMProvider = "anthropic"
-	LLMProviderOpenAI       LLMProvider = "openai"
-	LLMProviderGitHubModels LLMProvider = "github-models"
-	LLMProviderTesting      LLMProvider = "testing"
+	// Use native tool calling, falling back to JSON mode if the server does not support tools
+	LLMToolCallingAuto LLMToolCalling = "auto"
+	// Use native tool calling only
+	LLMToolCallingNative LLMToolCalling = "native"
+	// Describe tools in the prompt and parse tool calls from a JSON response
+	LLMToolCallingJSON LLMToolCalling = "json"
 )
 
 type GitHub struct {
 	Token string `yaml:"token,omitempty"`
 }
 
+const DefaultGitLabURL = "https://gitlab.com/api/v4"
+
+type GitLab struct {
+	URL   string `yaml:"url,omitempty"`
+	Token string `yaml:"token,omitempty"`
+}
+
+// GetURL returns the configured GitLab API base URL, defaulting to gitlab.com
+func (g *GitLab) GetURL() string {
+	if g.URL == "" {
+		return DefaultGitLabURL
+	}
+	return strings.TrimSuffix(g.URL, "/")
+}
+
 type Extractor struct {
 	Type    CrawlerType    `yaml:"type,omitempty"`
 	Options CrawlerOptions `yaml:"options,omitempty"`
@@ -153,7 +237,7 @@ func (dt ExtractorType) String() string {
 
 func (dt ExtractorType) IsValid() bool {
 	switch dt {
-	case DocTypeMarkdown, DocTypeHTML:
+	case DocTypeMarkdown, DocTypeHTML, DocTypeRst, DocTypeAsciiDoc, DocTypeGoDoc, DocTypeOpenAPI, DocTypeIpynb, DocTypeDocx, DocTypePdf:
 		return true
 	default:
 		return false
@@ -161,12 +245,19 @@ func (dt ExtractorType) IsValid() bool {
 }
 
 func (dt ExtractorType) PossibleValues() string {
-	return fmt.Sprintf("%s, %s", DocTypeMarkdown, DocTypeHTML)
+	return fmt.Sprintf("%s, %s, %s, %s, %s, %s, %s, %s, %s", DocTypeMarkdown, DocTypeHTML, DocTypeRst, DocTypeAsciiDoc, DocTypeGoDoc, DocTypeOpenAPI, DocTypeIpynb, DocTypeDocx, DocTypePdf)
 }
 
 const (
 	DocTypeMarkdown ExtractorType = "md"
 	DocTypeHTML     ExtractorType = "html"
+	DocTypeRst      ExtractorType = "rst"
+	DocTypeAsciiDoc ExtractorType = "adoc"
+	DocTypeGoDoc    ExtractorType = "godoc"
+	DocTypeOpenAPI  ExtractorType = "openapi"
+	DocTypeIpynb    ExtractorType = "ipynb"
+	DocTypeDocx     ExtractorType = "docx"
+	DocTypePdf      ExtractorType = "pdf"
 )
 
 type ExtractorOptions struct {
@@ -271,10 +362,45 @@ type CheckDocumentation struct {
 type CheckOptions struct {
 	DetectDocumentationUpdates CheckOptionsDetectDocumentationUpdates `yaml:"detectDocumentationUpdates,omitempty"`
 	UpdateIf                   CheckOptionsUpdateIf                   `yaml:"updateIf,omitempty"`
+	Relevance                  CheckOptionsRelevance                  `yaml:"relevance,omitempty"`
+	Redaction                  CheckOptionsRedaction                  `yaml:"redaction,omitempty"`
+}
+
+// CheckOptionsRedaction replaces secrets (found by the built-in detectors and any custom patterns)
+// with placeholders before code changes, pull requests, issues, commit messages, and documentation
+// are sent to the LLM
+type CheckOptionsRedaction struct {
+	Enabled  bool                           `yaml:"enabled,omitempty"`
+	Patterns []CheckOptionsRedactionPattern `yaml:"patterns,omitempty"`
+	AuditLog string                         `yaml:"auditLog,omitempty"`
+}
+
+// CheckOptionsRedactionPattern is a custom secret detector. If the regex has a capture group only
+// the first group is redacted, otherwise the entire match is redacted.
+type CheckOptionsRedactionPattern struct {
+	Name  string `yaml:"name,omitempty"`
+	Regex string `yaml:"regex,omitempty"`
+}
+
+// CheckOptionsRelevance limits the documentation included in the prompt for each file to the
+// sections most relevant to the change (so that prompts fit within the LLM's context window)
+type CheckOptionsRelevance struct {
+	MaxSections int                              `yaml:"maxSections,omitempty"`
+	Embeddings  *CheckOptionsRelevanceEmbeddings `yaml:"embeddings,omitempty"`
+}
+
+// CheckOptionsRelevanceEmbeddings is the provider used to embed documentation and changes when
+// ranking documentation by relevance
+type CheckOptionsRelevanceEmbeddings struct {
+	Provider LLMProvider `yaml:"provider,omitempty"`
+	Model    string      `yaml:"model,omitempty"`
+	Key      string      `yaml:"key,omitempty"`
+	Endpoint string      `yaml:"endpoint,omitempty"`
 }
 
 type CheckOptionsDetectDocumentationUpdates struct {
 	Source string `yaml:"source,omitempty"`
+	Path   string `yaml:"path,omitempty"`
 }
 
 type CheckOptionsUpdateIf struct {
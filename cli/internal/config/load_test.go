@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGetEscapedEnv(t *testing.T) {
@@ -29,3 +31,22 @@ Line2`, `"Line1\"\nLine2"`},
 		}
 	}
 }
+
+func TestLoadLLMTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hyaline.yml")
+	err := os.WriteFile(path, []byte("llm:\n  provider: anthropic\n  retries: 0\n  timeout:\n    call: 90s\n    total: 30m\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatalf("expected no error, got error: %s", err.Error())
+	}
+	if cfg.LLM.Timeout.Call != 90*time.Second || cfg.LLM.Timeout.Total != 30*time.Minute {
+		t.Errorf("got timeout %+v, wanted call 90s and total 30m", cfg.LLM.Timeout)
+	}
+	if cfg.LLM.Retries == nil || *cfg.LLM.Retries != 0 {
+		t.Errorf("got retries %v, wanted 0", cfg.LLM.Retries)
+	}
+}
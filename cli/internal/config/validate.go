@@ -17,6 +17,13 @@ func Validate(cfg *Config) (err error) {
 		return
 	}
 
+	// Validate GitLab
+	err = ValidateGitLab(cfg)
+	if err != nil {
+		slog.Debug("config.Validate found invalid gitlab", "error", err)
+		return
+	}
+
 	// Verify extract
 	err = ValidateExtract(cfg)
 	if err != nil {
@@ -3,6 +3,8 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"path"
 	"regexp"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -52,6 +54,15 @@ func ValidateCheck(cfg *Config) error {
 			return fmt.Errorf("extract.options.detectDocumentationUpdates.source must match regex /%s/, found: %s", sourceIDRegex, source)
 		}
 	}
+	if cfg.Check.Options.DetectDocumentationUpdates.Path != "" {
+		updatePath := cfg.Check.Options.DetectDocumentationUpdates.Path
+		if path.IsAbs(updatePath) || !fs.ValidPath(path.Clean(updatePath)) {
+			return fmt.Errorf("check.options.detectDocumentationUpdates.path must be a relative path within the repository, found: %s", updatePath)
+		}
+		if cfg.Check.Options.DetectDocumentationUpdates.Source == "" {
+			return errors.New("check.options.detectDocumentationUpdates.source must be set when check.options.detectDocumentationUpdates.path is set")
+		}
+	}
 	if err := validateCheckUpdateIf("check.options.updateIf.touched", cfg.Check.Options.UpdateIf.Touched); err != nil {
 		return err
 	}
@@ -67,6 +78,12 @@ func ValidateCheck(cfg *Config) error {
 	if err := validateCheckUpdateIf("check.options.updateIf.renamed", cfg.Check.Options.UpdateIf.Renamed); err != nil {
 		return err
 	}
+	if err := validateCheckRelevance(&cfg.Check.Options.Relevance); err != nil {
+		return err
+	}
+	if err := validateCheckRedaction(&cfg.Check.Options.Redaction); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -84,6 +101,63 @@ func validateCheckUpdateIf(location string, entries []CheckOptionsUpdateIfEntry)
 	return nil
 }
 
+func validateCheckRelevance(relevance *CheckOptionsRelevance) error {
+	if relevance.MaxSections < 0 {
+		return fmt.Errorf("check.options.relevance.maxSections must be non-negative, found: %d", relevance.MaxSections)
+	}
+
+	embeddings := relevance.Embeddings
+	if embeddings == nil {
+		return nil
+	}
+	if relevance.MaxSections == 0 {
+		return errors.New("check.options.relevance.maxSections must be set when check.options.relevance.embeddings is set")
+	}
+	switch embeddings.Provider {
+	case LLMProviderOpenAI, LLMProviderGitHubModels, LLMProviderOllama, LLMProviderOpenAICompatible:
+	default:
+		return fmt.Errorf("check.options.relevance.embeddings.provider must be one of %s, %s, %s, or %s, found: %s", LLMProviderOpenAI, LLMProviderGitHubModels, LLMProviderOllama, LLMProviderOpenAICompatible, embeddings.Provider)
+	}
+	if embeddings.Model == "" {
+		return errors.New("check.options.relevance.embeddings.model must be set")
+	}
+	if embeddings.Provider == LLMProviderOpenAICompatible && embeddings.Endpoint == "" {
+		return fmt.Errorf("check.options.relevance.embeddings.endpoint must be set for the %s provider", LLMProviderOpenAICompatible)
+	}
+
+	return nil
+}
+
+// redactionPatternNameRegex is the format of the name of a custom redaction pattern, which is
+// included in the placeholder that replaces each match
+const redactionPatternNameRegex = `^[a-z0-9]+(-[a-z0-9]+)*$`
+
+func validateCheckRedaction(redaction *CheckOptionsRedaction) error {
+	if !redaction.Enabled {
+		if len(redaction.Patterns) > 0 {
+			return errors.New("check.options.redaction.enabled must be true when check.options.redaction.patterns is set")
+		}
+		if redaction.AuditLog != "" {
+			return errors.New("check.options.redaction.enabled must be true when check.options.redaction.auditLog is set")
+		}
+	}
+
+	for i, pattern := range redaction.Patterns {
+		if !regexp.MustCompile(redactionPatternNameRegex).MatchString(pattern.Name) {
+			return fmt.Errorf("check.options.redaction.patterns[%d].name must match regex /%s/, found: %s", i, redactionPatternNameRegex, pattern.Name)
+		}
+		regex, err := regexp.Compile(pattern.Regex)
+		if pattern.Regex == "" || err != nil {
+			return fmt.Errorf("check.options.redaction.patterns[%d].regex must be a valid regular expression, found: %s", i, pattern.Regex)
+		}
+		if regex.MatchString("") {
+			return fmt.Errorf("check.options.redaction.patterns[%d].regex must not match an empty string, found: %s", i, pattern.Regex)
+		}
+	}
+
+	return nil
+}
+
 func validateCheckCodeFilter(location string, filter CheckCodeFilter) error {
 	if filter.Path == "" || !doublestar.ValidatePattern(filter.Path) {
 		return fmt.Errorf("%s.path must be a valid pattern, found: %s", location, filter.Path)
@@ -48,6 +48,29 @@ func TestValidateCheck(t *testing.T) {
 			Source: "**invalid**",
 		},
 	}
+	validOptionsUpdatePath := CheckOptions{
+		DetectDocumentationUpdates: CheckOptionsDetectDocumentationUpdates{
+			Source: "docs",
+			Path:   "docs/",
+		},
+	}
+	invalidOptionsAbsoluteUpdatePath := CheckOptions{
+		DetectDocumentationUpdates: CheckOptionsDetectDocumentationUpdates{
+			Source: "docs",
+			Path:   "/docs",
+		},
+	}
+	invalidOptionsEscapingUpdatePath := CheckOptions{
+		DetectDocumentationUpdates: CheckOptionsDetectDocumentationUpdates{
+			Source: "docs",
+			Path:   "../docs",
+		},
+	}
+	invalidOptionsUpdatePathNoSource := CheckOptions{
+		DetectDocumentationUpdates: CheckOptionsDetectDocumentationUpdates{
+			Path: "docs",
+		},
+	}
 	invalidOptionsInvalidUpdateIfTouched := CheckOptions{
 		UpdateIf: CheckOptionsUpdateIf{
 			Touched: []CheckOptionsUpdateIfEntry{
@@ -100,6 +123,10 @@ func TestValidateCheck(t *testing.T) {
 		{&Check{false, validCode, invalidDocumentationInvalidInclude, validOptions}, `check.documentation.include[0].source must be a valid pattern, found: `},
 		{&Check{false, validCode, invalidDocumentationInvalidExclude, validOptions}, `check.documentation.exclude[0].source must be a valid pattern, found: `},
 		{&Check{false, validCode, validDocumentation, invalidOptionsInvalidUpdateSource}, `extract.options.detectDocumentationUpdates.source must match regex /^[A-z0-9][A-z0-9_-]{0,63}$/, found: **invalid**`},
+		{&Check{false, validCode, validDocumentation, validOptionsUpdatePath}, ``},
+		{&Check{false, validCode, validDocumentation, invalidOptionsAbsoluteUpdatePath}, `check.options.detectDocumentationUpdates.path must be a relative path within the repository, found: /docs`},
+		{&Check{false, validCode, validDocumentation, invalidOptionsEscapingUpdatePath}, `check.options.detectDocumentationUpdates.path must be a relative path within the repository, found: ../docs`},
+		{&Check{false, validCode, validDocumentation, invalidOptionsUpdatePathNoSource}, `check.options.detectDocumentationUpdates.source must be set when check.options.detectDocumentationUpdates.path is set`},
 		{&Check{false, validCode, validDocumentation, invalidOptionsInvalidUpdateIfTouched}, `check.options.updateIf.touched[0].code.path must be a valid pattern, found: `},
 		{&Check{false, validCode, validDocumentation, invalidOptionsInvalidUpdateIfAdded}, `check.options.updateIf.added[0].code.path must be a valid pattern, found: `},
 		{&Check{false, validCode, validDocumentation, invalidOptionsInvalidUpdateIfModified}, `check.options.updateIf.modified[0].code.path must be a valid pattern, found: `},
@@ -311,3 +338,67 @@ func TestValidateDocumentationFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateCheckRelevance(t *testing.T) {
+	var tests = []struct {
+		relevance CheckOptionsRelevance
+		err       string
+	}{
+		{CheckOptionsRelevance{}, ``},
+		{CheckOptionsRelevance{MaxSections: 50}, ``},
+		{CheckOptionsRelevance{MaxSections: -1}, `check.options.relevance.maxSections must be non-negative, found: -1`},
+		{CheckOptionsRelevance{MaxSections: 50, Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderOpenAI, Model: "text-embedding-3-small"}}, ``},
+		{CheckOptionsRelevance{MaxSections: 50, Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderOllama, Model: "nomic-embed-text"}}, ``},
+		{CheckOptionsRelevance{Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderOpenAI, Model: "text-embedding-3-small"}}, `check.options.relevance.maxSections must be set when check.options.relevance.embeddings is set`},
+		{CheckOptionsRelevance{MaxSections: 50, Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderAnthropic, Model: "claude"}}, `check.options.relevance.embeddings.provider must be one of openai, github-models, ollama, or openai-compatible, found: anthropic`},
+		{CheckOptionsRelevance{MaxSections: 50, Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderOpenAI}}, `check.options.relevance.embeddings.model must be set`},
+		{CheckOptionsRelevance{MaxSections: 50, Embeddings: &CheckOptionsRelevanceEmbeddings{Provider: LLMProviderOpenAICompatible, Model: "embed"}}, `check.options.relevance.embeddings.endpoint must be set for the openai-compatible provider`},
+	}
+
+	for i, test := range tests {
+		err := validateCheckRelevance(&test.relevance)
+
+		if test.err == "" && err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+		}
+		if test.err != "" && err == nil {
+			t.Errorf("test %d - expected error: %s, got no error", i, test.err)
+		}
+		if test.err != "" && err != nil && err.Error() != test.err {
+			t.Errorf("test %d - expected error: %s, got error: %s", i, test.err, err.Error())
+		}
+	}
+}
+
+func TestValidateCheckRedaction(t *testing.T) {
+	var tests = []struct {
+		redaction CheckOptionsRedaction
+		err       string
+	}{
+		{CheckOptionsRedaction{}, ``},
+		{CheckOptionsRedaction{Enabled: true}, ``},
+		{CheckOptionsRedaction{Enabled: true, AuditLog: "./redactions.json", Patterns: []CheckOptionsRedactionPattern{{Name: "internal-token", Regex: `itk_[A-Za-z0-9]{32}`}}}, ``},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "db-password", Regex: `DB_PASSWORD=(\S+)`}}}, ``},
+		{CheckOptionsRedaction{Patterns: []CheckOptionsRedactionPattern{{Name: "internal-token", Regex: `itk_[A-Za-z0-9]{32}`}}}, `check.options.redaction.enabled must be true when check.options.redaction.patterns is set`},
+		{CheckOptionsRedaction{AuditLog: "./redactions.json"}, `check.options.redaction.enabled must be true when check.options.redaction.auditLog is set`},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "", Regex: `itk_[A-Za-z0-9]{32}`}}}, "check.options.redaction.patterns[0].name must match regex /^[a-z0-9]+(-[a-z0-9]+)*$/, found: "},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "Internal Token", Regex: `itk_[A-Za-z0-9]{32}`}}}, "check.options.redaction.patterns[0].name must match regex /^[a-z0-9]+(-[a-z0-9]+)*$/, found: Internal Token"},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "internal-token"}}}, `check.options.redaction.patterns[0].regex must be a valid regular expression, found: `},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "internal-token", Regex: `itk_[`}}}, `check.options.redaction.patterns[0].regex must be a valid regular expression, found: itk_[`},
+		{CheckOptionsRedaction{Enabled: true, Patterns: []CheckOptionsRedactionPattern{{Name: "internal-token", Regex: `itk_.*`}, {Name: "anything", Regex: `.*`}}}, `check.options.redaction.patterns[1].regex must not match an empty string, found: .*`},
+	}
+
+	for i, test := range tests {
+		err := validateCheckRedaction(&test.redaction)
+
+		if test.err == "" && err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+		}
+		if test.err != "" && err == nil {
+			t.Errorf("test %d - expected error: %s, got no error", i, test.err)
+		}
+		if test.err != "" && err != nil && err.Error() != test.err {
+			t.Errorf("test %d - expected error: %s, got error: %s", i, test.err, err.Error())
+		}
+	}
+}
@@ -155,7 +155,7 @@ func TestValidateExtract(t *testing.T) {
 		{&Extract{false, validSource, invalidCrawlerExclude, validExtractors, validMetadata}, `extract.crawler.exclude[0] must be a valid pattern, found: {a`},
 		{&Extract{false, validSource, invalidCrawlerExcludeEmpty, validExtractors, validMetadata}, `extract.crawler.exclude[0] must be a valid pattern, found: `},
 		{&Extract{false, validSource, validCrawler, invalidExtractorsEmpty, validMetadata}, `extract.extractors must contain at least one extractor, none found`},
-		{&Extract{false, validSource, validCrawler, invalidExtractorsType, validMetadata}, `extract.extractors[0].type must be one of md, html, found: bogus`},
+		{&Extract{false, validSource, validCrawler, invalidExtractorsType, validMetadata}, `extract.extractors[0].type must be one of md, html, rst, adoc, godoc, openapi, ipynb, docx, pdf, found: bogus`},
 		{&Extract{false, validSource, validCrawler, invalidExtractorsInclude, validMetadata}, `extract.extractors[0].include[0] must be a valid pattern, found: {a`},
 		{&Extract{false, validSource, validCrawler, invalidExtractorsIncludeEmpty, validMetadata}, `extract.extractors[0].include[0] must be a valid pattern, found: `},
 		{&Extract{false, validSource, validCrawler, invalidExtractorsExclude, validMetadata}, `extract.extractors[0].exclude[0] must be a valid pattern, found: {a`},
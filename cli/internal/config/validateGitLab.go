@@ -0,0 +1,20 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+)
+
+func ValidateGitLab(cfg *Config) (err error) {
+	if cfg.GitLab.URL != "" {
+		u, parseErr := url.Parse(cfg.GitLab.URL)
+		if parseErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			err = errors.New("gitlab.url must be an absolute http or https url, found: " + cfg.GitLab.URL)
+			slog.Debug("config.Validate found invalid gitlab url", "url", cfg.GitLab.URL, "error", err)
+			return
+		}
+	}
+
+	return
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 )
 
@@ -12,5 +13,97 @@ func ValidateLLM(cfg *Config) (err error) {
 		return
 	}
 
+	if cfg.LLM.Provider == LLMProviderOpenAICompatible && cfg.LLM.Endpoint == "" {
+		err = errors.New("llm.endpoint must be set when llm.provider is " + LLMProviderOpenAICompatible.String())
+		slog.Debug("config.Validate found openai-compatible provider without an endpoint", "error", err)
+		return
+	}
+
+	if cfg.LLM.ToolCalling != "" {
+		if !cfg.LLM.ToolCalling.IsValidLLMToolCalling() {
+			err = fmt.Errorf("llm.toolCalling must be one of %s, %s, %s, found: %s", LLMToolCallingAuto, LLMToolCallingNative, LLMToolCallingJSON, cfg.LLM.ToolCalling)
+			slog.Debug("config.Validate found invalid llm toolCalling", "toolCalling", cfg.LLM.ToolCalling.String(), "error", err)
+			return
+		}
+		if !cfg.LLM.Provider.IsOpenAICompatible() {
+			err = fmt.Errorf("llm.toolCalling can only be set when llm.provider is %s or %s", LLMProviderOllama, LLMProviderOpenAICompatible)
+			slog.Debug("config.Validate found llm toolCalling set for an unsupported provider", "provider", cfg.LLM.Provider.String(), "error", err)
+			return
+		}
+	}
+
+	if cfg.LLM.Concurrency < 0 {
+		err = fmt.Errorf("llm.concurrency must be non-negative, found: %d", cfg.LLM.Concurrency)
+		slog.Debug("config.Validate found invalid llm concurrency", "error", err)
+		return
+	}
+
+	if cfg.LLM.RateLimit.RequestsPerMinute < 0 {
+		err = fmt.Errorf("llm.rateLimit.requestsPerMinute must be non-negative, found: %d", cfg.LLM.RateLimit.RequestsPerMinute)
+		slog.Debug("config.Validate found invalid llm rate limit", "error", err)
+		return
+	}
+
+	if cfg.LLM.RateLimit.TokensPerMinute < 0 {
+		err = fmt.Errorf("llm.rateLimit.tokensPerMinute must be non-negative, found: %d", cfg.LLM.RateLimit.TokensPerMinute)
+		slog.Debug("config.Validate found invalid llm rate limit", "error", err)
+		return
+	}
+
+	if cfg.LLM.MaxTokens < 0 {
+		err = fmt.Errorf("llm.maxTokens must be non-negative, found: %d", cfg.LLM.MaxTokens)
+		slog.Debug("config.Validate found invalid llm maxTokens", "error", err)
+		return
+	}
+
+	if cfg.LLM.Retries != nil && *cfg.LLM.Retries < 0 {
+		err = fmt.Errorf("llm.retries must be non-negative, found: %d", *cfg.LLM.Retries)
+		slog.Debug("config.Validate found invalid llm retries", "error", err)
+		return
+	}
+
+	if cfg.LLM.Timeout.Call < 0 {
+		err = fmt.Errorf("llm.timeout.call must be non-negative, found: %s", cfg.LLM.Timeout.Call)
+		slog.Debug("config.Validate found invalid llm timeout", "error", err)
+		return
+	}
+
+	if cfg.LLM.Timeout.Total < 0 {
+		err = fmt.Errorf("llm.timeout.total must be non-negative, found: %s", cfg.LLM.Timeout.Total)
+		slog.Debug("config.Validate found invalid llm timeout", "error", err)
+		return
+	}
+
+	if cfg.LLM.Pricing != nil {
+		prices := []struct {
+			name  string
+			price float64
+		}{
+			{"input", cfg.LLM.Pricing.Input},
+			{"output", cfg.LLM.Pricing.Output},
+			{"cacheRead", cfg.LLM.Pricing.CacheRead},
+			{"cacheWrite", cfg.LLM.Pricing.CacheWrite},
+		}
+		for _, price := range prices {
+			if price.price < 0 {
+				err = fmt.Errorf("llm.pricing.%s must be non-negative, found: %g", price.name, price.price)
+				slog.Debug("config.Validate found invalid llm pricing", "error", err)
+				return
+			}
+		}
+	}
+
+	if cfg.LLM.Budget.MaxTokens < 0 {
+		err = fmt.Errorf("llm.budget.maxTokens must be non-negative, found: %d", cfg.LLM.Budget.MaxTokens)
+		slog.Debug("config.Validate found invalid llm budget", "error", err)
+		return
+	}
+
+	if cfg.LLM.Record != "" && cfg.LLM.Replay != "" {
+		err = errors.New("llm.record and llm.replay cannot both be set")
+		slog.Debug("config.Validate found both llm record and replay set", "record", cfg.LLM.Record, "replay", cfg.LLM.Replay, "error", err)
+		return
+	}
+
 	return
 }
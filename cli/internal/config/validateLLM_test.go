@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidateLLM(t *testing.T) {
+	var tests = []struct {
+		llm LLM
+		err string
+	}{
+		{LLM{}, ``},
+		{LLM{Provider: LLMProviderAnthropic}, ``},
+		{LLM{Provider: "invalid"}, `invalid llm provider detected: invalid`},
+		{LLM{Provider: LLMProviderOllama}, ``},
+		{LLM{Provider: LLMProviderOllama, ToolCalling: LLMToolCallingJSON}, ``},
+		{LLM{Provider: LLMProviderOpenAICompatible}, `llm.endpoint must be set when llm.provider is openai-compatible`},
+		{LLM{Provider: LLMProviderOpenAICompatible, Endpoint: "http://localhost:8000/v1", ToolCalling: LLMToolCallingNative}, ``},
+		{LLM{Provider: LLMProviderOllama, ToolCalling: "invalid"}, `llm.toolCalling must be one of auto, native, json, found: invalid`},
+		{LLM{Provider: LLMProviderOpenAI, ToolCalling: LLMToolCallingAuto}, `llm.toolCalling can only be set when llm.provider is ollama or openai-compatible`},
+		{LLM{Provider: LLMProviderAnthropic, Record: "cassette.json"}, ``},
+		{LLM{Replay: "cassette.json"}, ``},
+		{LLM{Provider: LLMProviderAnthropic, Record: "cassette.json", Replay: "cassette.json"}, `llm.record and llm.replay cannot both be set`},
+		{LLM{Provider: LLMProviderAnthropic, Concurrency: 4, RateLimit: LLMRateLimit{RequestsPerMinute: 50, TokensPerMinute: 40000}}, ``},
+		{LLM{Provider: LLMProviderAnthropic, Concurrency: -1}, `llm.concurrency must be non-negative, found: -1`},
+		{LLM{Provider: LLMProviderAnthropic, RateLimit: LLMRateLimit{RequestsPerMinute: -1}}, `llm.rateLimit.requestsPerMinute must be non-negative, found: -1`},
+		{LLM{Provider: LLMProviderAnthropic, RateLimit: LLMRateLimit{TokensPerMinute: -1}}, `llm.rateLimit.tokensPerMinute must be non-negative, found: -1`},
+		{LLM{Provider: LLMProviderAnthropic, MaxTokens: 4096, Retries: intPtr(0), Timeout: LLMTimeout{Call: time.Minute, Total: time.Hour}}, ``},
+		{LLM{Provider: LLMProviderAnthropic, MaxTokens: -1}, `llm.maxTokens must be non-negative, found: -1`},
+		{LLM{Provider: LLMProviderAnthropic, Retries: intPtr(-1)}, `llm.retries must be non-negative, found: -1`},
+		{LLM{Provider: LLMProviderAnthropic, Timeout: LLMTimeout{Call: -time.Second}}, `llm.timeout.call must be non-negative, found: -1s`},
+		{LLM{Provider: LLMProviderAnthropic, Timeout: LLMTimeout{Total: -time.Minute}}, `llm.timeout.total must be non-negative, found: -1m0s`},
+		{LLM{Provider: LLMProviderAnthropic, Pricing: &LLMPricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}, Budget: LLMBudget{MaxTokens: 100000}}, ``},
+		{LLM{Provider: LLMProviderAnthropic, Pricing: &LLMPricing{Output: -1.5}}, `llm.pricing.output must be non-negative, found: -1.5`},
+		{LLM{Provider: LLMProviderAnthropic, Budget: LLMBudget{MaxTokens: -1}}, `llm.budget.maxTokens must be non-negative, found: -1`},
+	}
+
+	for i, test := range tests {
+		cfg := &Config{
+			LLM: test.llm,
+		}
+
+		err := ValidateLLM(cfg)
+
+		if test.err == "" && err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+		}
+		if test.err != "" && err == nil {
+			t.Errorf("test %d - expected error: %s, got no error", i, test.err)
+		}
+		if test.err != "" && err != nil && err.Error() != test.err {
+			t.Errorf("test %d - expected error: %s, got error: %s", i, test.err, err.Error())
+		}
+	}
+}
@@ -11,9 +11,15 @@ import (
 
 type extractorCallback func(id string, data []byte) error
 
-func Documentation(cfg *config.Extract, db *sqlite.Queries) (err error) {
+// Documentation crawls and extracts the documentation for the configured source into db. If
+// previousDB is set, documents whose raw data is unchanged since that extraction are copied
+// across as-is rather than being extracted again.
+func Documentation(cfg *config.Extract, db *sqlite.Queries, previousDB *sqlite.Queries) (err error) {
 	ctx := context.Background()
 	count := 0
+	added := 0
+	changed := 0
+	unchanged := 0
 
 	// Determine root
 	root, err := getRoot(cfg)
@@ -35,19 +41,93 @@ func Documentation(cfg *config.Extract, db *sqlite.Queries) (err error) {
 		return
 	}
 
-	// Initialize extractor callback
-	extractor := func(id string, rawData []byte) error {
+	// Load previous documentation (if any)
+	var previous *previousDocumentation
+	if previousDB != nil {
+		previous, err = loadPreviousDocumentation(cfg.Source.ID, previousDB)
+		if err != nil {
+			slog.Debug("extract.Documentation could not load previous documentation", "error", err)
+			return
+		}
+	}
+
+	// extractDocument copies the document from the previous extraction if it is unchanged, and
+	// otherwise extracts it using extract. The document's hash is recorded so that this extraction
+	// can be used as the previous extraction of a later run.
+	extractDocument := func(id string, docType config.ExtractorType, options *config.ExtractorOptions, rawData []byte, extract func() error) error {
 		count++
+		hash := hashDocument(id, docType, options, cfg.Metadata, rawData)
+		same := false
+		if previous != nil {
+			var exists bool
+			exists, same = previous.check(id, hash)
+			if same {
+				unchanged++
+			} else if exists {
+				changed++
+			} else {
+				added++
+			}
+		}
+
+		var err error
+		if same {
+			slog.Debug("extract.Documentation copying unchanged document", "document", id)
+			err = previous.copyDocument(id, db)
+		} else {
+			err = extract()
+		}
+		if err != nil {
+			return err
+		}
+
+		err = db.InsertDocumentHash(ctx, sqlite.InsertDocumentHashParams{
+			SourceID:   cfg.Source.ID,
+			DocumentID: id,
+			Hash:       hash,
+		})
+		if err != nil {
+			slog.Debug("extract.Documentation could not insert document hash", "document", id, "error", err)
+			return err
+		}
+
+		return nil
+	}
 
+	// Go source files are extracted per package once crawling is complete
+	goFiles := newGoFiles()
+
+	// Initialize extractor callback
+	extractor := func(id string, rawData []byte) error {
 		// Find and call the first extractor that matches
 		for _, e := range cfg.Extractors {
 			if config.PathIsIncluded(id, e.Include, e.Exclude) {
-				switch e.Type {
-				case config.DocTypeMarkdown:
-					return extractMd(id, cfg.Source.ID, rawData, &e.Options, db)
-				case config.DocTypeHTML:
-					return extractHtml(id, cfg.Source.ID, rawData, &e.Options, db)
+				if e.Type == config.DocTypeGoDoc {
+					goFiles.add(id, rawData, &e.Options)
+					return nil
 				}
+
+				return extractDocument(id, e.Type, &e.Options, rawData, func() error {
+					switch e.Type {
+					case config.DocTypeMarkdown:
+						return extractMd(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeHTML:
+						return extractHtml(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeRst:
+						return extractRst(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeAsciiDoc:
+						return extractAdoc(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeOpenAPI:
+						return extractOpenAPI(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeIpynb:
+						return extractIpynb(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypeDocx:
+						return extractDocx(id, cfg.Source.ID, rawData, &e.Options, db)
+					case config.DocTypePdf:
+						return extractPdf(id, cfg.Source.ID, rawData, &e.Options, db)
+					}
+					return nil
+				})
 			}
 		}
 
@@ -70,6 +150,17 @@ func Documentation(cfg *config.Extract, db *sqlite.Queries) (err error) {
 		return
 	}
 
+	// Extract Go packages
+	for _, pkg := range goFiles.packages() {
+		err = extractDocument(pkg.id, config.DocTypeGoDoc, pkg.options, pkg.rawData, func() error {
+			return extractGoDoc(&pkg, cfg.Source.ID, db)
+		})
+		if err != nil {
+			slog.Debug("extract.Documentation could not extract go package", "package", pkg.id, "error", err)
+			return
+		}
+	}
+
 	// Add metadata
 	slog.Info("Adding metadata")
 	err = addMetadata(cfg.Source.ID, cfg.Metadata, db)
@@ -78,7 +169,11 @@ func Documentation(cfg *config.Extract, db *sqlite.Queries) (err error) {
 		return
 	}
 
-	slog.Info("Extracted documentation", "count", count)
+	if previous != nil {
+		slog.Info("Extracted documentation", "count", count, "added", added, "changed", changed, "unchanged", unchanged, "removed", previous.removed())
+	} else {
+		slog.Info("Extracted documentation", "count", count)
+	}
 	return
 }
 
@@ -0,0 +1,553 @@
+package extract
+
+import (
+	"context"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func extractAdoc(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Convert the AsciiDoc to markdown
+	markdown, purpose := convertAdocToMarkdown(string(rawData), purposeKey)
+
+	// Insert document
+	err := db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeAsciiDoc.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractAdoc could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, !options.DisablePurposeExtraction, purposeKey, db)
+	if err != nil {
+		slog.Debug("extract.extractAdoc could not extract sections", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// convertAdocToMarkdown converts an AsciiDoc document to markdown, returning the markdown and the
+// purpose of the document (if any). The purpose of the document is read from an attribute entry
+// (e.g. ":purpose: ...") in the document header, and the purpose of a section from an attribute
+// entry directly after its title. Section purposes are written to the markdown as purpose comments
+// so they are picked up by extractSections. If purposeKey is blank no purposes are extracted.
+//
+// This is not a complete AsciiDoc parser. It handles the constructs commonly found in
+// documentation (sections, lists, delimited blocks, admonitions, attributes, and inline markup),
+// and otherwise passes text through as-is.
+func convertAdocToMarkdown(document string, purposeKey string) (string, string) {
+	document = strings.ReplaceAll(document, "\r", "")
+	lines := strings.Split(document, "\n")
+
+	c := &adocConverter{
+		purposeKey: purposeKey,
+		attributes: map[string]string{},
+	}
+	start := c.header(lines)
+	c.convert(lines[start:])
+
+	return cleanMarkdown(c.out), c.purpose
+}
+
+type adocConverter struct {
+	purposeKey string
+	attributes map[string]string
+	purpose    string
+	out        []string
+}
+
+var (
+	adocAttributeRegex       = regexp.MustCompile(`^:(!?[\w][\w-]*!?):(?:\s+(.*))?$`)
+	adocSectionRegex         = regexp.MustCompile(`^(={1,6}|#{1,6})\s+(.+?)(?:\s+=+)?$`)
+	adocBlockAttributesRegex = regexp.MustCompile(`^\[(.*)\]$`)
+	adocBlockTitleRegex      = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocAdmonitionRegex      = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocBlockMacroRegex      = regexp.MustCompile(`^([\w-]+)::(\S*?)\[(.*)\]$`)
+	adocUnorderedRegex       = regexp.MustCompile(`^(\*{1,5}|-)\s+(.*)$`)
+	adocOrderedRegex         = regexp.MustCompile(`^(\.{1,5}|\d+\.)\s+(.*)$`)
+	adocDescriptionRegex     = regexp.MustCompile(`^([^\s:\[][^\[]*?)(:{2,4}|;;)(?:\s+(.*))?$`)
+	adocDelimiterRegex       = regexp.MustCompile(`^(-{4,}|\.{4,}|={4,}|\*{4,}|_{4,}|\+{4,}|/{4,}|--)$`)
+)
+
+// adocAdmonitions are the admonition labels and their titles
+var adocAdmonitions = map[string]string{
+	"NOTE": "Note", "TIP": "Tip", "IMPORTANT": "Important", "WARNING": "Warning", "CAUTION": "Caution",
+}
+
+func (c *adocConverter) emit(lines ...string) {
+	c.out = append(c.out, lines...)
+}
+
+// header reads the document header (the document title and the attribute entries around it),
+// returning the index of the first line after the header
+func (c *adocConverter) header(lines []string) int {
+	i := 0
+	for i < len(lines) {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == "" && len(c.out) == 0:
+			// Skip blank lines before the header
+		case line == "":
+			// The header ends at the first blank line after the title
+			return i
+		case strings.HasPrefix(line, "////"):
+			i = adocSkipDelimited(lines, i)
+			continue
+		case strings.HasPrefix(line, "//"):
+			// Comments are dropped
+		case adocAttributeRegex.MatchString(line):
+			if name := c.attribute(line); name == c.purposeKey && c.purposeKey != "" {
+				c.purpose = c.attributes[name]
+			}
+		case strings.HasPrefix(line, "= ") && len(c.out) == 0:
+			c.emit("# "+c.inline(strings.TrimSpace(line[2:])), "")
+		case len(c.out) > 0:
+			// The author and revision lines follow the title
+		default:
+			// There is no document title, so the header is only attribute entries
+			return i
+		}
+		i++
+	}
+
+	return i
+}
+
+// attribute sets (or unsets) the attribute defined by an attribute entry, returning the attribute
+// name
+func (c *adocConverter) attribute(line string) string {
+	match := adocAttributeRegex.FindStringSubmatch(line)
+	name := match[1]
+	if strings.HasPrefix(name, "!") || strings.HasSuffix(name, "!") {
+		name = strings.Trim(name, "!")
+		delete(c.attributes, name)
+		return name
+	}
+	c.attributes[name] = c.substitute(strings.TrimSpace(match[2]))
+
+	return name
+}
+
+func (c *adocConverter) convert(lines []string) {
+	// Block attributes apply to the next block
+	style := ""
+	language := ""
+	// Admonition paragraphs are rendered as block quotes until the end of the paragraph
+	inAdmonition := false
+	// Section purposes are read from attribute entries directly after the section title
+	afterTitle := false
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		// Blank lines end paragraphs
+		if trimmed == "" {
+			inAdmonition = false
+			c.emit("")
+			continue
+		}
+		if inAdmonition {
+			c.emit("> " + c.inline(trimmed))
+			continue
+		}
+
+		// Comments are dropped
+		if strings.HasPrefix(line, "////") {
+			i = adocSkipDelimited(lines, i) - 1
+			continue
+		}
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		// Attribute entries (including section purposes)
+		if adocAttributeRegex.MatchString(line) {
+			name := c.attribute(line)
+			if afterTitle && name == c.purposeKey && c.purposeKey != "" {
+				c.emit(formatPurposeComment(c.purposeKey, c.attributes[name]))
+			}
+			continue
+		}
+		afterTitle = false
+
+		// Section titles
+		if match := adocSectionRegex.FindStringSubmatch(line); match != nil {
+			c.emit(strings.Repeat("#", len(match[1]))+" "+c.inline(match[2]), "")
+			afterTitle = true
+			style, language = "", ""
+			continue
+		}
+
+		// Block anchors and attributes
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			continue
+		}
+		if match := adocBlockAttributesRegex.FindStringSubmatch(line); match != nil {
+			attributes := strings.Split(match[1], ",")
+			style = strings.TrimSpace(attributes[0])
+			// Remove any id or roles (e.g. source#id.role)
+			if index := strings.IndexAny(style, "#.%"); index > 0 {
+				style = style[:index]
+			}
+			language = ""
+			if style == "source" && len(attributes) > 1 {
+				language = strings.TrimSpace(attributes[1])
+			}
+			continue
+		}
+
+		// Block titles
+		if match := adocBlockTitleRegex.FindStringSubmatch(line); match != nil {
+			c.emit("**" + c.inline(match[1]) + "**")
+			continue
+		}
+
+		// Delimited blocks
+		if adocDelimiterRegex.MatchString(line) {
+			body, next := adocDelimited(lines, i)
+			c.delimited(line, style, language, body)
+			style, language = "", ""
+			i = next - 1
+			continue
+		}
+		if strings.HasPrefix(line, "```") {
+			body, next := adocDelimited(lines, i)
+			c.emit(line)
+			c.emit(body...)
+			c.emit("```")
+			i = next - 1
+			continue
+		}
+		if strings.HasPrefix(line, "|===") {
+			body, next := adocDelimited(lines, i)
+			c.table(body)
+			style, language = "", ""
+			i = next - 1
+			continue
+		}
+
+		// Admonition paragraphs
+		if match := adocAdmonitionRegex.FindStringSubmatch(line); match != nil {
+			c.emit("> **" + adocAdmonitions[match[1]] + ":** " + c.inline(match[2]))
+			inAdmonition = true
+			continue
+		}
+		if title, ok := adocAdmonitions[style]; ok {
+			c.emit("> **" + title + ":** " + c.inline(trimmed))
+			inAdmonition = true
+			style = ""
+			continue
+		}
+		style, language = "", ""
+
+		// Block macros
+		if match := adocBlockMacroRegex.FindStringSubmatch(line); match != nil {
+			if match[1] == "image" {
+				alt, _, _ := strings.Cut(match[3], ",")
+				c.emit("![" + alt + "](" + c.substitute(match[2]) + ")")
+			}
+			// Other block macros (such as include and toc) are dropped
+			continue
+		}
+
+		// Thematic and page breaks
+		if line == "'''" {
+			c.emit("---")
+			continue
+		}
+		if line == "<<<" {
+			continue
+		}
+
+		// List continuations
+		if line == "+" {
+			c.emit("")
+			continue
+		}
+
+		// Lists
+		if match := adocUnorderedRegex.FindStringSubmatch(trimmed); match != nil {
+			depth := len(match[1])
+			if match[1] == "-" {
+				depth = 1
+			}
+			c.emit(strings.Repeat("  ", depth-1) + "- " + c.inline(match[2]))
+			continue
+		}
+		if match := adocOrderedRegex.FindStringSubmatch(trimmed); match != nil {
+			depth := len(match[1])
+			if strings.HasSuffix(match[1], ".") && match[1][0] != '.' {
+				depth = 1
+			}
+			c.emit(strings.Repeat("   ", depth-1) + "1. " + c.inline(match[2]))
+			continue
+		}
+		if match := adocDescriptionRegex.FindStringSubmatch(line); match != nil && !strings.Contains(match[1], "://") {
+			term := "- **" + c.inline(strings.TrimSpace(match[1])) + "**"
+			if match[3] != "" {
+				term += ": " + c.inline(match[3])
+			}
+			c.emit(term)
+			continue
+		}
+
+		// Everything else is text. Escape any text that would otherwise be read as a heading.
+		converted := c.inline(line)
+		if strings.HasPrefix(converted, "#") {
+			converted = "\\" + converted
+		}
+		c.emit(converted)
+	}
+}
+
+// delimited converts the body of a delimited block
+func (c *adocConverter) delimited(delimiter string, style string, language string, body []string) {
+	switch {
+	case strings.HasPrefix(delimiter, "----"), strings.HasPrefix(delimiter, "...."):
+		c.emit("```" + language)
+		c.emit(body...)
+		c.emit("```")
+	case strings.HasPrefix(delimiter, "++++"), strings.HasPrefix(delimiter, "////"):
+		// Passthrough blocks and comments are dropped
+	case strings.HasPrefix(delimiter, "____"), style == "quote":
+		c.quote("", body)
+	case adocAdmonitions[style] != "":
+		c.quote(adocAdmonitions[style], body)
+	case style == "source", style == "listing", style == "literal":
+		c.emit("```" + language)
+		c.emit(body...)
+		c.emit("```")
+	default:
+		// Examples, sidebars, and open blocks keep their content
+		nested := &adocConverter{attributes: c.attributes}
+		nested.convert(body)
+		c.emit(nested.out...)
+	}
+}
+
+// quote emits a block quote, starting with a bold title (if any)
+func (c *adocConverter) quote(title string, body []string) {
+	nested := &adocConverter{attributes: c.attributes}
+	nested.convert(body)
+	converted := strings.Split(cleanMarkdown(nested.out), "\n")
+
+	if title != "" {
+		first := "> **" + title + ":**"
+		if converted[0] != "" {
+			first += " " + converted[0]
+		}
+		c.emit(first)
+		converted = converted[1:]
+	}
+	for _, line := range converted {
+		c.emit(strings.TrimRight("> "+line, " "))
+	}
+}
+
+// table emits a markdown table for the body of an AsciiDoc table, using the first row as the header
+func (c *adocConverter) table(body []string) {
+	// The number of columns is the number of cells on the first line
+	columns := 0
+	cells := []string{}
+	for _, line := range body {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			// Text without a leading | continues the previous cell
+			if line != "" && len(cells) > 0 {
+				cells[len(cells)-1] = strings.TrimSpace(cells[len(cells)-1] + " " + line)
+			}
+			continue
+		}
+		lineCells := strings.Split(line[1:], "|")
+		if columns == 0 {
+			columns = len(lineCells)
+		}
+		for _, cell := range lineCells {
+			cells = append(cells, strings.TrimSpace(cell))
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	for start := 0; start < len(cells); start += columns {
+		row := make([]string, columns)
+		for j := 0; j < columns && start+j < len(cells); j++ {
+			row[j] = strings.ReplaceAll(c.inline(cells[start+j]), "|", "\\|")
+		}
+		c.emit("| " + strings.Join(row, " | ") + " |")
+		if start == 0 {
+			c.emit("|" + strings.Repeat(" --- |", columns))
+		}
+	}
+}
+
+// adocDelimited returns the body of the delimited block starting at lines[i], along with the index
+// of the line after the closing delimiter
+func adocDelimited(lines []string, i int) ([]string, int) {
+	delimiter := strings.TrimRight(lines[i], " \t")
+	closing := delimiter
+	if strings.HasPrefix(delimiter, "```") {
+		closing = "```"
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimRight(lines[j], " \t") == closing {
+			return lines[i+1 : j], j + 1
+		}
+	}
+
+	return lines[i+1:], len(lines)
+}
+
+// adocSkipDelimited returns the index of the line after the delimited block starting at lines[i]
+func adocSkipDelimited(lines []string, i int) int {
+	_, next := adocDelimited(lines, i)
+	return next
+}
+
+var (
+	adocCodeRegex         = regexp.MustCompile("`\\+(.+?)\\+`|``(.+?)``|`([^`]+)`|\\+\\+\\+(.+?)\\+\\+\\+|\\+\\+(.+?)\\+\\+|(?:^|\\B)\\+([^\\s+](?:[^+]*?[^\\s+])?)\\+(?:\\B|$)")
+	adocAttributeRefRegex = regexp.MustCompile(`\{([\w][\w-]*)\}`)
+	adocURLRegex          = regexp.MustCompile(`(?:link:)?((?:https?|ftp|mailto|irc):[^\s\[]+)\[([^\]]*)\]`)
+	adocLinkRegex         = regexp.MustCompile(`link:([^\s\[]+)\[([^\]]*)\]`)
+	adocXrefRegex         = regexp.MustCompile(`xref:([^\s\[]+)\[([^\]]*)\]`)
+	adocCrossRefRegex     = regexp.MustCompile(`<<([^,>]+)(?:,\s*([^>]+))?>>`)
+	adocImageRegex        = regexp.MustCompile(`image:([^\s\[:][^\s\[]*)\[([^\]]*)\]`)
+	adocFootnoteRegex     = regexp.MustCompile(`footnote:(?:[\w-]*)\[([^\]]*)\]`)
+	adocUIMacroRegex      = regexp.MustCompile(`(?:kbd|btn|menu|pass|anchor):([^\s\[]*)\[([^\]]*)\]`)
+	adocStrongRegex       = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	adocConstrainedStrong = regexp.MustCompile(`(^|[^\w*\\])\*([^\s*](?:[^*]*?[^\s*])?)\*([^\w*]|$)`)
+	adocEmphasisRegex     = regexp.MustCompile(`__(.+?)__`)
+	adocConstrainedEmph   = regexp.MustCompile(`(^|[^\w_\\])_([^\s_](?:[^_]*?[^\s_])?)_([^\w_]|$)`)
+	adocHighlightRegex    = regexp.MustCompile(`(^|[^\w#\\])(?:\[[^\]]*\])?#([^\s#](?:[^#]*?[^\s#])?)#([^\w#]|$)`)
+)
+
+// Markers used for bold and italic markup while converting, so that converted markup is not
+// converted again
+const (
+	adocStrongMarker   = "\x00S"
+	adocEmphasisMarker = "\x00E"
+	adocCodeMarker     = "\x00C"
+)
+
+// substitute replaces attribute references (e.g. {version}) with their values. Undefined
+// attributes are left as-is.
+func (c *adocConverter) substitute(text string) string {
+	return adocAttributeRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := c.attributes[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// inline converts AsciiDoc inline markup in text to markdown
+func (c *adocConverter) inline(text string) string {
+	// Code and passthroughs are kept as-is, so replace them with markers while converting
+	literals := []string{}
+	text = adocCodeRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocCodeRegex.FindStringSubmatch(match)
+		literal := ""
+		switch {
+		case parts[1] != "":
+			literal = "`" + parts[1] + "`"
+		case parts[2] != "":
+			literal = "`" + parts[2] + "`"
+		case parts[3] != "":
+			literal = "`" + c.substitute(parts[3]) + "`"
+		default:
+			literal = parts[4] + parts[5] + parts[6]
+		}
+		literals = append(literals, literal)
+		return adocCodeMarker + strconv.Itoa(len(literals)-1) + adocCodeMarker
+	})
+
+	text = c.substitute(text)
+
+	// Macros
+	text = adocImageRegex.ReplaceAllString(text, "![$2]($1)")
+	text = adocURLRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocURLRegex.FindStringSubmatch(match)
+		return adocLink(parts[1], parts[2])
+	})
+	text = adocLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocLinkRegex.FindStringSubmatch(match)
+		return adocLink(parts[1], parts[2])
+	})
+	text = adocXrefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocXrefRegex.FindStringSubmatch(match)
+		if parts[2] != "" {
+			return parts[2]
+		}
+		return parts[1]
+	})
+	text = adocCrossRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocCrossRefRegex.FindStringSubmatch(match)
+		if parts[2] != "" {
+			return strings.TrimSpace(parts[2])
+		}
+		return strings.TrimSpace(parts[1])
+	})
+	text = adocFootnoteRegex.ReplaceAllString(text, "($1)")
+	text = adocUIMacroRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := adocUIMacroRegex.FindStringSubmatch(match)
+		if parts[2] != "" {
+			return parts[2]
+		}
+		return parts[1]
+	})
+
+	// Formatting (run constrained replacements twice, as adjacent matches share a boundary)
+	text = adocStrongRegex.ReplaceAllString(text, adocStrongMarker+"$1"+adocStrongMarker)
+	text = adocEmphasisRegex.ReplaceAllString(text, adocEmphasisMarker+"$1"+adocEmphasisMarker)
+	for range 2 {
+		text = adocConstrainedStrong.ReplaceAllString(text, "$1"+adocStrongMarker+"$2"+adocStrongMarker+"$3")
+		text = adocConstrainedEmph.ReplaceAllString(text, "$1"+adocEmphasisMarker+"$2"+adocEmphasisMarker+"$3")
+		text = adocHighlightRegex.ReplaceAllString(text, "$1$2$3")
+	}
+	text = strings.ReplaceAll(text, adocStrongMarker, "**")
+	text = strings.ReplaceAll(text, adocEmphasisMarker, "*")
+
+	// Restore code and passthroughs
+	for i, literal := range literals {
+		text = strings.Replace(text, adocCodeMarker+strconv.Itoa(i)+adocCodeMarker, literal, 1)
+	}
+
+	return text
+}
+
+// adocLink returns a markdown link for an AsciiDoc link target and link text
+func adocLink(target string, text string) string {
+	// Remove any link attributes (e.g. window=_blank) and the ^ shorthand for opening a new window
+	if index := strings.Index(text, ","); index > -1 && strings.Contains(text[index:], "=") {
+		text = text[:index]
+	}
+	text = strings.TrimSuffix(strings.Trim(text, `"`), "^")
+	if text == "" {
+		return target
+	}
+
+	return "[" + text + "](" + target + ")"
+}
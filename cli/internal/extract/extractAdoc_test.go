@@ -0,0 +1,150 @@
+package extract
+
+import (
+	"testing"
+)
+
+func TestConvertAdocToMarkdown(t *testing.T) {
+	titles := `= Title
+Jane Doe <jane@example.com>
+:toc:
+
+== Section
+
+=== Subsection
+
+== Another Section ==`
+	titlesMarkdown := `# Title
+
+## Section
+
+### Subsection
+
+## Another Section`
+
+	blocks := `[source,go]
+----
+// not a comment
+fmt.Println("hi")
+----
+
+NOTE: Read this.
+It matters.
+
+[WARNING]
+====
+Be careful.
+====
+
+.Output
+....
+hi
+....`
+	blocksMarkdown := "```go\n// not a comment\nfmt.Println(\"hi\")\n```\n\n> **Note:** Read this.\n> It matters.\n\n> **Warning:** Be careful.\n\n**Output**\n```\nhi\n```"
+
+	inline := ":product: Hyaline\n\nUse `{product} check` with *{product}*, _care_, https://example.com[the docs^], and <<install,Install>>."
+	inlineMarkdown := "Use `Hyaline check` with **Hyaline**, *care*, [the docs](https://example.com), and Install."
+
+	lists := `* One
+** Nested
+. First
+.. Nested
+
+CPU:: The processor`
+	listsMarkdown := `- One
+  - Nested
+1. First
+   1. Nested
+
+- **CPU**: The processor`
+
+	table := `|===
+|Name |Value
+
+|a
+|b
+|===`
+	tableMarkdown := `| Name | Value |
+| --- | --- |
+| a | b |`
+
+	comments := "// A comment\n////\nA block comment\n////\nText\n\ninclude::other.adoc[]\nimage::diagram.png[Diagram,200]"
+	commentsMarkdown := "Text\n\n![Diagram](diagram.png)"
+
+	var tests = []struct {
+		name     string
+		document string
+		markdown string
+	}{
+		{"Empty", "", ""},
+		{"Titles", titles, titlesMarkdown},
+		{"Blocks", blocks, blocksMarkdown},
+		{"Inline Markup", inline, inlineMarkdown},
+		{"Lists", lists, listsMarkdown},
+		{"Table", table, tableMarkdown},
+		{"Comments and Macros", comments, commentsMarkdown},
+	}
+
+	for _, test := range tests {
+		markdown, _ := convertAdocToMarkdown(test.document, "purpose")
+		if markdown != test.markdown {
+			t.Errorf("%s - expected:\n%s\ngot:\n%s", test.name, test.markdown, markdown)
+		}
+	}
+}
+
+func TestConvertAdocToMarkdown_Purpose(t *testing.T) {
+	header := `= Title
+:purpose: Document purpose
+
+Content`
+	noTitle := `:purpose: Document purpose
+
+Content`
+	sections := `= Title
+
+== Section
+:purpose: Section purpose
+
+Content
+
+== Other Section
+
+Content
+
+:purpose: Not a section purpose`
+	sectionsMarkdown := `# Title
+
+## Section
+
+<!-- purpose: "Section purpose" -->
+
+Content
+
+## Other Section
+
+Content`
+
+	var tests = []struct {
+		name     string
+		document string
+		key      string
+		purpose  string
+		markdown string
+	}{
+		{"Header", header, "purpose", "Document purpose", "# Title\n\nContent"},
+		{"Header Key Not Present", header, "custom", "", "# Title\n\nContent"},
+		{"No Title", noTitle, "purpose", "Document purpose", "Content"},
+		{"Sections", sections, "purpose", "", sectionsMarkdown},
+	}
+
+	for _, test := range tests {
+		markdown, purpose := convertAdocToMarkdown(test.document, test.key)
+		if purpose != test.purpose {
+			t.Errorf("%s - expected purpose %q, got %q", test.name, test.purpose, purpose)
+		}
+		if markdown != test.markdown {
+			t.Errorf("%s - expected:\n%s\ngot:\n%s", test.name, test.markdown, markdown)
+		}
+	}
+}
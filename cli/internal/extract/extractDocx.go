@@ -0,0 +1,530 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func extractDocx(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Convert docx to markdown
+	markdown, purpose, err := convertDocxToMarkdown(rawData, purposeKey)
+	if err != nil {
+		slog.Debug("extract.extractDocx could not convert docx to markdown", "error", err)
+		return err
+	}
+
+	// Insert document
+	err = db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeDocx.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractDocx could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, false, "", db)
+	if err != nil {
+		slog.Debug("extract.extractDocx could not extract sections", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// convertDocxToMarkdown converts a Word (.docx) document to markdown. Paragraphs with a heading
+// or title style (or an outline level) become headings, numbered and bulleted paragraphs become
+// list items, and tables become markdown tables. The purpose of the document is read from the
+// custom document property named purposeKey (if any).
+func convertDocxToMarkdown(rawData []byte, purposeKey string) (markdown string, purpose string, err error) {
+	reader, err := zip.NewReader(bytes.NewReader(rawData), int64(len(rawData)))
+	if err != nil {
+		return
+	}
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	documentFile, ok := files["word/document.xml"]
+	if !ok {
+		err = errors.New("could not find word/document.xml")
+		return
+	}
+	document, err := parseXMLFile(documentFile)
+	if err != nil {
+		return
+	}
+
+	converter := &docxConverter{
+		styles:    map[string]docxStyle{},
+		links:     map[string]string{},
+		numbering: map[string]map[string]bool{},
+	}
+	if file, ok := files["word/styles.xml"]; ok {
+		var styles *xmlNode
+		if styles, err = parseXMLFile(file); err != nil {
+			return
+		}
+		converter.loadStyles(styles)
+	}
+	if file, ok := files["word/_rels/document.xml.rels"]; ok {
+		var relationships *xmlNode
+		if relationships, err = parseXMLFile(file); err != nil {
+			return
+		}
+		converter.loadLinks(relationships)
+	}
+	if file, ok := files["word/numbering.xml"]; ok {
+		var numbering *xmlNode
+		if numbering, err = parseXMLFile(file); err != nil {
+			return
+		}
+		converter.loadNumbering(numbering)
+	}
+	if file, ok := files["docProps/custom.xml"]; ok && purposeKey != "" {
+		var properties *xmlNode
+		if properties, err = parseXMLFile(file); err != nil {
+			return
+		}
+		for _, property := range properties.descendants("property") {
+			if strings.EqualFold(property.attrs["name"], purposeKey) {
+				purpose = strings.TrimSpace(property.textContent())
+			}
+		}
+	}
+
+	body := document.descendants("body")
+	if len(body) == 0 {
+		err = errors.New("could not find the body of the document")
+		return
+	}
+	for _, child := range body[0].children {
+		converter.convertBlock(child)
+	}
+	markdown = cleanMarkdown(converter.out)
+
+	return
+}
+
+// xmlNode is a simplified XML element, identified by its local name (namespaces are ignored)
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+func parseXMLFile(file *zip.File) (*xmlNode, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	root := &xmlNode{attrs: map[string]string{}}
+	stack := []*xmlNode{root}
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].text += string(t)
+		}
+	}
+
+	return root, nil
+}
+
+// child returns the first child with the name (if any)
+func (node *xmlNode) child(name string) *xmlNode {
+	for _, child := range node.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// childVal returns the val attribute of the first child with the name (if any)
+func (node *xmlNode) childVal(name string) (string, bool) {
+	child := node.child(name)
+	if child == nil {
+		return "", false
+	}
+	val, ok := child.attrs["val"]
+	return val, ok
+}
+
+// descendants returns all descendants with the name, in document order
+func (node *xmlNode) descendants(name string) []*xmlNode {
+	nodes := []*xmlNode{}
+	for _, child := range node.children {
+		if child.name == name {
+			nodes = append(nodes, child)
+		}
+		nodes = append(nodes, child.descendants(name)...)
+	}
+	return nodes
+}
+
+// textContent returns the text of the node and its descendants
+func (node *xmlNode) textContent() string {
+	text := node.text
+	for _, child := range node.children {
+		text += child.textContent()
+	}
+	return text
+}
+
+// docxStyle is a paragraph style from word/styles.xml
+type docxStyle struct {
+	name         string
+	basedOn      string
+	outlineLevel int
+	numbered     bool
+}
+
+type docxConverter struct {
+	styles    map[string]docxStyle
+	links     map[string]string
+	numbering map[string]map[string]bool
+	out       []string
+	inList    bool
+}
+
+// docxRun is a run of text with the same formatting
+type docxRun struct {
+	text   string
+	bold   bool
+	italic bool
+	link   string
+}
+
+var docxHeadingStyleRegex = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+func (converter *docxConverter) loadStyles(styles *xmlNode) {
+	for _, style := range styles.descendants("style") {
+		if style.attrs["type"] != "paragraph" {
+			continue
+		}
+		s := docxStyle{outlineLevel: -1}
+		s.name, _ = style.childVal("name")
+		s.basedOn, _ = style.childVal("basedOn")
+		if pPr := style.child("pPr"); pPr != nil {
+			if val, ok := pPr.childVal("outlineLvl"); ok {
+				if level, err := strconv.Atoi(val); err == nil {
+					s.outlineLevel = level
+				}
+			}
+			s.numbered = pPr.child("numPr") != nil
+		}
+		converter.styles[style.attrs["styleId"]] = s
+	}
+}
+
+func (converter *docxConverter) loadLinks(relationships *xmlNode) {
+	for _, relationship := range relationships.descendants("Relationship") {
+		if strings.HasSuffix(relationship.attrs["Type"], "/hyperlink") {
+			converter.links[relationship.attrs["Id"]] = relationship.attrs["Target"]
+		}
+	}
+}
+
+// loadNumbering records which list levels are bullets (as opposed to numbers) for each numbering
+func (converter *docxConverter) loadNumbering(numbering *xmlNode) {
+	abstract := map[string]map[string]bool{}
+	for _, abstractNum := range numbering.descendants("abstractNum") {
+		levels := map[string]bool{}
+		for _, level := range abstractNum.descendants("lvl") {
+			format, _ := level.childVal("numFmt")
+			levels[level.attrs["ilvl"]] = format == "bullet"
+		}
+		abstract[abstractNum.attrs["abstractNumId"]] = levels
+	}
+	for _, num := range numbering.descendants("num") {
+		if abstractID, ok := num.childVal("abstractNumId"); ok {
+			converter.numbering[num.attrs["numId"]] = abstract[abstractID]
+		}
+	}
+}
+
+// headingLevel returns the heading level of a paragraph style (0 if the style is not a heading)
+func (converter *docxConverter) headingLevel(styleID string) int {
+	for range 10 {
+		style, ok := converter.styles[styleID]
+		if !ok {
+			break
+		}
+		if style.outlineLevel >= 0 && style.outlineLevel < 9 {
+			return style.outlineLevel + 1
+		}
+		name := strings.ToLower(strings.TrimSpace(style.name))
+		if name == "title" {
+			return 1
+		}
+		if match := docxHeadingStyleRegex.FindStringSubmatch(name); match != nil {
+			return int(match[1][0] - '0')
+		}
+		styleID = style.basedOn
+	}
+	return 0
+}
+
+func (converter *docxConverter) convertBlock(node *xmlNode) {
+	switch node.name {
+	case "p":
+		converter.convertParagraph(node)
+	case "tbl":
+		converter.convertTable(node)
+	case "sdt":
+		if content := node.child("sdtContent"); content != nil {
+			for _, child := range content.children {
+				converter.convertBlock(child)
+			}
+		}
+	}
+}
+
+func (converter *docxConverter) convertParagraph(paragraph *xmlNode) {
+	styleID := ""
+	level := 0
+	listLevel := -1
+	listNumID := ""
+	if pPr := paragraph.child("pPr"); pPr != nil {
+		styleID, _ = pPr.childVal("pStyle")
+		level = converter.headingLevel(styleID)
+		if val, ok := pPr.childVal("outlineLvl"); ok {
+			if outlineLevel, err := strconv.Atoi(val); err == nil && outlineLevel < 9 {
+				level = outlineLevel + 1
+			}
+		}
+		if numPr := pPr.child("numPr"); numPr != nil {
+			listNumID, _ = numPr.childVal("numId")
+			val, _ := numPr.childVal("ilvl")
+			listLevel, _ = strconv.Atoi(val)
+		} else if converter.styles[styleID].numbered {
+			listLevel = 0
+		}
+	}
+	if listNumID == "0" {
+		// A numId of 0 removes numbering from the paragraph
+		listLevel = -1
+	}
+
+	runs := converter.runs(paragraph, "")
+	if level > 0 {
+		text := ""
+		for _, run := range runs {
+			text += run.text
+		}
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			return
+		}
+		converter.out = append(converter.out, "", strings.Repeat("#", min(level, 6))+" "+text, "")
+		converter.inList = false
+		return
+	}
+
+	text := strings.TrimSpace(formatDocxRuns(runs))
+	if text == "" {
+		return
+	}
+	text = escapeMarkdownHeadings(text)
+	if listLevel >= 0 {
+		marker := "1."
+		if bullets, ok := converter.numbering[listNumID]; !ok || bullets[strconv.Itoa(listLevel)] {
+			marker = "-"
+		}
+		// Indent by 3 spaces so nested items are nested under both bulleted and numbered items
+		indent := strings.Repeat("   ", listLevel)
+		text = indent + marker + " " + strings.ReplaceAll(text, "\n", "\n"+indent+"   ")
+		if !converter.inList {
+			converter.out = append(converter.out, "")
+		}
+		converter.out = append(converter.out, text)
+		converter.inList = true
+		return
+	}
+
+	converter.out = append(converter.out, "", text, "")
+	converter.inList = false
+}
+
+// runs returns the runs of text within a paragraph (or hyperlink, etc.), in document order
+func (converter *docxConverter) runs(node *xmlNode, link string) []docxRun {
+	runs := []docxRun{}
+	for _, child := range node.children {
+		switch child.name {
+		case "r":
+			run := docxRun{link: link}
+			if rPr := child.child("rPr"); rPr != nil {
+				run.bold = docxToggle(rPr, "b")
+				run.italic = docxToggle(rPr, "i")
+			}
+			for _, content := range child.children {
+				switch content.name {
+				case "t":
+					run.text += content.text
+				case "tab":
+					run.text += " "
+				case "br", "cr":
+					run.text += "\n"
+				}
+			}
+			runs = append(runs, run)
+		case "hyperlink":
+			target := link
+			if id, ok := child.attrs["id"]; ok {
+				target = converter.links[id]
+			}
+			runs = append(runs, converter.runs(child, target)...)
+		case "del", "pPr", "rPr":
+			// Deleted text and properties are not content
+		default:
+			// Include runs nested in insertions, fields, smart tags, etc.
+			runs = append(runs, converter.runs(child, link)...)
+		}
+	}
+	return runs
+}
+
+// docxToggle returns whether a toggle property (such as bold) is on
+func docxToggle(rPr *xmlNode, name string) bool {
+	property := rPr.child(name)
+	if property == nil {
+		return false
+	}
+	val, ok := property.attrs["val"]
+	return !ok || (val != "0" && val != "false" && val != "off")
+}
+
+// formatDocxRuns formats runs of text as markdown, merging adjacent runs with the same formatting
+func formatDocxRuns(runs []docxRun) string {
+	merged := []docxRun{}
+	for _, run := range runs {
+		if run.text == "" {
+			continue
+		}
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if last.bold == run.bold && last.italic == run.italic && last.link == run.link {
+				last.text += run.text
+				continue
+			}
+		}
+		merged = append(merged, run)
+	}
+
+	var builder strings.Builder
+	for _, run := range merged {
+		// Keep surrounding whitespace outside of the formatting
+		text := strings.TrimSpace(run.text)
+		if text == "" {
+			builder.WriteString(run.text)
+			continue
+		}
+		leading := run.text[:strings.Index(run.text, text)]
+		trailing := run.text[len(leading)+len(text):]
+
+		marker := ""
+		if run.bold {
+			marker += "**"
+		}
+		if run.italic {
+			marker += "*"
+		}
+		text = marker + text + marker
+		if run.link != "" {
+			text = "[" + text + "](" + run.link + ")"
+		}
+		builder.WriteString(leading + text + trailing)
+	}
+
+	return builder.String()
+}
+
+func (converter *docxConverter) convertTable(table *xmlNode) {
+	rows := [][]string{}
+	columns := 0
+	for _, row := range table.children {
+		if row.name != "tr" {
+			continue
+		}
+		cells := []string{}
+		for _, cell := range row.children {
+			if cell.name != "tc" {
+				continue
+			}
+			paragraphs := []string{}
+			for _, paragraph := range cell.descendants("p") {
+				text := strings.Join(strings.Fields(formatDocxRuns(converter.runs(paragraph, ""))), " ")
+				if text != "" {
+					paragraphs = append(paragraphs, text)
+				}
+			}
+			cells = append(cells, strings.ReplaceAll(strings.Join(paragraphs, " "), "|", "\\|"))
+		}
+		columns = max(columns, len(cells))
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 || columns == 0 {
+		return
+	}
+
+	converter.out = append(converter.out, "")
+	for i, cells := range rows {
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		converter.out = append(converter.out, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			converter.out = append(converter.out, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	converter.out = append(converter.out, "")
+	converter.inList = false
+}
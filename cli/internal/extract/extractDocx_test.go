@@ -0,0 +1,128 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+const docxDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+  <w:body>
+    <w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Deploy Runbook</w:t></w:r></w:p>
+    <w:p><w:r><w:t xml:space="preserve">How to deploy </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>safely</w:t></w:r><w:r><w:rPr><w:b w:val="0"/></w:rPr><w:t xml:space="preserve">, see </w:t></w:r><w:hyperlink r:id="rId5"><w:r><w:t>the guide</w:t></w:r></w:hyperlink><w:r><w:t>.</w:t></w:r><w:del><w:r><w:delText>removed</w:delText></w:r></w:del></w:p>
+    <w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Prepare</w:t></w:r></w:p>
+    <w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Tag the release</w:t></w:r></w:p>
+    <w:p><w:pPr><w:numPr><w:ilvl w:val="1"/><w:numId w:val="2"/></w:numPr></w:pPr><w:r><w:t>Use semver</w:t></w:r></w:p>
+    <w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Notify the team</w:t></w:r></w:p>
+    <w:p><w:pPr><w:pStyle w:val="MyHeading"/></w:pPr><w:r><w:t>Checks</w:t></w:r></w:p>
+    <w:p><w:r><w:t># not a heading</w:t></w:r></w:p>
+    <w:tbl>
+      <w:tr><w:tc><w:p><w:r><w:t>Check</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Owner</w:t></w:r></w:p></w:tc></w:tr>
+      <w:tr><w:tc><w:p><w:r><w:t>Health | status</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>SRE</w:t></w:r></w:p></w:tc></w:tr>
+    </w:tbl>
+    <w:sectPr/>
+  </w:body>
+</w:document>`
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/></w:style>
+  <w:style w:type="paragraph" w:styleId="MyHeading"><w:name w:val="My Heading"/><w:basedOn w:val="Heading2"/></w:style>
+</w:styles>`
+
+const docxNumbering = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>
+  <w:abstractNum w:abstractNumId="1"><w:lvl w:ilvl="1"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>
+  <w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+  <w:num w:numId="2"><w:abstractNumId w:val="1"/></w:num>
+</w:numbering>`
+
+const docxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+  <Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/deploy" TargetMode="External"/>
+</Relationships>`
+
+const docxCustomProperties = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">
+  <property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Purpose"><vt:lpwstr>Explain how to deploy</vt:lpwstr></property>
+</Properties>`
+
+func buildTestDocx(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, contents := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = file.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvertDocxToMarkdown(t *testing.T) {
+	docx := buildTestDocx(t, map[string]string{
+		"word/document.xml":            docxDocument,
+		"word/styles.xml":              docxStyles,
+		"word/numbering.xml":           docxNumbering,
+		"word/_rels/document.xml.rels": docxRelationships,
+		"docProps/custom.xml":          docxCustomProperties,
+	})
+
+	markdown, purpose, err := convertDocxToMarkdown(docx, "purpose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Deploy Runbook
+
+How to deploy **safely**, see [the guide](https://example.com/deploy).
+
+# Prepare
+
+1. Tag the release
+   - Use semver
+1. Notify the team
+
+## Checks
+
+\# not a heading
+
+| Check | Owner |
+| --- | --- |
+| Health \| status | SRE |`
+	if markdown != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, markdown)
+	}
+	if purpose != "Explain how to deploy" {
+		t.Errorf("expected purpose from the custom property, got %q", purpose)
+	}
+
+	_, purpose, err = convertDocxToMarkdown(docx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purpose != "" {
+		t.Errorf("expected no purpose when purpose extraction is disabled, got %q", purpose)
+	}
+}
+
+func TestConvertDocxToMarkdown_Invalid(t *testing.T) {
+	if _, _, err := convertDocxToMarkdown([]byte("not a zip"), "purpose"); err == nil {
+		t.Errorf("expected an error for a file that is not a zip")
+	}
+	docx := buildTestDocx(t, map[string]string{"word/styles.xml": docxStyles})
+	if _, _, err := convertDocxToMarkdown(docx, "purpose"); err == nil {
+		t.Errorf("expected an error for a zip without word/document.xml")
+	}
+}
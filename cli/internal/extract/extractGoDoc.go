@@ -0,0 +1,473 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// goFiles collects the Go source files crawled for each package directory, as the godoc extractor
+// extracts a document per package rather than per file
+type goFiles struct {
+	dirs map[string]*goDirectory
+}
+
+type goDirectory struct {
+	// options are the options of the extractor matching the first file crawled in the directory
+	options *config.ExtractorOptions
+	files   map[string][]byte
+}
+
+// goPackage is a Go package ready to be extracted
+type goPackage struct {
+	id      string
+	rawData []byte
+	fset    *token.FileSet
+	files   []*ast.File
+	options *config.ExtractorOptions
+}
+
+func newGoFiles() *goFiles {
+	return &goFiles{
+		dirs: make(map[string]*goDirectory),
+	}
+}
+
+// add adds a crawled Go source file. Test files are skipped, as they do not document the package.
+func (g *goFiles) add(id string, rawData []byte, options *config.ExtractorOptions) {
+	if strings.HasSuffix(id, "_test.go") {
+		slog.Debug("extract.goFiles skipping test file", "document", id)
+		return
+	}
+
+	dir := path.Dir(id)
+	if g.dirs[dir] == nil {
+		g.dirs[dir] = &goDirectory{
+			options: options,
+			files:   make(map[string][]byte),
+		}
+	}
+	g.dirs[dir].files[id] = rawData
+}
+
+// packages parses the collected files and returns the packages they make up, sorted by ID. Each
+// package's ID is its directory, unless the directory holds more than one package (in which case
+// the package name is appended to the directory, e.g. dir@name). Files that cannot be parsed are
+// skipped.
+func (g *goFiles) packages() []goPackage {
+	dirs := make([]string, 0, len(g.dirs))
+	for dir := range g.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	packages := []goPackage{}
+	for _, dir := range dirs {
+		directory := g.dirs[dir]
+		ids := make([]string, 0, len(directory.files))
+		for id := range directory.files {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		// Group the files by package name, skipping files that cannot be parsed or are never built
+		fset := token.NewFileSet()
+		names := []string{}
+		files := map[string][]*ast.File{}
+		rawData := map[string]*bytes.Buffer{}
+		for _, id := range ids {
+			file, err := parser.ParseFile(fset, id, directory.files[id], parser.ParseComments)
+			if err != nil {
+				slog.Warn("Could not parse go file, skipping", "document", id, "error", err)
+				continue
+			}
+			if goFileIsIgnored(file) {
+				slog.Debug("extract.goFiles skipping ignored file", "document", id)
+				continue
+			}
+
+			name := file.Name.Name
+			if files[name] == nil {
+				names = append(names, name)
+				rawData[name] = &bytes.Buffer{}
+			}
+			files[name] = append(files[name], file)
+			fmt.Fprintf(rawData[name], "// file: %s\n%s\n", id, directory.files[id])
+		}
+
+		for _, name := range names {
+			id := dir
+			if len(names) > 1 {
+				id = dir + "@" + name
+			}
+			packages = append(packages, goPackage{
+				id:      id,
+				rawData: rawData[name].Bytes(),
+				fset:    fset,
+				files:   files[name],
+				options: directory.options,
+			})
+		}
+	}
+
+	return packages
+}
+
+// goFileIsIgnored returns true if the file has a "//go:build ignore" constraint
+func goFileIsIgnored(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if strings.TrimSpace(comment.Text) == "//go:build ignore" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func extractGoDoc(pkg *goPackage, sourceID string, db *sqlite.Queries) error {
+	// Directives are removed from doc comments by go/doc, so get them first
+	directives := getGoDirectives(pkg.files)
+
+	docPkg, err := doc.NewFromFiles(pkg.fset, pkg.files, pkg.id)
+	if err != nil {
+		slog.Debug("extract.extractGoDoc could not compute package documentation", "package", pkg.id, "error", err)
+		return err
+	}
+
+	// Convert the package documentation to markdown
+	extractPurpose := !pkg.options.DisablePurposeExtraction
+	markdown := renderGoPackage(docPkg, pkg.fset, extractPurpose)
+	purpose := ""
+	if extractPurpose {
+		purpose = docPkg.Synopsis(docPkg.Doc)
+	}
+
+	// Insert document
+	err = db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            pkg.id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeGoDoc.String(),
+		Purpose:       purpose,
+		RawData:       string(pkg.rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractGoDoc could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(pkg.id, sourceID, markdown, extractPurpose, goDocPurposeKey, db)
+	if err != nil {
+		slog.Debug("extract.extractGoDoc could not extract sections", "error", err)
+		return err
+	}
+
+	// Insert directives as tags on the document (for package directives) or section
+	return insertGoDirectiveTags(pkg.id, sourceID, markdown, directives, db)
+}
+
+// goDocPurposeKey is the key used for section purposes in the markdown rendered for a package
+const goDocPurposeKey = "purpose"
+
+var goDirectiveRegex = regexp.MustCompile(`^//((?:[a-z0-9]+:[a-z0-9]\S*)|line|extern|export)(?:\s+(.*))?$`)
+
+// getGoDirectives returns the directives (e.g. //go:generate) in the doc comments of the files,
+// keyed by the symbol they document (e.g. Type or Type.Method, with the package itself keyed by "")
+// and then by directive name. Repeated directives are joined with a space.
+func getGoDirectives(files []*ast.File) map[string]map[string]string {
+	directives := map[string]map[string]string{}
+	add := func(symbol string, groups ...*ast.CommentGroup) {
+		for _, group := range groups {
+			if group == nil {
+				continue
+			}
+			for _, comment := range group.List {
+				match := goDirectiveRegex.FindStringSubmatch(comment.Text)
+				if match == nil {
+					continue
+				}
+				if directives[symbol] == nil {
+					directives[symbol] = map[string]string{}
+				}
+				value := strings.TrimSpace(match[2])
+				if existing, ok := directives[symbol][match[1]]; ok && existing != "" {
+					value = strings.TrimSpace(existing + " " + value)
+				}
+				directives[symbol][match[1]] = value
+			}
+		}
+	}
+
+	for _, file := range files {
+		add("", file.Doc)
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				name := decl.Name.Name
+				if decl.Recv != nil && len(decl.Recv.List) > 0 {
+					name = goReceiverTypeName(decl.Recv.List[0].Type) + "." + name
+				}
+				add(name, decl.Doc)
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						add(spec.Name.Name, decl.Doc, spec.Doc)
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							add(name.Name, decl.Doc, spec.Doc)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return directives
+}
+
+// goReceiverTypeName returns the name of the type of a method receiver
+func goReceiverTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.StarExpr:
+		return goReceiverTypeName(expr.X)
+	case *ast.ParenExpr:
+		return goReceiverTypeName(expr.X)
+	case *ast.IndexExpr:
+		return goReceiverTypeName(expr.X)
+	case *ast.IndexListExpr:
+		return goReceiverTypeName(expr.X)
+	}
+
+	return ""
+}
+
+// renderGoPackage renders the documentation of a package as markdown. The package documentation
+// comes first, followed by a level 1 section for each exported constant, variable, function, and
+// type. The functions, methods, constants, and variables associated with a type are level 2
+// sections of the type. Each section holds the declaration and its doc comment, along with a
+// purpose comment holding the synopsis of the doc comment (if extractPurpose is set).
+func renderGoPackage(pkg *doc.Package, fset *token.FileSet, extractPurpose bool) string {
+	r := &goRenderer{
+		pkg:            pkg,
+		fset:           fset,
+		extractPurpose: extractPurpose,
+	}
+
+	if pkg.Doc != "" {
+		r.doc(pkg.Doc, 1)
+	}
+	for _, value := range pkg.Consts {
+		r.value(value, 1)
+	}
+	for _, value := range pkg.Vars {
+		r.value(value, 1)
+	}
+	for _, fn := range pkg.Funcs {
+		r.function(fn.Name, fn, 1)
+	}
+	for _, typ := range pkg.Types {
+		r.section(typ.Name, typ.Decl, typ.Doc, 1)
+		for _, value := range typ.Consts {
+			r.value(value, 2)
+		}
+		for _, value := range typ.Vars {
+			r.value(value, 2)
+		}
+		for _, fn := range typ.Funcs {
+			r.function(fn.Name, fn, 2)
+		}
+		for _, method := range typ.Methods {
+			// Skip methods promoted from embedded types
+			if method.Level > 0 {
+				continue
+			}
+			r.function(method.Name, method, 2)
+		}
+	}
+
+	return strings.TrimSpace(r.out.String())
+}
+
+type goRenderer struct {
+	pkg            *doc.Package
+	fset           *token.FileSet
+	extractPurpose bool
+	out            strings.Builder
+}
+
+func (r *goRenderer) value(value *doc.Value, level int) {
+	r.section(strings.Join(value.Names, ", "), value.Decl, value.Doc, level)
+}
+
+func (r *goRenderer) function(name string, fn *doc.Func, level int) {
+	r.section(name, fn.Decl, fn.Doc, level)
+}
+
+// section renders a section with the declaration and doc comment of a symbol
+func (r *goRenderer) section(name string, decl ast.Decl, docText string, level int) {
+	fmt.Fprintf(&r.out, "%s %s\n\n", strings.Repeat("#", level), name)
+	if r.extractPurpose {
+		if synopsis := r.pkg.Synopsis(docText); synopsis != "" {
+			fmt.Fprintf(&r.out, "%s\n\n", formatPurposeComment(goDocPurposeKey, synopsis))
+		}
+	}
+	fmt.Fprintf(&r.out, "```go\n%s\n```\n\n", r.declaration(decl))
+	if docText != "" {
+		// Headings in doc comments are nested below the section
+		r.doc(docText, level+1)
+	}
+}
+
+// doc renders a doc comment as markdown
+func (r *goRenderer) doc(text string, headingLevel int) {
+	printer := r.pkg.Printer()
+	printer.HeadingLevel = headingLevel
+	printer.DocLinkBaseURL = "https://pkg.go.dev"
+	r.out.Write(printer.Markdown(r.pkg.Parser().Parse(text)))
+	r.out.WriteString("\n")
+}
+
+// declaration returns the source of a declaration, without its doc comment or body but with the
+// comments on its fields and specs
+func (r *goRenderer) declaration(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		copied := *d
+		copied.Doc = nil
+		copied.Body = nil
+		decl = &copied
+	case *ast.GenDecl:
+		copied := *d
+		copied.Doc = nil
+		decl = &copied
+	}
+
+	// Only keep the comments that belong to the parts of the declaration that are documented
+	comments := []*ast.CommentGroup{}
+	ast.Inspect(decl, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Field:
+			comments = appendCommentGroups(comments, node.Doc, node.Comment)
+		case *ast.ValueSpec:
+			comments = appendCommentGroups(comments, node.Doc, node.Comment)
+		case *ast.TypeSpec:
+			comments = appendCommentGroups(comments, node.Doc, node.Comment)
+		}
+		return true
+	})
+
+	// Print using the same settings as gofmt
+	var b bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	err := cfg.Fprint(&b, r.fset, &printer.CommentedNode{Node: decl, Comments: comments})
+	if err != nil {
+		slog.Debug("extract.goRenderer could not print declaration", "error", err)
+		return ""
+	}
+
+	return b.String()
+}
+
+func appendCommentGroups(comments []*ast.CommentGroup, groups ...*ast.CommentGroup) []*ast.CommentGroup {
+	for _, group := range groups {
+		if group != nil {
+			comments = append(comments, group)
+		}
+	}
+	return comments
+}
+
+// insertGoDirectiveTags inserts directives as tags on the document (for package directives) and on
+// the sections of the symbols they document
+func insertGoDirectiveTags(documentID string, sourceID string, markdown string, directives map[string]map[string]string, db *sqlite.Queries) error {
+	ctx := context.Background()
+	sectionIDs := getGoSectionIDs(markdown)
+
+	symbols := make([]string, 0, len(directives))
+	for symbol := range directives {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		for key, value := range directives[symbol] {
+			if symbol == "" {
+				err := db.UpsertDocumentTag(ctx, sqlite.UpsertDocumentTagParams{
+					SourceID:   sourceID,
+					DocumentID: documentID,
+					TagKey:     key,
+					TagValue:   value,
+				})
+				if err != nil {
+					slog.Debug("extract.insertGoDirectiveTags could not insert document tag", "document", documentID, "error", err)
+					return err
+				}
+				continue
+			}
+
+			// Directives on unexported symbols are skipped, as they have no section
+			sectionID, ok := sectionIDs[symbol]
+			if !ok {
+				continue
+			}
+			err := db.UpsertSectionTag(ctx, sqlite.UpsertSectionTagParams{
+				SourceID:   sourceID,
+				DocumentID: documentID,
+				SectionID:  sectionID,
+				TagKey:     key,
+				TagValue:   value,
+			})
+			if err != nil {
+				slog.Debug("extract.insertGoDirectiveTags could not insert section tag", "document", documentID, "section", sectionID, "error", err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getGoSectionIDs returns the ID of the section for each symbol in the markdown rendered for a
+// package, keyed the same way as getGoDirectives (e.g. Type or Type.Method)
+func getGoSectionIDs(markdown string) map[string]string {
+	sectionIDs := map[string]string{}
+	var walk func(s *section)
+	walk = func(s *section) {
+		for _, child := range s.Children {
+			// Sections for grouped constants and variables are named for every symbol in the group
+			for _, name := range strings.Split(child.Name, ", ") {
+				if s.Parent != nil {
+					sectionIDs[s.Name+"."+name] = child.FullName
+				}
+				if _, ok := sectionIDs[name]; !ok || s.Parent == nil {
+					sectionIDs[name] = child.FullName
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(getMarkdownSections(strings.Split(markdown, "\n")))
+
+	return sectionIDs
+}
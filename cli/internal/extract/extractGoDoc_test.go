@@ -0,0 +1,191 @@
+package extract
+
+import (
+	"go/doc"
+	"hyaline/internal/config"
+	"strings"
+	"testing"
+)
+
+var goWidgetFiles = map[string]string{
+	"widget/widget.go": `// Package widget builds widgets.
+//
+//hyaline:owner platform
+package widget
+
+// DefaultSize is the size of a widget when none is given.
+const DefaultSize = 10
+
+// Widget is a thing that can be built.
+//
+//go:generate stringer -type=Widget
+type Widget struct {
+	// Name of the widget
+	Name string
+	size int
+}
+
+// New returns a new widget.
+func New(name string) *Widget {
+	return &Widget{Name: name, size: DefaultSize}
+}
+
+// Build builds the widget.
+//
+//go:noinline
+func (w *Widget) Build() error {
+	return nil
+}
+
+func (w *Widget) reset() {}
+`,
+	"widget/color.go": `package widget
+
+// Colors
+const (
+	Red  = "red"
+	Blue = "blue"
+)
+`,
+	"widget/widget_test.go": `package widget
+
+// TestOnly is not documentation.
+func TestOnly() {}
+`,
+	"widget/gen.go": `//go:build ignore
+
+package main
+
+// Generate is ignored.
+func Generate() {}
+`,
+}
+
+// getTestGoPackages returns the packages made up of files
+func getTestGoPackages(files map[string]string) []goPackage {
+	g := newGoFiles()
+	for id, contents := range files {
+		g.add(id, []byte(contents), &config.ExtractorOptions{})
+	}
+	return g.packages()
+}
+
+func TestGoFilesPackages(t *testing.T) {
+	files := map[string]string{
+		"broken/broken.go": "package broken\n\nfunc Broken( {",
+		"broken/ok.go":     "package broken\n\nfunc Ok() {}\n",
+		"multi/a.go":       "package a\n",
+		"multi/b.go":       "package b\n",
+	}
+	for id, contents := range goWidgetFiles {
+		files[id] = contents
+	}
+
+	packages := getTestGoPackages(files)
+	var tests = []struct {
+		id    string
+		files int
+	}{
+		{"broken", 1},
+		{"multi@a", 1},
+		{"multi@b", 1},
+		{"widget", 2},
+	}
+	if len(packages) != len(tests) {
+		t.Fatalf("expected %d packages, got %d", len(tests), len(packages))
+	}
+	for i, test := range tests {
+		if packages[i].id != test.id || len(packages[i].files) != test.files {
+			t.Errorf("test %d - expected package %s with %d files, got %s with %d files", i, test.id, test.files, packages[i].id, len(packages[i].files))
+		}
+	}
+}
+
+func TestRenderGoPackage(t *testing.T) {
+	pkg := getTestGoPackages(goWidgetFiles)[0]
+	docPkg, err := doc.NewFromFiles(pkg.fset, pkg.files, pkg.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markdown := renderGoPackage(docPkg, pkg.fset, true)
+
+	for _, excluded := range []string{"TestOnly", "Generate", "reset", "size int"} {
+		if strings.Contains(markdown, excluded) {
+			t.Errorf("expected %s to not be rendered, got:\n%s", excluded, markdown)
+		}
+	}
+
+	// A section per exported symbol, with methods and constructors under their type
+	root := getMarkdownSections(strings.Split(markdown, "\n"))
+	extractMarkdownSectionPurposes(root, goDocPurposeKey)
+	sectionMap := map[string]*section{}
+	var walk func(s *section)
+	walk = func(s *section) {
+		for _, child := range s.Children {
+			sectionMap[child.FullName] = child
+			walk(child)
+		}
+	}
+	walk(root)
+	var tests = []struct {
+		id      string
+		purpose string
+		content string
+	}{
+		{"Red, Blue", "Colors", "Red  = \"red\""},
+		{"Widget", "Widget is a thing that can be built.", "// Name of the widget\n\tName string"},
+		{"DefaultSize", "DefaultSize is the size of a widget when none is given.", "const DefaultSize = 10"},
+		{"Widget/New", "New returns a new widget.", "func New(name string) *Widget\n```"},
+		{"Widget/Build", "Build builds the widget.", "func (w *Widget) Build() error"},
+	}
+	if len(sectionMap) != len(tests) {
+		t.Errorf("expected %d sections, got %d", len(tests), len(sectionMap))
+	}
+	for i, test := range tests {
+		section, ok := sectionMap[test.id]
+		if !ok {
+			t.Errorf("test %d - expected section %s, got none", i, test.id)
+			continue
+		}
+		if section.Purpose != test.purpose {
+			t.Errorf("test %d - expected purpose %q, got %q", i, test.purpose, section.Purpose)
+		}
+		if !strings.Contains(section.Content, test.content) {
+			t.Errorf("test %d - expected content to contain %q, got:\n%s", i, test.content, section.Content)
+		}
+	}
+}
+
+func TestGetGoDirectives(t *testing.T) {
+	pkg := getTestGoPackages(goWidgetFiles)[0]
+	directives := getGoDirectives(pkg.files)
+	docPkg, err := doc.NewFromFiles(pkg.fset, pkg.files, pkg.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectionIDs := getGoSectionIDs(renderGoPackage(docPkg, pkg.fset, true))
+
+	// Directives on the package clause belong to the document, the rest to the section of their symbol
+	var tests = []struct {
+		symbol  string
+		section string
+		key     string
+		value   string
+	}{
+		{"", "", "hyaline:owner", "platform"},
+		{"Widget", "Widget", "go:generate", "stringer -type=Widget"},
+		{"Widget.Build", "Widget/Build", "go:noinline", ""},
+	}
+	if len(directives) != len(tests) {
+		t.Errorf("expected directives for %d symbols, got %v", len(tests), directives)
+	}
+	for i, test := range tests {
+		value, ok := directives[test.symbol][test.key]
+		if !ok || value != test.value {
+			t.Errorf("test %d - expected directive %s=%q for %q, got %v", i, test.key, test.value, test.symbol, directives[test.symbol])
+		}
+		if test.symbol != "" && sectionIDs[test.symbol] != test.section {
+			t.Errorf("test %d - expected section %s for %s, got %q", i, test.section, test.symbol, sectionIDs[test.symbol])
+		}
+	}
+}
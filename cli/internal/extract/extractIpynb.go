@@ -0,0 +1,190 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"strings"
+)
+
+// notebook is the subset of the Jupyter notebook format (nbformat 4) used for extraction
+type notebook struct {
+	Metadata map[string]any `json:"metadata"`
+	Cells    []notebookCell `json:"cells"`
+}
+
+type notebookCell struct {
+	CellType string           `json:"cell_type"`
+	Metadata map[string]any   `json:"metadata"`
+	Source   notebookText     `json:"source"`
+	Outputs  []notebookOutput `json:"outputs"`
+}
+
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	EName      string                  `json:"ename"`
+	EValue     string                  `json:"evalue"`
+}
+
+// notebookText is multiline text, which notebooks store as either a string or a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = notebookText(text)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		// Ignore data that is not text (such as JSON outputs)
+		return nil
+	}
+	*t = notebookText(strings.Join(lines, ""))
+	return nil
+}
+
+// notebookMaxOutputLines is the number of lines of each cell output kept when extracting
+const notebookMaxOutputLines = 10
+
+func extractIpynb(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	var nb notebook
+	err := json.Unmarshal(rawData, &nb)
+	if err != nil {
+		slog.Debug("extract.extractIpynb could not parse notebook", "error", err)
+		return err
+	}
+
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Convert the notebook to markdown
+	markdown := convertNotebookToMarkdown(&nb, purposeKey)
+	purpose := ""
+	if purposeKey != "" {
+		purpose, _ = nb.Metadata[purposeKey].(string)
+	}
+
+	// Insert document
+	err = db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeIpynb.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractIpynb could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, purposeKey != "", purposeKey, db)
+	if err != nil {
+		slog.Debug("extract.extractIpynb could not extract sections", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// convertNotebookToMarkdown converts a notebook to markdown. Markdown cells are kept as-is (so
+// their headings become sections), code cells become fenced code blocks followed by their text
+// outputs (truncated to notebookMaxOutputLines lines), and rich outputs (such as images) are
+// dropped. If a markdown cell starts with a heading and its metadata has a purpose (identified by
+// purposeKey), the purpose is added to the section as a purpose comment.
+func convertNotebookToMarkdown(nb *notebook, purposeKey string) string {
+	language := notebookLanguage(nb)
+	out := []string{}
+
+	for _, cell := range nb.Cells {
+		source := strings.TrimSpace(strings.ReplaceAll(string(cell.Source), "\r", ""))
+		if source == "" && len(cell.Outputs) == 0 {
+			continue
+		}
+
+		switch cell.CellType {
+		case "markdown":
+			lines := strings.Split(source, "\n")
+			if purpose, ok := cell.Metadata[purposeKey].(string); ok && purposeKey != "" && countPounds(lines[0]) > 0 {
+				lines = append([]string{lines[0], "", formatPurposeComment(purposeKey, purpose)}, lines[1:]...)
+			}
+			out = append(out, strings.Join(lines, "\n"))
+		case "code":
+			if source != "" {
+				out = append(out, "```"+language+"\n"+source+"\n```")
+			}
+			if outputs := notebookOutputs(cell.Outputs); outputs != "" {
+				out = append(out, "Output:\n\n```\n"+outputs+"\n```")
+			}
+		case "raw":
+			out = append(out, "```\n"+source+"\n```")
+		}
+	}
+
+	return strings.Join(out, "\n\n")
+}
+
+// notebookLanguage returns the language of the code cells in a notebook (if known)
+func notebookLanguage(nb *notebook) string {
+	if info, ok := nb.Metadata["language_info"].(map[string]any); ok {
+		if name, ok := info["name"].(string); ok {
+			return name
+		}
+	}
+	if kernel, ok := nb.Metadata["kernelspec"].(map[string]any); ok {
+		if language, ok := kernel["language"].(string); ok {
+			return language
+		}
+	}
+
+	return ""
+}
+
+// notebookOutputs returns the text outputs of a cell, truncated to notebookMaxOutputLines lines
+func notebookOutputs(outputs []notebookOutput) string {
+	texts := []string{}
+	for _, output := range outputs {
+		switch output.OutputType {
+		case "stream":
+			texts = append(texts, strings.TrimRight(string(output.Text), "\n"))
+		case "execute_result", "display_data":
+			if text, ok := output.Data["text/plain"]; ok {
+				texts = append(texts, strings.TrimRight(string(text), "\n"))
+			}
+		case "error":
+			texts = append(texts, fmt.Sprintf("%s: %s", output.EName, output.EValue))
+		}
+	}
+
+	text := strings.TrimSpace(strings.ReplaceAll(strings.Join(texts, "\n"), "\r", ""))
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > notebookMaxOutputLines {
+		remaining := len(lines) - notebookMaxOutputLines
+		lines = append(lines[:notebookMaxOutputLines], fmt.Sprintf("... (%d more lines)", remaining))
+	}
+
+	// Make sure the output cannot end the code block early
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			lines[i] = " " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,106 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const ipynbAnalysis = `{
+  "nbformat": 4,
+  "nbformat_minor": 5,
+  "metadata": {
+    "purpose": "Analyze weekly signups",
+    "kernelspec": {"name": "python3", "language": "python"},
+    "language_info": {"name": "python"}
+  },
+  "cells": [
+    {"cell_type": "markdown", "metadata": {}, "source": ["# Signups\n", "\n", "How signups changed this week."]},
+    {"cell_type": "markdown", "metadata": {"purpose": "Load the data"}, "source": "## Load"},
+    {"cell_type": "code", "metadata": {}, "execution_count": 1, "source": ["# read the csv\n", "df = load()\n", "df.head()"], "outputs": [
+      {"output_type": "stream", "name": "stdout", "text": "loaded\n"},
+      {"output_type": "execute_result", "execution_count": 1, "metadata": {}, "data": {"text/plain": ["   id\n", "0   1"], "image/png": "iVBORw0KGgo="}}
+    ]},
+    {"cell_type": "markdown", "metadata": {}, "source": "## Plot"},
+    {"cell_type": "code", "metadata": {}, "execution_count": 2, "source": "df.plot()", "outputs": [
+      {"output_type": "display_data", "metadata": {}, "data": {"image/png": "iVBORw0KGgo="}}
+    ]},
+    {"cell_type": "code", "metadata": {}, "execution_count": 3, "source": "explode()", "outputs": [
+      {"output_type": "error", "ename": "NameError", "evalue": "name 'explode' is not defined", "traceback": []}
+    ]},
+    {"cell_type": "code", "metadata": {}, "execution_count": null, "source": "", "outputs": []}
+  ]
+}`
+
+func TestConvertNotebookToMarkdown(t *testing.T) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(ipynbAnalysis), &nb); err != nil {
+		t.Fatal(err)
+	}
+	markdown := convertNotebookToMarkdown(&nb, "purpose")
+	if strings.Contains(markdown, "iVBORw0KGgo=") {
+		t.Errorf("expected image outputs to be dropped, got:\n%s", markdown)
+	}
+
+	root := getMarkdownSections(strings.Split(markdown, "\n"))
+	extractMarkdownSectionPurposes(root, "purpose")
+	sectionMap := map[string]*section{}
+	var walk func(s *section)
+	walk = func(s *section) {
+		for _, child := range s.Children {
+			sectionMap[child.FullName] = child
+			walk(child)
+		}
+	}
+	walk(root)
+	var tests = []struct {
+		id       string
+		purpose  string
+		contents []string
+	}{
+		{"Signups", "", []string{"How signups changed this week."}},
+		{"Signups/Load", "Load the data", []string{"```python\n# read the csv\ndf = load()\ndf.head()\n```", "Output:\n\n```\nloaded\n   id\n0   1\n```"}},
+		{"Signups/Plot", "", []string{"```python\ndf.plot()\n```", "```python\nexplode()\n```", "NameError: name 'explode' is not defined"}},
+	}
+	if len(sectionMap) != len(tests) {
+		t.Errorf("expected %d sections, got %d", len(tests), len(sectionMap))
+	}
+	for i, test := range tests {
+		section, ok := sectionMap[test.id]
+		if !ok {
+			t.Errorf("test %d - expected section %s, got none", i, test.id)
+			continue
+		}
+		if section.Purpose != test.purpose {
+			t.Errorf("test %d - expected purpose %q, got %q", i, test.purpose, section.Purpose)
+		}
+		for _, content := range test.contents {
+			if !strings.Contains(section.Content, content) {
+				t.Errorf("test %d - expected content to contain %q, got:\n%s", i, content, section.Content)
+			}
+		}
+	}
+}
+
+func TestConvertNotebookToMarkdown_TruncatesOutputs(t *testing.T) {
+	lines := []string{}
+	for i := range 15 {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	nb := notebook{Cells: []notebookCell{{
+		CellType: "code",
+		Source:   "print(lines)",
+		Outputs: []notebookOutput{
+			{OutputType: "stream", Text: notebookText(strings.Join(lines, ""))},
+		},
+	}}}
+
+	markdown := convertNotebookToMarkdown(&nb, "purpose")
+	if !strings.Contains(markdown, "line 9\n... (5 more lines)\n```") {
+		t.Errorf("expected output to be truncated after %d lines, got:\n%s", notebookMaxOutputLines, markdown)
+	}
+	if strings.Contains(markdown, "line 10") {
+		t.Errorf("expected line 10 to be truncated, got:\n%s", markdown)
+	}
+}
@@ -0,0 +1,631 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func extractOpenAPI(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	ctx := context.Background()
+
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Render the spec as markdown
+	markdown, purpose, sectionTags, err := convertOpenAPIToMarkdown(id, rawData, purposeKey)
+	if err != nil {
+		slog.Debug("extract.extractOpenAPI could not convert spec", "document", id, "error", err)
+		return err
+	}
+
+	// Insert document
+	err = db.InsertDocument(ctx, sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeOpenAPI.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractOpenAPI could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, purposeKey != "", purposeKey, db)
+	if err != nil {
+		slog.Debug("extract.extractOpenAPI could not extract sections", "error", err)
+		return err
+	}
+
+	// Insert the tags of each tag and operation section
+	sectionIDs := make([]string, 0, len(sectionTags))
+	for sectionID := range sectionTags {
+		sectionIDs = append(sectionIDs, sectionID)
+	}
+	sort.Strings(sectionIDs)
+	for _, sectionID := range sectionIDs {
+		for _, tag := range sectionTags[sectionID] {
+			err = db.UpsertSectionTag(ctx, sqlite.UpsertSectionTagParams{
+				SourceID:   sourceID,
+				DocumentID: id,
+				SectionID:  sectionID,
+				TagKey:     tag.key,
+				TagValue:   tag.value,
+			})
+			if err != nil {
+				slog.Debug("extract.extractOpenAPI could not insert section tag", "section", sectionID, "error", err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// convertOpenAPIToMarkdown renders an OpenAPI 3 spec (in YAML or JSON) as markdown, returning the
+// markdown, the purpose of the spec, and the tags of each section (keyed by section ID)
+func convertOpenAPIToMarkdown(id string, rawData []byte, purposeKey string) (markdown string, purpose string, sectionTags map[string][]openAPITag, err error) {
+	// Parse the spec (YAML or JSON, as JSON is valid YAML)
+	var node yaml.Node
+	err = yaml.Unmarshal(rawData, &node)
+	if err != nil {
+		slog.Debug("extract.convertOpenAPIToMarkdown could not parse spec", "error", err)
+		return
+	}
+	spec, ok := newOpenAPIValue(&node).(*openAPIObject)
+	if !ok || !strings.HasPrefix(spec.str("openapi"), "3.") {
+		err = errors.New("could not find an OpenAPI 3 spec in " + id)
+		slog.Debug("extract.convertOpenAPIToMarkdown document is not an OpenAPI 3 spec", "document", id, "error", err)
+		return
+	}
+
+	r := &openAPIRenderer{
+		spec:       spec,
+		purposeKey: purposeKey,
+		tags:       map[int][]openAPITag{},
+	}
+	r.render()
+	markdown = strings.TrimSpace(strings.Join(r.out, "\n"))
+	if purposeKey != "" {
+		purpose = spec.object("info").str("x-" + purposeKey)
+	}
+
+	sectionIDs := getSectionIDsByLine(markdown)
+	sectionTags = map[string][]openAPITag{}
+	for line, tags := range r.tags {
+		if len(tags) > 0 {
+			sectionTags[sectionIDs[line]] = append(sectionTags[sectionIDs[line]], tags...)
+		}
+	}
+
+	return
+}
+
+// openAPIObject is an object in an OpenAPI spec that keeps the order of its keys, so that paths,
+// properties, and so on are rendered in the order they are written
+type openAPIObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// newOpenAPIValue converts a parsed YAML node to an *openAPIObject, []any, or scalar value
+func newOpenAPIValue(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return newOpenAPIValue(node.Content[0])
+	case yaml.AliasNode:
+		return newOpenAPIValue(node.Alias)
+	case yaml.MappingNode:
+		object := &openAPIObject{values: map[string]any{}}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if _, ok := object.values[key]; !ok {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = newOpenAPIValue(node.Content[i+1])
+		}
+		return object
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			list = append(list, newOpenAPIValue(item))
+		}
+		return list
+	default:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return node.Value
+		}
+		return value
+	}
+}
+
+func (o *openAPIObject) get(key string) any {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+func (o *openAPIObject) object(key string) *openAPIObject {
+	object, _ := o.get(key).(*openAPIObject)
+	return object
+}
+
+func (o *openAPIObject) list(key string) []any {
+	list, _ := o.get(key).([]any)
+	return list
+}
+
+func (o *openAPIObject) str(key string) string {
+	switch value := o.get(key).(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (o *openAPIObject) bool(key string) bool {
+	value, _ := o.get(key).(bool)
+	return value
+}
+
+type openAPITag struct {
+	key   string
+	value string
+}
+
+// openAPIMethods are the operations of a path item, in the order they are rendered
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type openAPIOperation struct {
+	method    string
+	path      string
+	pathItem  *openAPIObject
+	operation *openAPIObject
+}
+
+// openAPIRenderer renders an OpenAPI spec as markdown. The document starts with the API info,
+// followed by a level 1 section for each tag (with a level 2 section for each operation with that
+// as its first tag), a level 1 section for each operation without a tag, and a level 1 Schemas
+// section (with a level 2 section for each schema in the components).
+type openAPIRenderer struct {
+	spec       *openAPIObject
+	purposeKey string
+	out        []string
+	// tags are the tags of the sections rendered, keyed by the line of the section heading
+	tags map[int][]openAPITag
+}
+
+func (r *openAPIRenderer) emit(lines ...string) {
+	r.out = append(r.out, lines...)
+}
+
+// paragraph emits text followed by a blank line (if the text is not blank)
+func (r *openAPIRenderer) paragraph(text string) {
+	text = strings.TrimSpace(text)
+	if text != "" {
+		r.emit(strings.Split(text, "\n")...)
+		r.emit("")
+	}
+}
+
+// heading emits a section heading with its purpose (if any) and tags, making sure the heading is
+// not read as part of the previous line
+func (r *openAPIRenderer) heading(level int, name string, object *openAPIObject, tags ...openAPITag) {
+	if len(r.out) > 0 && r.out[len(r.out)-1] != "" {
+		r.emit("")
+	}
+	r.tags[len(r.out)] = tags
+	r.emit(strings.Repeat("#", level)+" "+name, "")
+	if r.purposeKey != "" {
+		if purpose := object.str("x-" + r.purposeKey); purpose != "" {
+			r.emit(formatPurposeComment(r.purposeKey, purpose), "")
+		}
+	}
+}
+
+func (r *openAPIRenderer) render() {
+	// Info
+	info := r.spec.object("info")
+	title := info.str("title")
+	if version := info.str("version"); version != "" {
+		title = strings.TrimSpace(title + " (version " + version + ")")
+	}
+	if title != "" {
+		r.paragraph("**" + title + "**")
+	}
+	r.paragraph(escapeMarkdownHeadings(info.str("description")))
+	if servers := r.spec.list("servers"); len(servers) > 0 {
+		r.emit("Servers:", "")
+		for _, server := range servers {
+			server, _ := server.(*openAPIObject)
+			line := "- `" + server.str("url") + "`"
+			if description := server.str("description"); description != "" {
+				line += ": " + description
+			}
+			r.emit(line)
+		}
+		r.emit("")
+	}
+
+	// Group the operations by their first tag, keeping the order of the tags in the spec
+	tagNames := []string{}
+	tagObjects := map[string]*openAPIObject{}
+	for _, tag := range r.spec.list("tags") {
+		tag, _ := tag.(*openAPIObject)
+		name := tag.str("name")
+		if name != "" {
+			if _, ok := tagObjects[name]; !ok {
+				tagNames = append(tagNames, name)
+			}
+			tagObjects[name] = tag
+		}
+	}
+	operationsByTag := map[string][]openAPIOperation{}
+	untagged := []openAPIOperation{}
+	paths := r.spec.object("paths")
+	for _, path := range paths.keysOrEmpty() {
+		pathItem := paths.object(path)
+		for _, method := range openAPIMethods {
+			operation := pathItem.object(method)
+			if operation == nil {
+				continue
+			}
+			op := openAPIOperation{method: method, path: path, pathItem: pathItem, operation: operation}
+			tags := operation.list("tags")
+			if len(tags) == 0 {
+				untagged = append(untagged, op)
+				continue
+			}
+			tag := fmt.Sprint(tags[0])
+			if _, ok := tagObjects[tag]; !ok {
+				tagNames = append(tagNames, tag)
+				tagObjects[tag] = nil
+			}
+			operationsByTag[tag] = append(operationsByTag[tag], op)
+		}
+	}
+
+	// Tags and their operations
+	for _, name := range tagNames {
+		tag := tagObjects[name]
+		r.heading(1, name, tag, openAPITag{"tag", name})
+		r.paragraph(escapeMarkdownHeadings(tag.str("description")))
+		if docs := tag.object("externalDocs"); docs != nil {
+			r.paragraph(openAPIExternalDocs(docs))
+		}
+		for _, op := range operationsByTag[name] {
+			r.operation(op, 2)
+		}
+	}
+	for _, op := range untagged {
+		r.operation(op, 1)
+	}
+
+	// Schemas
+	schemas := r.spec.object("components").object("schemas")
+	if schemas != nil && len(schemas.keys) > 0 {
+		r.heading(1, "Schemas", nil)
+		for _, name := range schemas.keys {
+			schema := schemas.object(name)
+			r.heading(2, name, schema)
+			r.schema(schema)
+		}
+	}
+}
+
+// operation renders the section for an operation, named by its operationId (or its method and
+// path if it does not have one)
+func (r *openAPIRenderer) operation(op openAPIOperation, level int) {
+	operation := op.operation
+	endpoint := strings.ToUpper(op.method) + " " + op.path
+
+	tags := []openAPITag{}
+	name := operation.str("operationId")
+	if name != "" {
+		tags = append(tags, openAPITag{"operationId", name})
+	} else {
+		name = endpoint
+	}
+	for _, tag := range operation.list("tags") {
+		tags = append(tags, openAPITag{"tag", fmt.Sprint(tag)})
+	}
+	r.heading(level, name, operation, tags...)
+
+	r.paragraph("`" + endpoint + "`")
+	if operation.bool("deprecated") {
+		r.paragraph("**Deprecated**")
+	}
+	r.paragraph(escapeMarkdownHeadings(operation.str("summary")))
+	r.paragraph(escapeMarkdownHeadings(operation.str("description")))
+	if docs := operation.object("externalDocs"); docs != nil {
+		r.paragraph(openAPIExternalDocs(docs))
+	}
+
+	// Parameters (operation parameters override path item parameters with the same name and location)
+	parameters := []*openAPIObject{}
+	index := map[string]int{}
+	for _, list := range [][]any{op.pathItem.list("parameters"), operation.list("parameters")} {
+		for _, parameter := range list {
+			parameter := r.resolve(parameter)
+			key := parameter.str("in") + ":" + parameter.str("name")
+			if i, ok := index[key]; ok {
+				parameters[i] = parameter
+				continue
+			}
+			index[key] = len(parameters)
+			parameters = append(parameters, parameter)
+		}
+	}
+	if len(parameters) > 0 {
+		r.emit("**Parameters**", "")
+		r.emit("| Name | In | Type | Required | Description |", "| --- | --- | --- | --- | --- |")
+		for _, parameter := range parameters {
+			r.emit(openAPITableRow(
+				"`"+parameter.str("name")+"`",
+				parameter.str("in"),
+				r.schemaType(parameter.get("schema")),
+				openAPIYesNo(parameter.bool("required")),
+				parameter.str("description"),
+			))
+		}
+		r.emit("")
+	}
+
+	// Request body
+	if requestBody := r.resolve(operation.get("requestBody")); requestBody != nil {
+		label := "**Request Body**"
+		if requestBody.bool("required") {
+			label += " (required)"
+		}
+		r.paragraph(label)
+		r.paragraph(escapeMarkdownHeadings(requestBody.str("description")))
+		r.content(requestBody.object("content"))
+	}
+
+	// Responses
+	if responses := operation.object("responses"); responses != nil && len(responses.keys) > 0 {
+		r.emit("**Responses**", "")
+		for _, status := range responses.keys {
+			response := r.resolve(responses.get(status))
+			line := "- `" + status + "`"
+			if description := strings.TrimSpace(response.str("description")); description != "" {
+				line += ": " + strings.Join(strings.Fields(description), " ")
+			}
+			r.emit(line)
+			content := response.object("content")
+			for _, mediaType := range content.keysOrEmpty() {
+				line := "  - `" + mediaType + "`"
+				if schemaType := r.schemaType(content.object(mediaType).get("schema")); schemaType != "" {
+					line += ": " + schemaType
+				}
+				r.emit(line)
+			}
+		}
+		r.emit("")
+	}
+}
+
+// content renders the media types of a request body, along with their schemas
+func (r *openAPIRenderer) content(content *openAPIObject) {
+	for _, mediaType := range content.keysOrEmpty() {
+		schema := content.object(mediaType).get("schema")
+		line := "`" + mediaType + "`"
+		if schemaType := r.schemaType(schema); schemaType != "" {
+			line += ": " + schemaType
+		}
+		r.paragraph(line)
+		r.properties(r.resolve(schema))
+	}
+}
+
+// schema renders a schema, including its properties (if it is an object)
+func (r *openAPIRenderer) schema(schema *openAPIObject) {
+	if schemaType := r.schemaType(schema); schemaType != "" {
+		r.paragraph("Type: " + schemaType)
+	}
+	r.paragraph(escapeMarkdownHeadings(schema.str("description")))
+	r.properties(schema)
+}
+
+// properties renders a table of the properties of an object schema (including the properties of
+// any allOf schemas)
+func (r *openAPIRenderer) properties(schema *openAPIObject) {
+	names := []string{}
+	properties := map[string]*openAPIObject{}
+	required := map[string]bool{}
+	var collect func(schema *openAPIObject, depth int)
+	collect = func(schema *openAPIObject, depth int) {
+		// Limit the depth to guard against circular references
+		if schema == nil || depth > 8 {
+			return
+		}
+		for _, part := range schema.list("allOf") {
+			collect(r.resolve(part), depth+1)
+		}
+		for _, name := range schema.list("required") {
+			required[fmt.Sprint(name)] = true
+		}
+		props := schema.object("properties")
+		for _, name := range props.keysOrEmpty() {
+			if _, ok := properties[name]; !ok {
+				names = append(names, name)
+			}
+			properties[name] = props.object(name)
+		}
+	}
+	collect(schema, 0)
+	if len(names) == 0 {
+		return
+	}
+
+	r.emit("| Property | Type | Required | Description |", "| --- | --- | --- | --- |")
+	for _, name := range names {
+		property := properties[name]
+		description := property.str("description")
+		if description == "" {
+			// Use the description of the referenced schema
+			description = r.resolve(property).str("description")
+		}
+		r.emit(openAPITableRow("`"+name+"`", r.schemaType(property), openAPIYesNo(required[name]), description))
+	}
+	r.emit("")
+}
+
+// resolve returns the object a value refers to (if it is a local reference), or the value itself
+func (r *openAPIRenderer) resolve(value any) *openAPIObject {
+	object, _ := value.(*openAPIObject)
+	for i := 0; i < 8 && object != nil; i++ {
+		ref := object.str("$ref")
+		if !strings.HasPrefix(ref, "#/") {
+			return object
+		}
+		var current any = r.spec
+		for _, part := range strings.Split(ref[2:], "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			parent, _ := current.(*openAPIObject)
+			current = parent.get(part)
+		}
+		resolved, _ := current.(*openAPIObject)
+		if resolved == nil {
+			return object
+		}
+		object = resolved
+	}
+
+	return object
+}
+
+// schemaType returns a short description of the type of a schema (e.g. array of `Pet`)
+func (r *openAPIRenderer) schemaType(value any) string {
+	schema, _ := value.(*openAPIObject)
+	if schema == nil {
+		return ""
+	}
+
+	if ref := schema.str("$ref"); ref != "" {
+		return "`" + ref[strings.LastIndex(ref, "/")+1:] + "`"
+	}
+	for _, combinator := range []string{"allOf", "oneOf", "anyOf"} {
+		if parts := schema.list(combinator); len(parts) > 0 {
+			types := []string{}
+			for _, part := range parts {
+				if partType := r.schemaType(part); partType != "" {
+					types = append(types, partType)
+				}
+			}
+			label := map[string]string{"allOf": "all of", "oneOf": "one of", "anyOf": "any of"}[combinator]
+			return label + " " + strings.Join(types, ", ")
+		}
+	}
+
+	// In OpenAPI 3.1 type can be a list of types
+	types := []string{}
+	if list := schema.list("type"); len(list) > 0 {
+		for _, t := range list {
+			types = append(types, fmt.Sprint(t))
+		}
+	} else if t := schema.str("type"); t != "" {
+		types = append(types, t)
+	} else if schema.object("properties") != nil {
+		types = append(types, "object")
+	}
+	for i, t := range types {
+		switch {
+		case t == "array":
+			if items := r.schemaType(schema.get("items")); items != "" {
+				types[i] = "array of " + items
+			}
+		case t == "object" && schema.object("additionalProperties") != nil:
+			if values := r.schemaType(schema.get("additionalProperties")); values != "" {
+				types[i] = "map of " + values
+			}
+		}
+	}
+	description := strings.Join(types, " or ")
+	if format := schema.str("format"); format != "" {
+		description += " (" + format + ")"
+	}
+	if schema.bool("nullable") {
+		description += ", nullable"
+	}
+	if enum := schema.list("enum"); len(enum) > 0 {
+		values := make([]string, 0, len(enum))
+		for _, value := range enum {
+			values = append(values, "`"+fmt.Sprint(value)+"`")
+		}
+		description += ", one of " + strings.Join(values, ", ")
+	}
+
+	return strings.TrimPrefix(description, ", ")
+}
+
+func (o *openAPIObject) keysOrEmpty() []string {
+	if o == nil {
+		return nil
+	}
+	return o.keys
+}
+
+func openAPIExternalDocs(docs *openAPIObject) string {
+	text := docs.str("description")
+	if text == "" {
+		text = docs.str("url")
+	}
+	return "See [" + text + "](" + docs.str("url") + ")"
+}
+
+func openAPIYesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// openAPITableRow returns a markdown table row, escaping the cells so they stay on one line
+func openAPITableRow(cells ...string) string {
+	for i, cell := range cells {
+		cell = strings.Join(strings.Fields(cell), " ")
+		cells[i] = strings.ReplaceAll(cell, "|", "\\|")
+	}
+	return "| " + strings.Join(cells, " | ") + " |"
+}
+
+// escapeMarkdownHeadings escapes lines of text that would otherwise be read as headings, so that
+// descriptions written in markdown do not add sections
+func escapeMarkdownHeadings(text string) string {
+	lines := strings.Split(text, "\n")
+	inCodeBlock := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inCodeBlock = !inCodeBlock
+		}
+		if !inCodeBlock && strings.HasPrefix(line, "#") {
+			lines[i] = "\\" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
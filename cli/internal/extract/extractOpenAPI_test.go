@@ -0,0 +1,184 @@
+package extract
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const openAPIPetstore = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+  description: Manage the pets in the store.
+  x-purpose: Document the Petstore API
+tags:
+  - name: pets
+    description: Everything about your pets
+    x-purpose: Pet operations
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List all pets
+      tags: [pets]
+      parameters:
+        - $ref: "#/components/parameters/Limit"
+      responses:
+        "200":
+          description: A list of pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Pet"
+    post:
+      operationId: createPet
+      tags: [pets, admin]
+      x-purpose: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Pet"
+      responses:
+        "201":
+          description: Created
+  /health:
+    get:
+      responses:
+        "200":
+          description: OK
+components:
+  parameters:
+    Limit:
+      name: limit
+      in: query
+      description: How many pets to return
+      schema:
+        type: integer
+        format: int32
+  schemas:
+    Pet:
+      description: A pet in the store
+      required: [name]
+      properties:
+        name:
+          type: string
+          description: The name of the pet
+        status:
+          type: string
+          enum: [available, sold]
+`
+
+const openAPIPetstoreJSON = `{
+  "openapi": "3.1.0",
+  "info": {"title": "Petstore", "version": "1.0.0", "x-purpose": "Document the Petstore API"},
+  "paths": {
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": ["string", "null"]}}],
+      "get": {"operationId": "getPet", "tags": ["pets"], "responses": {"404": {"description": "Not found"}}}
+    }
+  }
+}`
+
+// openAPISectionTest is a section expected in a rendered spec, along with its tags
+type openAPISectionTest struct {
+	id       string
+	purpose  string
+	contents []string
+	tags     []string
+}
+
+func TestConvertOpenAPIToMarkdown(t *testing.T) {
+	var documentTests = []struct {
+		name     string
+		document string
+		purpose  string
+		tests    []openAPISectionTest
+	}{
+		{"YAML", openAPIPetstore, "Document the Petstore API", []openAPISectionTest{
+			{"pets", "Pet operations", []string{"Everything about your pets"}, []string{"tag=pets"}},
+			{"pets/listPets", "", []string{"`GET /pets`", "List all pets", "| `limit` | query | integer (int32) | no | How many pets to return |", "- `200`: A list of pets", "  - `application/json`: array of `Pet`"}, []string{"operationId=listPets", "tag=pets"}},
+			{"pets/createPet", "Create a pet", []string{"`POST /pets`", "**Request Body** (required)", "`application/json`: `Pet`", "| `name` | string | yes | The name of the pet |", "| `status` | string, one of `available`, `sold` | no |  |"}, []string{"operationId=createPet", "tag=pets", "tag=admin"}},
+			{"GET _health", "", []string{"`GET /health`", "- `200`: OK"}, nil},
+			{"Schemas", "", nil, nil},
+			{"Schemas/Pet", "", []string{"Type: object", "A pet in the store", "| `name` | string | yes | The name of the pet |"}, nil},
+		}},
+		{"JSON", openAPIPetstoreJSON, "Document the Petstore API", []openAPISectionTest{
+			{"pets", "", nil, []string{"tag=pets"}},
+			{"pets/getPet", "", []string{"`GET /pets/{petId}`", "| `petId` | path | string or null | yes |  |", "- `404`: Not found"}, []string{"operationId=getPet", "tag=pets"}},
+		}},
+	}
+
+	for _, documentTest := range documentTests {
+		markdown, purpose, sectionTags, err := convertOpenAPIToMarkdown("petstore", []byte(documentTest.document), "purpose")
+		if err != nil {
+			t.Errorf("%s - expected no error, got: %v", documentTest.name, err)
+			continue
+		}
+		if purpose != documentTest.purpose {
+			t.Errorf("%s - expected purpose %q, got %q", documentTest.name, documentTest.purpose, purpose)
+		}
+
+		root := getMarkdownSections(strings.Split(markdown, "\n"))
+		extractMarkdownSectionPurposes(root, "purpose")
+		sectionMap := map[string]*section{}
+		var walk func(s *section)
+		walk = func(s *section) {
+			for _, child := range s.Children {
+				sectionMap[child.FullName] = child
+				walk(child)
+			}
+		}
+		walk(root)
+		if len(sectionMap) != len(documentTest.tests) {
+			t.Errorf("%s - expected %d sections, got %d", documentTest.name, len(documentTest.tests), len(sectionMap))
+		}
+
+		for i, test := range documentTest.tests {
+			section, ok := sectionMap[test.id]
+			if !ok {
+				t.Errorf("%s test %d - expected section %s, got none", documentTest.name, i, test.id)
+				continue
+			}
+			if section.Purpose != test.purpose {
+				t.Errorf("%s test %d - expected purpose %q, got %q", documentTest.name, i, test.purpose, section.Purpose)
+			}
+			for _, content := range test.contents {
+				if !strings.Contains(section.Content, content) {
+					t.Errorf("%s test %d - expected content to contain %q, got:\n%s", documentTest.name, i, content, section.Content)
+				}
+			}
+
+			// Operation IDs and tags become section tags
+			tags := []string{}
+			for _, tag := range sectionTags[test.id] {
+				tags = append(tags, tag.key+"="+tag.value)
+			}
+			if !slices.Equal(tags, test.tags) {
+				t.Errorf("%s test %d - expected tags %v, got %v", documentTest.name, i, test.tags, tags)
+			}
+		}
+	}
+}
+
+func TestConvertOpenAPIToMarkdown_NotASpec(t *testing.T) {
+	var tests = []struct {
+		name     string
+		document string
+	}{
+		{"Swagger", "swagger: \"2.0\"\n"},
+		{"Scalar", "hello\n"},
+		{"Empty", ""},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := convertOpenAPIToMarkdown("config.yaml", []byte(test.document), "purpose")
+		if err == nil || !strings.Contains(err.Error(), "could not find an OpenAPI 3 spec in config.yaml") {
+			t.Errorf("%s - expected an error for a document that is not an OpenAPI 3 spec, got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,1255 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"io"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+func extractPdf(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Convert pdf to markdown
+	markdown, purpose, err := convertPdfToMarkdown(rawData, purposeKey)
+	if err != nil {
+		slog.Debug("extract.extractPdf could not convert pdf to markdown", "error", err)
+		return fmt.Errorf("could not extract text from %s: %w", id, err)
+	}
+	if markdown == "" {
+		slog.Warn("Could not find any text in pdf (it may only contain images)", "document", id)
+	}
+
+	// Insert document
+	err = db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypePdf.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractPdf could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, false, "", db)
+	if err != nil {
+		slog.Debug("extract.extractPdf could not extract sections", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// convertPdfToMarkdown converts the text of a PDF to markdown. Headings are identified using the
+// document outline (bookmarks) if it has one, and otherwise by font size (lines set larger than
+// the body text are headings, with larger sizes being higher level headings). The purpose of the
+// document is read from the entry of the document information dictionary named purposeKey (if any).
+//
+// Note that this is a best-effort text extraction: encrypted PDFs are not supported, and text
+// drawn as images (e.g. scanned documents) or using fonts without a usable encoding is skipped.
+func convertPdfToMarkdown(rawData []byte, purposeKey string) (markdown string, purpose string, err error) {
+	pdf, err := parsePdf(rawData)
+	if err != nil {
+		return
+	}
+
+	if purposeKey != "" {
+		if info, ok := pdf.resolve(pdf.trailer["Info"]).(pdfDict); ok {
+			for key, value := range info {
+				if strings.EqualFold(key, purposeKey) {
+					if text, ok := pdf.resolve(value).(pdfString); ok {
+						purpose = strings.TrimSpace(decodePdfTextString(text))
+					}
+				}
+			}
+		}
+	}
+
+	lines := []pdfLine{}
+	for i, page := range pdf.pages() {
+		lines = append(lines, pdf.pageLines(page, i)...)
+	}
+	markdown = formatPdfLines(lines, pdf.outline())
+
+	return
+}
+
+// PDF objects are represented using nil, bool, float64, pdfName, pdfString, pdfKeyword, []any,
+// pdfDict, pdfRef, and *pdfStream
+type pdfName string
+type pdfString string
+type pdfKeyword string
+type pdfDict map[string]any
+type pdfRef struct {
+	num int
+	gen int
+}
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+}
+
+type pdfDocument struct {
+	objects map[int]any
+	trailer pdfDict
+}
+
+var pdfObjectRegex = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+var pdfTrailerRegex = regexp.MustCompile(`trailer\s*<<`)
+var pdfNameEscapeRegex = regexp.MustCompile(`#[0-9A-Fa-f]{2}`)
+var pdfInlineImageEndRegex = regexp.MustCompile(`\sEI(\s|$)`)
+
+// parsePdf parses the objects of a PDF. Rather than relying on the cross-reference table (which is
+// often damaged) the file is scanned for objects, with later objects replacing earlier ones.
+func parsePdf(rawData []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(rawData, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, errors.New("not a pdf")
+	}
+
+	pdf := &pdfDocument{objects: map[int]any{}, trailer: pdfDict{}}
+	objectStreams := []*pdfStream{}
+	pos := 0
+	for {
+		match := pdfObjectRegex.FindSubmatchIndex(rawData[pos:])
+		if match == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(rawData[pos+match[2] : pos+match[3]]))
+		lexer := &pdfLexer{data: rawData, pos: pos + match[1]}
+		pos += match[1]
+		object, err := lexer.readObject()
+		if err != nil {
+			continue
+		}
+		pos = lexer.pos
+		pdf.objects[num] = object
+
+		if stream, ok := object.(*pdfStream); ok {
+			switch stream.dict["Type"] {
+			case pdfName("ObjStm"):
+				objectStreams = append(objectStreams, stream)
+			case pdfName("XRef"):
+				// Cross-reference streams replace the trailer in newer PDFs
+				for key, value := range stream.dict {
+					pdf.trailer[key] = value
+				}
+			}
+		}
+	}
+
+	// Traditional trailers (the last trailer takes precedence)
+	for _, index := range pdfTrailerRegex.FindAllIndex(rawData, -1) {
+		lexer := &pdfLexer{data: rawData, pos: index[0] + len("trailer")}
+		if trailer, err := lexer.readObject(); err == nil {
+			if dict, ok := trailer.(pdfDict); ok {
+				for key, value := range dict {
+					pdf.trailer[key] = value
+				}
+			}
+		}
+	}
+
+	// Objects in object streams
+	for _, stream := range objectStreams {
+		data, err := pdf.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		count := pdfInt(pdf.resolve(stream.dict["N"]))
+		first := pdfInt(pdf.resolve(stream.dict["First"]))
+		header := &pdfLexer{data: data}
+		for range count {
+			num, err := header.readObject()
+			if err != nil {
+				break
+			}
+			offset, err := header.readObject()
+			if err != nil {
+				break
+			}
+			n, ok := num.(float64)
+			if !ok {
+				break
+			}
+			if _, exists := pdf.objects[int(n)]; exists {
+				continue
+			}
+			lexer := &pdfLexer{data: data, pos: first + pdfInt(offset)}
+			if object, err := lexer.readObject(); err == nil {
+				pdf.objects[int(n)] = object
+			}
+		}
+	}
+
+	if _, ok := pdf.trailer["Encrypt"]; ok {
+		return nil, errors.New("encrypted pdfs are not supported")
+	}
+	if _, ok := pdf.resolve(pdf.trailer["Root"]).(pdfDict); !ok {
+		// Fall back to finding the catalog directly
+		for num, object := range pdf.objects {
+			if dict, ok := object.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				pdf.trailer["Root"] = pdfRef{num: num}
+				break
+			}
+		}
+	}
+
+	return pdf, nil
+}
+
+// resolve follows indirect references
+func (pdf *pdfDocument) resolve(object any) any {
+	for range 32 {
+		ref, ok := object.(pdfRef)
+		if !ok {
+			return object
+		}
+		object = pdf.objects[ref.num]
+	}
+	return nil
+}
+
+func (pdf *pdfDocument) dict(object any) pdfDict {
+	switch o := pdf.resolve(object).(type) {
+	case pdfDict:
+		return o
+	case *pdfStream:
+		return o.dict
+	}
+	return pdfDict{}
+}
+
+func pdfInt(object any) int {
+	if number, ok := object.(float64); ok {
+		return int(number)
+	}
+	return 0
+}
+
+// decodeStream returns the decoded data of a stream (FlateDecode, ASCII85Decode, and
+// ASCIIHexDecode filters are supported)
+func (pdf *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	filters := []any{}
+	switch filter := pdf.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = append(filters, filter)
+	case []any:
+		filters = filter
+	}
+
+	data := stream.raw
+	for _, filter := range filters {
+		switch pdf.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := io.ReadAll(reader)
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			encoded := bytes.TrimSuffix(bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))), []byte("~>"))
+			decoded := make([]byte, 4*len(encoded)+4)
+			n, _, err := ascii85.Decode(decoded, encoded, true)
+			if err != nil {
+				return nil, err
+			}
+			data = decoded[:n]
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data = decodePdfHex(data)
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+	}
+
+	return data, nil
+}
+
+// pages returns the pages of the document in order, with inherited resources resolved
+func (pdf *pdfDocument) pages() []pdfDict {
+	pages := []pdfDict{}
+	seen := map[any]struct{}{}
+	var walk func(node any, resources any)
+	walk = func(node any, resources any) {
+		if ref, ok := node.(pdfRef); ok {
+			if _, ok := seen[ref]; ok {
+				return
+			}
+			seen[ref] = struct{}{}
+		}
+		dict := pdf.dict(node)
+		if r, ok := dict["Resources"]; ok {
+			resources = r
+		}
+		if kids, ok := pdf.resolve(dict["Kids"]).([]any); ok {
+			for _, kid := range kids {
+				walk(kid, resources)
+			}
+			return
+		}
+		if _, ok := dict["Contents"]; ok {
+			page := pdfDict{"Contents": dict["Contents"], "Resources": resources}
+			pages = append(pages, page)
+		}
+	}
+	walk(pdf.dict(pdf.trailer["Root"])["Pages"], nil)
+
+	return pages
+}
+
+// pdfOutlineEntry is an entry of the document outline (bookmarks)
+type pdfOutlineEntry struct {
+	title string
+	level int
+}
+
+// outline returns the entries of the document outline in order
+func (pdf *pdfDocument) outline() []pdfOutlineEntry {
+	entries := []pdfOutlineEntry{}
+	seen := map[any]struct{}{}
+	var walk func(item any, level int)
+	walk = func(item any, level int) {
+		for item != nil && len(entries) < 10000 {
+			if _, ok := seen[item]; ok {
+				return
+			}
+			seen[item] = struct{}{}
+			dict := pdf.dict(item)
+			if title, ok := pdf.resolve(dict["Title"]).(pdfString); ok {
+				entries = append(entries, pdfOutlineEntry{title: normalizePdfText(decodePdfTextString(title)), level: level})
+			}
+			if first, ok := dict["First"]; ok {
+				walk(first, level+1)
+			}
+			item = dict["Next"]
+		}
+	}
+	if outlines, ok := pdf.dict(pdf.trailer["Root"])["Outlines"]; ok {
+		walk(pdf.dict(outlines)["First"], 1)
+	}
+
+	return entries
+}
+
+// decodePdfTextString decodes a text string (UTF-16BE with a byte order mark, otherwise treated
+// as PDFDocEncoding, which is close to WinAnsiEncoding)
+func decodePdfTextString(text pdfString) string {
+	if strings.HasPrefix(string(text), "\xfe\xff") {
+		return decodeUTF16BE([]byte(text[2:]))
+	}
+	if strings.HasPrefix(string(text), "\xef\xbb\xbf") {
+		return string(text[3:])
+	}
+	var builder strings.Builder
+	for i := 0; i < len(text); i++ {
+		builder.WriteRune(decodeWinAnsi(text[i]))
+	}
+	return builder.String()
+}
+
+func decodeUTF16BE(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// winAnsiHigh maps the characters of WinAnsiEncoding that differ from Latin-1
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8a: 'Š', 0x8b: '‹', 0x8c: 'Œ', 0x8e: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+	0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9a: 'š', 0x9b: '›',
+	0x9c: 'œ', 0x9e: 'ž', 0x9f: 'Ÿ',
+}
+
+func decodeWinAnsi(b byte) rune {
+	if r, ok := winAnsiHigh[b]; ok {
+		return r
+	}
+	return rune(b)
+}
+
+func decodePdfHex(data []byte) []byte {
+	digits := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == '>' {
+			break
+		}
+		if (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F') {
+			digits = append(digits, b)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded := make([]byte, len(digits)/2)
+	hex.Decode(decoded, digits)
+	return decoded
+}
+
+// pdfLexer reads objects (and content stream operators) from PDF data
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errPdfEOF = errors.New("unexpected end of pdf data")
+
+func isPdfSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isPdfDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+func (lexer *pdfLexer) skipSpace() {
+	for lexer.pos < len(lexer.data) {
+		b := lexer.data[lexer.pos]
+		if b == '%' {
+			for lexer.pos < len(lexer.data) && lexer.data[lexer.pos] != '\n' && lexer.data[lexer.pos] != '\r' {
+				lexer.pos++
+			}
+			continue
+		}
+		if !isPdfSpace(b) {
+			return
+		}
+		lexer.pos++
+	}
+}
+
+// readRegular reads a run of regular (non-space, non-delimiter) characters
+func (lexer *pdfLexer) readRegular() string {
+	start := lexer.pos
+	for lexer.pos < len(lexer.data) && !isPdfSpace(lexer.data[lexer.pos]) && !isPdfDelimiter(lexer.data[lexer.pos]) {
+		lexer.pos++
+	}
+	return string(lexer.data[start:lexer.pos])
+}
+
+func (lexer *pdfLexer) readObject() (any, error) {
+	lexer.skipSpace()
+	if lexer.pos >= len(lexer.data) {
+		return nil, errPdfEOF
+	}
+
+	switch b := lexer.data[lexer.pos]; {
+	case b == '/':
+		lexer.pos++
+		name := lexer.readRegular()
+		if strings.Contains(name, "#") {
+			name = pdfNameEscapeRegex.ReplaceAllStringFunc(name, func(code string) string {
+				return string(decodePdfHex([]byte(code[1:])))
+			})
+		}
+		return pdfName(name), nil
+	case b == '(':
+		return lexer.readLiteralString()
+	case b == '<' && lexer.pos+1 < len(lexer.data) && lexer.data[lexer.pos+1] == '<':
+		lexer.pos += 2
+		dict := pdfDict{}
+		for {
+			lexer.skipSpace()
+			if lexer.pos >= len(lexer.data) {
+				return nil, errPdfEOF
+			}
+			if bytes.HasPrefix(lexer.data[lexer.pos:], []byte(">>")) {
+				lexer.pos += 2
+				break
+			}
+			key, err := lexer.readObject()
+			if err != nil {
+				return nil, err
+			}
+			value, err := lexer.readObject()
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := key.(pdfName); ok {
+				dict[string(name)] = value
+			}
+		}
+		return lexer.readStream(dict), nil
+	case b == '<':
+		end := bytes.IndexByte(lexer.data[lexer.pos:], '>')
+		if end < 0 {
+			return nil, errPdfEOF
+		}
+		text := decodePdfHex(lexer.data[lexer.pos+1 : lexer.pos+end])
+		lexer.pos += end + 1
+		return pdfString(text), nil
+	case b == '[':
+		lexer.pos++
+		array := []any{}
+		for {
+			lexer.skipSpace()
+			if lexer.pos >= len(lexer.data) {
+				return nil, errPdfEOF
+			}
+			if lexer.data[lexer.pos] == ']' {
+				lexer.pos++
+				break
+			}
+			value, err := lexer.readObject()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	case b == ']' || b == '>' || b == ')' || b == '{' || b == '}':
+		lexer.pos++
+		return pdfKeyword(string(b)), nil
+	}
+
+	token := lexer.readRegular()
+	if token == "" {
+		lexer.pos++
+		return nil, fmt.Errorf("unexpected character %q", lexer.data[lexer.pos-1])
+	}
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return pdfKeyword(token), nil
+	}
+
+	// An integer may be the start of an indirect reference (num gen R)
+	if !strings.ContainsAny(token, ".-+") {
+		save := lexer.pos
+		lexer.skipSpace()
+		gen := lexer.readRegular()
+		lexer.skipSpace()
+		if _, err := strconv.Atoi(gen); err == nil && gen != "" && lexer.readRegular() == "R" {
+			g, _ := strconv.Atoi(gen)
+			return pdfRef{num: int(number), gen: g}, nil
+		}
+		lexer.pos = save
+	}
+
+	return number, nil
+}
+
+func (lexer *pdfLexer) readLiteralString() (any, error) {
+	lexer.pos++
+	var builder bytes.Buffer
+	depth := 1
+	for lexer.pos < len(lexer.data) {
+		b := lexer.data[lexer.pos]
+		lexer.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(builder.String()), nil
+			}
+		case '\\':
+			if lexer.pos >= len(lexer.data) {
+				return nil, errPdfEOF
+			}
+			escaped := lexer.data[lexer.pos]
+			lexer.pos++
+			switch escaped {
+			case 'n':
+				builder.WriteByte('\n')
+			case 'r':
+				builder.WriteByte('\r')
+			case 't':
+				builder.WriteByte('\t')
+			case 'b':
+				builder.WriteByte('\b')
+			case 'f':
+				builder.WriteByte('\f')
+			case '\r':
+				if lexer.pos < len(lexer.data) && lexer.data[lexer.pos] == '\n' {
+					lexer.pos++
+				}
+			case '\n':
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					code := int(escaped - '0')
+					for i := 0; i < 2 && lexer.pos < len(lexer.data) && lexer.data[lexer.pos] >= '0' && lexer.data[lexer.pos] <= '7'; i++ {
+						code = code*8 + int(lexer.data[lexer.pos]-'0')
+						lexer.pos++
+					}
+					builder.WriteByte(byte(code))
+				} else {
+					builder.WriteByte(escaped)
+				}
+			}
+			continue
+		}
+		builder.WriteByte(b)
+	}
+	return nil, errPdfEOF
+}
+
+// readStream reads the data of a stream following a dictionary (if any)
+func (lexer *pdfLexer) readStream(dict pdfDict) any {
+	save := lexer.pos
+	lexer.skipSpace()
+	if !bytes.HasPrefix(lexer.data[lexer.pos:], []byte("stream")) {
+		lexer.pos = save
+		return dict
+	}
+	start := lexer.pos + len("stream")
+	if bytes.HasPrefix(lexer.data[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(lexer.data) && (lexer.data[start] == '\n' || lexer.data[start] == '\r') {
+		start++
+	}
+
+	// Use the length if it is direct and correct, otherwise search for the end of the stream
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(lexer.data) && bytes.HasPrefix(bytes.TrimLeft(lexer.data[end:], "\r\n "), []byte("endstream")) {
+			lexer.pos = end
+			return &pdfStream{dict: dict, raw: lexer.data[start:end]}
+		}
+	}
+	end := bytes.Index(lexer.data[start:], []byte("endstream"))
+	if end < 0 {
+		lexer.pos = len(lexer.data)
+		return &pdfStream{dict: dict, raw: lexer.data[start:]}
+	}
+	lexer.pos = start + end + len("endstream")
+	raw := lexer.data[start : start+end]
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	return &pdfStream{dict: dict, raw: raw}
+}
+
+// pdfFont decodes the text shown with a font
+type pdfFont struct {
+	codeLength   int
+	toUnicode    map[string]string
+	differences  map[byte]string
+	widths       map[int]float64
+	defaultWidth float64
+}
+
+func (pdf *pdfDocument) loadFont(object any) *pdfFont {
+	dict := pdf.dict(object)
+	font := &pdfFont{codeLength: 1, widths: map[int]float64{}, defaultWidth: 500}
+
+	descendant := dict
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLength = 2
+		font.defaultWidth = 1000
+		if descendants, ok := pdf.resolve(dict["DescendantFonts"]).([]any); ok && len(descendants) > 0 {
+			descendant = pdf.dict(descendants[0])
+		}
+		if dw, ok := pdf.resolve(descendant["DW"]).(float64); ok {
+			font.defaultWidth = dw
+		}
+		// W is made of "c [w1 w2 ...]" and "cFirst cLast w" entries
+		if w, ok := pdf.resolve(descendant["W"]).([]any); ok {
+			for i := 0; i < len(w); {
+				first, ok := pdf.resolve(w[i]).(float64)
+				if !ok || i+1 >= len(w) {
+					break
+				}
+				if widths, ok := pdf.resolve(w[i+1]).([]any); ok {
+					for j, width := range widths {
+						if width, ok := pdf.resolve(width).(float64); ok {
+							font.widths[int(first)+j] = width
+						}
+					}
+					i += 2
+					continue
+				}
+				last, _ := pdf.resolve(w[i+1]).(float64)
+				if i+2 >= len(w) {
+					break
+				}
+				width, _ := pdf.resolve(w[i+2]).(float64)
+				for c := int(first); c <= int(last) && c-int(first) < 65536; c++ {
+					font.widths[c] = width
+				}
+				i += 3
+			}
+		}
+	} else {
+		firstChar := pdfInt(pdf.resolve(dict["FirstChar"]))
+		if widths, ok := pdf.resolve(dict["Widths"]).([]any); ok {
+			for i, width := range widths {
+				if width, ok := pdf.resolve(width).(float64); ok {
+					font.widths[firstChar+i] = width
+				}
+			}
+		}
+		if encoding, ok := pdf.resolve(dict["Encoding"]).(pdfDict); ok {
+			if differences, ok := pdf.resolve(encoding["Differences"]).([]any); ok {
+				font.differences = map[byte]string{}
+				code := 0
+				for _, difference := range differences {
+					switch d := pdf.resolve(difference).(type) {
+					case float64:
+						code = int(d)
+					case pdfName:
+						if code >= 0 && code < 256 {
+							font.differences[byte(code)] = glyphNameToText(string(d))
+						}
+						code++
+					}
+				}
+			}
+		}
+	}
+
+	if stream, ok := pdf.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := pdf.decodeStream(stream); err == nil {
+			font.toUnicode = parseToUnicode(data)
+		}
+	}
+
+	return font
+}
+
+// parseToUnicode parses the bfchar and bfrange mappings of a ToUnicode CMap
+func parseToUnicode(data []byte) map[string]string {
+	mappings := map[string]string{}
+	lexer := &pdfLexer{data: data}
+	operands := []any{}
+	mode := ""
+	for {
+		object, err := lexer.readObject()
+		if err == errPdfEOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		keyword, ok := object.(pdfKeyword)
+		if !ok {
+			operands = append(operands, object)
+			continue
+		}
+		switch keyword {
+		case "beginbfchar", "beginbfrange":
+			mode = string(keyword)
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					mappings[string(src)] = decodeUTF16BE([]byte(dst))
+				}
+			}
+			mode = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 {
+					continue
+				}
+				start, end := pdfCode(lo), pdfCode(hi)
+				for code := start; code <= end && code-start < 65536; code++ {
+					src := pdfCodeString(code, len(lo))
+					switch dst := operands[i+2].(type) {
+					case pdfString:
+						// Increment the last character of the destination
+						runes := []rune(decodeUTF16BE([]byte(dst)))
+						if len(runes) > 0 {
+							runes[len(runes)-1] += rune(code - start)
+						}
+						mappings[src] = string(runes)
+					case []any:
+						if code-start < len(dst) {
+							if text, ok := dst[code-start].(pdfString); ok {
+								mappings[src] = decodeUTF16BE([]byte(text))
+							}
+						}
+					}
+				}
+			}
+			mode = ""
+		}
+		if mode == "" || keyword == "beginbfchar" || keyword == "beginbfrange" {
+			operands = operands[:0]
+		}
+	}
+	return mappings
+}
+
+func pdfCode(text pdfString) int {
+	code := 0
+	for i := 0; i < len(text); i++ {
+		code = code<<8 | int(text[i])
+	}
+	return code
+}
+
+func pdfCodeString(code int, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(code)
+		code >>= 8
+	}
+	return string(b)
+}
+
+// glyphNames maps common glyph names to text (single letters and uniXXXX names are handled
+// separately)
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "quoteleft": "‘", "parenleft": "(",
+	"parenright": ")", "asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".",
+	"slash": "/", "zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5",
+	"six": "6", "seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";",
+	"less": "<", "equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "underscore": "_", "braceleft": "{", "bar": "|",
+	"braceright": "}", "bullet": "•", "endash": "–", "emdash": "—", "quotedblleft": "“",
+	"quotedblright": "”", "ellipsis": "…", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi",
+	"ffl": "ffl", "minus": "-", "copyright": "©", "registered": "®", "trademark": "™",
+}
+
+func glyphNameToText(name string) string {
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if len(name) == 1 {
+		return name
+	}
+	if strings.HasPrefix(name, "uni") && len(name) == 7 {
+		if code, err := strconv.ParseUint(name[3:], 16, 32); err == nil {
+			return string(rune(code))
+		}
+	}
+	return ""
+}
+
+// decode returns the text of a string shown with the font, along with the width of each code
+// (in thousandths of a unit of text space)
+func (font *pdfFont) decode(text pdfString) (string, []float64) {
+	var builder strings.Builder
+	widths := []float64{}
+	for i := 0; i+font.codeLength <= len(text); i += font.codeLength {
+		src := string(text[i : i+font.codeLength])
+		code := pdfCode(pdfString(src))
+		width, ok := font.widths[code]
+		if !ok {
+			width = font.defaultWidth
+		}
+		widths = append(widths, width)
+
+		if mapped, ok := font.toUnicode[src]; ok {
+			builder.WriteString(mapped)
+		} else if font.codeLength == 1 {
+			if mapped, ok := font.differences[src[0]]; ok {
+				builder.WriteString(mapped)
+			} else {
+				builder.WriteRune(decodeWinAnsi(src[0]))
+			}
+		}
+	}
+	return builder.String(), widths
+}
+
+// pdfMatrix is an affine transformation [a b c d e f]
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// pdfLine is a line of text on a page
+type pdfLine struct {
+	text string
+	size float64
+	x    float64
+	y    float64
+	endX float64
+	page int
+}
+
+// pdfTextState is the state of the content stream interpreter
+type pdfTextState struct {
+	ctm         pdfMatrix
+	tm          pdfMatrix
+	lm          pdfMatrix
+	font        *pdfFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	leading     float64
+}
+
+// pageLines returns the lines of text on a page
+func (pdf *pdfDocument) pageLines(page pdfDict, pageIndex int) []pdfLine {
+	content := []byte{}
+	streams := []any{page["Contents"]}
+	if array, ok := pdf.resolve(page["Contents"]).([]any); ok {
+		streams = array
+	}
+	for _, object := range streams {
+		if stream, ok := pdf.resolve(object).(*pdfStream); ok {
+			if data, err := pdf.decodeStream(stream); err == nil {
+				content = append(append(content, data...), '\n')
+			}
+		}
+	}
+
+	lines := []pdfLine{}
+	pdf.interpret(content, pdf.dict(page["Resources"]), pdfIdentity, pageIndex, &lines, 0)
+	return lines
+}
+
+// interpret runs a content stream, appending the text it shows to lines
+func (pdf *pdfDocument) interpret(content []byte, resources pdfDict, ctm pdfMatrix, pageIndex int, lines *[]pdfLine, depth int) {
+	fonts := map[string]*pdfFont{}
+	fontDicts := pdf.dict(resources["Font"])
+	state := pdfTextState{ctm: ctm, tm: pdfIdentity, lm: pdfIdentity}
+	stack := []pdfTextState{}
+
+	show := func(text pdfString) {
+		if state.font == nil {
+			return
+		}
+		decoded, widths := state.font.decode(text)
+		trm := state.tm.multiply(state.ctm)
+		size := state.fontSize * math.Hypot(trm[2], trm[3])
+		x, y := trm[4], trm[5]
+
+		advance := 0.0
+		for i, width := range widths {
+			advance += width/1000*state.fontSize + state.charSpacing
+			if state.font.codeLength == 1 && i < len(text) && text[i] == ' ' {
+				advance += state.wordSpacing
+			}
+		}
+		state.tm = pdfMatrix{1, 0, 0, 1, advance, 0}.multiply(state.tm)
+		endX := state.tm.multiply(state.ctm)[4]
+		addPdfText(lines, decoded, size, x, y, endX, pageIndex)
+	}
+	moveLine := func(tx, ty float64) {
+		state.lm = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(state.lm)
+		state.tm = state.lm
+	}
+
+	lexer := &pdfLexer{data: content}
+	operands := []any{}
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if n, ok := operands[i].(float64); ok {
+				return n
+			}
+		}
+		return 0
+	}
+	for {
+		object, err := lexer.readObject()
+		if err == errPdfEOF {
+			break
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+		keyword, ok := object.(pdfKeyword)
+		if !ok {
+			operands = append(operands, object)
+			continue
+		}
+
+		switch keyword {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				// The text matrices are not part of the graphics state
+				tm, lm := state.tm, state.lm
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				state.tm, state.lm = tm, lm
+			}
+		case "cm":
+			if len(operands) == 6 {
+				state.ctm = pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}.multiply(state.ctm)
+			}
+		case "BT":
+			state.tm, state.lm = pdfIdentity, pdfIdentity
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					if _, loaded := fonts[string(name)]; !loaded {
+						fonts[string(name)] = pdf.loadFont(fontDicts[string(name)])
+					}
+					state.font = fonts[string(name)]
+				}
+				state.fontSize = number(1)
+			}
+		case "Tc":
+			state.charSpacing = number(0)
+		case "Tw":
+			state.wordSpacing = number(0)
+		case "TL":
+			state.leading = number(0)
+		case "Td":
+			moveLine(number(0), number(1))
+		case "TD":
+			state.leading = -number(1)
+			moveLine(number(0), number(1))
+		case "Tm":
+			if len(operands) == 6 {
+				state.lm = pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+				state.tm = state.lm
+			}
+		case "T*":
+			moveLine(0, -state.leading)
+		case "Tj":
+			if len(operands) > 0 {
+				if text, ok := operands[len(operands)-1].(pdfString); ok {
+					show(text)
+				}
+			}
+		case "'", "\"":
+			if keyword == "\"" && len(operands) == 3 {
+				state.wordSpacing, state.charSpacing = number(0), number(1)
+			}
+			moveLine(0, -state.leading)
+			if len(operands) > 0 {
+				if text, ok := operands[len(operands)-1].(pdfString); ok {
+					show(text)
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if array, ok := operands[len(operands)-1].([]any); ok {
+					for _, element := range array {
+						switch e := element.(type) {
+						case pdfString:
+							show(e)
+						case float64:
+							state.tm = pdfMatrix{1, 0, 0, 1, -e / 1000 * state.fontSize, 0}.multiply(state.tm)
+						}
+					}
+				}
+			}
+		case "Do":
+			// Text can be drawn by form XObjects
+			if len(operands) > 0 && depth < 8 {
+				if name, ok := operands[0].(pdfName); ok {
+					if stream, ok := pdf.resolve(pdf.dict(resources["XObject"])[string(name)]).(*pdfStream); ok && stream.dict["Subtype"] == pdfName("Form") {
+						if data, err := pdf.decodeStream(stream); err == nil {
+							matrix := pdfIdentity
+							if m, ok := pdf.resolve(stream.dict["Matrix"]).([]any); ok && len(m) == 6 {
+								for i := range m {
+									matrix[i], _ = pdf.resolve(m[i]).(float64)
+								}
+							}
+							formResources := resources
+							if r, ok := stream.dict["Resources"]; ok {
+								formResources = pdf.dict(r)
+							}
+							pdf.interpret(data, formResources, matrix.multiply(state.ctm), pageIndex, lines, depth+1)
+						}
+					}
+				}
+			}
+		case "ID":
+			// Skip the data of inline images
+			end := pdfInlineImageEndRegex.FindIndex(lexer.data[lexer.pos:])
+			if end == nil {
+				lexer.pos = len(lexer.data)
+			} else {
+				lexer.pos += end[1]
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// addPdfText adds text to the current line, or starts a new line if the text is not on the same
+// line as the previous text
+func addPdfText(lines *[]pdfLine, text string, size float64, x float64, y float64, endX float64, pageIndex int) {
+	if text == "" {
+		return
+	}
+	if len(*lines) > 0 {
+		last := &(*lines)[len(*lines)-1]
+		tolerance := math.Max(math.Min(size, last.size)*0.5, 1)
+		if last.page == pageIndex && math.Abs(last.y-y) < tolerance {
+			// Add a space if there is a gap between the text and the end of the line
+			if x-last.endX > math.Min(size, last.size)*0.15 && !strings.HasSuffix(last.text, " ") && !strings.HasPrefix(text, " ") {
+				last.text += " "
+			}
+			last.text += text
+			last.size = math.Max(last.size, size)
+			last.endX = endX
+			return
+		}
+	}
+	*lines = append(*lines, pdfLine{text: text, size: size, x: x, y: y, endX: endX, page: pageIndex})
+}
+
+var pdfSpaceRegex = regexp.MustCompile(`\s+`)
+
+func normalizePdfText(text string) string {
+	return strings.TrimSpace(pdfSpaceRegex.ReplaceAllString(text, " "))
+}
+
+var pdfBulletRegex = regexp.MustCompile(`^[•◦▪‣∙·]\s*`)
+
+// formatPdfLines formats lines of text as markdown, using the outline (if any of its entries
+// match a line) or font sizes to identify headings
+func formatPdfLines(lines []pdfLine, outline []pdfOutlineEntry) string {
+	for i := range lines {
+		lines[i].text = normalizePdfText(lines[i].text)
+	}
+
+	levels := make([]int, len(lines))
+	matched := false
+	next := 0
+	for i, line := range lines {
+		for j := next; j < len(outline); j++ {
+			if line.text != "" && strings.EqualFold(line.text, outline[j].title) {
+				levels[i] = min(outline[j].level, 6)
+				next = j + 1
+				matched = true
+				break
+			}
+		}
+	}
+	if !matched {
+		levels = pdfFontSizeLevels(lines)
+	}
+
+	out := []string{}
+	paragraph := ""
+	listItem := false
+	flush := func() {
+		if paragraph != "" {
+			if listItem {
+				out = append(out, "- "+paragraph)
+			} else {
+				out = append(out, "", escapeMarkdownHeadings(paragraph), "")
+			}
+		}
+		paragraph = ""
+		listItem = false
+	}
+	for i, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		if levels[i] > 0 {
+			// Merge headings that wrap onto multiple lines
+			if i > 0 && levels[i-1] == levels[i] && lines[i-1].page == line.page && paragraph == "" && len(out) > 0 {
+				out[len(out)-1] += " " + line.text
+				continue
+			}
+			flush()
+			out = append(out, "", strings.Repeat("#", levels[i])+" "+line.text)
+			continue
+		}
+
+		if bullet := pdfBulletRegex.FindString(line.text); bullet != "" {
+			flush()
+			paragraph = line.text[len(bullet):]
+			listItem = true
+			continue
+		}
+		if paragraph != "" {
+			previous := lines[i-1]
+			gap := previous.y - line.y
+			if previous.page != line.page || gap > line.size*1.5 || gap < 0 || math.Abs(previous.size-line.size) > 0.5 {
+				flush()
+			}
+		}
+		if paragraph == "" {
+			paragraph = line.text
+		} else if strings.HasSuffix(paragraph, "-") && len(paragraph) > 1 && unicode.IsLetter(rune(paragraph[len(paragraph)-2])) && unicode.IsLower([]rune(line.text)[0]) {
+			// Join words hyphenated across lines
+			paragraph = paragraph[:len(paragraph)-1] + line.text
+		} else {
+			paragraph += " " + line.text
+		}
+	}
+	flush()
+
+	return cleanMarkdown(out)
+}
+
+// pdfFontSizeLevels returns the heading level of each line based on font size: the most common
+// size (by number of characters) is the body text, and lines that are larger are headings, with
+// the largest size being level 1
+func pdfFontSizeLevels(lines []pdfLine) []int {
+	levels := make([]int, len(lines))
+	counts := map[float64]int{}
+	for _, line := range lines {
+		counts[math.Round(line.size*2)/2] += len(line.text)
+	}
+	body := 0.0
+	for size, count := range counts {
+		if count > counts[body] || (count == counts[body] && size < body) {
+			body = size
+		}
+	}
+
+	sizes := []float64{}
+	for size := range counts {
+		if size >= body*1.15 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+
+	for i, line := range lines {
+		size := math.Round(line.size*2) / 2
+		if size < body*1.15 || len(line.text) > 200 || !strings.ContainsFunc(line.text, unicode.IsLetter) {
+			continue
+		}
+		levels[i] = min(sort.Search(len(sizes), func(j int) bool { return sizes[j] <= size })+1, 6)
+	}
+
+	return levels
+}
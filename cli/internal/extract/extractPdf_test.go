@@ -0,0 +1,147 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildTestPdf builds a PDF from objects (numbered from 1, with object 1 being the catalog)
+func buildTestPdf(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := []int{}
+	for i, object := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+func testPdfStream(data string, compress bool) string {
+	if !compress {
+		return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(data), data)
+	}
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write([]byte(data))
+	writer.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.String())
+}
+
+// A PDF where headings can only be identified by font size
+var pdfFontSizes = buildTestPdf([]string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+	testPdfStream(`BT /F1 24 Tf 72 720 Td (Runbook) Tj ET
+BT /F1 16 Tf 72 680 Td (Restarting the service) Tj ET
+BT /F1 11 Tf 13 TL 72 650 Td (Stop the service before deploy-) Tj T* (ing a new version.) Tj ET
+BT /F1 11 Tf 72 610 Td (\225 Check the logs) Tj ET
+BT /F1 11 Tf 72 597 Td (\225 Check the #alerts) Tj ET
+q 2 0 0 2 0 0 cm BT /F1 8 Tf 36 280 Td (Rollback) Tj ET Q
+BT /F1 11 Tf 72 530 Td [(Use) -500 (git revert)] TJ ET`, false),
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+}, "")
+
+// A PDF with an outline, where headings are the same size as the body text
+var pdfOutline = buildTestPdf([]string{
+	"<< /Type /Catalog /Pages 2 0 R /Outlines 6 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+	"<< /Type /Page /Parent 2 0 R /Contents 5 0 R /Resources << /Font << /F1 10 0 R /F2 11 0 R >> >> >>",
+	"<< /Type /Page /Parent 2 0 R /Contents [13 0 R] /Resources << /Font << /F1 10 0 R >> /XObject << /X1 14 0 R >> >> >>",
+	testPdfStream(`BT /F1 12 Tf 72 720 Td (Overview) Tj 0 -20 Td (The API serves requests.) Tj ET
+BT /F2 12 Tf 72 660 Td <0001000200030004> Tj ET
+BT /F1 12 Tf 72 640 Td (Details) Tj ET`, true),
+	"<< /Type /Outlines /First 7 0 R /Last 8 0 R /Count 3 >>",
+	"<< /Title (Overview) /Parent 6 0 R /Next 8 0 R >>",
+	"<< /Title (Details) /Parent 6 0 R /Prev 7 0 R /First 9 0 R /Last 9 0 R >>",
+	"<< /Title <FEFF00530074006500700073> /Parent 8 0 R >>",
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /Encoding /Identity-H /ToUnicode 12 0 R >>",
+	testPdfStream(`/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <0069>
+endbfchar
+1 beginbfrange
+<0003> <0004> <0061>
+endbfrange
+endcmap`, false),
+	testPdfStream(`BT /F1 12 Tf 72 720 Td (Steps) Tj ET
+/X1 Do`, false),
+	`<< /Type /XObject /Subtype /Form /BBox [0 0 612 792] /Resources << /Font << /F1 10 0 R >> >> /Length 45 >>
+stream
+BT /F1 12 Tf 72 700 Td (Restart it.) Tj ET
+endstream`,
+	"<< /Title (Restart the API) /Purpose (Explain how to restart the API) >>",
+}, "/Info 15 0 R")
+
+func TestConvertPdfToMarkdown(t *testing.T) {
+	var tests = []struct {
+		name     string
+		pdf      []byte
+		expected string
+	}{
+		{"font sizes", pdfFontSizes, `# Runbook
+
+## Restarting the service
+
+Stop the service before deploying a new version.
+
+- Check the logs
+- Check the #alerts
+
+## Rollback
+
+Use git revert`},
+		{"outline", pdfOutline, `# Overview
+
+The API serves requests.
+
+Hiab
+
+# Details
+
+## Steps
+
+Restart it.`},
+	}
+
+	for _, test := range tests {
+		markdown, _, err := convertPdfToMarkdown(test.pdf, "purpose")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if markdown != test.expected {
+			t.Errorf("%s - expected:\n%s\ngot:\n%s", test.name, test.expected, markdown)
+		}
+	}
+}
+
+func TestConvertPdfToMarkdown_Invalid(t *testing.T) {
+	var tests = []struct {
+		pdf      []byte
+		expected string
+	}{
+		{[]byte("not a pdf"), "not a pdf"},
+		{buildTestPdf([]string{"<< /Type /Catalog >>", "<< /Filter /Standard >>"}, "/Encrypt 2 0 R"), "encrypted pdfs are not supported"},
+	}
+
+	for i, test := range tests {
+		_, _, err := convertPdfToMarkdown(test.pdf, "purpose")
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("test %d - expected error %q, got %v", i, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package extract
+
+import (
+	"context"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+func extractRst(id string, sourceID string, rawData []byte, options *config.ExtractorOptions, db *sqlite.Queries) error {
+	// Determine the purpose key (if not disabled)
+	purposeKey := ""
+	if !options.DisablePurposeExtraction {
+		purposeKey = options.PurposeKey
+		if purposeKey == "" {
+			purposeKey = "purpose"
+		}
+	}
+
+	// Convert the reStructuredText to markdown
+	markdown, purpose := convertRstToMarkdown(string(rawData), purposeKey)
+
+	// Insert document
+	err := db.InsertDocument(context.Background(), sqlite.InsertDocumentParams{
+		ID:            id,
+		SourceID:      sourceID,
+		Type:          config.DocTypeRst.String(),
+		Purpose:       purpose,
+		RawData:       string(rawData),
+		ExtractedData: markdown,
+	})
+	if err != nil {
+		slog.Debug("extract.extractRst could not insert document", "error", err)
+		return err
+	}
+
+	// Extract/insert sections
+	err = extractSections(id, sourceID, markdown, !options.DisablePurposeExtraction, purposeKey, db)
+	if err != nil {
+		slog.Debug("extract.extractRst could not extract sections", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// convertRstToMarkdown converts a reStructuredText document to markdown, returning the markdown
+// and the purpose of the document (if any). The purpose of the document is read from a field list
+// (e.g. ":purpose: ...") at the start of the document or directly after the document title, and the
+// purpose of a section from a field list directly after its title. Section purposes are written to
+// the markdown as purpose comments so they are picked up by extractSections. If purposeKey is
+// blank no purposes are extracted.
+//
+// This is not a complete reStructuredText parser. It handles the constructs commonly found in
+// documentation (sections, lists, literal blocks, directives, and inline markup), and otherwise
+// passes text through as-is.
+func convertRstToMarkdown(document string, purposeKey string) (string, string) {
+	document = strings.ReplaceAll(document, "\r", "")
+	document = strings.ReplaceAll(document, "\t", "        ")
+	lines := strings.Split(document, "\n")
+
+	c := &rstConverter{
+		purposeKey: purposeKey,
+		styles:     []string{},
+	}
+
+	// A field list at the start of the document holds document metadata (such as its purpose)
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if fields, next := rstFieldList(lines, start); len(fields) > 0 {
+		c.purpose = fields[purposeKey]
+		c.docinfo = true
+		start = next
+	}
+
+	c.convert(lines[start:])
+
+	return cleanMarkdown(c.out), c.purpose
+}
+
+type rstConverter struct {
+	purposeKey string
+	// styles are the section title adornment styles seen so far, where the index of a style is its
+	// level - 1 (reStructuredText assigns levels in the order styles are first seen)
+	styles []string
+	// docinfo is true once the document metadata could have been read (which is either at the start
+	// of the document or directly after the first title)
+	docinfo bool
+	purpose string
+	out     []string
+}
+
+var (
+	rstDirectiveRegex       = regexp.MustCompile(`^\.\.\s+([\w:.+-]+)::(?:\s+(.*))?$`)
+	rstTargetRegex          = regexp.MustCompile(`^\.\.\s+_[^:]*:`)
+	rstSubstitutionRegex    = regexp.MustCompile(`^\.\.\s+\|[^|]+\|`)
+	rstFootnoteRegex        = regexp.MustCompile(`^\.\.\s+\[([^\]]+)\](?:\s+(.*))?$`)
+	rstFieldRegex           = regexp.MustCompile(`^:([^:\s][^:]*):(?:\s+(.*))?$`)
+	rstDirectiveOptionRegex = regexp.MustCompile(`^:([\w-]+):(?:\s+(.*))?$`)
+	rstEnumeratedAutoRegex  = regexp.MustCompile(`^(\s*)#\.(\s)`)
+)
+
+const rstAdornmentCharacters = "=-`:'\"~^_*+#<>.!$%&(),/;?@[\\]{|}"
+
+// rstDroppedDirectives are directives that do not hold any documentation content
+var rstDroppedDirectives = map[string]struct{}{
+	"toctree": {}, "index": {}, "meta": {}, "contents": {}, "include": {}, "literalinclude": {},
+	"raw": {}, "highlight": {}, "default-role": {}, "role": {}, "currentmodule": {}, "module": {},
+	"sectionauthor": {}, "moduleauthor": {}, "codeauthor": {}, "tabularcolumns": {}, "sectnum": {},
+	"header": {}, "footer": {}, "title": {}, "autosummary": {},
+}
+
+// rstAdmonitions are directives that are rendered as a block quote with a title
+var rstAdmonitions = map[string]string{
+	"note": "Note", "warning": "Warning", "tip": "Tip", "important": "Important", "caution": "Caution",
+	"danger": "Danger", "attention": "Attention", "hint": "Hint", "error": "Error", "seealso": "See also",
+	"todo": "Todo",
+}
+
+// rstVersionAdmonitions are admonitions whose argument is a version
+var rstVersionAdmonitions = map[string]string{
+	"versionadded": "Added in version", "versionchanged": "Changed in version", "deprecated": "Deprecated since version",
+}
+
+func (c *rstConverter) emit(lines ...string) {
+	c.out = append(c.out, lines...)
+}
+
+func (c *rstConverter) convert(lines []string) {
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], " ")
+		trimmed := strings.TrimSpace(line)
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		atBlockStart := i == 0 || strings.TrimSpace(lines[i-1]) == ""
+
+		// Blank lines
+		if trimmed == "" {
+			c.emit("")
+			i++
+			continue
+		}
+
+		// Section titles
+		if atBlockStart && indent == "" {
+			if title, style, next, ok := rstSectionTitle(lines, i); ok {
+				i = c.section(title, style, lines, next)
+				continue
+			}
+		}
+
+		// Transitions
+		if atBlockStart && indent == "" && len(trimmed) >= 4 && isRstAdornment(trimmed) && (i+1 == len(lines) || strings.TrimSpace(lines[i+1]) == "") {
+			c.emit("---")
+			i++
+			continue
+		}
+
+		// Explicit markup (directives, comments, targets, and footnotes)
+		if trimmed == ".." || strings.HasPrefix(trimmed, ".. ") {
+			i = c.explicitMarkup(lines, i, len(indent))
+			continue
+		}
+
+		// Paragraphs ending in :: are followed by a literal block
+		if strings.HasSuffix(trimmed, "::") {
+			if block, next := rstIndentedBlock(lines, i+1, len(indent)); len(block) > 0 {
+				text := strings.TrimSuffix(trimmed, "::")
+				switch {
+				case text == "":
+				case strings.HasSuffix(text, " "):
+					c.emit(indent+convertRstInline(strings.TrimSpace(text)), "")
+				default:
+					c.emit(indent+convertRstInline(text)+":", "")
+				}
+				c.fence("", block)
+				i = next
+				continue
+			}
+		}
+
+		// Auto-numbered lists (#.) become ordered lists
+		line = rstEnumeratedAutoRegex.ReplaceAllString(line, "${1}1.${2}")
+
+		// Escape any text that would otherwise be read as a heading
+		converted := convertRstInline(line)
+		if strings.HasPrefix(converted, "#") {
+			converted = "\\" + converted
+		}
+		c.emit(converted)
+		i++
+	}
+}
+
+// section emits a heading for a section title, along with the purpose of the section (if any),
+// returning the index of the next line to convert
+func (c *rstConverter) section(title string, style string, lines []string, next int) int {
+	level := 0
+	for j, seen := range c.styles {
+		if seen == style {
+			level = j + 1
+			break
+		}
+	}
+	if level == 0 {
+		c.styles = append(c.styles, style)
+		level = len(c.styles)
+	}
+	c.emit(strings.Repeat("#", level)+" "+convertRstInline(title), "")
+
+	// A field list directly after the first title holds the document metadata (if not already
+	// read), and a field list directly after any other title sets the purpose of the section
+	first := !c.docinfo
+	c.docinfo = true
+	start := next
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	fields, end := rstFieldList(lines, start)
+	if len(fields) == 0 {
+		return next
+	}
+	if first {
+		c.purpose = fields[c.purposeKey]
+		return end
+	}
+	if purpose, ok := fields[c.purposeKey]; ok && c.purposeKey != "" {
+		c.emit(formatPurposeComment(c.purposeKey, purpose))
+		return end
+	}
+
+	return next
+}
+
+// explicitMarkup converts the explicit markup block starting at lines[i], returning the index of
+// the next line to convert
+func (c *rstConverter) explicitMarkup(lines []string, i int, indent int) int {
+	trimmed := strings.TrimSpace(lines[i])
+	prefix := strings.Repeat(" ", indent)
+
+	// Targets and substitution definitions are dropped
+	if rstTargetRegex.MatchString(trimmed) || rstSubstitutionRegex.MatchString(trimmed) {
+		_, next := rstIndentedBlock(lines, i+1, indent)
+		return next
+	}
+
+	// Footnotes and citations keep their text
+	if match := rstFootnoteRegex.FindStringSubmatch(trimmed); match != nil {
+		body, next := rstIndentedBlock(lines, i+1, indent)
+		text := strings.TrimSpace(match[2] + " " + strings.Join(trimLines(body), " "))
+		c.emit(prefix + "[" + match[1] + "] " + convertRstInline(text))
+		return next
+	}
+
+	// Anything that is not a directive is a comment, which is dropped
+	match := rstDirectiveRegex.FindStringSubmatch(trimmed)
+	if match == nil {
+		_, next := rstIndentedBlock(lines, i+1, indent)
+		return next
+	}
+	name := strings.ToLower(match[1])
+	argument := strings.TrimSpace(match[2])
+	options, body, next := rstDirectiveBody(lines, i+1, indent)
+
+	if _, ok := rstDroppedDirectives[name]; ok {
+		return next
+	}
+
+	switch name {
+	case "code-block", "code", "sourcecode":
+		c.fence(argument, body)
+	case "image", "figure":
+		c.emit(prefix + "![" + options["alt"] + "](" + argument + ")")
+		if len(body) > 0 {
+			c.emit("")
+			c.nested(prefix, body)
+		}
+	case "rubric":
+		c.emit(prefix + "**" + convertRstInline(argument) + "**")
+	case "admonition":
+		c.admonition(prefix, argument, body)
+	default:
+		if title, ok := rstAdmonitions[name]; ok {
+			if argument != "" {
+				body = append([]string{argument}, body...)
+			}
+			c.admonition(prefix, title, body)
+			return next
+		}
+		if title, ok := rstVersionAdmonitions[name]; ok {
+			c.admonition(prefix, strings.TrimSpace(title+" "+argument), body)
+			return next
+		}
+
+		// Keep the argument and body of any other directive (such as a Sphinx object description)
+		if argument != "" {
+			if strings.Contains(name, ":") || len(body) > 0 {
+				c.emit(prefix + "`" + argument + "`")
+			} else {
+				c.emit(prefix + convertRstInline(argument))
+			}
+			if len(body) > 0 {
+				c.emit("")
+			}
+		}
+		c.nested(prefix, body)
+	}
+
+	return next
+}
+
+// admonition emits a block quote starting with a bold title
+func (c *rstConverter) admonition(prefix string, title string, body []string) {
+	nested := &rstConverter{purposeKey: "", styles: c.styles, docinfo: true}
+	nested.convert(body)
+	converted := strings.Split(cleanMarkdown(nested.out), "\n")
+
+	first := prefix + "> **" + title + ":**"
+	if len(converted) > 0 && converted[0] != "" {
+		first += " " + converted[0]
+	}
+	c.emit(first)
+	for _, line := range converted[1:] {
+		c.emit(strings.TrimRight(prefix+"> "+line, " "))
+	}
+}
+
+// nested converts the body of a directive, indented by prefix
+func (c *rstConverter) nested(prefix string, body []string) {
+	nested := &rstConverter{purposeKey: "", styles: c.styles, docinfo: true}
+	nested.convert(body)
+	for _, line := range nested.out {
+		if line == "" || strings.HasPrefix(line, "```") {
+			c.emit(line)
+		} else {
+			c.emit(prefix + line)
+		}
+	}
+}
+
+// fence emits a fenced code block. Fences are never indented, as extractSections only recognizes
+// fences at the start of a line.
+func (c *rstConverter) fence(language string, block []string) {
+	c.emit("```" + language)
+	c.emit(block...)
+	c.emit("```")
+}
+
+// isRstAdornment returns true if the line is made up of a single repeated punctuation character
+func isRstAdornment(line string) bool {
+	if line == "" || !strings.ContainsRune(rstAdornmentCharacters, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// rstSectionTitle returns the section title starting at lines[i] (if any), along with its adornment
+// style and the index of the line after the title
+func rstSectionTitle(lines []string, i int) (string, string, int, bool) {
+	first := strings.TrimRight(lines[i], " ")
+
+	// Title with an overline and underline
+	if isRstAdornment(first) && i+2 < len(lines) {
+		title := strings.TrimSpace(lines[i+1])
+		underline := strings.TrimRight(lines[i+2], " ")
+		if title != "" && !isRstAdornment(title) && underline != "" && underline[0] == first[0] && isRstAdornment(underline) {
+			return title, "over" + first[:1], i + 3, true
+		}
+		return "", "", 0, false
+	}
+
+	// Title with an underline only. Short underlines are allowed to be shorter than the title (as
+	// docutils does with a warning), but must be at least 3 characters to not be mistaken for
+	// other markup (such as ::)
+	if i+1 < len(lines) && !isRstAdornment(first) {
+		underline := strings.TrimRight(lines[i+1], " ")
+		if isRstAdornment(underline) && (len(underline) >= 3 || len(underline) >= len(first)) {
+			return strings.TrimSpace(first), underline[:1], i + 2, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// rstIndentedBlock returns the block of lines starting at lines[start] indented more than indent,
+// dedented and with leading and trailing blank lines removed, along with the index of the line
+// after the block (or start if there is no block)
+func rstIndentedBlock(lines []string, start int, indent int) ([]string, int) {
+	end := start
+	for end < len(lines) {
+		line := strings.TrimRight(lines[end], " ")
+		if line != "" && len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+			break
+		}
+		end++
+	}
+
+	// Remove leading and trailing blank lines (trailing blank lines are left to be converted)
+	first, last := start, end
+	for first < last && strings.TrimSpace(lines[first]) == "" {
+		first++
+	}
+	for last > first && strings.TrimSpace(lines[last-1]) == "" {
+		last--
+	}
+	if first == last {
+		return nil, start
+	}
+
+	// Dedent by the smallest indent in the block
+	minIndent := -1
+	for _, line := range lines[first:last] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if minIndent == -1 || lineIndent < minIndent {
+			minIndent = lineIndent
+		}
+	}
+	block := make([]string, 0, last-first)
+	for _, line := range lines[first:last] {
+		line = strings.TrimRight(line, " ")
+		if len(line) >= minIndent {
+			line = line[minIndent:]
+		} else {
+			line = ""
+		}
+		block = append(block, line)
+	}
+
+	return block, last
+}
+
+// rstDirectiveBody returns the options and the body of the directive whose body starts at
+// lines[start], along with the index of the line after the directive
+func rstDirectiveBody(lines []string, start int, indent int) (map[string]string, []string, int) {
+	block, next := rstIndentedBlock(lines, start, indent)
+
+	// Options come first, and end at the first line that is not an option
+	options := map[string]string{}
+	i := 0
+	for i < len(block) {
+		match := rstDirectiveOptionRegex.FindStringSubmatch(block[i])
+		if match == nil {
+			break
+		}
+		options[match[1]] = strings.TrimSpace(match[2])
+		i++
+	}
+	for i < len(block) && block[i] == "" {
+		i++
+	}
+
+	return options, block[i:], next
+}
+
+// rstFieldList returns the fields of the field list starting at lines[start] (if any), along with
+// the index of the line after the field list. Field values that continue over multiple lines are
+// joined with spaces.
+func rstFieldList(lines []string, start int) (map[string]string, int) {
+	fields := map[string]string{}
+	i := start
+	for i < len(lines) {
+		match := rstFieldRegex.FindStringSubmatch(strings.TrimRight(lines[i], " "))
+		if match == nil {
+			break
+		}
+		body, next := rstIndentedBlock(lines, i+1, 0)
+		value := strings.TrimSpace(match[2] + " " + strings.Join(trimLines(body), " "))
+		fields[strings.ToLower(strings.TrimSpace(match[1]))] = value
+		i = next
+	}
+
+	return fields, i
+}
+
+var (
+	rstLiteralRegex   = regexp.MustCompile("``(.+?)``")
+	rstRoleRegex      = regexp.MustCompile(":([\\w:+.-]+):`([^`]+)`")
+	rstLinkRegex      = regexp.MustCompile("`([^`<]*?)\\s*<([^<>`]+)>`__?")
+	rstReferenceRegex = regexp.MustCompile("`([^`]+)`__?")
+	rstRoleTitleRegex = regexp.MustCompile(`^(.*?)\s*<([^<>]+)>$`)
+)
+
+// rstTextRoles are roles whose text is kept as plain text (other roles are rendered as code)
+var rstTextRoles = map[string]struct{}{
+	"ref": {}, "doc": {}, "term": {}, "abbr": {}, "numref": {}, "any": {}, "emphasis": {},
+	"strong": {}, "title-reference": {}, "t": {}, "sub": {}, "sup": {}, "subscript": {},
+	"superscript": {}, "pep": {}, "rfc": {}, "dfn": {}, "guilabel": {}, "menuselection": {},
+}
+
+// convertRstInline converts reStructuredText inline markup in text to markdown
+func convertRstInline(text string) string {
+	// Inline literals are kept as-is, so only convert the text between them
+	var converted strings.Builder
+	last := 0
+	for _, match := range rstLiteralRegex.FindAllStringSubmatchIndex(text, -1) {
+		converted.WriteString(convertRstInlineText(text[last:match[0]]))
+		literal := text[match[2]:match[3]]
+		if strings.Contains(literal, "`") {
+			converted.WriteString("`` " + literal + " ``")
+		} else {
+			converted.WriteString("`" + literal + "`")
+		}
+		last = match[1]
+	}
+	converted.WriteString(convertRstInlineText(text[last:]))
+
+	return converted.String()
+}
+
+func convertRstInlineText(text string) string {
+	// Roles (e.g. :ref:`Title <target>` or :func:`name`)
+	text = rstRoleRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := rstRoleRegex.FindStringSubmatch(match)
+		role, content := parts[1], parts[2]
+		if title := rstRoleTitleRegex.FindStringSubmatch(content); title != nil && title[1] != "" {
+			content = title[1]
+		}
+		content = strings.TrimPrefix(content, "!")
+		if strings.HasPrefix(content, "~") {
+			content = content[strings.LastIndex(content, ".")+1:]
+			content = strings.TrimPrefix(content, "~")
+		}
+		role = role[strings.LastIndex(role, ":")+1:]
+		switch role {
+		case "emphasis":
+			return "*" + content + "*"
+		case "strong":
+			return "**" + content + "**"
+		}
+		if _, ok := rstTextRoles[role]; ok {
+			return content
+		}
+		return "`" + content + "`"
+	})
+
+	// Hyperlinks (e.g. `Title <https://example.com>`_)
+	text = rstLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := rstLinkRegex.FindStringSubmatch(match)
+		title, target := parts[1], parts[2]
+		if title == "" {
+			title = target
+		}
+		// Targets ending in _ are references to other targets in the document
+		if strings.HasSuffix(target, "_") {
+			return title
+		}
+		return "[" + title + "](" + target + ")"
+	})
+
+	// References (e.g. `Title`_)
+	text = rstReferenceRegex.ReplaceAllString(text, "$1")
+
+	return text
+}
+
+// trimLines returns lines with leading and trailing space removed from each line
+func trimLines(lines []string) []string {
+	trimmed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed = append(trimmed, strings.TrimSpace(line))
+	}
+	return trimmed
+}
+
+// cleanMarkdown joins lines of converted markdown, collapsing runs of blank lines and removing
+// leading and trailing space
+func cleanMarkdown(lines []string) string {
+	cleaned := make([]string, 0, len(lines))
+	inCodeBlock := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			inCodeBlock = !inCodeBlock
+		}
+		if !inCodeBlock && line == "" && len(cleaned) > 0 && cleaned[len(cleaned)-1] == "" {
+			continue
+		}
+		cleaned = append(cleaned, line)
+	}
+
+	return strings.TrimSpace(strings.Join(cleaned, "\n"))
+}
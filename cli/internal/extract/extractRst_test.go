@@ -0,0 +1,146 @@
+package extract
+
+import (
+	"testing"
+)
+
+func TestConvertRstToMarkdown(t *testing.T) {
+	titles := `=====
+Title
+=====
+
+Section
+=======
+
+Subsection
+----------
+
+Another Section
+===============`
+	titlesMarkdown := `# Title
+
+## Section
+
+### Subsection
+
+## Another Section`
+
+	literal := `Run this::
+
+    pip install hyaline
+    # not a heading
+
+Or this: ::
+
+    go install`
+	literalMarkdown := "Run this:\n\n```\npip install hyaline\n# not a heading\n```\n\nOr this:\n\n```\ngo install\n```"
+
+	directives := `.. code-block:: go
+   :linenos:
+
+   fmt.Println("hi")
+
+.. note:: Read this.
+   It matters.
+
+.. image:: diagram.png
+   :alt: Diagram
+
+.. toctree::
+   :maxdepth: 2
+
+   install
+
+.. A comment
+.. _target:`
+	directivesMarkdown := "```go\nfmt.Println(\"hi\")\n```\n\n> **Note:** Read this.\n> It matters.\n\n![Diagram](diagram.png)"
+
+	inline := "Use ``hyaline check``, see `the docs <https://example.com>`_, :ref:`Install <install>`, and :func:`os.Open`."
+	inlineMarkdown := "Use `hyaline check`, see [the docs](https://example.com), Install, and `os.Open`."
+
+	lists := `#. First
+#. Second
+
+* Bullet`
+	listsMarkdown := `1. First
+1. Second
+
+* Bullet`
+
+	var tests = []struct {
+		name     string
+		document string
+		markdown string
+	}{
+		{"Empty", "", ""},
+		{"Titles", titles, titlesMarkdown},
+		{"Literal Blocks", literal, literalMarkdown},
+		{"Directives", directives, directivesMarkdown},
+		{"Inline Markup", inline, inlineMarkdown},
+		{"Lists", lists, listsMarkdown},
+		{"Heading Escaped", "#hashtag", "\\#hashtag"},
+		{"Transition", "Before\n\n----\n\nAfter", "Before\n\n---\n\nAfter"},
+	}
+
+	for _, test := range tests {
+		markdown, _ := convertRstToMarkdown(test.document, "purpose")
+		if markdown != test.markdown {
+			t.Errorf("%s - expected:\n%s\ngot:\n%s", test.name, test.markdown, markdown)
+		}
+	}
+}
+
+func TestConvertRstToMarkdown_Purpose(t *testing.T) {
+	fieldList := `:purpose: Document purpose
+:orphan:
+
+Title
+=====
+
+Content`
+	afterTitle := `Title
+=====
+:purpose: Document purpose
+
+Section
+-------
+
+:purpose: Section purpose
+
+Content`
+	afterTitleMarkdown := `# Title
+
+## Section
+
+<!-- purpose: "Section purpose" -->
+
+Content`
+	multiLine := `:purpose: A purpose that
+   continues on the next line
+
+Content`
+
+	var tests = []struct {
+		name     string
+		document string
+		key      string
+		purpose  string
+		markdown string
+	}{
+		{"Field List", fieldList, "purpose", "Document purpose", "# Title\n\nContent"},
+		{"Field List Key Not Present", fieldList, "custom", "", "# Title\n\nContent"},
+		{"After Title", afterTitle, "purpose", "Document purpose", afterTitleMarkdown},
+		{"Multi Line", multiLine, "purpose", "A purpose that continues on the next line", "Content"},
+		{"No Purpose", "Title\n=====\n\nContent", "purpose", "", "# Title\n\nContent"},
+	}
+
+	for _, test := range tests {
+		markdown, purpose := convertRstToMarkdown(test.document, test.key)
+		if purpose != test.purpose {
+			t.Errorf("%s - expected purpose %q, got %q", test.name, test.purpose, purpose)
+		}
+		if markdown != test.markdown {
+			t.Errorf("%s - expected:\n%s\ngot:\n%s", test.name, test.markdown, markdown)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package extract
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"hyaline/internal/sqlite"
 	"log/slog"
@@ -16,6 +17,9 @@ type section struct {
 	Content  string
 	Purpose  string
 	Children []*section
+	// The lines of the section (including its heading and subsections) are lines[Start:End]
+	Start int
+	End   int
 }
 
 func extractSections(documentID string, sourceID string, markdown string, extractPurpose bool, purposeKey string, db *sqlite.Queries) error {
@@ -53,7 +57,7 @@ func getMarkdownSections(lines []string) *section {
 	// Start parsing not in a code block
 	inCodeBlock := false
 
-	for _, line := range lines {
+	for i, line := range lines {
 		// If the line starts with ```, enter or exit the code block
 		if strings.HasPrefix(line, "```") {
 			inCodeBlock = !inCodeBlock
@@ -87,15 +91,18 @@ func getMarkdownSections(lines []string) *section {
 				FullName: uniqueFullName,
 				Content:  "",
 				Children: []*section{},
+				Start:    i,
 			}
 			current.Children = append(current.Children, newSection)
 			current = newSection
 		}
 
 		// Insert this line up the chain to the root
+		current.End = i + 1
 		parent := current.Parent
 		for parent != nil {
 			parent.Content = parent.Content + "\n" + line
+			parent.End = i + 1
 			parent = parent.Parent
 		}
 	}
@@ -204,3 +211,78 @@ func insertSections(s *section, order int, documentID string, sourceID string, d
 
 	return nil
 }
+
+// GetMarkdownSectionContents returns the content of each section in the markdown keyed by its
+// section ID, using the same rules for naming sections that are used when extracting them
+func GetMarkdownSectionContents(markdown string) map[string]string {
+	contents := make(map[string]string)
+
+	var addSections func(s *section)
+	addSections = func(s *section) {
+		if s.Parent != nil {
+			contents[s.FullName] = strings.TrimSpace(s.Content)
+		}
+		for _, child := range s.Children {
+			addSections(child)
+		}
+	}
+	addSections(getMarkdownSections(strings.Split(markdown, "\n")))
+
+	return contents
+}
+
+// FindMarkdownSection returns the range of lines [start, end) of the section with the given ID
+// (including its heading and subsections), using the same rules for naming sections that are used
+// when extracting them
+func FindMarkdownSection(lines []string, sectionID string) (start int, end int, ok bool) {
+	var find func(s *section) *section
+	find = func(s *section) *section {
+		if s.Parent != nil && s.FullName == sectionID {
+			return s
+		}
+		for _, child := range s.Children {
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+
+	found := find(getMarkdownSections(lines))
+	if found == nil {
+		return 0, 0, false
+	}
+
+	return found.Start, found.End, true
+}
+
+// formatPurposeComment returns an HTML comment setting the purpose (identified by key) of a
+// markdown section, as read by extractMarkdownSectionPurposes. This lets extractors that convert
+// other formats to markdown carry section purposes through to extractSections.
+func formatPurposeComment(key string, purpose string) string {
+	// A JSON string is a valid yaml string, and escapes any characters (such as >) that could
+	// end the comment early
+	value, err := json.Marshal(purpose)
+	if err != nil {
+		slog.Debug("extract.formatPurposeComment could not marshal purpose", "error", err)
+		return ""
+	}
+
+	return fmt.Sprintf("<!-- %s: %s -->", key, value)
+}
+
+// getSectionIDsByLine returns the ID of each section in markdown keyed by the line of its heading,
+// allowing extractors that generate markdown to find the sections they generated
+func getSectionIDsByLine(markdown string) map[int]string {
+	ids := map[int]string{}
+	var walk func(s *section)
+	walk = func(s *section) {
+		for _, child := range s.Children {
+			ids[child.Start] = child.FullName
+			walk(child)
+		}
+	}
+	walk(getMarkdownSections(strings.Split(markdown, "\n")))
+
+	return ids
+}
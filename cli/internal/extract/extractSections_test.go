@@ -175,6 +175,41 @@ func TestExtractMarkdownSectionPurposes(t *testing.T) {
 	}
 }
 
+func TestFindMarkdownSection(t *testing.T) {
+	lines := []string{
+		"Intro",
+		"# Section A",
+		"Some content",
+		"## Subsection A1",
+		"More content",
+		"",
+		"# Section B",
+		"```",
+		"# Not a section",
+		"```",
+	}
+
+	var tests = []struct {
+		sectionID string
+		start     int
+		end       int
+		ok        bool
+	}{
+		{"Section A", 1, 6, true},
+		{"Section A/Subsection A1", 3, 6, true},
+		{"Section B", 6, 10, true},
+		{"Not a section", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for i, test := range tests {
+		start, end, ok := FindMarkdownSection(lines, test.sectionID)
+		if start != test.start || end != test.end || ok != test.ok {
+			t.Errorf("test %d - expected %d, %d, %t, got %d, %d, %t", i, test.start, test.end, test.ok, start, end, ok)
+		}
+	}
+}
+
 // getAllFullNames recursively collects all FullName values from a section tree
 func getAllFullNames(s *section) []string {
 	var fullNames []string
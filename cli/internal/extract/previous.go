@@ -0,0 +1,203 @@
+package extract
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"log/slog"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// previousDocumentation holds the documents, sections, and tags extracted for a source
+// during a previous run so that unchanged documents can be copied instead of re-extracted
+type previousDocumentation struct {
+	documents    map[string]sqlite.DOCUMENT
+	hashes       map[string]string
+	documentTags map[string][]sqlite.GetAllDocumentTagsForSourceRow
+	sections     map[string][]sqlite.SECTION
+	sectionTags  map[string][]sqlite.GetAllSectionTagsForSourceRow
+	seen         map[string]struct{}
+}
+
+func loadPreviousDocumentation(sourceID string, db *sqlite.Queries) (previous *previousDocumentation, err error) {
+	ctx := context.Background()
+	previous = &previousDocumentation{
+		documents:    make(map[string]sqlite.DOCUMENT),
+		hashes:       make(map[string]string),
+		documentTags: make(map[string][]sqlite.GetAllDocumentTagsForSourceRow),
+		sections:     make(map[string][]sqlite.SECTION),
+		sectionTags:  make(map[string][]sqlite.GetAllSectionTagsForSourceRow),
+		seen:         make(map[string]struct{}),
+	}
+
+	documents, err := db.GetDocumentsForSource(ctx, sourceID)
+	if err != nil {
+		slog.Debug("extract.loadPreviousDocumentation could not get documents", "error", err)
+		return
+	}
+	for _, document := range documents {
+		previous.documents[document.ID] = document
+	}
+
+	// Documents without a hash (e.g. from a data set extracted before hashes were recorded) are
+	// always extracted again
+	hashes, err := db.GetDocumentHashesForSource(ctx, sourceID)
+	if err != nil {
+		slog.Warn("Could not load document hashes from previous documentation, all documents will be extracted again", "error", err)
+		err = nil
+	}
+	for _, hash := range hashes {
+		previous.hashes[hash.DocumentID] = hash.Hash
+	}
+
+	documentTags, err := db.GetAllDocumentTagsForSource(ctx, sourceID)
+	if err != nil {
+		slog.Debug("extract.loadPreviousDocumentation could not get document tags", "error", err)
+		return
+	}
+	for _, tag := range documentTags {
+		previous.documentTags[tag.DocumentID] = append(previous.documentTags[tag.DocumentID], tag)
+	}
+
+	// Note: sections are returned in peer order, which is preserved when copying
+	sections, err := db.GetAllSectionsForSource(ctx, sourceID)
+	if err != nil {
+		slog.Debug("extract.loadPreviousDocumentation could not get sections", "error", err)
+		return
+	}
+	for _, section := range sections {
+		previous.sections[section.DocumentID] = append(previous.sections[section.DocumentID], section)
+	}
+
+	sectionTags, err := db.GetAllSectionTagsForSource(ctx, sourceID)
+	if err != nil {
+		slog.Debug("extract.loadPreviousDocumentation could not get section tags", "error", err)
+		return
+	}
+	for _, tag := range sectionTags {
+		previous.sectionTags[tag.DocumentID] = append(previous.sectionTags[tag.DocumentID], tag)
+	}
+
+	slog.Info("Loaded previous documentation", "documents", len(previous.documents))
+	return
+}
+
+// check records that the document was encountered and returns whether it existed previously
+// and, if so, whether it is unchanged (same hash, see hashDocument)
+func (previous *previousDocumentation) check(id string, hash string) (exists bool, unchanged bool) {
+	previous.seen[id] = struct{}{}
+
+	_, exists = previous.documents[id]
+	if !exists {
+		return
+	}
+	previousHash, ok := previous.hashes[id]
+	unchanged = ok && previousHash == hash
+
+	return
+}
+
+// removed returns the number of previous documents that were not encountered during this extraction
+func (previous *previousDocumentation) removed() int {
+	count := 0
+	for id := range previous.documents {
+		if _, ok := previous.seen[id]; !ok {
+			count++
+		}
+	}
+
+	return count
+}
+
+// copyDocument copies a previously extracted document along with its sections and tags into db
+func (previous *previousDocumentation) copyDocument(id string, db *sqlite.Queries) error {
+	ctx := context.Background()
+	document := previous.documents[id]
+
+	err := db.InsertDocument(ctx, sqlite.InsertDocumentParams{
+		ID:            document.ID,
+		SourceID:      document.SourceID,
+		Type:          document.Type,
+		Purpose:       document.Purpose,
+		RawData:       document.RawData,
+		ExtractedData: document.ExtractedData,
+	})
+	if err != nil {
+		slog.Debug("extract.copyDocument could not insert document", "document", id, "error", err)
+		return err
+	}
+
+	for _, tag := range previous.documentTags[id] {
+		err = db.UpsertDocumentTag(ctx, sqlite.UpsertDocumentTagParams{
+			SourceID:   document.SourceID,
+			DocumentID: tag.DocumentID,
+			TagKey:     tag.TagKey,
+			TagValue:   tag.TagValue,
+		})
+		if err != nil {
+			slog.Debug("extract.copyDocument could not insert document tag", "document", id, "error", err)
+			return err
+		}
+	}
+
+	for _, section := range previous.sections[id] {
+		err = db.InsertSection(ctx, sqlite.InsertSectionParams{
+			ID:            section.ID,
+			DocumentID:    section.DocumentID,
+			SourceID:      section.SourceID,
+			ParentID:      section.ParentID,
+			PeerOrder:     section.PeerOrder,
+			Name:          section.Name,
+			Purpose:       section.Purpose,
+			ExtractedData: section.ExtractedData,
+		})
+		if err != nil {
+			slog.Debug("extract.copyDocument could not insert section", "document", id, "section", section.ID, "error", err)
+			return err
+		}
+	}
+
+	for _, tag := range previous.sectionTags[id] {
+		err = db.UpsertSectionTag(ctx, sqlite.UpsertSectionTagParams{
+			SourceID:   document.SourceID,
+			DocumentID: tag.DocumentID,
+			SectionID:  tag.SectionID,
+			TagKey:     tag.TagKey,
+			TagValue:   tag.TagValue,
+		})
+		if err != nil {
+			slog.Debug("extract.copyDocument could not insert section tag", "document", id, "section", tag.SectionID, "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hashDocument returns a hash of everything that determines what is stored for a document: the
+// extractor type and options, the metadata that applies to the document (and its sections), and
+// the raw data. Metadata is included because its purposes and tags are copied along with the
+// document and are never removed by addMetadata.
+func hashDocument(id string, docType config.ExtractorType, options *config.ExtractorOptions, metadata []config.ExtractMetadata, rawData []byte) string {
+	applied := []config.ExtractMetadata{}
+	for _, m := range metadata {
+		if doublestar.MatchUnvalidated(m.Document, id) {
+			applied = append(applied, m)
+		}
+	}
+	key, _ := json.Marshal(struct {
+		Type     config.ExtractorType
+		Options  *config.ExtractorOptions
+		Metadata []config.ExtractMetadata
+	}{docType, options, applied})
+
+	hash := sha256.New()
+	hash.Write(key)
+	hash.Write([]byte{0})
+	hash.Write(rawData)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,207 @@
+package extract
+
+import (
+	"context"
+	"hyaline/internal/config"
+	"hyaline/internal/sqlite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDocumentationWithPrevious(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	docsDir := filepath.Join(dir, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name string, contents string) {
+		if err := os.WriteFile(filepath.Join(docsDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Extract{
+		Source: config.ExtractSource{ID: "docs"},
+		Crawler: config.ExtractCrawler{
+			Type:    config.ExtractorTypeFs,
+			Options: config.CrawlerOptions{Path: docsDir},
+			Include: []string{"**/*.md"},
+		},
+		Extractors: []config.ExtractExtractor{
+			{Type: config.DocTypeMarkdown, Include: []string{"**/*.md"}},
+		},
+	}
+
+	// Initial extraction
+	writeFile("unchanged.md", "# Unchanged\nSame content")
+	writeFile("changed.md", "# Changed\nOld content")
+	writeFile("removed.md", "# Removed\nGone soon")
+	previousDB, closePrevious, err := sqlite.InitOutput(filepath.Join(dir, "previous.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closePrevious()
+	if err = Documentation(cfg, previousDB, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark the unchanged document and a section tag so we can tell it was copied rather than extracted
+	err = previousDB.UpdateDocumentPurpose(ctx, sqlite.UpdateDocumentPurposeParams{Purpose: "copied", ID: "unchanged.md", SourceID: "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = previousDB.UpsertSectionTag(ctx, sqlite.UpsertSectionTagParams{SourceID: "docs", DocumentID: "unchanged.md", SectionID: "Unchanged", TagKey: "copied", TagValue: "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the documentation and extract again using the previous database
+	writeFile("changed.md", "# Changed\nNew content")
+	writeFile("added.md", "# Added\nNew document")
+	if err = os.Remove(filepath.Join(docsDir, "removed.md")); err != nil {
+		t.Fatal(err)
+	}
+	currentDB, closeCurrent, err := sqlite.InitOutput(filepath.Join(dir, "current.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeCurrent()
+	if err = Documentation(cfg, currentDB, previousDB); err != nil {
+		t.Fatal(err)
+	}
+
+	documents, err := currentDB.GetDocumentsForSource(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	documentMap := make(map[string]sqlite.DOCUMENT)
+	for _, document := range documents {
+		documentMap[document.ID] = document
+	}
+	if len(documentMap) != 3 {
+		t.Errorf("expected 3 documents, got %d", len(documentMap))
+	}
+	if _, ok := documentMap["removed.md"]; ok {
+		t.Errorf("expected removed.md to not be present")
+	}
+	if documentMap["unchanged.md"].Purpose != "copied" {
+		t.Errorf("expected unchanged.md to be copied from the previous database")
+	}
+	if documentMap["changed.md"].ExtractedData != "# Changed\nNew content" {
+		t.Errorf("expected changed.md to be extracted again, got %s", documentMap["changed.md"].ExtractedData)
+	}
+	if _, ok := documentMap["added.md"]; !ok {
+		t.Errorf("expected added.md to be present")
+	}
+
+	sectionTags, err := currentDB.GetSectionTags(ctx, sqlite.GetSectionTagsParams{SourceID: "docs", DocumentID: "unchanged.md", SectionID: "Unchanged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectionTags) != 1 || sectionTags[0].TagKey != "copied" {
+		t.Errorf("expected section tags to be copied from the previous database, got %v", sectionTags)
+	}
+}
+
+func TestDocumentationWithPrevious_ConfigChanged(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	docsDir := filepath.Join(dir, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"tagged.md":   "---\npurpose: From front matter\n---\n# Tagged\nContent",
+		"other.md":    "# Other\nContent",
+		"api/spec.md": "---\nsummary: From summary\n---\n# Spec\nContent",
+	}
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(docsDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(docsDir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Extract{
+		Source: config.ExtractSource{ID: "docs"},
+		Crawler: config.ExtractCrawler{
+			Type:    config.ExtractorTypeFs,
+			Options: config.CrawlerOptions{Path: docsDir},
+			Include: []string{"**/*.md"},
+		},
+		Extractors: []config.ExtractExtractor{
+			{Type: config.DocTypeMarkdown, Include: []string{"api/*.md"}},
+			{Type: config.DocTypeMarkdown, Include: []string{"*.md"}},
+		},
+		Metadata: []config.ExtractMetadata{
+			{Document: "tagged.md", Purpose: "From metadata", Tags: []config.ExtractMetadataTag{{Key: "team", Value: "docs"}}},
+			{Document: "tagged.md", Section: "Tagged", Tags: []config.ExtractMetadataTag{{Key: "owner", Value: "alice"}}},
+		},
+	}
+
+	// Initial extraction
+	previousDB, closePrevious, err := sqlite.InitOutput(filepath.Join(dir, "previous.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closePrevious()
+	if err = Documentation(cfg, previousDB, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark the document not affected by the changes so we can tell it was copied rather than extracted
+	err = previousDB.UpdateDocumentPurpose(ctx, sqlite.UpdateDocumentPurposeParams{Purpose: "copied", ID: "other.md", SourceID: "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the metadata and change the options of an extractor, then extract again
+	cfg.Metadata = nil
+	cfg.Extractors[0].Options.PurposeKey = "summary"
+	currentDB, closeCurrent, err := sqlite.InitOutput(filepath.Join(dir, "current.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeCurrent()
+	if err = Documentation(cfg, currentDB, previousDB); err != nil {
+		t.Fatal(err)
+	}
+
+	documents, err := currentDB.GetDocumentsForSource(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	purposes := map[string]string{}
+	for _, document := range documents {
+		purposes[document.ID] = document.Purpose
+	}
+	expected := map[string]string{
+		"tagged.md":   "From front matter",
+		"other.md":    "copied",
+		"api/spec.md": "From summary",
+	}
+	for id, purpose := range expected {
+		if purposes[id] != purpose {
+			t.Errorf("expected %s to have purpose %q, got %q", id, purpose, purposes[id])
+		}
+	}
+
+	documentTags, err := currentDB.GetAllDocumentTagsForSource(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documentTags) != 0 {
+		t.Errorf("expected removed metadata document tags to not be present, got %v", documentTags)
+	}
+	sectionTags, err := currentDB.GetAllSectionTagsForSource(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sectionTags) != 0 {
+		t.Errorf("expected removed metadata section tags to not be present, got %v", sectionTags)
+	}
+}
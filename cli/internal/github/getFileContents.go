@@ -0,0 +1,45 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// GetFileContents retrieves the contents of a file at the given commit sha in the repository of
+// a GitHub Pull Request or Issue reference. Returns nil contents if the file does not exist.
+func GetFileContents(ref string, path string, sha string, token string) (contents []byte, err error) {
+	// Parse reference
+	owner, repo, _, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Get file
+	client := github.NewClient(nil).WithAuthToken(token)
+	opts := &github.RepositoryContentGetOptions{
+		Ref: sha,
+	}
+	file, _, resp, err := client.Repositories.GetContents(context.Background(), owner, repo, path, opts)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			err = nil
+		}
+		return
+	}
+	if file == nil {
+		err = errors.New("path is not a file: " + path)
+		return
+	}
+
+	// Decode contents
+	content, err := file.GetContent()
+	if err != nil {
+		return
+	}
+	contents = []byte(content)
+
+	return
+}
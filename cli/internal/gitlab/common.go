@@ -0,0 +1,95 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Parse a GitLab MR or Issue reference (GROUP/PROJECT/IID) and return the various parts.
+// The project may be nested within subgroups (e.g. GROUP/SUBGROUP/PROJECT/IID)
+func parseReference(ref string) (project string, iid int64, err error) {
+	index := strings.LastIndex(ref, "/")
+	if index <= 0 || strings.HasPrefix(ref, "/") || !strings.Contains(ref[:index], "/") {
+		err = errors.New("reference must be in the form GROUP/PROJECT/IID")
+		return
+	}
+	project = ref[:index]
+	iid, err = strconv.ParseInt(ref[index+1:], 10, 64)
+
+	return
+}
+
+// projectURL returns the API url for a project, escaping the project path so it can be used as an ID
+func projectURL(cfg *config.GitLab, project string) string {
+	return fmt.Sprintf("%s/projects/%s", cfg.GetURL(), url.PathEscape(project))
+}
+
+// doRequest calls the GitLab API and decodes the JSON response into result (if not nil).
+// Returns the response so callers can inspect the status and pagination headers
+func doRequest(method string, rawURL string, body interface{}, result interface{}, cfg *config.GitLab) (resp *http.Response, err error) {
+	var reader io.Reader
+	if body != nil {
+		var data []byte
+		data, err = json.Marshal(body)
+		if err != nil {
+			return
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return
+	}
+	if cfg.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", cfg.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = &ResponseError{StatusCode: resp.StatusCode, Method: method, URL: rawURL}
+		return
+	}
+
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+	}
+
+	return
+}
+
+// ResponseError is returned when the GitLab API responds with a non 2xx status code
+type ResponseError struct {
+	StatusCode int
+	Method     string
+	URL        string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("gitlab api %s %s returned status %d", e.Method, e.URL, e.StatusCode)
+}
+
+// nextPage returns the next page from the pagination headers, or 0 if there is no next page
+func nextPage(resp *http.Response) int {
+	next, err := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	if err != nil {
+		return 0
+	}
+	return next
+}
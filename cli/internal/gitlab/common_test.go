@@ -0,0 +1,30 @@
+package gitlab
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	var tests = []struct {
+		ref     string
+		project string
+		iid     int64
+		err     bool
+	}{
+		{"group/project/1", "group/project", 1, false},
+		{"group/subgroup/project/42", "group/subgroup/project", 42, false},
+		{"project/1", "", 0, true},
+		{"group/project/abc", "group/project", 0, true},
+		{"/group/project/1", "", 0, true},
+		{"1", "", 0, true},
+	}
+
+	for i, test := range tests {
+		project, iid, err := parseReference(test.ref)
+		if (err != nil) != test.err {
+			t.Errorf("(%d) expected error %t, got %v", i, test.err, err)
+			continue
+		}
+		if !test.err && (project != test.project || iid != test.iid) {
+			t.Errorf("(%d) expected %s %d, got %s %d", i, test.project, test.iid, project, iid)
+		}
+	}
+}
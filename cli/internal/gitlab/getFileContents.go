@@ -0,0 +1,46 @@
+package gitlab
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// GetFileContents retrieves the contents of a file at the given commit sha in the project of a
+// GitLab MR or Issue reference. Returns nil contents if the file does not exist.
+func GetFileContents(ref string, path string, sha string, cfg *config.GitLab) (contents []byte, err error) {
+	// Parse reference
+	project, _, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Get raw file
+	rawURL := fmt.Sprintf("%s/repository/files/%s/raw?ref=%s", projectURL(cfg, project), url.PathEscape(path), url.QueryEscape(sha))
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return
+	}
+	if cfg.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", cfg.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = &ResponseError{StatusCode: resp.StatusCode, Method: http.MethodGet, URL: rawURL}
+		return
+	}
+
+	contents, err = io.ReadAll(resp.Body)
+
+	return
+}
@@ -0,0 +1,70 @@
+package gitlab
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"net/http"
+)
+
+type Issue struct {
+	Title string
+	Body  string
+}
+
+type rawIssue struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func GetIssue(ref string, cfg *config.GitLab) (issue *Issue, err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Get Issue
+	raw := rawIssue{}
+	_, err = doRequest(http.MethodGet, fmt.Sprintf("%s/issues/%d", projectURL(cfg, project), iid), nil, &raw, cfg)
+	if err != nil {
+		return
+	}
+
+	issue = &Issue{
+		Title: raw.Title,
+		Body:  raw.Description,
+	}
+
+	return
+}
+
+// ListMergeRequestIssues returns the issues that will be closed when the Merge Request is merged
+// (i.e. the issues linked to the MR using closing patterns such as "Closes #1")
+func ListMergeRequestIssues(ref string, cfg *config.GitLab) (issues []*Issue, err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// List issues with pagination
+	page := 1
+	for page != 0 {
+		var rawIssues []rawIssue
+		url := fmt.Sprintf("%s/merge_requests/%d/closes_issues?per_page=100&page=%d", projectURL(cfg, project), iid, page)
+		resp, err := doRequest(http.MethodGet, url, nil, &rawIssues, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, raw := range rawIssues {
+			issues = append(issues, &Issue{
+				Title: raw.Title,
+				Body:  raw.Description,
+			})
+		}
+		page = nextPage(resp)
+	}
+
+	return
+}
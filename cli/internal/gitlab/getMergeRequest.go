@@ -0,0 +1,53 @@
+package gitlab
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"net/http"
+)
+
+type MergeRequest struct {
+	Title string
+	Body  string
+	Head  string
+	Base  string
+}
+
+type rawMergeRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	SHA         string `json:"sha"`
+	DiffRefs    struct {
+		BaseSHA string `json:"base_sha"`
+		HeadSHA string `json:"head_sha"`
+	} `json:"diff_refs"`
+}
+
+func GetMergeRequest(ref string, cfg *config.GitLab) (mr *MergeRequest, err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Get MR
+	rawMR := rawMergeRequest{}
+	_, err = doRequest(http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", projectURL(cfg, project), iid), nil, &rawMR, cfg)
+	if err != nil {
+		return
+	}
+
+	// Get MR details
+	head := rawMR.DiffRefs.HeadSHA
+	if head == "" {
+		head = rawMR.SHA
+	}
+	mr = &MergeRequest{
+		Title: rawMR.Title,
+		Body:  rawMR.Description,
+		Head:  head,
+		Base:  rawMR.DiffRefs.BaseSHA,
+	}
+
+	return
+}
@@ -0,0 +1,42 @@
+package gitlab
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"net/http"
+)
+
+type MergeRequestFile struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+}
+
+// GetMergeRequestFiles retrieves the list of files changed in a GitLab Merge Request
+func GetMergeRequestFiles(ref string, cfg *config.GitLab) ([]*MergeRequestFile, error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// List all files changed in the MR with pagination
+	var allFiles []*MergeRequestFile
+	page := 1
+	for page != 0 {
+		var files []*MergeRequestFile
+		url := fmt.Sprintf("%s/merge_requests/%d/diffs?per_page=100&page=%d", projectURL(cfg, project), iid, page)
+		resp, err := doRequest(http.MethodGet, url, nil, &files, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		allFiles = append(allFiles, files...)
+		page = nextPage(resp)
+	}
+
+	return allFiles, nil
+}
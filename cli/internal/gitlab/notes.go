@@ -0,0 +1,69 @@
+package gitlab
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"net/http"
+)
+
+type Note struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+type noteBody struct {
+	Body string `json:"body"`
+}
+
+func ListNotes(ref string, cfg *config.GitLab) (notes []Note, err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// List notes with pagination
+	page := 1
+	for page != 0 {
+		var noteList []Note
+		url := fmt.Sprintf("%s/merge_requests/%d/notes?sort=asc&order_by=created_at&per_page=100&page=%d", projectURL(cfg, project), iid, page)
+		resp, err := doRequest(http.MethodGet, url, nil, &noteList, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, noteList...)
+		page = nextPage(resp)
+	}
+
+	return
+}
+
+func AddNote(ref string, body string, cfg *config.GitLab) (err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Add Note
+	url := fmt.Sprintf("%s/merge_requests/%d/notes", projectURL(cfg, project), iid)
+	_, err = doRequest(http.MethodPost, url, &noteBody{Body: body}, nil, cfg)
+
+	return
+}
+
+func UpdateNote(ref string, noteID int64, body string, cfg *config.GitLab) (err error) {
+	// Parse reference
+	project, iid, err := parseReference(ref)
+	if err != nil {
+		return
+	}
+
+	// Update Note
+	url := fmt.Sprintf("%s/merge_requests/%d/notes/%d", projectURL(cfg, project), iid, noteID)
+	_, err = doRequest(http.MethodPut, url, &noteBody{Body: body}, nil, cfg)
+
+	return
+}
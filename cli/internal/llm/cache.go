@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// getCacheKey returns the key identifying an LLM call in the cache, which is a hash of the
+// provider, model, and prompt (see getCassetteKey)
+func getCacheKey(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, error) {
+	promptHash, err := getCassetteKey(systemPrompt, userPrompt, tools)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(cfg.Provider.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(cfg.Model))
+	hash.Write([]byte{0})
+	hash.Write([]byte(promptHash))
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// cacheLLM replays the call from the cache directory at cfg.Cache if it has been made before.
+// Otherwise the LLM is called using callLLM and the tool calls it made and the result it returned
+// are stored in the cache.
+func cacheLLM(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM, callLLM CallLLMHandler) (result string, usage Usage, err error) {
+	key, err := getCacheKey(systemPrompt, userPrompt, tools, cfg)
+	if err != nil {
+		slog.Debug("llm.cacheLLM could not get cache key", "error", err)
+		return
+	}
+	path := filepath.Join(cfg.Cache, key+".json")
+
+	// Replay the cached interaction (if any)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var interaction CassetteInteraction
+		err = json.Unmarshal(data, &interaction)
+		if err == nil {
+			slog.Debug("llm.cacheLLM using cached interaction", "key", key)
+			result, err = replayInteraction(&interaction, tools)
+			return
+		}
+		// Treat an unreadable cache entry as a miss so it is overwritten below
+		slog.Warn("Ignoring invalid LLM cache entry", "path", path, "error", err)
+	case !errors.Is(err, fs.ErrNotExist):
+		slog.Debug("llm.cacheLLM could not read cache entry", "path", path, "error", err)
+		return
+	}
+
+	interaction, usage, err := callAndRecord(systemPrompt, userPrompt, tools, cfg, callLLM)
+	if err != nil {
+		return
+	}
+	interaction.Key = key
+	result = interaction.Result
+
+	// Failing to write to the cache should not fail the call
+	if writeErr := writeCacheEntry(cfg.Cache, key, interaction); writeErr != nil {
+		slog.Warn("Could not write LLM cache entry", "cache", cfg.Cache, "key", key, "error", writeErr)
+		return
+	}
+	slog.Debug("llm.cacheLLM cached interaction", "key", key, "toolCalls", len(interaction.ToolCalls))
+
+	return
+}
+
+// writeCacheEntry writes the interaction to the cache directory. The entry is written to a
+// temporary file and renamed so that concurrent readers never see a partial entry.
+func writeCacheEntry(cache string, key string, interaction *CassetteInteraction) error {
+	err := os.MkdirAll(cache, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(cache, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filepath.Join(cache, key+".json"))
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"hyaline/internal/config"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheLLM(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": `{"tool_calls": [{"name": "record_value", "arguments": {"value": "cached"}}]}`,
+		})
+	})
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	cfg := &config.LLM{
+		Provider:    config.LLMProviderOpenAICompatible,
+		Model:       "test-model",
+		Endpoint:    endpoint,
+		ToolCalling: config.LLMToolCallingJSON,
+		Cache:       cacheDir,
+	}
+
+	// The first call is a cache miss, and subsequent calls are served from the cache
+	for i := 0; i < 3; i++ {
+		calls := []string{}
+		_, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), cfg)
+		if err != nil {
+			t.Fatalf("call %d - expected no error, got: %v", i, err)
+		}
+		if len(calls) != 1 || calls[0] != `{"value": "cached"}` {
+			t.Errorf("call %d - expected tool to be called with the cached arguments, got: %v", i, calls)
+		}
+	}
+	if len(*requests) != 1 {
+		t.Errorf("expected 1 request to the LLM, got %d", len(*requests))
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("expected no error reading cache directory, got: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", len(entries))
+	}
+
+	// A different model is a cache miss
+	otherModel := *cfg
+	otherModel.Model = "other-model"
+	calls := []string{}
+	_, _, err = CallLLM("system prompt", "user prompt", testTools(&calls), &otherModel)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected a different model to call the LLM, got %d requests", len(*requests))
+	}
+
+	// An invalid cache entry is treated as a miss and overwritten
+	err = os.WriteFile(filepath.Join(cacheDir, entries[0].Name()), []byte("invalid"), 0644)
+	if err != nil {
+		t.Fatalf("expected no error writing cache entry, got: %v", err)
+	}
+	_, _, err = CallLLM("system prompt", "user prompt", testTools(&calls), cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("expected an invalid cache entry to call the LLM, got %d requests", len(*requests))
+	}
+	_, _, err = CallLLM("system prompt", "user prompt", testTools(&calls), cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("expected the overwritten cache entry to be used, got %d requests", len(*requests))
+	}
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hyaline/internal/config"
@@ -17,25 +18,94 @@ type Tool struct {
 	Callback func(string) (bool, string, error)
 }
 
-type CallLLMHandler func(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, error)
+type CallLLMHandler func(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, Usage, error)
 
-func CallLLM(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, err error) {
+// CallLLM calls the configured LLM, returning its result along with the tokens it used. Calls that
+// are replayed from a cassette or the cache use no tokens.
+func CallLLM(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
+	return callLLM(context.Background(), systemPrompt, userPrompt, tools, cfg)
+}
+
+// WithContext returns a handler that calls the configured LLM (see CallLLM), cancelling the call
+// once ctx is done (e.g. once the run's llm.timeout.total has elapsed, see WithTotalTimeout)
+func WithContext(ctx context.Context) CallLLMHandler {
+	return func(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, Usage, error) {
+		return callLLM(ctx, systemPrompt, userPrompt, tools, cfg)
+	}
+}
+
+// WithTotalTimeout returns a context for a run that is cancelled once llm.timeout.total (if set)
+// has elapsed
+func WithTotalTimeout(ctx context.Context, cfg *config.LLM) (context.Context, context.CancelFunc) {
+	if cfg.Timeout.Total <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, cfg.Timeout.Total, fmt.Errorf("llm.timeout.total of %s exceeded", cfg.Timeout.Total))
+}
+
+func callLLM(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
+	if cfg != nil && cfg.Replay != "" {
+		slog.Debug("Replaying LLM call", "cassette", cfg.Replay)
+		result, err = replayLLM(systemPrompt, userPrompt, tools, cfg)
+		return
+	}
+
+	provider := func(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, Usage, error) {
+		return callProvider(ctx, systemPrompt, userPrompt, tools, cfg)
+	}
+	handler := provider
+	if cfg != nil && cfg.Cache != "" {
+		handler = func(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (string, Usage, error) {
+			return cacheLLM(systemPrompt, userPrompt, tools, cfg, provider)
+		}
+	}
+	if cfg != nil && cfg.Record != "" {
+		slog.Debug("Recording LLM call", "cassette", cfg.Record)
+		return recordLLM(systemPrompt, userPrompt, tools, cfg, handler)
+	}
+
+	return handler(systemPrompt, userPrompt, tools, cfg)
+}
+
+func callProvider(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
 	if cfg == nil || cfg.Provider == "" {
 		slog.Error("llm configuration must be present to call an llm")
 		err = errors.New("llm configuration missing")
 		return
 	}
 	slog.Debug("Calling LLM", "provider", cfg.Provider, "model", cfg.Model)
+
+	ctx, cancel := newCallContext(ctx, cfg)
+	defer cancel()
+
 	switch cfg.Provider {
 	case config.LLMProviderAnthropic:
-		return callAnthropic(systemPrompt, userPrompt, tools, cfg)
+		result, usage, err = callAnthropic(ctx, systemPrompt, userPrompt, tools, cfg)
 	case config.LLMProviderOpenAI, config.LLMProviderGitHubModels:
-		return callOpenAI(systemPrompt, userPrompt, tools, cfg)
+		result, usage, err = callOpenAI(ctx, systemPrompt, userPrompt, tools, cfg)
+	case config.LLMProviderOllama, config.LLMProviderOpenAICompatible:
+		result, usage, err = callOpenAICompatible(ctx, systemPrompt, userPrompt, tools, cfg)
 	case config.LLMProviderTesting:
-		return "LLM TEST RESPONSE", nil
+		return "LLM TEST RESPONSE", Usage{}, nil
 	default:
 		err = fmt.Errorf("unsupported provider: %s", cfg.Provider)
 	}
 
+	// Report timeouts using their cause rather than the underlying request error
+	if err != nil && ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+
 	return
 }
+
+// newCallContext returns the context to use when calling the LLM, which is cancelled once either
+// llm.timeout.call has elapsed or ctx is done
+func newCallContext(ctx context.Context, cfg *config.LLM) (context.Context, context.CancelFunc) {
+	if cfg.Timeout.Call <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeoutCause(ctx, cfg.Timeout.Call, fmt.Errorf("llm.timeout.call of %s exceeded", cfg.Timeout.Call))
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/anthropics/anthropic-sdk-go/option"
 )
 
-func callAnthropic(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, err error) {
+// DefaultAnthropicMaxTokens is the maximum number of tokens to generate when llm.maxTokens is not set
+const DefaultAnthropicMaxTokens = 1024
+
+func callAnthropic(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
 	if cfg.Key == "" {
 		slog.Warn("Calling anthropic without a key being set")
 	}
@@ -20,8 +23,17 @@ func callAnthropic(systemPrompt string, userPrompt string, tools []*Tool, cfg *c
 		slog.Debug("Using custom Anthropic endpoint", "endpoint", cfg.Endpoint)
 		clientOptions = append(clientOptions, option.WithBaseURL(cfg.Endpoint))
 	}
+	if cfg.Retries != nil {
+		clientOptions = append(clientOptions, option.WithMaxRetries(*cfg.Retries))
+	}
 	client := anthropic.NewClient(clientOptions...)
 
+	// Anthropic requires max tokens to be set
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = DefaultAnthropicMaxTokens
+	}
+
 	messages := []anthropic.MessageParam{
 		anthropic.NewUserMessage(anthropic.NewTextBlock(userPrompt)),
 	}
@@ -53,9 +65,14 @@ func callAnthropic(systemPrompt string, userPrompt string, tools []*Tool, cfg *c
 	for {
 		// Call anthropic with the message(s)
 		var message *anthropic.Message
-		message, err = client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		err = waitForRateLimit(ctx, cfg)
+		if err != nil {
+			slog.Error("llm.callAnthropic errored while waiting for the rate limit", "error", err)
+			return
+		}
+		message, err = client.Messages.New(ctx, anthropic.MessageNewParams{
 			Model:      cfg.Model,
-			MaxTokens:  1024,
+			MaxTokens:  int64(maxTokens),
 			System:     []anthropic.TextBlockParam{{Text: systemPrompt}},
 			Messages:   messages,
 			Tools:      toolParams,
@@ -65,6 +82,9 @@ func callAnthropic(systemPrompt string, userPrompt string, tools []*Tool, cfg *c
 			slog.Error("llm.callAnthropic errored when sending a new message", "error", err)
 			return
 		}
+		messageUsage := anthropicUsage(message.Usage)
+		usage.Add(messageUsage)
+		recordRateLimitUsage(cfg, messageUsage.TotalTokens())
 
 		// Add new message(s) to the list
 		messages = append(messages, message.ToParam())
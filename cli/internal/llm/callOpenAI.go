@@ -10,27 +10,8 @@ import (
 	"github.com/openai/openai-go/v2/option"
 )
 
-func callOpenAI(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, err error) {
-	if cfg.Key == "" {
-		slog.Warn("Calling OpenAI without a key being set")
-	}
-	var clientOptions []option.RequestOption
-	clientOptions = append(clientOptions, option.WithAPIKey(cfg.Key))
-
-	if cfg.Provider == config.LLMProviderGitHubModels {
-		if cfg.Endpoint == "" {
-			slog.Debug("Using default GitHub Models endpoint")
-			clientOptions = append(clientOptions, option.WithBaseURL("https://models.github.ai/inference"))
-		} else {
-			slog.Debug("Using custom GitHub Models endpoint", "endpoint", cfg.Endpoint)
-			clientOptions = append(clientOptions, option.WithBaseURL(cfg.Endpoint))
-		}
-	} else if cfg.Endpoint != "" {
-		slog.Debug("Using custom OpenAI endpoint", "endpoint", cfg.Endpoint)
-		clientOptions = append(clientOptions, option.WithBaseURL(cfg.Endpoint))
-	}
-
-	client := openai.NewClient(clientOptions...)
+func callOpenAI(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
+	client := newOpenAIClient(cfg)
 
 	messages := []openai.ChatCompletionMessageParamUnion{
 		openai.UserMessage(userPrompt),
@@ -68,6 +49,10 @@ func callOpenAI(systemPrompt string, userPrompt string, tools []*Tool, cfg *conf
 			Messages: messages,
 		}
 
+		if cfg.MaxTokens > 0 {
+			params.MaxCompletionTokens = openai.Int(int64(cfg.MaxTokens))
+		}
+
 		// Add system message
 		if systemPrompt != "" {
 			params.Messages = append([]openai.ChatCompletionMessageParamUnion{
@@ -76,18 +61,29 @@ func callOpenAI(systemPrompt string, userPrompt string, tools []*Tool, cfg *conf
 		}
 
 		// Add tools if present
+		// Note: OpenAI compatible servers may not support tool_choice, so it is left unset for them
 		if len(tools) > 0 {
 			params.Tools = toolParams
-			params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
-				OfAuto: openai.String("auto"),
+			if !cfg.Provider.IsOpenAICompatible() {
+				params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+					OfAuto: openai.String("auto"),
+				}
 			}
 		}
 
-		chatCompletion, err = client.Chat.Completions.New(context.Background(), params)
+		err = waitForRateLimit(ctx, cfg)
+		if err != nil {
+			slog.Error("llm.callOpenAI errored while waiting for the rate limit", "error", err)
+			return
+		}
+		chatCompletion, err = client.Chat.Completions.New(ctx, params)
 		if err != nil {
 			slog.Error("llm.callOpenAI errored when sending a new message", "error", err)
 			return
 		}
+		completionUsage := openAIUsage(chatCompletion.Usage)
+		usage.Add(completionUsage)
+		recordRateLimitUsage(cfg, completionUsage.TotalTokens())
 
 		// Add new message to the list
 		messages = append(messages, chatCompletion.Choices[0].Message.ToParam())
@@ -148,3 +144,35 @@ func callOpenAI(systemPrompt string, userPrompt string, tools []*Tool, cfg *conf
 
 	return
 }
+
+// newOpenAIClient creates a client for the OpenAI API (or a provider exposing an OpenAI compatible API)
+func newOpenAIClient(cfg *config.LLM) openai.Client {
+	// Note: local and self-hosted servers typically do not require a key
+	if cfg.Key == "" && !cfg.Provider.IsOpenAICompatible() {
+		slog.Warn("Calling OpenAI without a key being set")
+	}
+	var clientOptions []option.RequestOption
+	clientOptions = append(clientOptions, option.WithAPIKey(cfg.Key))
+
+	if cfg.Provider == config.LLMProviderGitHubModels {
+		if cfg.Endpoint == "" {
+			slog.Debug("Using default GitHub Models endpoint")
+			clientOptions = append(clientOptions, option.WithBaseURL("https://models.github.ai/inference"))
+		} else {
+			slog.Debug("Using custom GitHub Models endpoint", "endpoint", cfg.Endpoint)
+			clientOptions = append(clientOptions, option.WithBaseURL(cfg.Endpoint))
+		}
+	} else if cfg.Provider == config.LLMProviderOllama && cfg.Endpoint == "" {
+		slog.Debug("Using default Ollama endpoint")
+		clientOptions = append(clientOptions, option.WithBaseURL(config.DefaultOllamaEndpoint))
+	} else if cfg.Endpoint != "" {
+		slog.Debug("Using custom OpenAI endpoint", "endpoint", cfg.Endpoint)
+		clientOptions = append(clientOptions, option.WithBaseURL(cfg.Endpoint))
+	}
+
+	if cfg.Retries != nil {
+		clientOptions = append(clientOptions, option.WithMaxRetries(*cfg.Retries))
+	}
+
+	return openai.NewClient(clientOptions...)
+}
@@ -0,0 +1,241 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/shared"
+)
+
+// callOpenAICompatible calls a local or self-hosted server exposing an OpenAI compatible API (such
+// as Ollama). As these servers (or the models they serve) may not support tool calling, tools can
+// instead be described in the prompt and called via a JSON response (JSON mode).
+func callOpenAICompatible(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
+	toolCalling := cfg.ToolCalling
+	if toolCalling == "" {
+		toolCalling = config.LLMToolCallingAuto
+	}
+
+	// JSON mode is only needed when there are tools to call
+	if len(tools) == 0 || toolCalling == config.LLMToolCallingNative {
+		return callOpenAI(ctx, systemPrompt, userPrompt, tools, cfg)
+	}
+	if toolCalling == config.LLMToolCallingJSON {
+		return callOpenAIJSON(ctx, systemPrompt, userPrompt, tools, cfg)
+	}
+
+	// Try native tool calling first, falling back to JSON mode if tools are not supported. Tools are
+	// tracked so that the fallback only happens if the first request failed, as falling back after a
+	// tool was called would call it again.
+	toolCalled := false
+	nativeTools := make([]*Tool, len(tools))
+	for i, tool := range tools {
+		nativeTool := *tool
+		nativeTool.Callback = func(input string) (bool, string, error) {
+			toolCalled = true
+			return tool.Callback(input)
+		}
+		nativeTools[i] = &nativeTool
+	}
+	result, usage, err = callOpenAI(ctx, systemPrompt, userPrompt, nativeTools, cfg)
+	if err != nil && !toolCalled && isToolsUnsupportedError(err) {
+		slog.Info("LLM does not support tool calling, falling back to JSON mode", "provider", cfg.Provider, "model", cfg.Model)
+		var jsonUsage Usage
+		result, jsonUsage, err = callOpenAIJSON(ctx, systemPrompt, userPrompt, tools, cfg)
+		usage.Add(jsonUsage)
+	}
+
+	return
+}
+
+var toolsUnsupportedRegex = regexp.MustCompile(`tool.*(not support|unsupported|not enabled|requires)|(not support|unsupported).*tool`)
+
+// isToolsUnsupportedError returns true if err indicates the server rejected the request because it
+// (or the model) does not support tools, rather than for some other reason (e.g. the context length
+// being exceeded)
+func isToolsUnsupportedError(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusNotImplemented:
+		return toolsUnsupportedRegex.MatchString(strings.ToLower(apiErr.Message + " " + apiErr.RawJSON()))
+	default:
+		return false
+	}
+}
+
+type jsonModeResponse struct {
+	ToolCalls []jsonModeToolCall `json:"tool_calls"`
+	Response  string             `json:"response"`
+}
+
+type jsonModeToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+func callOpenAIJSON(ctx context.Context, systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, usage Usage, err error) {
+	client := newOpenAIClient(cfg)
+
+	jsonModePrompt, err := formatJSONModePrompt(tools)
+	if err != nil {
+		slog.Debug("llm.callOpenAIJSON could not format tools", "error", err)
+		return
+	}
+	if systemPrompt != "" {
+		jsonModePrompt = systemPrompt + "\n\n" + jsonModePrompt
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(jsonModePrompt),
+		openai.UserMessage(userPrompt),
+	}
+
+	// Loop and call llm until we don't have any outstanding tool calls left OR
+	// a tool call signals that we are done
+	for {
+		var chatCompletion *openai.ChatCompletion
+		err = waitForRateLimit(ctx, cfg)
+		if err != nil {
+			slog.Error("llm.callOpenAIJSON errored while waiting for the rate limit", "error", err)
+			return
+		}
+		params := openai.ChatCompletionNewParams{
+			Model:    cfg.Model,
+			Messages: messages,
+			ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+				OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+			},
+		}
+		if cfg.MaxTokens > 0 {
+			params.MaxCompletionTokens = openai.Int(int64(cfg.MaxTokens))
+		}
+		chatCompletion, err = client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			slog.Error("llm.callOpenAIJSON errored when sending a new message", "error", err)
+			return
+		}
+		completionUsage := openAIUsage(chatCompletion.Usage)
+		usage.Add(completionUsage)
+		recordRateLimitUsage(cfg, completionUsage.TotalTokens())
+		if len(chatCompletion.Choices) == 0 {
+			err = errors.New("llm returned no choices")
+			slog.Error("llm.callOpenAIJSON received no choices", "error", err)
+			return
+		}
+		content := chatCompletion.Choices[0].Message.Content
+		messages = append(messages, openai.AssistantMessage(content))
+
+		var response *jsonModeResponse
+		response, err = parseJSONModeResponse(content)
+		if err != nil {
+			slog.Error("llm.callOpenAIJSON received an invalid JSON response", "content", content, "error", err)
+			return
+		}
+		if response.Response != "" {
+			result = response.Response
+		}
+
+		// Initialize done sentinel to false
+		// This is used to short-circuit the call loop in cases where a tool call
+		// signals that we should stop.
+		done := false
+
+		// Handle tool calls
+		toolResults := []string{}
+		for _, toolCall := range response.ToolCalls {
+			// Get our tool using the function name
+			tool := getTool(toolCall.Name, tools)
+			if tool == nil {
+				err = fmt.Errorf("invalid tool name received: %s", toolCall.Name)
+				slog.Error("llm.callOpenAIJSON received an invalid tool name", "name", toolCall.Name, "error", err)
+				return
+			}
+
+			// Call the tool
+			arguments := string(toolCall.Arguments)
+			if arguments == "" || arguments == "null" {
+				arguments = "{}"
+			}
+			slog.Debug("llm.callOpenAIJSON invoking tool", "tool", toolCall.Name)
+			stop, toolResponse, toolErr := tool.Callback(arguments)
+
+			// Handle if the tool requests that we stop now rather than loop
+			if stop {
+				done = true
+			}
+
+			// Handle if there was an error
+			if toolErr != nil {
+				slog.Error("llm.callOpenAIJSON received a tool error", "tool", toolCall.Name, "error", toolErr)
+				toolResponse = fmt.Sprintf("Error: %v", toolErr)
+			}
+
+			toolResults = append(toolResults, fmt.Sprintf("<tool_result>\n<name>%s</name>\n<result>%s</result>\n</tool_result>", toolCall.Name, toolResponse))
+		}
+
+		// If we don't have any tool results OR a tool said stop, we are done
+		if len(toolResults) == 0 || done {
+			break
+		}
+
+		// Add tool results as a message and continue
+		messages = append(messages, openai.UserMessage(strings.Join(toolResults, "\n")))
+	}
+
+	return
+}
+
+// formatJSONModePrompt describes the available tools and how to call them using a JSON response
+func formatJSONModePrompt(tools []*Tool) (string, error) {
+	var prompt strings.Builder
+	prompt.WriteString("You have access to the following tools. ")
+	prompt.WriteString("Always respond with a single JSON object (and nothing else) in the form ")
+	prompt.WriteString(`{"tool_calls": [{"name": "<tool name>", "arguments": {<arguments matching the tool's input_schema>}}], "response": "<optional text response>"}. `)
+	prompt.WriteString(`If you do not need to call a tool respond with an empty list of tool_calls. `)
+	prompt.WriteString("The results of any tool calls will be provided to you in <tool_result> tags.\n\n")
+
+	prompt.WriteString("<tools>\n")
+	for _, tool := range tools {
+		schema, err := json.Marshal(tool.Schema)
+		if err != nil {
+			return "", err
+		}
+		prompt.WriteString("<tool>\n")
+		fmt.Fprintf(&prompt, "<name>%s</name>\n", tool.Name)
+		fmt.Fprintf(&prompt, "<description>%s</description>\n", tool.Description)
+		fmt.Fprintf(&prompt, "<input_schema>%s</input_schema>\n", schema)
+		prompt.WriteString("</tool>\n")
+	}
+	prompt.WriteString("</tools>")
+
+	return prompt.String(), nil
+}
+
+// parseJSONModeResponse parses a JSON mode response, tolerating markdown code fences or text
+// surrounding the JSON object
+func parseJSONModeResponse(content string) (*jsonModeResponse, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end < start {
+		return nil, errors.New("response does not contain a JSON object")
+	}
+
+	var response jsonModeResponse
+	err := json.Unmarshal([]byte(content[start:end+1]), &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,305 @@
+package llm
+
+import (
+	"encoding/json"
+	"hyaline/internal/config"
+	"hyaline/internal/tool"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testToolSchema struct {
+	Value string `json:"value"`
+}
+
+// startFakeOpenAICompatible starts a fake OpenAI compatible server that responds to each chat
+// completion request using the handler, and records the requests it received
+func startFakeOpenAICompatible(t *testing.T, handler func(request map[string]interface{}) (int, interface{})) (*[]map[string]interface{}, string) {
+	requests := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		request := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		requests = append(requests, request)
+
+		status, body := handler(request)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &requests, server.URL + "/v1"
+}
+
+func chatCompletion(message map[string]interface{}) map[string]interface{} {
+	message["role"] = "assistant"
+	return map[string]interface{}{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   "test-model",
+		"choices": []map[string]interface{}{
+			{"index": 0, "finish_reason": "stop", "message": message},
+		},
+	}
+}
+
+func testTools(calls *[]string) []*Tool {
+	return []*Tool{
+		{
+			Name:        "record_value",
+			Description: "Record a value",
+			Schema:      tool.Reflector.Reflect(&testToolSchema{}),
+			Callback: func(input string) (bool, string, error) {
+				*calls = append(*calls, input)
+				return true, "", nil
+			},
+		},
+	}
+}
+
+func TestCallOpenAICompatibleJSON(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": "```json\n{\"tool_calls\": [{\"name\": \"record_value\", \"arguments\": {\"value\": \"foo\"}}]}\n```",
+		})
+	})
+
+	calls := []string{}
+	_, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider:    config.LLMProviderOpenAICompatible,
+		Model:       "test-model",
+		Endpoint:    endpoint,
+		ToolCalling: config.LLMToolCallingJSON,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0] != `{"value": "foo"}` {
+		t.Errorf("expected tool to be called with the parsed arguments, got: %v", calls)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*requests))
+	}
+	request := (*requests)[0]
+	if _, ok := request["tools"]; ok {
+		t.Errorf("expected JSON mode request to not include tools")
+	}
+	responseFormat, _ := request["response_format"].(map[string]interface{})
+	if responseFormat["type"] != "json_object" {
+		t.Errorf("expected JSON mode request to use the json_object response format, got: %v", request["response_format"])
+	}
+	messages, _ := request["messages"].([]interface{})
+	systemMessage, _ := messages[0].(map[string]interface{})
+	if content, _ := systemMessage["content"].(string); !strings.HasPrefix(content, "system prompt") || !strings.Contains(content, "<name>record_value</name>") {
+		t.Errorf("expected system message to describe the tools, got: %v", systemMessage["content"])
+	}
+}
+
+func TestCallOpenAICompatibleAutoFallback(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		if _, ok := request["tools"]; ok {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]interface{}{"message": "test-model does not support tools"},
+			}
+		}
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": `{"tool_calls": [{"name": "record_value", "arguments": {"value": "bar"}}]}`,
+		})
+	})
+
+	calls := []string{}
+	_, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider: config.LLMProviderOllama,
+		Model:    "test-model",
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("expected 2 requests (native then JSON mode), got %d", len(*requests))
+	}
+	if len(calls) != 1 || calls[0] != `{"value": "bar"}` {
+		t.Errorf("expected tool to be called with the parsed arguments, got: %v", calls)
+	}
+}
+
+func TestCallOpenAICompatibleAutoFallback_SumsUsage(t *testing.T) {
+	_, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		if _, ok := request["tools"]; ok {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]interface{}{"message": "tools are not supported"},
+			}
+		}
+		completion := chatCompletion(map[string]interface{}{"content": `{"tool_calls": [], "response": "done"}`})
+		completion["usage"] = map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		return http.StatusOK, completion
+	})
+
+	calls := []string{}
+	result, usage, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider: config.LLMProviderOllama,
+		Model:    "test-model",
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if result != "done" {
+		t.Errorf("expected the JSON mode response, got: %s", result)
+	}
+	if usage.InputTokens != 10 || usage.OutputTokens != 5 {
+		t.Errorf("expected usage to include the JSON mode request, got: %v", usage)
+	}
+}
+
+func TestCallOpenAICompatibleAutoFallback_NotToolsUnsupported(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]interface{}{"message": "the request exceeds the available context size"},
+		}
+	})
+
+	calls := []string{}
+	retries := 0
+	_, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider: config.LLMProviderOllama,
+		Model:    "test-model",
+		Endpoint: endpoint,
+		Retries:  &retries,
+	})
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if len(*requests) != 1 {
+		t.Errorf("expected 1 request (no fallback), got %d", len(*requests))
+	}
+}
+
+func TestCallOpenAICompatibleAutoFallback_AfterToolCall(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		messages, _ := request["messages"].([]interface{})
+		if len(messages) > 2 {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]interface{}{"message": "test-model does not support tools"},
+			}
+		}
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": "",
+			"tool_calls": []map[string]interface{}{
+				{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]interface{}{"name": "record_value", "arguments": `{"value":"baz"}`},
+				},
+			},
+		})
+	})
+
+	calls := []string{}
+	tools := testTools(&calls)
+	callback := tools[0].Callback
+	tools[0].Callback = func(input string) (bool, string, error) {
+		_, response, err := callback(input)
+		return false, response, err
+	}
+	retries := 0
+	_, _, err := CallLLM("system prompt", "user prompt", tools, &config.LLM{
+		Provider: config.LLMProviderOllama,
+		Model:    "test-model",
+		Endpoint: endpoint,
+		Retries:  &retries,
+	})
+	if err == nil {
+		t.Fatalf("expected an error, got none")
+	}
+	if len(*requests) != 2 {
+		t.Errorf("expected 2 requests (no fallback after a tool was called), got %d", len(*requests))
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected tool to be called once, got: %v", calls)
+	}
+}
+
+func TestCallOpenAICompatibleNative(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": "",
+			"tool_calls": []map[string]interface{}{
+				{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]interface{}{"name": "record_value", "arguments": `{"value":"baz"}`},
+				},
+			},
+		})
+	})
+
+	calls := []string{}
+	_, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider: config.LLMProviderOllama,
+		Model:    "test-model",
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0] != `{"value":"baz"}` {
+		t.Errorf("expected tool to be called with its arguments, got: %v", calls)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*requests))
+	}
+	if _, ok := (*requests)[0]["tool_choice"]; ok {
+		t.Errorf("expected request to not force a tool choice")
+	}
+}
+
+func TestParseJSONModeResponse(t *testing.T) {
+	var tests = []struct {
+		content   string
+		toolCalls int
+		response  string
+		err       bool
+	}{
+		{`{"tool_calls": []}`, 0, "", false},
+		{`{"tool_calls": [], "response": "done"}`, 0, "done", false},
+		{"```json\n{\"tool_calls\": [{\"name\": \"a\", \"arguments\": {}}]}\n```", 1, "", false},
+		{`Here you go: {"tool_calls": [{"name": "a"}, {"name": "b"}]}`, 2, "", false},
+		{`no json here`, 0, "", true},
+		{`{"tool_calls": "invalid"}`, 0, "", true},
+	}
+
+	for i, test := range tests {
+		response, err := parseJSONModeResponse(test.content)
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d - expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if len(response.ToolCalls) != test.toolCalls {
+			t.Errorf("test %d - expected %d tool calls, got %d", i, test.toolCalls, len(response.ToolCalls))
+		}
+		if response.Response != test.response {
+			t.Errorf("test %d - expected response %q, got %q", i, test.response, response.Response)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"hyaline/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// startFakeProvider starts a fake LLM provider server that fails the first failures requests with
+// status (setting the Retry-After header) and then responds with body
+func startFakeProvider(t *testing.T, failures int32, status int, body interface{}) (*atomic.Int32, *[]map[string]interface{}, string) {
+	var count atomic.Int32
+	requests := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		w.Header().Set("Content-Type", "application/json")
+		if count.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"error": {"message": "try again", "type": "rate_limit_error"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &count, &requests, server.URL
+}
+
+func anthropicMessage(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          "msg_test",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       "test-model",
+		"content":     []map[string]interface{}{{"type": "text", "text": text}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]interface{}{"input_tokens": 10, "output_tokens": 5},
+	}
+}
+
+func TestCallLLMRetries(t *testing.T) {
+	var tests = []struct {
+		provider config.LLMProvider
+		status   int
+		failures int32
+		retries  *int
+		err      bool
+	}{
+		{config.LLMProviderAnthropic, http.StatusTooManyRequests, 2, nil, false},
+		{config.LLMProviderAnthropic, http.StatusTooManyRequests, 1, intPtr(0), true},
+		{config.LLMProviderAnthropic, http.StatusServiceUnavailable, 3, intPtr(3), false},
+		{config.LLMProviderOpenAI, http.StatusTooManyRequests, 2, nil, false},
+		{config.LLMProviderOpenAI, http.StatusInternalServerError, 1, intPtr(0), true},
+		{config.LLMProviderOpenAI, http.StatusBadGateway, 3, intPtr(3), false},
+	}
+
+	for i, test := range tests {
+		var body interface{} = anthropicMessage("done")
+		if test.provider == config.LLMProviderOpenAI {
+			body = chatCompletion(map[string]interface{}{"content": "done"})
+		}
+		count, _, endpoint := startFakeProvider(t, test.failures, test.status, body)
+		if test.provider == config.LLMProviderOpenAI {
+			endpoint += "/v1"
+		}
+
+		result, _, err := CallLLM("system prompt", "user prompt", nil, &config.LLM{
+			Provider: test.provider,
+			Model:    "test-model",
+			Key:      "test",
+			Endpoint: endpoint,
+			Retries:  test.retries,
+		})
+		if test.err {
+			if err == nil {
+				t.Errorf("test %d - expected error, got none", i)
+			}
+			if count.Load() != test.failures {
+				t.Errorf("test %d - expected %d requests, got %d", i, test.failures, count.Load())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if result != "done" {
+			t.Errorf("test %d - expected result done, got %s", i, result)
+		}
+		if count.Load() != test.failures+1 {
+			t.Errorf("test %d - expected %d requests, got %d", i, test.failures+1, count.Load())
+		}
+	}
+}
+
+func TestCallLLMMaxTokens(t *testing.T) {
+	var tests = []struct {
+		provider  config.LLMProvider
+		maxTokens int
+		field     string
+		expected  interface{}
+	}{
+		{config.LLMProviderAnthropic, 0, "max_tokens", float64(DefaultAnthropicMaxTokens)},
+		{config.LLMProviderAnthropic, 4096, "max_tokens", float64(4096)},
+		{config.LLMProviderOpenAI, 0, "max_completion_tokens", nil},
+		{config.LLMProviderOpenAI, 2048, "max_completion_tokens", float64(2048)},
+	}
+
+	for i, test := range tests {
+		var body interface{} = anthropicMessage("done")
+		if test.provider == config.LLMProviderOpenAI {
+			body = chatCompletion(map[string]interface{}{"content": "done"})
+		}
+		_, requests, endpoint := startFakeProvider(t, 0, http.StatusOK, body)
+		if test.provider == config.LLMProviderOpenAI {
+			endpoint += "/v1"
+		}
+
+		_, _, err := CallLLM("system prompt", "user prompt", nil, &config.LLM{
+			Provider:  test.provider,
+			Model:     "test-model",
+			Key:       "test",
+			Endpoint:  endpoint,
+			MaxTokens: test.maxTokens,
+		})
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if len(*requests) != 1 {
+			t.Errorf("test %d - expected 1 request, got %d", i, len(*requests))
+			continue
+		}
+		if (*requests)[0][test.field] != test.expected {
+			t.Errorf("test %d - expected %s to be %v, got %v", i, test.field, test.expected, (*requests)[0][test.field])
+		}
+	}
+}
+
+func TestCallLLMTimeout(t *testing.T) {
+	// The fake server never responds (until the test completes)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	var tests = []struct {
+		timeout config.LLMTimeout
+		err     string
+	}{
+		{config.LLMTimeout{Call: 50 * time.Millisecond}, "llm.timeout.call of 50ms exceeded"},
+		{config.LLMTimeout{Total: 50 * time.Millisecond}, "llm.timeout.total of 50ms exceeded"},
+		{config.LLMTimeout{Call: time.Minute, Total: 50 * time.Millisecond}, "llm.timeout.total of 50ms exceeded"},
+	}
+
+	for i, test := range tests {
+		cfg := &config.LLM{
+			Provider: config.LLMProviderAnthropic,
+			Model:    "test-model",
+			Key:      "test",
+			Endpoint: server.URL,
+			Retries:  intPtr(0),
+			Timeout:  test.timeout,
+		}
+		ctx, cancel := WithTotalTimeout(context.Background(), cfg)
+		start := time.Now()
+		_, _, err := WithContext(ctx)("system prompt", "user prompt", nil, cfg)
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("test %d - expected error: %s, got: %v", i, test.err, err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("test %d - expected call to time out promptly, took %s", i, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Cassette contains recorded LLM interactions that can be replayed later (so that calls are
+// deterministic and do not require network access)
+type Cassette struct {
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// CassetteInteraction is a single recorded LLM call, keyed by a hash of the system prompt, user
+// prompt, and tools that were sent to the LLM. Embeddings requests are recorded with their
+// Embeddings, keyed by a hash of the texts that were embedded (see getEmbeddingsKey).
+type CassetteInteraction struct {
+	Key        string             `json:"key"`
+	Provider   string             `json:"provider,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	ToolCalls  []CassetteToolCall `json:"toolCalls"`
+	Result     string             `json:"result"`
+	Embeddings [][]float64        `json:"embeddings,omitempty"`
+}
+
+// CassetteToolCall is a tool call made by the LLM, along with the JSON input it was called with
+type CassetteToolCall struct {
+	Name  string `json:"name"`
+	Input string `json:"input"`
+}
+
+// cassetteMutex guards reading and writing cassette files
+var cassetteMutex sync.Mutex
+
+// getCassetteKey returns the key identifying an LLM call, which is a hash of the system prompt,
+// user prompt, and the name, description, and schema of each tool
+func getCassetteKey(systemPrompt string, userPrompt string, tools []*Tool) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(systemPrompt))
+	hash.Write([]byte{0})
+	hash.Write([]byte(userPrompt))
+	for _, tool := range tools {
+		schema, err := json.Marshal(tool.Schema)
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte{0})
+		hash.Write([]byte(tool.Name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(tool.Description))
+		hash.Write([]byte{0})
+		hash.Write(schema)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// loadCassette loads the cassette at path. If the cassette does not exist an empty cassette is
+// returned.
+func loadCassette(path string) (*Cassette, error) {
+	cassette := &Cassette{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cassette, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, cassette)
+	if err != nil {
+		return nil, err
+	}
+
+	return cassette, nil
+}
+
+// saveCassette writes the cassette to path, ordering interactions by key so that the file is
+// stable across runs
+func saveCassette(path string, cassette *Cassette) error {
+	slices.SortFunc(cassette.Interactions, func(a, b CassetteInteraction) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordLLM calls the LLM using callLLM and records the tool calls it made and the result it
+// returned to the cassette at cfg.Record
+func recordLLM(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM, callLLM CallLLMHandler) (result string, usage Usage, err error) {
+	key, err := getCassetteKey(systemPrompt, userPrompt, tools)
+	if err != nil {
+		slog.Debug("llm.recordLLM could not get cassette key", "error", err)
+		return
+	}
+
+	interaction, usage, err := callAndRecord(systemPrompt, userPrompt, tools, cfg, callLLM)
+	if err != nil {
+		return
+	}
+	interaction.Key = key
+	result = interaction.Result
+
+	err = recordInteraction(cfg.Record, interaction)
+	if err != nil {
+		return
+	}
+	slog.Debug("llm.recordLLM recorded interaction", "cassette", cfg.Record, "key", key, "toolCalls", len(interaction.ToolCalls))
+
+	return
+}
+
+// recordInteraction adds the interaction to the cassette at path, replacing any interaction
+// previously recorded with the same key
+func recordInteraction(path string, interaction *CassetteInteraction) error {
+	cassetteMutex.Lock()
+	defer cassetteMutex.Unlock()
+
+	cassette, err := loadCassette(path)
+	if err != nil {
+		slog.Debug("llm.recordInteraction could not load cassette", "cassette", path, "error", err)
+		return err
+	}
+	index := slices.IndexFunc(cassette.Interactions, func(i CassetteInteraction) bool {
+		return i.Key == interaction.Key
+	})
+	if index == -1 {
+		cassette.Interactions = append(cassette.Interactions, *interaction)
+	} else {
+		cassette.Interactions[index] = *interaction
+	}
+
+	err = saveCassette(path, cassette)
+	if err != nil {
+		slog.Debug("llm.recordInteraction could not save cassette", "cassette", path, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// replayLLM replays a call recorded in the cassette at cfg.Replay, passing each recorded tool call
+// back through its tool's Callback and returning the recorded result
+func replayLLM(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM) (result string, err error) {
+	key, err := getCassetteKey(systemPrompt, userPrompt, tools)
+	if err != nil {
+		slog.Debug("llm.replayLLM could not get cassette key", "error", err)
+		return
+	}
+
+	cassetteMutex.Lock()
+	cassette, err := loadCassette(cfg.Replay)
+	cassetteMutex.Unlock()
+	if err != nil {
+		slog.Debug("llm.replayLLM could not load cassette", "cassette", cfg.Replay, "error", err)
+		return
+	}
+
+	index := slices.IndexFunc(cassette.Interactions, func(i CassetteInteraction) bool {
+		return i.Key == key
+	})
+	if index == -1 {
+		err = fmt.Errorf("no recorded interaction found in %s for key %s", cfg.Replay, key)
+		slog.Debug("llm.replayLLM could not find interaction", "error", err)
+		return
+	}
+
+	return replayInteraction(&cassette.Interactions[index], tools)
+}
+
+// callAndRecord calls the LLM using callLLM, recording each tool call made by the LLM along with
+// the result it returned (along with the tokens used)
+func callAndRecord(systemPrompt string, userPrompt string, tools []*Tool, cfg *config.LLM, callLLM CallLLMHandler) (*CassetteInteraction, Usage, error) {
+	// Wrap each tool so that we record the calls made to it
+	var toolCallsMutex sync.Mutex
+	toolCalls := []CassetteToolCall{}
+	recordingTools := make([]*Tool, 0, len(tools))
+	for _, tool := range tools {
+		callback := tool.Callback
+		recordingTool := *tool
+		recordingTool.Callback = func(input string) (bool, string, error) {
+			toolCallsMutex.Lock()
+			toolCalls = append(toolCalls, CassetteToolCall{
+				Name:  recordingTool.Name,
+				Input: input,
+			})
+			toolCallsMutex.Unlock()
+			return callback(input)
+		}
+		recordingTools = append(recordingTools, &recordingTool)
+	}
+
+	result, usage, err := callLLM(systemPrompt, userPrompt, recordingTools, cfg)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return &CassetteInteraction{
+		Provider:  cfg.Provider.String(),
+		Model:     cfg.Model,
+		ToolCalls: toolCalls,
+		Result:    result,
+	}, usage, nil
+}
+
+// replayInteraction passes each tool call in the interaction back through its tool's Callback and
+// returns the recorded result
+func replayInteraction(interaction *CassetteInteraction, tools []*Tool) (string, error) {
+	for _, toolCall := range interaction.ToolCalls {
+		tool := getTool(toolCall.Name, tools)
+		if tool == nil {
+			err := fmt.Errorf("invalid tool name recorded: %s", toolCall.Name)
+			slog.Debug("llm.replayInteraction found an invalid tool name", "name", toolCall.Name, "error", err)
+			return "", err
+		}
+
+		slog.Debug("llm.replayInteraction invoking tool", "tool", toolCall.Name)
+		_, _, toolErr := tool.Callback(toolCall.Input)
+		if toolErr != nil {
+			slog.Error("llm.replayInteraction received a tool error", "tool", toolCall.Name, "error", toolErr)
+		}
+	}
+
+	return interaction.Result, nil
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"hyaline/internal/config"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	requests, endpoint := startFakeOpenAICompatible(t, func(request map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, chatCompletion(map[string]interface{}{
+			"content": `{"tool_calls": [{"name": "record_value", "arguments": {"value": "recorded"}}], "response": "done"}`,
+		})
+	})
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+
+	// Record
+	calls := []string{}
+	result, _, err := CallLLM("system prompt", "user prompt", testTools(&calls), &config.LLM{
+		Provider:    config.LLMProviderOpenAICompatible,
+		Model:       "test-model",
+		Endpoint:    endpoint,
+		ToolCalling: config.LLMToolCallingJSON,
+		Record:      cassettePath,
+	})
+	if err != nil {
+		t.Fatalf("expected no error recording, got: %v", err)
+	}
+	if result != "done" || len(calls) != 1 {
+		t.Fatalf("expected recording to call the LLM, got result %q and calls %v", result, calls)
+	}
+
+	cassette, err := loadCassette(cassettePath)
+	if err != nil {
+		t.Fatalf("expected no error loading cassette, got: %v", err)
+	}
+	if len(cassette.Interactions) != 1 || len(cassette.Interactions[0].ToolCalls) != 1 {
+		t.Fatalf("expected 1 interaction with 1 tool call, got: %+v", cassette.Interactions)
+	}
+
+	// Replay (the fake server should not be called again)
+	replayCalls := []string{}
+	result, _, err = CallLLM("system prompt", "user prompt", testTools(&replayCalls), &config.LLM{
+		Replay: cassettePath,
+	})
+	if err != nil {
+		t.Fatalf("expected no error replaying, got: %v", err)
+	}
+	if result != "done" {
+		t.Errorf("expected replayed result %q, got %q", "done", result)
+	}
+	if len(replayCalls) != 1 || replayCalls[0] != calls[0] {
+		t.Errorf("expected replayed tool calls %v, got %v", calls, replayCalls)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("expected 1 request to the LLM, got %d", len(*requests))
+	}
+
+	// Replaying a call that was not recorded fails
+	_, _, err = CallLLM("system prompt", "a different user prompt", testTools(&replayCalls), &config.LLM{
+		Replay: cassettePath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction found") {
+		t.Errorf("expected missing interaction error, got: %v", err)
+	}
+}
+
+func TestGetCassetteKey(t *testing.T) {
+	calls := []string{}
+	base, err := getCassetteKey("system", "user", testTools(&calls))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var tests = []struct {
+		systemPrompt string
+		userPrompt   string
+		tools        []*Tool
+		same         bool
+	}{
+		{"system", "user", testTools(&calls), true},
+		{"system", "user changed", testTools(&calls), false},
+		{"system changed", "user", testTools(&calls), false},
+		{"system", "user", nil, false},
+		{"systemuser", "", testTools(&calls), false},
+	}
+
+	for i, test := range tests {
+		key, err := getCassetteKey(test.systemPrompt, test.userPrompt, test.tools)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if (key == base) != test.same {
+			t.Errorf("test %d - expected same key to be %t", i, test.same)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hyaline/internal/config"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/openai/openai-go/v2"
+)
+
+// embedBatchSize is the maximum number of texts to embed in a single request
+const embedBatchSize = 100
+
+// EmbedHandler returns an embedding for each text along with the tokens used. llmCfg holds the
+// record, replay, cache, retry, and timeout settings that apply to the request.
+type EmbedHandler func(texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) ([][]float64, Usage, error)
+
+// Embed returns an embedding for each text using the configured embeddings provider (which must
+// expose an OpenAI compatible embeddings API). Like CallLLM, requests are recorded, replayed, and
+// cached per llmCfg, and requests that are replayed or cached use no tokens.
+func Embed(texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) ([][]float64, Usage, error) {
+	return embed(context.Background(), texts, cfg, llmCfg)
+}
+
+// EmbedWithContext returns a handler that embeds texts (see Embed), cancelling the request once
+// ctx is done (e.g. once the run's llm.timeout.total has elapsed, see WithTotalTimeout)
+func EmbedWithContext(ctx context.Context) EmbedHandler {
+	return func(texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) ([][]float64, Usage, error) {
+		return embed(ctx, texts, cfg, llmCfg)
+	}
+}
+
+func embed(ctx context.Context, texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) (embeddings [][]float64, usage Usage, err error) {
+	if llmCfg == nil {
+		llmCfg = &config.LLM{}
+	}
+	key := getEmbeddingsKey(texts, cfg)
+
+	if llmCfg.Replay != "" {
+		slog.Debug("Replaying embeddings request", "cassette", llmCfg.Replay)
+		embeddings, err = replayEmbeddings(key, llmCfg)
+		return
+	}
+
+	if llmCfg.Cache != "" {
+		path := filepath.Join(llmCfg.Cache, key+".json")
+		var interaction CassetteInteraction
+		var data []byte
+		data, err = os.ReadFile(path)
+		switch {
+		case err == nil:
+			err = json.Unmarshal(data, &interaction)
+			if err == nil && len(interaction.Embeddings) == len(texts) {
+				slog.Debug("llm.embed using cached embeddings", "key", key)
+				embeddings = interaction.Embeddings
+				return
+			}
+			// Treat an unreadable cache entry as a miss so it is overwritten below
+			slog.Warn("Ignoring invalid embeddings cache entry", "path", path, "error", err)
+		case !errors.Is(err, fs.ErrNotExist):
+			slog.Debug("llm.embed could not read cache entry", "path", path, "error", err)
+			return
+		}
+	}
+
+	embeddings, usage, err = embedProvider(ctx, texts, cfg, llmCfg)
+	if err != nil {
+		return
+	}
+	interaction := &CassetteInteraction{
+		Key:        key,
+		Provider:   cfg.Provider.String(),
+		Model:      cfg.Model,
+		Embeddings: embeddings,
+	}
+
+	// Failing to write to the cache should not fail the request
+	if llmCfg.Cache != "" {
+		if writeErr := writeCacheEntry(llmCfg.Cache, key, interaction); writeErr != nil {
+			slog.Warn("Could not write embeddings cache entry", "cache", llmCfg.Cache, "key", key, "error", writeErr)
+		} else {
+			slog.Debug("llm.embed cached embeddings", "key", key)
+		}
+	}
+
+	if llmCfg.Record != "" {
+		slog.Debug("Recording embeddings request", "cassette", llmCfg.Record)
+		err = recordInteraction(llmCfg.Record, interaction)
+		if err != nil {
+			slog.Debug("llm.embed could not record embeddings", "cassette", llmCfg.Record, "error", err)
+			return
+		}
+	}
+
+	return
+}
+
+// getEmbeddingsKey returns the key identifying an embeddings request in a cassette or the cache,
+// which is a hash of the embeddings provider, model, and texts
+func getEmbeddingsKey(texts []string, cfg *config.CheckOptionsRelevanceEmbeddings) string {
+	hash := sha256.New()
+	hash.Write([]byte("embeddings"))
+	hash.Write([]byte{0})
+	hash.Write([]byte(cfg.Provider.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(cfg.Model))
+	for _, text := range texts {
+		hash.Write([]byte{0})
+		hash.Write([]byte(text))
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// replayEmbeddings returns the embeddings recorded for key in the cassette at llmCfg.Replay
+func replayEmbeddings(key string, llmCfg *config.LLM) ([][]float64, error) {
+	cassetteMutex.Lock()
+	cassette, err := loadCassette(llmCfg.Replay)
+	cassetteMutex.Unlock()
+	if err != nil {
+		slog.Debug("llm.replayEmbeddings could not load cassette", "cassette", llmCfg.Replay, "error", err)
+		return nil, err
+	}
+
+	index := slices.IndexFunc(cassette.Interactions, func(i CassetteInteraction) bool {
+		return i.Key == key
+	})
+	if index == -1 {
+		err = fmt.Errorf("no recorded embeddings found in %s for key %s", llmCfg.Replay, key)
+		slog.Debug("llm.replayEmbeddings could not find interaction", "error", err)
+		return nil, err
+	}
+
+	return cassette.Interactions[index].Embeddings, nil
+}
+
+// embedProvider requests the embeddings from the embeddings provider in batches
+func embedProvider(ctx context.Context, texts []string, cfg *config.CheckOptionsRelevanceEmbeddings, llmCfg *config.LLM) (embeddings [][]float64, usage Usage, err error) {
+	client := newOpenAIClient(&config.LLM{
+		Provider: cfg.Provider,
+		Model:    cfg.Model,
+		Key:      cfg.Key,
+		Endpoint: cfg.Endpoint,
+		Retries:  llmCfg.Retries,
+	})
+	slog.Debug("Calling embeddings provider", "provider", cfg.Provider, "model", cfg.Model, "texts", len(texts))
+
+	ctx, cancel := newCallContext(ctx, llmCfg)
+	defer cancel()
+
+	embeddings = make([][]float64, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+
+		var response *openai.CreateEmbeddingResponse
+		response, err = client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+			Model: cfg.Model,
+			Input: openai.EmbeddingNewParamsInputUnion{
+				OfArrayOfStrings: texts[start:end],
+			},
+		})
+		if err != nil {
+			// Report timeouts using their cause rather than the underlying request error
+			if ctx.Err() != nil {
+				err = context.Cause(ctx)
+			}
+			slog.Debug("llm.embedProvider errored when creating embeddings", "error", err)
+			return nil, usage, err
+		}
+		usage.InputTokens += response.Usage.PromptTokens
+
+		for _, embedding := range response.Data {
+			index := start + int(embedding.Index)
+			if embedding.Index < 0 || index >= end {
+				err = fmt.Errorf("embeddings provider returned an invalid index: %d", embedding.Index)
+				slog.Debug("llm.embedProvider received an invalid index", "error", err)
+				return nil, usage, err
+			}
+			embeddings[index] = embedding.Embedding
+		}
+	}
+
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			err = fmt.Errorf("embeddings provider did not return an embedding for text %d", i)
+			slog.Debug("llm.embedProvider is missing an embedding", "error", err)
+			return nil, usage, err
+		}
+	}
+
+	return
+}
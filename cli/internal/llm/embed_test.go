@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"encoding/json"
+	"hyaline/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// startFakeEmbeddings starts a fake embeddings API that embeds each text as its length, returning
+// the number of requests made to it along with its endpoint
+func startFakeEmbeddings(t *testing.T) (*int, string) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var request struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		data := []map[string]interface{}{}
+		for i, input := range request.Input {
+			data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": []float64{float64(len(input)), 1}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"model":  "test-embed",
+			"data":   data,
+			"usage":  map[string]interface{}{"prompt_tokens": len(request.Input), "total_tokens": len(request.Input)},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return &requests, server.URL
+}
+
+func TestEmbedRecordReplayCache(t *testing.T) {
+	requests, endpoint := startFakeEmbeddings(t)
+	cfg := &config.CheckOptionsRelevanceEmbeddings{
+		Provider: config.LLMProviderOpenAICompatible,
+		Model:    "test-embed",
+		Endpoint: endpoint,
+	}
+	texts := []string{"a", "bb", "ccc"}
+	expected := [][]float64{{1, 1}, {2, 1}, {3, 1}}
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	// Record (and cache)
+	embeddings, usage, err := Embed(texts, cfg, &config.LLM{Record: cassettePath, Cache: cacheDir})
+	if err != nil {
+		t.Fatalf("expected no error recording, got: %v", err)
+	}
+	if !reflect.DeepEqual(embeddings, expected) {
+		t.Errorf("expected embeddings %v, got %v", expected, embeddings)
+	}
+	if usage.InputTokens != 3 {
+		t.Errorf("expected 3 input tokens, got %d", usage.InputTokens)
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d (error: %v)", len(entries), err)
+	}
+
+	// Cached and replayed requests do not call the embeddings provider or use any tokens
+	embeddings, usage, err = Embed(texts, cfg, &config.LLM{Cache: cacheDir})
+	if err != nil || !reflect.DeepEqual(embeddings, expected) || !usage.IsZero() {
+		t.Errorf("expected cached embeddings %v with no usage, got %v and %+v (error: %v)", expected, embeddings, usage, err)
+	}
+	embeddings, usage, err = Embed(texts, cfg, &config.LLM{Replay: cassettePath})
+	if err != nil || !reflect.DeepEqual(embeddings, expected) || !usage.IsZero() {
+		t.Errorf("expected replayed embeddings %v with no usage, got %v and %+v (error: %v)", expected, embeddings, usage, err)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request to the embeddings provider, got %d", *requests)
+	}
+
+	// Replaying texts that were not recorded fails
+	_, _, err = Embed([]string{"dddd"}, cfg, &config.LLM{Replay: cassettePath})
+	if err == nil || !strings.Contains(err.Error(), "no recorded embeddings found") {
+		t.Errorf("expected missing embeddings error, got: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"hyaline/internal/config"
+	"sync"
+	"sync/atomic"
+)
+
+// Pool runs tasks that call the LLM concurrently, with at most llm.concurrency tasks running at
+// the same time (defaulting to 1, which runs tasks sequentially)
+type Pool struct {
+	concurrency int
+}
+
+func NewPool(cfg *config.LLM) *Pool {
+	concurrency := 1
+	if cfg != nil && cfg.Concurrency > 0 {
+		concurrency = cfg.Concurrency
+	}
+
+	return &Pool{
+		concurrency: concurrency,
+	}
+}
+
+// Run runs each task and waits for them to complete. If any task fails, tasks that have not yet
+// started are skipped and the error of the first failed task (in task order) is returned.
+func (p *Pool) Run(tasks []func() error) error {
+	errs := make([]error, len(tasks))
+	var failed atomic.Bool
+
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		sem <- struct{}{}
+		if failed.Load() {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = task()
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
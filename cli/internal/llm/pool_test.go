@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"errors"
+	"hyaline/internal/config"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolRun(t *testing.T) {
+	var tests = []struct {
+		concurrency int
+		maxInFlight int32
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 3},
+	}
+
+	for i, test := range tests {
+		var inFlight, maxInFlight atomic.Int32
+		results := make([]int, 6)
+		tasks := []func() error{}
+		for j := range results {
+			tasks = append(tasks, func() error {
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					previous := maxInFlight.Load()
+					if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				results[j] = j
+				return nil
+			})
+		}
+
+		err := NewPool(&config.LLM{Concurrency: test.concurrency}).Run(tasks)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+		}
+		if maxInFlight.Load() != test.maxInFlight {
+			t.Errorf("test %d - expected at most %d tasks in flight, got %d", i, test.maxInFlight, maxInFlight.Load())
+		}
+		for j, result := range results {
+			if result != j {
+				t.Errorf("test %d - expected task %d to run", i, j)
+			}
+		}
+	}
+}
+
+func TestPoolRunError(t *testing.T) {
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+	var ran atomic.Int32
+
+	tasks := []func() error{
+		func() error { ran.Add(1); time.Sleep(20 * time.Millisecond); return nil },
+		func() error { ran.Add(1); time.Sleep(10 * time.Millisecond); return errFirst },
+		func() error { ran.Add(1); return errSecond },
+	}
+	for i := 0; i < 5; i++ {
+		tasks = append(tasks, func() error { ran.Add(1); time.Sleep(50 * time.Millisecond); return nil })
+	}
+
+	err := NewPool(&config.LLM{Concurrency: 3}).Run(tasks)
+	if !errors.Is(err, errFirst) {
+		t.Errorf("expected the error of the first failed task, got: %v", err)
+	}
+	if ran.Load() == int32(len(tasks)) {
+		t.Errorf("expected remaining tasks to be skipped once a task failed")
+	}
+}
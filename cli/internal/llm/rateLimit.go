@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"hyaline/internal/config"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// rateLimiter limits the number of requests and tokens sent to an LLM provider within a rolling
+// one minute window. As the number of tokens a request uses is only known once it completes,
+// requests wait until the tokens used within the window are under the limit.
+type rateLimiter struct {
+	mu                sync.Mutex
+	requestsPerMinute int
+	tokensPerMinute   int
+	requests          []time.Time
+	tokens            []tokenUsage
+	now               func() time.Time
+	sleep             func(context.Context, time.Duration) error
+}
+
+type tokenUsage struct {
+	at     time.Time
+	tokens int64
+}
+
+type rateLimiterKey struct {
+	provider          config.LLMProvider
+	endpoint          string
+	key               string
+	requestsPerMinute int
+	tokensPerMinute   int
+}
+
+// rateLimiters are shared by all calls using the same provider (and limits), so that concurrent
+// calls are limited together
+var (
+	rateLimitersMutex sync.Mutex
+	rateLimiters      = map[rateLimiterKey]*rateLimiter{}
+)
+
+const rateLimitWindow = time.Minute
+
+func newRateLimiter(requestsPerMinute int, tokensPerMinute int) *rateLimiter {
+	return &rateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+		now:               time.Now,
+		sleep:             sleepContext,
+	}
+}
+
+// getRateLimiter returns the rate limiter to use for cfg, or nil if no rate limit is configured
+func getRateLimiter(cfg *config.LLM) *rateLimiter {
+	if cfg.RateLimit.RequestsPerMinute <= 0 && cfg.RateLimit.TokensPerMinute <= 0 {
+		return nil
+	}
+
+	key := rateLimiterKey{
+		provider:          cfg.Provider,
+		endpoint:          cfg.Endpoint,
+		key:               cfg.Key,
+		requestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+		tokensPerMinute:   cfg.RateLimit.TokensPerMinute,
+	}
+
+	rateLimitersMutex.Lock()
+	defer rateLimitersMutex.Unlock()
+	limiter, ok := rateLimiters[key]
+	if !ok {
+		limiter = newRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.TokensPerMinute)
+		rateLimiters[key] = limiter
+	}
+
+	return limiter
+}
+
+// waitForRateLimit blocks until a request can be sent to the LLM without exceeding the configured
+// rate limit (if any), or until ctx is done
+func waitForRateLimit(ctx context.Context, cfg *config.LLM) error {
+	limiter := getRateLimiter(cfg)
+	if limiter != nil {
+		return limiter.wait(ctx)
+	}
+	return nil
+}
+
+// recordRateLimitUsage records the tokens used by a request against the configured rate limit
+// (if any)
+func recordRateLimitUsage(cfg *config.LLM, tokens int64) {
+	limiter := getRateLimiter(cfg)
+	if limiter != nil {
+		limiter.record(tokens)
+	}
+}
+
+// wait blocks until a request is allowed, and then records the request. If ctx is done first the
+// request is not recorded and the error of ctx is returned.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		now := r.now()
+		r.prune(now)
+
+		var delay time.Duration
+		if r.requestsPerMinute > 0 && len(r.requests) >= r.requestsPerMinute {
+			delay = r.requests[0].Add(rateLimitWindow).Sub(now)
+		}
+		if r.tokensPerMinute > 0 {
+			var used int64
+			for _, usage := range r.tokens {
+				used += usage.tokens
+			}
+			// Wait for the oldest usage(s) to leave the window until we are under the limit
+			for i := 0; used >= int64(r.tokensPerMinute) && i < len(r.tokens); i++ {
+				used -= r.tokens[i].tokens
+				delay = max(delay, r.tokens[i].at.Add(rateLimitWindow).Sub(now))
+			}
+		}
+
+		if delay <= 0 {
+			r.requests = append(r.requests, now)
+			r.mu.Unlock()
+			return nil
+		}
+		r.mu.Unlock()
+
+		slog.Info("Waiting for LLM rate limit", "delay", delay.Round(time.Millisecond))
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext sleeps for d, returning early with the error of ctx if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// record records the tokens used by a request
+func (r *rateLimiter) record(tokens int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, tokenUsage{
+		at:     r.now(),
+		tokens: tokens,
+	})
+}
+
+// prune removes requests and token usage that are outside of the window
+func (r *rateLimiter) prune(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	i := 0
+	for i < len(r.requests) && !r.requests[i].After(cutoff) {
+		i++
+	}
+	r.requests = r.requests[i:]
+
+	j := 0
+	for j < len(r.tokens) && !r.tokens[j].at.After(cutoff) {
+		j++
+	}
+	r.tokens = r.tokens[j:]
+}
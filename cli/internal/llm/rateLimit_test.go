@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"hyaline/internal/config"
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter returns a rate limiter using a fake clock that advances when it sleeps
+func newTestRateLimiter(requestsPerMinute int, tokensPerMinute int) (*rateLimiter, *time.Time, *[]time.Duration) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sleeps := []time.Duration{}
+	limiter := newRateLimiter(requestsPerMinute, tokensPerMinute)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	return limiter, &now, &sleeps
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+	limiter, now, sleeps := newTestRateLimiter(2, 0)
+
+	limiter.wait(context.Background())
+	*now = now.Add(10 * time.Second)
+	limiter.wait(context.Background())
+	if len(*sleeps) != 0 {
+		t.Fatalf("expected requests under the limit to not wait, got: %v", *sleeps)
+	}
+
+	// The third request must wait until the first request leaves the window
+	limiter.wait(context.Background())
+	if len(*sleeps) != 1 || (*sleeps)[0] != 50*time.Second {
+		t.Errorf("expected to wait 50s, got: %v", *sleeps)
+	}
+}
+
+func TestRateLimiterTokens(t *testing.T) {
+	limiter, now, sleeps := newTestRateLimiter(0, 1000)
+
+	limiter.wait(context.Background())
+	limiter.record(600)
+	*now = now.Add(20 * time.Second)
+	limiter.wait(context.Background())
+	limiter.record(600)
+	*now = now.Add(20 * time.Second)
+	if len(*sleeps) != 0 {
+		t.Fatalf("expected requests under the limit to not wait, got: %v", *sleeps)
+	}
+
+	// 1200 tokens have been used, so we must wait for the first usage to leave the window
+	limiter.wait(context.Background())
+	if len(*sleeps) != 1 || (*sleeps)[0] != 20*time.Second {
+		t.Errorf("expected to wait 20s, got: %v", *sleeps)
+	}
+}
+
+func TestRateLimiterContextDone(t *testing.T) {
+	limiter := newRateLimiter(1, 0)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	// The second request would wait a minute, but returns as soon as the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := limiter.wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected to stop waiting when the context is done, waited %s", elapsed)
+	}
+	if len(limiter.requests) != 1 {
+		t.Errorf("expected the cancelled request to not be recorded, got %d requests", len(limiter.requests))
+	}
+}
+
+func TestGetRateLimiter(t *testing.T) {
+	if getRateLimiter(&config.LLM{Provider: config.LLMProviderAnthropic}) != nil {
+		t.Errorf("expected no rate limiter when no rate limit is configured")
+	}
+
+	cfg := &config.LLM{Provider: config.LLMProviderAnthropic, RateLimit: config.LLMRateLimit{RequestsPerMinute: 10}}
+	limiter := getRateLimiter(cfg)
+	if limiter == nil {
+		t.Fatalf("expected a rate limiter when a rate limit is configured")
+	}
+	if getRateLimiter(&config.LLM{Provider: config.LLMProviderAnthropic, RateLimit: config.LLMRateLimit{RequestsPerMinute: 10}}) != limiter {
+		t.Errorf("expected the same rate limiter to be shared for the same configuration")
+	}
+	if getRateLimiter(&config.LLM{Provider: config.LLMProviderOpenAI, RateLimit: config.LLMRateLimit{RequestsPerMinute: 10}}) == limiter {
+		t.Errorf("expected a different rate limiter for a different provider")
+	}
+}
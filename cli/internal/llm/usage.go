@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"fmt"
+	"hyaline/internal/config"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go/v2"
+)
+
+// Usage is the number of tokens used when calling an LLM. Input tokens do not include tokens that
+// were read from or written to the provider's prompt cache.
+type Usage struct {
+	InputTokens      int64 `json:"inputTokens"`
+	OutputTokens     int64 `json:"outputTokens"`
+	CacheReadTokens  int64 `json:"cacheReadTokens"`
+	CacheWriteTokens int64 `json:"cacheWriteTokens"`
+}
+
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+}
+
+func (u Usage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+func (u Usage) IsZero() bool {
+	return u.TotalTokens() == 0
+}
+
+func anthropicUsage(usage anthropic.Usage) Usage {
+	return Usage{
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheReadTokens:  usage.CacheReadInputTokens,
+		CacheWriteTokens: usage.CacheCreationInputTokens,
+	}
+}
+
+func openAIUsage(usage openai.CompletionUsage) Usage {
+	// OpenAI includes cached tokens in the prompt tokens
+	cached := usage.PromptTokensDetails.CachedTokens
+	return Usage{
+		InputTokens:     usage.PromptTokens - cached,
+		OutputTokens:    usage.CompletionTokens,
+		CacheReadTokens: cached,
+	}
+}
+
+// UsageSummary is the usage of one or more LLM calls along with their estimated cost (if
+// llm.pricing is configured)
+type UsageSummary struct {
+	Usage
+	TotalTokens   int64    `json:"totalTokens"`
+	EstimatedCost *float64 `json:"estimatedCost,omitempty"`
+}
+
+func Summarize(usage Usage, cfg *config.LLM) UsageSummary {
+	return UsageSummary{
+		Usage:         usage,
+		TotalTokens:   usage.TotalTokens(),
+		EstimatedCost: EstimateCost(usage, cfg),
+	}
+}
+
+// EstimateCost returns the estimated cost of usage using llm.pricing, or nil if pricing is not
+// configured
+func EstimateCost(usage Usage, cfg *config.LLM) *float64 {
+	if cfg == nil || cfg.Pricing == nil {
+		return nil
+	}
+
+	// Prices are per million tokens
+	cost := (float64(usage.InputTokens)*cfg.Pricing.Input +
+		float64(usage.OutputTokens)*cfg.Pricing.Output +
+		float64(usage.CacheReadTokens)*cfg.Pricing.CacheRead +
+		float64(usage.CacheWriteTokens)*cfg.Pricing.CacheWrite) / 1_000_000
+
+	return &cost
+}
+
+// Budget tracks the usage of a run, returning an error once llm.budget.maxTokens is exceeded
+type Budget struct {
+	mu        sync.Mutex
+	maxTokens int64
+	used      Usage
+}
+
+func NewBudget(cfg *config.LLM) *Budget {
+	budget := &Budget{}
+	if cfg != nil {
+		budget.maxTokens = cfg.Budget.MaxTokens
+	}
+
+	return budget
+}
+
+// Add records usage against the budget, returning an error if the budget has been exceeded
+func (b *Budget) Add(usage Usage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used.Add(usage)
+
+	if b.maxTokens > 0 && b.used.TotalTokens() > b.maxTokens {
+		return fmt.Errorf("llm.budget.maxTokens of %d exceeded, used %d tokens", b.maxTokens, b.used.TotalTokens())
+	}
+
+	return nil
+}
+
+// Used returns the total usage recorded against the budget
+func (b *Budget) Used() Usage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
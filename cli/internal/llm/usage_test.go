@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"hyaline/internal/config"
+	"net/http"
+	"testing"
+)
+
+func TestCallLLMUsage(t *testing.T) {
+	anthropicBody := anthropicMessage("done")
+	anthropicBody["usage"] = map[string]interface{}{
+		"input_tokens":                10,
+		"output_tokens":               5,
+		"cache_read_input_tokens":     100,
+		"cache_creation_input_tokens": 20,
+	}
+	openAIBody := chatCompletion(map[string]interface{}{"content": "done"})
+	openAIBody["usage"] = map[string]interface{}{
+		"prompt_tokens":         110,
+		"completion_tokens":     5,
+		"total_tokens":          115,
+		"prompt_tokens_details": map[string]interface{}{"cached_tokens": 100},
+	}
+
+	var tests = []struct {
+		provider config.LLMProvider
+		body     interface{}
+		expected Usage
+	}{
+		{config.LLMProviderAnthropic, anthropicBody, Usage{InputTokens: 10, OutputTokens: 5, CacheReadTokens: 100, CacheWriteTokens: 20}},
+		{config.LLMProviderOpenAI, openAIBody, Usage{InputTokens: 10, OutputTokens: 5, CacheReadTokens: 100}},
+		{config.LLMProviderTesting, nil, Usage{}},
+	}
+
+	for i, test := range tests {
+		_, _, endpoint := startFakeProvider(t, 0, http.StatusOK, test.body)
+		if test.provider == config.LLMProviderOpenAI {
+			endpoint += "/v1"
+		}
+
+		_, usage, err := CallLLM("system prompt", "user prompt", nil, &config.LLM{
+			Provider: test.provider,
+			Model:    "test-model",
+			Key:      "test",
+			Endpoint: endpoint,
+		})
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if usage != test.expected {
+			t.Errorf("test %d - expected usage %+v, got %+v", i, test.expected, usage)
+		}
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	usage := Usage{InputTokens: 1_000_000, OutputTokens: 500_000, CacheReadTokens: 2_000_000, CacheWriteTokens: 100_000}
+
+	if EstimateCost(usage, &config.LLM{}) != nil {
+		t.Errorf("expected no estimated cost when pricing is not configured")
+	}
+
+	cost := EstimateCost(usage, &config.LLM{Pricing: &config.LLMPricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}})
+	if cost == nil {
+		t.Fatalf("expected an estimated cost when pricing is configured")
+	}
+	expected := 3 + 7.5 + 0.6 + 0.375
+	if *cost < expected-0.000001 || *cost > expected+0.000001 {
+		t.Errorf("expected estimated cost of %f, got %f", expected, *cost)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	// No budget is never exceeded
+	budget := NewBudget(&config.LLM{})
+	err := budget.Add(Usage{InputTokens: 1_000_000})
+	if err != nil {
+		t.Errorf("expected no error without a budget, got: %v", err)
+	}
+
+	budget = NewBudget(&config.LLM{Budget: config.LLMBudget{MaxTokens: 100}})
+	err = budget.Add(Usage{InputTokens: 50, OutputTokens: 25})
+	if err != nil {
+		t.Errorf("expected no error under the budget, got: %v", err)
+	}
+	err = budget.Add(Usage{InputTokens: 20, OutputTokens: 10})
+	expected := "llm.budget.maxTokens of 100 exceeded, used 105 tokens"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error: %s, got: %v", expected, err)
+	}
+	if budget.Used().TotalTokens() != 105 {
+		t.Errorf("expected 105 tokens to be used, got %d", budget.Used().TotalTokens())
+	}
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"container/heap"
+	"log/slog"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Commit is a commit included in a change
+type Commit struct {
+	Hash    string
+	Message string
+}
+
+// Flags used to mark which side of the range a commit is reachable from
+const (
+	commitFromHead uint8 = 1 << iota
+	commitFromBase
+)
+
+// GetCommits returns up to max commits that are reachable from head but not from base (the same
+// commits as git log base..head), newest first. If max is 0 all commits are returned.
+func GetCommits(r *git.Repository, head plumbing.Hash, base plumbing.Hash, max int) (commits []Commit, err error) {
+	slog.Debug("repo.GetCommits getting commits", "head", head.String(), "base", base.String(), "max", max)
+
+	// Walk back from head and base in commit time order, marking each commit with the side(s) it is
+	// reachable from, until only commits reachable from base remain to be walked. Commits are
+	// visited after all of their descendants, so a commit's marks are final when it is visited.
+	marks := make(map[plumbing.Hash]uint8)
+	queue := &commitQueue{}
+	queued := make(map[plumbing.Hash]bool)
+	unmarked := 0
+	push := func(hash plumbing.Hash, mark uint8) error {
+		previous := marks[hash]
+		if previous|mark == previous {
+			return nil
+		}
+		marks[hash] = previous | mark
+		if queued[hash] {
+			if previous&commitFromBase == 0 && mark&commitFromBase != 0 {
+				unmarked--
+			}
+			return nil
+		}
+		commit, err := r.CommitObject(hash)
+		if err != nil {
+			slog.Debug("repo.GetCommits could not get commit", "hash", hash.String(), "error", err)
+			return err
+		}
+		heap.Push(queue, commit)
+		queued[hash] = true
+		if marks[hash]&commitFromBase == 0 {
+			unmarked++
+		}
+		return nil
+	}
+
+	if err = push(head, commitFromHead); err != nil {
+		return
+	}
+	if err = push(base, commitFromBase); err != nil {
+		return
+	}
+
+	for unmarked > 0 && (max == 0 || len(commits) < max) {
+		commit := heap.Pop(queue).(*object.Commit)
+		delete(queued, commit.Hash)
+		mark := marks[commit.Hash]
+		if mark&commitFromBase == 0 {
+			unmarked--
+			commits = append(commits, Commit{
+				Hash:    commit.Hash.String(),
+				Message: strings.TrimSpace(commit.Message),
+			})
+		}
+		for _, parent := range commit.ParentHashes {
+			if err = push(parent, mark); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// commitQueue is a priority queue of commits ordered from the newest to the oldest commit
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any) {
+	*q = append(*q, x.(*object.Commit))
+}
+func (q *commitQueue) Pop() any {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
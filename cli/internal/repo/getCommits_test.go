@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestGetCommits(t *testing.T) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create commits one minute apart with the given parents
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	count := 0
+	commit := func(message string, parents ...plumbing.Hash) plumbing.Hash {
+		count++
+		signature := &object.Signature{Name: "Test", Email: "test@example.com", When: start.Add(time.Duration(count) * time.Minute)}
+		hash, err := wt.Commit(message, &git.CommitOptions{
+			Author:            signature,
+			Committer:         signature,
+			Parents:           parents,
+			AllowEmptyCommits: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// main: a - b - f
+	// feature (from a): c - d - e (merge of b) - g
+	a := commit("a")
+	b := commit("b", a)
+	c := commit("c\n\nwith a body\n", a)
+	d := commit("d", c)
+	e := commit("e", d, b)
+	f := commit("f", b)
+	g := commit("g", e)
+
+	var tests = []struct {
+		head     plumbing.Hash
+		base     plumbing.Hash
+		max      int
+		expected []string
+	}{
+		{g, f, 0, []string{"g", "e", "d", "c\n\nwith a body"}},
+		{g, f, 2, []string{"g", "e"}},
+		{d, b, 0, []string{"d", "c\n\nwith a body"}},
+		{f, g, 0, []string{"f"}},
+		{b, g, 0, nil},
+		{g, g, 0, nil},
+	}
+
+	for i, test := range tests {
+		commits, err := GetCommits(r, test.head, test.base, test.max)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		var messages []string
+		for _, commit := range commits {
+			messages = append(messages, commit.Message)
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("test %d - expected %v, got %v", i, test.expected, messages)
+		}
+	}
+}
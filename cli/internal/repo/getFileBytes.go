@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"log/slog"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GetFileBytes returns the contents of the file at path in the commit ref, or nil if the file
+// does not exist in that commit
+func GetFileBytes(ref plumbing.Hash, r *git.Repository, path string) (bytes []byte, err error) {
+	commit, err := r.CommitObject(ref)
+	if err != nil {
+		slog.Debug("repo.GetFileBytes could not get commit", "ref", ref.String(), "error", err)
+		return
+	}
+	file, err := commit.File(path)
+	if err == object.ErrFileNotFound {
+		err = nil
+		return
+	}
+	if err != nil {
+		slog.Debug("repo.GetFileBytes could not get file", "path", path, "error", err)
+		return
+	}
+
+	return GetBlobBytes(file.Blob)
+}
@@ -0,0 +1,185 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// WorktreeChange is a file that differs between HEAD and either the index or the working tree.
+// Original and Current are nil if the file does not exist on that side of the change.
+type WorktreeChange struct {
+	Path     string
+	Original []byte
+	Current  []byte
+}
+
+// GetWorktreeChanges returns the files that differ between HEAD and the index (if staged is true),
+// or between HEAD and the working tree (including staged changes, but not untracked files). The
+// hash of HEAD is also returned, which is zero if the repository does not have any commits yet.
+func GetWorktreeChanges(r *git.Repository, staged bool) (changes []WorktreeChange, head plumbing.Hash, err error) {
+	// Get the files in HEAD
+	headFiles := make(map[string]*object.File)
+	ref, err := r.Head()
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		slog.Debug("repo.GetWorktreeChanges could not get HEAD", "error", err)
+		return
+	}
+	if err == nil {
+		head = ref.Hash()
+		var commit *object.Commit
+		commit, err = r.CommitObject(head)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get HEAD commit", "error", err, "head", head.String())
+			return
+		}
+		var files *object.FileIter
+		files, err = commit.Files()
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get HEAD files", "error", err)
+			return
+		}
+		err = files.ForEach(func(file *object.File) error {
+			headFiles[file.Name] = file
+			return nil
+		})
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not iterate HEAD files", "error", err)
+			return
+		}
+	}
+	err = nil
+
+	getOriginal := func(path string) ([]byte, error) {
+		file, ok := headFiles[path]
+		if !ok {
+			return nil, nil
+		}
+		return GetBlobBytes(file.Blob)
+	}
+
+	if staged {
+		changes, err = getStagedChanges(r, headFiles, getOriginal)
+	} else {
+		changes, err = getWorkingTreeChanges(r, getOriginal)
+	}
+	if err != nil {
+		return
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return
+}
+
+// getStagedChanges compares the entries in the index with the files in HEAD
+func getStagedChanges(r *git.Repository, headFiles map[string]*object.File, getOriginal func(path string) ([]byte, error)) (changes []WorktreeChange, err error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		slog.Debug("repo.GetWorktreeChanges could not get index", "error", err)
+		return
+	}
+
+	staged := make(map[string]struct{})
+	for _, entry := range idx.Entries {
+		// Skip the stages of files with merge conflicts (normal entries are stage 0)
+		if entry.Stage != 0 {
+			continue
+		}
+		staged[entry.Name] = struct{}{}
+		if file, ok := headFiles[entry.Name]; ok && file.Hash == entry.Hash {
+			continue
+		}
+
+		change := WorktreeChange{Path: entry.Name}
+		change.Original, err = getOriginal(entry.Name)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get original file", "path", entry.Name, "error", err)
+			return
+		}
+		var blob *object.Blob
+		blob, err = r.BlobObject(entry.Hash)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get staged blob", "path", entry.Name, "error", err)
+			return
+		}
+		change.Current, err = GetBlobBytes(*blob)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not read staged blob", "path", entry.Name, "error", err)
+			return
+		}
+		changes = append(changes, change)
+	}
+
+	// Files in HEAD that are not in the index were deleted
+	for path := range headFiles {
+		if _, ok := staged[path]; ok {
+			continue
+		}
+		change := WorktreeChange{Path: path}
+		change.Original, err = getOriginal(path)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get original file", "path", path, "error", err)
+			return
+		}
+		changes = append(changes, change)
+	}
+
+	return
+}
+
+// getWorkingTreeChanges compares the files in the working tree with the files in HEAD, using the
+// status of the working tree to find the files that may have changed
+func getWorkingTreeChanges(r *git.Repository, getOriginal func(path string) ([]byte, error)) (changes []WorktreeChange, err error) {
+	w, err := r.Worktree()
+	if err != nil {
+		slog.Debug("repo.GetWorktreeChanges could not get worktree", "error", err)
+		return
+	}
+	status, err := w.Status()
+	if err != nil {
+		slog.Debug("repo.GetWorktreeChanges could not get worktree status", "error", err)
+		return
+	}
+
+	for path, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked && fileStatus.Worktree == git.Untracked {
+			continue
+		}
+
+		change := WorktreeChange{Path: path}
+		change.Original, err = getOriginal(path)
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not get original file", "path", path, "error", err)
+			return
+		}
+		change.Current, err = util.ReadFile(w.Filesystem, path)
+		if errors.Is(err, os.ErrNotExist) {
+			change.Current, err = nil, nil
+		}
+		if err != nil {
+			slog.Debug("repo.GetWorktreeChanges could not read file", "path", path, "error", err)
+			return
+		}
+
+		// Skip files whose changes were reverted
+		if change.Original != nil && change.Current != nil && bytes.Equal(change.Original, change.Current) {
+			continue
+		}
+		if change.Original == nil && change.Current == nil {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// summarizeChanges returns the action of each change keyed by path (one of insert, modify, or
+// delete) along with the current contents
+func summarizeChanges(changes []WorktreeChange) map[string]string {
+	summary := make(map[string]string)
+	for _, change := range changes {
+		switch {
+		case change.Original == nil:
+			summary[change.Path] = "insert " + string(change.Current)
+		case change.Current == nil:
+			summary[change.Path] = "delete"
+		default:
+			summary[change.Path] = "modify " + string(change.Current)
+		}
+	}
+
+	return summary
+}
+
+func TestGetWorktreeChanges(t *testing.T) {
+	r, hash := setupTestRepo(t)
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stage a new file, modify the committed file without staging it, and add an untracked file
+	if err = util.WriteFile(wt.Filesystem, "staged.txt", []byte("staged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Add("staged.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = util.WriteFile(wt.Filesystem, "test.txt", []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = util.WriteFile(wt.Filesystem, "untracked.txt", []byte("untracked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		staged   bool
+		expected map[string]string
+	}{
+		{false, map[string]string{"staged.txt": "insert staged", "test.txt": "modify modified"}},
+		{true, map[string]string{"staged.txt": "insert staged"}},
+	}
+
+	for i, test := range tests {
+		changes, head, err := GetWorktreeChanges(r, test.staged)
+		if err != nil {
+			t.Errorf("test %d - expected no error, got error: %s", i, err.Error())
+			continue
+		}
+		if head != hash {
+			t.Errorf("test %d - expected head %s, got %s", i, hash.String(), head.String())
+		}
+		summary := summarizeChanges(changes)
+		if len(summary) != len(test.expected) {
+			t.Errorf("test %d - expected %v, got %v", i, test.expected, summary)
+		}
+		for path, expected := range test.expected {
+			if summary[path] != expected {
+				t.Errorf("test %d - expected %s to be %q, got %q", i, path, expected, summary[path])
+			}
+		}
+	}
+}
+
+func TestGetWorktreeChanges_Deleted(t *testing.T) {
+	r, _ := setupTestRepo(t)
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the file is only staged once it is removed from the index
+	if err = wt.Filesystem.Remove("test.txt"); err != nil {
+		t.Fatal(err)
+	}
+	changes, _, err := GetWorktreeChanges(r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no staged changes, got %v", summarizeChanges(changes))
+	}
+	changes, _, err = GetWorktreeChanges(r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := summarizeChanges(changes); len(summary) != 1 || summary["test.txt"] != "delete" {
+		t.Errorf("expected test.txt to be deleted, got %v", summary)
+	}
+
+	if _, err = wt.Remove("test.txt"); err != nil {
+		t.Fatal(err)
+	}
+	changes, _, err = GetWorktreeChanges(r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := summarizeChanges(changes); len(summary) != 1 || summary["test.txt"] != "delete" {
+		t.Errorf("expected test.txt to be deleted, got %v", summary)
+	}
+}
+
+func TestGetWorktreeChanges_NoCommits(t *testing.T) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = util.WriteFile(wt.Filesystem, "new.txt", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Add("new.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, head, err := GetWorktreeChanges(r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !head.IsZero() {
+		t.Errorf("expected a zero head, got %s", head.String())
+	}
+	if summary := summarizeChanges(changes); len(summary) != 1 || summary["new.txt"] != "insert new" {
+		t.Errorf("expected new.txt to be inserted, got %v", summary)
+	}
+}
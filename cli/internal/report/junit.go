@@ -0,0 +1,68 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// JUnitTestSuites is the root of a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// AddSuite adds a test suite to the report, updating the test and failure counts
+func (suites *JUnitTestSuites) AddSuite(name string, testCases []JUnitTestCase) {
+	suite := JUnitTestSuite{
+		Name:      name,
+		Tests:     len(testCases),
+		TestCases: testCases,
+	}
+	for _, testCase := range testCases {
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	suites.Suites = append(suites.Suites, suite)
+	suites.Tests += suite.Tests
+	suites.Failures += suite.Failures
+}
+
+// WriteJUnit writes the report as indented XML (including the XML header)
+func WriteJUnit(w io.Writer, suites *JUnitTestSuites) error {
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
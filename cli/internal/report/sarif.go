@@ -0,0 +1,133 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+const sarifVersion = "2.1.0"
+
+// SARIF levels
+const (
+	SARIFLevelError   = "error"
+	SARIFLevelWarning = "warning"
+	SARIFLevelNote    = "note"
+	SARIFLevelNone    = "none"
+)
+
+// SARIF kinds
+const (
+	SARIFKindFail = "fail"
+	SARIFKindPass = "pass"
+)
+
+// SARIF is the root of a SARIF 2.1.0 log (only the subset of the spec used by Hyaline is modeled)
+type SARIF struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+type SARIFRule struct {
+	ID               string       `json:"id"`
+	ShortDescription SARIFMessage `json:"shortDescription"`
+}
+
+type SARIFResult struct {
+	RuleID           string                 `json:"ruleId"`
+	Kind             string                 `json:"kind,omitempty"`
+	Level            string                 `json:"level"`
+	Message          SARIFMessage           `json:"message"`
+	Locations        []SARIFLocation        `json:"locations,omitempty"`
+	RelatedLocations []SARIFLocation        `json:"relatedLocations,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFLocation struct {
+	ID               int                    `json:"id,omitempty"`
+	PhysicalLocation *SARIFPhysicalLocation `json:"physicalLocation,omitempty"`
+	Message          *SARIFMessage          `json:"message,omitempty"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+}
+
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// NewSARIF creates a SARIF log containing a single run for the named tool
+func NewSARIF(name string, rules []SARIFRule, results []SARIFResult) *SARIF {
+	if rules == nil {
+		rules = []SARIFRule{}
+	}
+	if results == nil {
+		results = []SARIFResult{}
+	}
+
+	return &SARIF{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []SARIFRun{
+			{
+				Tool: SARIFTool{
+					Driver: SARIFDriver{
+						Name:           name,
+						InformationURI: "https://www.hyaline.dev",
+						Rules:          rules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+}
+
+// NewSARIFLocation creates a location pointing at the uri with an optional message
+func NewSARIFLocation(id int, uri string, message string) SARIFLocation {
+	location := SARIFLocation{
+		ID: id,
+		PhysicalLocation: &SARIFPhysicalLocation{
+			ArtifactLocation: SARIFArtifactLocation{
+				URI: uri,
+			},
+		},
+	}
+	if message != "" {
+		location.Message = &SARIFMessage{Text: message}
+	}
+
+	return location
+}
+
+// WriteSARIF writes the SARIF log as indented JSON
+func WriteSARIF(w io.Writer, sarif *SARIF) error {
+	data, err := json.MarshalIndent(sarif, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
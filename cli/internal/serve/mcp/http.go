@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	StreamableHTTPPath = "/mcp"
+	SSEPath            = "/sse"
+	SSEMessagePath     = "/message"
+)
+
+// shutdownTimeout is how long in-flight requests are given to complete once shutdown begins
+const shutdownTimeout = 10 * time.Second
+
+// ServeHTTPTransport serves the MCP server over HTTP on addr until ctx is cancelled, after which
+// the server is gracefully shut down. Both the streamable HTTP transport (at /mcp) and the legacy
+// SSE transport (at /sse and /message) are served. If authToken is set, every request must
+// include it as a bearer token.
+func (hyalineMCPServer *Server) ServeHTTPTransport(ctx context.Context, addr string, authToken string) error {
+	// Request contexts are derived from baseCtx, which is cancelled on shutdown so long-lived
+	// streams (that would otherwise never become idle) are closed
+	baseCtx, cancelBaseCtx := context.WithCancel(context.Background())
+	defer cancelBaseCtx()
+
+	httpServer := &http.Server{
+		Addr: addr,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	httpServer.RegisterOnShutdown(cancelBaseCtx)
+
+	streamableServer := server.NewStreamableHTTPServer(hyalineMCPServer.mcpServer,
+		server.WithEndpointPath(StreamableHTTPPath),
+		server.WithStreamableHTTPServer(httpServer),
+	)
+	sseServer := server.NewSSEServer(hyalineMCPServer.mcpServer,
+		server.WithSSEEndpoint(SSEPath),
+		server.WithMessageEndpoint(SSEMessagePath),
+		server.WithHTTPServer(httpServer),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle(StreamableHTTPPath, streamableServer)
+	mux.Handle(SSEPath, sseServer.SSEHandler())
+	mux.Handle(SSEMessagePath, sseServer.MessageHandler())
+	httpServer.Handler = requireBearerToken(authToken, mux)
+
+	// Serve until the server fails or the context is cancelled
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("MCP server listening", "addr", addr, "streamableHTTP", StreamableHTTPPath, "sse", SSEPath)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		slog.Debug("serve.mcp.ServeHTTPTransport server error", "error", err)
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down MCP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Shutting down the SSE server closes any open SSE sessions before shutting down the shared
+	// http server
+	err := sseServer.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Debug("serve.mcp.ServeHTTPTransport could not gracefully shut down", "error", err)
+		return err
+	}
+
+	err = <-errCh
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// requireBearerToken rejects requests that do not include token as a bearer token in their
+// Authorization header. If token is empty, all requests are allowed.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hyaline"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
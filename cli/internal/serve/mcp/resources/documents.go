@@ -0,0 +1,119 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"hyaline/internal/docs"
+	"hyaline/internal/serve/mcp/utils"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const documentMIMEType = "text/markdown"
+
+// DocumentResource returns the resource for a single document, identified by its document URI
+func DocumentResource(source *utils.Source, document *utils.Document) mcp.Resource {
+	uri := &docs.DocumentURI{
+		SourceID:     source.ID,
+		DocumentPath: document.ID,
+	}
+
+	opts := []mcp.ResourceOption{
+		mcp.WithMIMEType(documentMIMEType),
+	}
+	if document.Purpose != "" {
+		opts = append(opts, mcp.WithResourceDescription(document.Purpose))
+	}
+
+	return mcp.NewResource(uri.String(), document.ID, opts...)
+}
+
+// SectionResourceTemplate returns the resource template used to read individual sections of a document
+func SectionResourceTemplate() mcp.ResourceTemplate {
+	return mcp.NewResourceTemplate("document://{source}/{+document}{#section}",
+		"Document section",
+		mcp.WithTemplateDescription("A section of a document. Section URIs follow this pattern: `document://<source-id>/<document-id>#<section>` where nested sections are separated by `/` (e.g. `#Section 1/Section 1.1`) and the section is percent-encoded (e.g. `#Section%201/Section%201.1`)."),
+		mcp.WithTemplateMIMEType(documentMIMEType),
+	)
+}
+
+// HandleReadDocument returns the contents of the document (or section of a document) identified
+// by the requested document URI
+func HandleReadDocument(_ context.Context, request mcp.ReadResourceRequest, documentationData *utils.DocumentationData) ([]mcp.ResourceContents, error) {
+	documentURI, err := docs.NewDocumentURI(request.Params.URI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI format: %w", err)
+	}
+	if documentURI.SourceID == "" || documentURI.DocumentPath == "" {
+		return nil, fmt.Errorf("URI must reference a document: %s", request.Params.URI)
+	}
+
+	// Sections (and documents) may be percent-encoded when read via the resource template
+	documentPath := unescape(documentURI.DocumentPath)
+	sectionID := unescape(documentURI.Section)
+
+	document := findDocument(documentationData, documentURI.SourceID, documentPath)
+	if document == nil {
+		return nil, fmt.Errorf("document not found: %s", request.Params.URI)
+	}
+
+	// Return the whole document if no section was requested
+	if sectionID == "" {
+		content := document.ExtractedData
+		if content == "" {
+			content = document.RawData
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: documentMIMEType,
+				Text:     content,
+			},
+		}, nil
+	}
+
+	for _, section := range document.Sections {
+		if section.ID == sectionID {
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{
+					URI:      request.Params.URI,
+					MIMEType: documentMIMEType,
+					Text:     section.ExtractedData,
+				},
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("section not found: %s", request.Params.URI)
+}
+
+func findDocument(documentationData *utils.DocumentationData, sourceID string, documentID string) *utils.Document {
+	for i := range documentationData.Sources {
+		source := &documentationData.Sources[i]
+		if source.ID != sourceID {
+			continue
+		}
+		for j := range source.Documents {
+			if source.Documents[j].ID == documentID {
+				return &source.Documents[j]
+			}
+		}
+	}
+
+	return nil
+}
+
+// unescape percent-decodes str, returning it unchanged if it is not valid percent-encoding
+func unescape(str string) string {
+	if !strings.Contains(str, "%") {
+		return str
+	}
+	unescaped, err := url.PathUnescape(str)
+	if err != nil {
+		return str
+	}
+
+	return unescaped
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"hyaline/internal/serve/mcp/prompts"
+	"hyaline/internal/serve/mcp/resources"
 	"hyaline/internal/serve/mcp/tools"
 	"hyaline/internal/serve/mcp/utils"
 	"hyaline/internal/sqlite"
 	"log/slog"
+	"sync"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -18,14 +20,17 @@ type Server struct {
 	mcpServer         *server.MCPServer
 	documentationData *utils.DocumentationData
 	options           utils.ServerOptions
+	// mu guards documentationData, which is replaced on reload while other requests may be in
+	// flight (when serving over HTTP)
+	mu sync.RWMutex
 }
 
 // NewServer creates and initializes a new MCP server
 func NewServer(db *sqlite.Queries, version string, opts utils.ServerOptions) (*Server, error) {
 	slog.Debug("serve.mcp.NewServer starting")
 
-	// Load all data into memory
-	documentationData, err := utils.LoadAllData(db)
+	// Load all data into memory (the caller retains ownership of db)
+	documentationData, err := utils.LoadAllData(db, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load data: %w", err)
 	}
@@ -34,7 +39,8 @@ func NewServer(db *sqlite.Queries, version string, opts utils.ServerOptions) (*S
 	mcpServer := server.NewMCPServer(
 		"Hyaline Documentation Server",
 		version,
-		server.WithToolCapabilities(false), // Tools don't change dynamically
+		server.WithToolCapabilities(false),           // Tools don't change dynamically
+		server.WithResourceCapabilities(false, true), // Resources change when documentation is reloaded
 	)
 
 	hyalineMCPServer := &Server{
@@ -43,9 +49,10 @@ func NewServer(db *sqlite.Queries, version string, opts utils.ServerOptions) (*S
 		options:           opts,
 	}
 
-	// Register tools and prompts
+	// Register tools, prompts, and resources
 	hyalineMCPServer.registerTools()
 	hyalineMCPServer.registerPrompts()
+	hyalineMCPServer.registerResources()
 
 	slog.Debug("serve.mcp.NewServer complete")
 	return hyalineMCPServer, nil
@@ -55,13 +62,38 @@ func (hyalineMCPServer *Server) ServeStdio() error {
 	return server.ServeStdio(hyalineMCPServer.mcpServer)
 }
 
+// Close releases any documentation data loaded after the server was created
+func (hyalineMCPServer *Server) Close() error {
+	hyalineMCPServer.mu.Lock()
+	defer hyalineMCPServer.mu.Unlock()
+	return hyalineMCPServer.documentationData.Close()
+}
+
+// withDocumentationData calls handle with the current documentation data, holding the read lock
+// until handle returns so that a reload cannot close the data while it is still in use
+func withDocumentationData[T any](hyalineMCPServer *Server, handle func(documentationData *utils.DocumentationData) (T, error)) (T, error) {
+	hyalineMCPServer.mu.RLock()
+	defer hyalineMCPServer.mu.RUnlock()
+	return handle(hyalineMCPServer.documentationData)
+}
+
 func (hyalineMCPServer *Server) registerTools() {
 	hyalineMCPServer.mcpServer.AddTool(tools.ListDocumentsTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		return tools.HandleListDocuments(ctx, request, hyalineMCPServer.documentationData)
+		return withDocumentationData(hyalineMCPServer, func(documentationData *utils.DocumentationData) (*mcp.CallToolResult, error) {
+			return tools.HandleListDocuments(ctx, request, documentationData)
+		})
 	})
 
 	hyalineMCPServer.mcpServer.AddTool(tools.GetDocumentsTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		return tools.HandleGetDocuments(ctx, request, hyalineMCPServer.documentationData)
+		return withDocumentationData(hyalineMCPServer, func(documentationData *utils.DocumentationData) (*mcp.CallToolResult, error) {
+			return tools.HandleGetDocuments(ctx, request, documentationData)
+		})
+	})
+
+	hyalineMCPServer.mcpServer.AddTool(tools.SearchDocumentsTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return withDocumentationData(hyalineMCPServer, func(documentationData *utils.DocumentationData) (*mcp.CallToolResult, error) {
+			return tools.HandleSearchDocuments(ctx, request, documentationData)
+		})
 	})
 
 	hyalineMCPServer.mcpServer.AddTool(tools.ReloadDocumentationTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -70,9 +102,19 @@ func (hyalineMCPServer *Server) registerTools() {
 			return result, err
 		}
 
-		// Update the documentation data if reload was successful
+		// Update the documentation data if reload was successful, releasing the previous data
 		if newDocumentationData != nil {
+			// The data and resources are swapped together so that concurrent reloads do not interleave.
+			// Taking the write lock waits for in-flight requests using the previous data to finish, and
+			// no request can get the previous data once the lock is released, so it is safe to close.
+			hyalineMCPServer.mu.Lock()
+			previousDocumentationData := hyalineMCPServer.documentationData
 			hyalineMCPServer.documentationData = newDocumentationData
+			hyalineMCPServer.setDocumentResources(newDocumentationData)
+			hyalineMCPServer.mu.Unlock()
+			if err := previousDocumentationData.Close(); err != nil {
+				slog.Warn("Could not close previous documentation data", "error", err)
+			}
 		}
 
 		return result, nil
@@ -84,3 +126,30 @@ func (hyalineMCPServer *Server) registerPrompts() {
 		return prompts.HandleAnswerQuestion(ctx, request)
 	})
 }
+
+func (hyalineMCPServer *Server) registerResources() {
+	hyalineMCPServer.mcpServer.AddResourceTemplate(resources.SectionResourceTemplate(), hyalineMCPServer.handleReadDocument)
+	hyalineMCPServer.setDocumentResources(hyalineMCPServer.documentationData)
+}
+
+// setDocumentResources replaces the document resources with those of the documentation data.
+// Clients are notified once that the list of resources changed.
+func (hyalineMCPServer *Server) setDocumentResources(documentationData *utils.DocumentationData) {
+	documentResources := []server.ServerResource{}
+	for _, source := range documentationData.Sources {
+		for _, document := range source.Documents {
+			documentResources = append(documentResources, server.ServerResource{
+				Resource: resources.DocumentResource(&source, &document),
+				Handler:  hyalineMCPServer.handleReadDocument,
+			})
+		}
+	}
+
+	hyalineMCPServer.mcpServer.SetResources(documentResources...)
+}
+
+func (hyalineMCPServer *Server) handleReadDocument(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	return withDocumentationData(hyalineMCPServer, func(documentationData *utils.DocumentationData) ([]mcp.ResourceContents, error) {
+		return resources.HandleReadDocument(ctx, request, documentationData)
+	})
+}
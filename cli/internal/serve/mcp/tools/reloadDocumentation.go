@@ -22,6 +22,25 @@ func ReloadDocumentationTool() mcp.Tool {
 func HandleReloadDocumentation(_ context.Context, request mcp.CallToolRequest, opts utils.ServerOptions) (*mcp.CallToolResult, *utils.DocumentationData, error) {
 	var absPath string
 
+	// Any temp dir and the database are kept for as long as the reloaded documentation is in use
+	// (as the search index is queried directly), so clean them up only if the reload fails
+	cleanup := []func() error{}
+	closeAll := func() error {
+		var err error
+		for i := len(cleanup) - 1; i >= 0; i-- {
+			if cleanupErr := cleanup[i](); cleanupErr != nil {
+				err = cleanupErr
+			}
+		}
+		return err
+	}
+	reloaded := false
+	defer func() {
+		if !reloaded {
+			_ = closeAll()
+		}
+	}()
+
 	// If GitHub repository is configured, download from GitHub
 	if opts.GitHubRepo != "" {
 		// Check if GitHub token is configured
@@ -34,7 +53,9 @@ func HandleReloadDocumentation(_ context.Context, request mcp.CallToolRequest, o
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to create temp dir: %s", err.Error())), nil, nil
 		}
-		defer os.RemoveAll(tempDir)
+		cleanup = append(cleanup, func() error {
+			return os.RemoveAll(tempDir)
+		})
 
 		// Download latest artifact
 		zipPath, err := github.DownloadLatestArtifact(opts.GitHubRepo, opts.GitHubArtifact, opts.GitHubToken, tempDir)
@@ -61,13 +82,14 @@ func HandleReloadDocumentation(_ context.Context, request mcp.CallToolRequest, o
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to initialize database: %s", err.Error())), nil, nil
 	}
-	defer close()
+	cleanup = append(cleanup, close)
 
 	// Load documentation data
-	documentationData, err := utils.LoadAllData(db)
+	documentationData, err := utils.LoadAllData(db, closeAll)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to load data: %s", err.Error())), nil, nil
 	}
+	reloaded = true
 
 	return mcp.NewToolResultText("Documentation reloaded successfully."), documentationData, nil
 }
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"hyaline/internal/docs"
+	"hyaline/internal/serve/mcp/utils"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const defaultSearchLimit = 10
+
+func SearchDocumentsTool() mcp.Tool {
+	return mcp.NewTool("search_documents",
+		mcp.WithDescription("Search the contents and names of documents and sections, returning the best matching document URIs ordered by relevance along with a snippet of the matching content. Use get_documents to retrieve the full contents of a result. Document URIs follow this pattern: `document://<source-id>/<document-id>[?<key>=<value>][#<section>]` where query parameters filter by tags (multiple values for same key are comma-separated)"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The words or phrases to search for. Results matching more of the terms are ranked higher."),
+		),
+		mcp.WithString("document_uri",
+			mcp.Description("The URI to limit the search to (can be partial). Format: document://<source-id>/<document-id>[?<key>=<value>][#<section>]. Query parameters filter results by tags. If not provided, searches all documents."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("The maximum number of results to return. Defaults to %d.", defaultSearchLimit)),
+		),
+	)
+}
+
+func HandleSearchDocuments(_ context.Context, request mcp.CallToolRequest, documentationData *utils.DocumentationData) (*mcp.CallToolResult, error) {
+
+	// Get the required query parameter
+	query, err := request.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Get the optional document_uri and limit parameters
+	documentURIStr := request.GetString("document_uri", "")
+	limit := request.GetInt("limit", defaultSearchLimit)
+	if limit <= 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid limit: %d, must be greater than 0", limit)), nil
+	}
+
+	// Parse the URI if provided
+	documentURI := &docs.DocumentURI{}
+	if documentURIStr != "" {
+		documentURI, err = docs.NewDocumentURI(documentURIStr)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid URI format: %s", err.Error())), nil
+		}
+	}
+
+	results, err := utils.SearchDocuments(documentationData, documentURI, query, limit)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("search failed: %s", err.Error())), nil
+	}
+
+	if results.Total == 0 {
+		return mcp.NewToolResultText("No documents found matching the specified query."), nil
+	}
+
+	var response strings.Builder
+	response.WriteString("The <results> XML structure contains the documents and sections that best match the query, ordered by relevance, with their corresponding document URIs and a <snippet> of the matching content.\n\n")
+	response.WriteString(results.Result.String())
+
+	return mcp.NewToolResultText(response.String()), nil
+}
@@ -24,13 +24,25 @@ type Section struct {
 	Tags docs.Tags
 }
 
-// DocumentationData holds all documentation data in memory for fast access
+// DocumentationData holds all documentation data in memory for fast access, along with the
+// database it was loaded from so that its search index can be queried
 type DocumentationData struct {
 	Sources []Source
+	DB      *sqlite.Queries
+	close   func() error
 }
 
-// LoadAllData loads all documentation data from the database into memory
-func LoadAllData(db *sqlite.Queries) (*DocumentationData, error) {
+// Close releases the database (and any files) backing the documentation data, if it owns them
+func (data *DocumentationData) Close() error {
+	if data.close == nil {
+		return nil
+	}
+	return data.close()
+}
+
+// LoadAllData loads all documentation data from the database into memory. The database is
+// retained for searching, and is released by calling close when the data is closed.
+func LoadAllData(db *sqlite.Queries, close func() error) (*DocumentationData, error) {
 	slog.Debug("serve.mcp.data.LoadAllData starting")
 
 	ctx := context.Background()
@@ -127,6 +139,8 @@ func LoadAllData(db *sqlite.Queries) (*DocumentationData, error) {
 	// Sources are already sorted by ID from the query
 	data := &DocumentationData{
 		Sources: sources,
+		DB:      db,
+		close:   close,
 	}
 
 	slog.Debug("serve.mcp.data.LoadAllData complete", "sourceCount", len(sources))
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hyaline/internal/docs"
+	"log/slog"
+	"strings"
+)
+
+// ErrNoSearchIndex is returned when the documentation was extracted without a search index
+var ErrNoSearchIndex = errors.New("the documentation does not have a search index, re-extract or merge it with a newer version of hyaline")
+
+// SearchDocuments searches the documentation's search index and returns up to limit of the best
+// matching documents and sections, filtered by the source, document, section, and tags in the URI
+func SearchDocuments(data *DocumentationData, documentURI *docs.DocumentURI, query string, limit int) (*Results, error) {
+	searchQuery := buildSearchQuery(query)
+	if searchQuery == "" {
+		return nil, errors.New("query must not be empty")
+	}
+
+	rows, err := data.DB.SearchDocuments(context.Background(), searchQuery)
+	if err != nil {
+		slog.Debug("mcp.utils.SearchDocuments could not search documents", "query", searchQuery, "error", err)
+		if strings.Contains(err.Error(), "no such table: SEARCH") {
+			return nil, ErrNoSearchIndex
+		}
+		return nil, err
+	}
+
+	// Index the documents so matches can be checked against their tags
+	documents := make(map[string]*Document)
+	for i := range data.Sources {
+		for j := range data.Sources[i].Documents {
+			document := &data.Sources[i].Documents[j]
+			documents[document.SourceID+"/"+document.ID] = document
+		}
+	}
+
+	results := &Results{}
+	results.Result.WriteString("<results>\n")
+
+	for _, row := range rows {
+		if limit > 0 && results.Total >= limit {
+			break
+		}
+
+		// Filter by source ID, document path, and section if specified
+		if documentURI.SourceID != "" && row.SourceID != documentURI.SourceID {
+			continue
+		}
+		if documentURI.DocumentPath != "" && row.DocumentID != documentURI.DocumentPath && !strings.HasPrefix(row.DocumentID, documentURI.DocumentPath+"/") {
+			continue
+		}
+		if documentURI.Section != "" && row.SectionID != documentURI.Section && !strings.HasPrefix(row.SectionID, documentURI.Section+"/") {
+			continue
+		}
+
+		document, ok := documents[row.SourceID+"/"+row.DocumentID]
+		if !ok {
+			continue
+		}
+
+		// Sections match on their own tags as well as the tags of their document
+		name := document.ID
+		tags := docs.NewTags()
+		for key, values := range document.Tags {
+			tags[key] = append(tags[key], values...)
+		}
+		if row.SectionID != "" {
+			for _, section := range document.Sections {
+				if section.ID == row.SectionID {
+					name = section.Name
+					for key, values := range section.Tags {
+						tags[key] = append(tags[key], values...)
+					}
+					break
+				}
+			}
+		}
+		if !documentURI.MatchesTags(tags) {
+			continue
+		}
+
+		uri := &docs.DocumentURI{
+			SourceID:     row.SourceID,
+			DocumentPath: row.DocumentID,
+			Section:      row.SectionID,
+		}
+
+		results.Result.WriteString("  <result>\n")
+		fmt.Fprintf(&results.Result, "    <uri>%s</uri>\n", uri.String())
+		fmt.Fprintf(&results.Result, "    <name>%s</name>\n", name)
+		fmt.Fprintf(&results.Result, "    <snippet>%s</snippet>\n", strings.TrimSpace(row.Snippet))
+		results.Result.WriteString("  </result>\n")
+		results.Total++
+	}
+
+	results.Result.WriteString("</results>")
+
+	slog.Debug("mcp.utils.SearchDocuments", "query", searchQuery, "matches", len(rows), "results", results.Total)
+	return results, nil
+}
+
+// buildSearchQuery converts free text into an FTS5 query that matches any of its terms, quoting
+// each term so that punctuation is not interpreted as query syntax
+func buildSearchQuery(query string) string {
+	terms := []string{}
+	for _, term := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+
+	return strings.Join(terms, " OR ")
+}
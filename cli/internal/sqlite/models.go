@@ -13,6 +13,12 @@ type DOCUMENT struct {
 	ExtractedData string
 }
 
+type DOCUMENTHASH struct {
+	SourceID   string
+	DocumentID string
+	Hash       string
+}
+
 type DOCUMENTTAG struct {
 	SourceID   string
 	DocumentID string
@@ -20,6 +26,14 @@ type DOCUMENTTAG struct {
 	TagValue   string
 }
 
+type SEARCH struct {
+	SourceID      interface{}
+	DocumentID    interface{}
+	SectionID     interface{}
+	Name          interface{}
+	ExtractedData interface{}
+}
+
 type SECTION struct {
 	ID            string
 	DocumentID    string
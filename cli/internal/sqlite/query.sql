@@ -57,6 +57,21 @@ WHERE ID = ? AND SOURCE_ID = ?;
 -- name: DeleteDocumentsForSource :exec
 DELETE FROM DOCUMENT WHERE SOURCE_ID = ?;
 
+-- name: InsertDocumentHash :exec
+INSERT INTO DOCUMENT_HASH (
+  SOURCE_ID, DOCUMENT_ID, HASH
+) VALUES (
+  ?, ?, ?
+);
+
+-- name: GetDocumentHashesForSource :many
+SELECT
+  DOCUMENT_ID, HASH
+FROM
+  DOCUMENT_HASH
+WHERE
+  SOURCE_ID = ?;
+
 -- name: UpsertDocumentTag :exec
 INSERT INTO DOCUMENT_TAG (
   SOURCE_ID, DOCUMENT_ID, TAG_KEY, TAG_VALUE
@@ -182,4 +197,18 @@ ORDER BY
   SOURCE_ID, DOCUMENT_ID, SECTION_ID, TAG_KEY, TAG_VALUE;
 
 -- name: DeleteSectionTagsForSource :exec
-DELETE FROM SECTION_TAG WHERE SOURCE_ID = ?;
\ No newline at end of file
+DELETE FROM SECTION_TAG WHERE SOURCE_ID = ?;
+
+-- name: SearchDocuments :many
+SELECT
+  CAST(SOURCE_ID AS TEXT) AS SOURCE_ID,
+  CAST(DOCUMENT_ID AS TEXT) AS DOCUMENT_ID,
+  CAST(SECTION_ID AS TEXT) AS SECTION_ID,
+  CAST(snippet(SEARCH, 4, '**', '**', '...', 32) AS TEXT) AS SNIPPET,
+  CAST(bm25(SEARCH, 0.0, 0.0, 0.0, 10.0, 1.0) AS REAL) AS RANK
+FROM
+  SEARCH
+WHERE
+  SEARCH MATCH ?
+ORDER BY
+  RANK, SOURCE_ID, DOCUMENT_ID, SECTION_ID;
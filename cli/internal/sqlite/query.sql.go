@@ -375,6 +375,43 @@ func (q *Queries) GetAllSources(ctx context.Context) ([]SOURCE, error) {
 	return items, nil
 }
 
+const getDocumentHashesForSource = `-- name: GetDocumentHashesForSource :many
+SELECT
+  DOCUMENT_ID, HASH
+FROM
+  DOCUMENT_HASH
+WHERE
+  SOURCE_ID = ?
+`
+
+type GetDocumentHashesForSourceRow struct {
+	DocumentID string
+	Hash       string
+}
+
+func (q *Queries) GetDocumentHashesForSource(ctx context.Context, sourceID string) ([]GetDocumentHashesForSourceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDocumentHashesForSource, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDocumentHashesForSourceRow
+	for rows.Next() {
+		var i GetDocumentHashesForSourceRow
+		if err := rows.Scan(&i.DocumentID, &i.Hash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getDocumentIDsForSource = `-- name: GetDocumentIDsForSource :many
 SELECT
   ID
@@ -651,6 +688,25 @@ func (q *Queries) InsertDocument(ctx context.Context, arg InsertDocumentParams)
 	return err
 }
 
+const insertDocumentHash = `-- name: InsertDocumentHash :exec
+INSERT INTO DOCUMENT_HASH (
+  SOURCE_ID, DOCUMENT_ID, HASH
+) VALUES (
+  ?, ?, ?
+)
+`
+
+type InsertDocumentHashParams struct {
+	SourceID   string
+	DocumentID string
+	Hash       string
+}
+
+func (q *Queries) InsertDocumentHash(ctx context.Context, arg InsertDocumentHashParams) error {
+	_, err := q.db.ExecContext(ctx, insertDocumentHash, arg.SourceID, arg.DocumentID, arg.Hash)
+	return err
+}
+
 const insertSection = `-- name: InsertSection :exec
 INSERT INTO SECTION (
   ID, DOCUMENT_ID, SOURCE_ID, PARENT_ID, PEER_ORDER, NAME, PURPOSE, EXTRACTED_DATA
@@ -709,6 +765,58 @@ func (q *Queries) InsertSource(ctx context.Context, arg InsertSourceParams) erro
 	return err
 }
 
+const searchDocuments = `-- name: SearchDocuments :many
+SELECT
+  CAST(SOURCE_ID AS TEXT) AS SOURCE_ID,
+  CAST(DOCUMENT_ID AS TEXT) AS DOCUMENT_ID,
+  CAST(SECTION_ID AS TEXT) AS SECTION_ID,
+  CAST(snippet(SEARCH, 4, '**', '**', '...', 32) AS TEXT) AS SNIPPET,
+  CAST(bm25(SEARCH, 0.0, 0.0, 0.0, 10.0, 1.0) AS REAL) AS RANK
+FROM
+  SEARCH
+WHERE
+  SEARCH MATCH ?
+ORDER BY
+  RANK, SOURCE_ID, DOCUMENT_ID, SECTION_ID
+`
+
+type SearchDocumentsRow struct {
+	SourceID   string
+	DocumentID string
+	SectionID  string
+	Snippet    string
+	Rank       float64
+}
+
+func (q *Queries) SearchDocuments(ctx context.Context, search string) ([]SearchDocumentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchDocuments, search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchDocumentsRow
+	for rows.Next() {
+		var i SearchDocumentsRow
+		if err := rows.Scan(
+			&i.SourceID,
+			&i.DocumentID,
+			&i.SectionID,
+			&i.Snippet,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateDocumentPurpose = `-- name: UpdateDocumentPurpose :exec
 UPDATE DOCUMENT 
 SET PURPOSE = ?
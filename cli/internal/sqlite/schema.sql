@@ -47,4 +47,50 @@ CREATE TABLE SECTION_TAG (
   PRIMARY KEY(SOURCE_ID, DOCUMENT_ID, SECTION_ID, TAG_KEY, TAG_VALUE)
 );
 
-CREATE INDEX SECTION_TAG_KEY_VALUE ON SECTION_TAG(TAG_KEY, TAG_VALUE);
\ No newline at end of file
+CREATE INDEX SECTION_TAG_KEY_VALUE ON SECTION_TAG(TAG_KEY, TAG_VALUE);
+
+CREATE TABLE DOCUMENT_HASH (
+  SOURCE_ID TEXT NOT NULL,
+  DOCUMENT_ID TEXT NOT NULL,
+  HASH TEXT NOT NULL,
+  PRIMARY KEY(SOURCE_ID, DOCUMENT_ID)
+);
+
+CREATE VIRTUAL TABLE SEARCH USING fts5(
+  SOURCE_ID UNINDEXED,
+  DOCUMENT_ID UNINDEXED,
+  SECTION_ID UNINDEXED,
+  NAME,
+  EXTRACTED_DATA,
+  tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER DOCUMENT_SEARCH_INSERT AFTER INSERT ON DOCUMENT BEGIN
+  INSERT INTO SEARCH (SOURCE_ID, DOCUMENT_ID, SECTION_ID, NAME, EXTRACTED_DATA)
+  VALUES (new.SOURCE_ID, new.ID, '', new.ID, new.EXTRACTED_DATA);
+END;
+
+CREATE TRIGGER DOCUMENT_SEARCH_UPDATE AFTER UPDATE OF EXTRACTED_DATA ON DOCUMENT BEGIN
+  UPDATE SEARCH SET EXTRACTED_DATA = new.EXTRACTED_DATA
+  WHERE SOURCE_ID = old.SOURCE_ID AND DOCUMENT_ID = old.ID AND SECTION_ID = '';
+END;
+
+CREATE TRIGGER DOCUMENT_SEARCH_DELETE AFTER DELETE ON DOCUMENT BEGIN
+  DELETE FROM SEARCH
+  WHERE SOURCE_ID = old.SOURCE_ID AND DOCUMENT_ID = old.ID AND SECTION_ID = '';
+END;
+
+CREATE TRIGGER SECTION_SEARCH_INSERT AFTER INSERT ON SECTION BEGIN
+  INSERT INTO SEARCH (SOURCE_ID, DOCUMENT_ID, SECTION_ID, NAME, EXTRACTED_DATA)
+  VALUES (new.SOURCE_ID, new.DOCUMENT_ID, new.ID, new.NAME, new.EXTRACTED_DATA);
+END;
+
+CREATE TRIGGER SECTION_SEARCH_UPDATE AFTER UPDATE OF NAME, EXTRACTED_DATA ON SECTION BEGIN
+  UPDATE SEARCH SET NAME = new.NAME, EXTRACTED_DATA = new.EXTRACTED_DATA
+  WHERE SOURCE_ID = old.SOURCE_ID AND DOCUMENT_ID = old.DOCUMENT_ID AND SECTION_ID = old.ID;
+END;
+
+CREATE TRIGGER SECTION_SEARCH_DELETE AFTER DELETE ON SECTION BEGIN
+  DELETE FROM SEARCH
+  WHERE SOURCE_ID = old.SOURCE_ID AND DOCUMENT_ID = old.DOCUMENT_ID AND SECTION_ID = old.ID;
+END;